@@ -1,21 +1,32 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"math"
 	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	log "github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
 	"k8s.io/api/admission/v1beta1"
 	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/apps/v1"
+	appsv1beta1 "k8s.io/api/apps/v1beta1"
+	appsv1beta2 "k8s.io/api/apps/v1beta2"
 	batchv1 "k8s.io/api/batch/v1"
 	batchv1beta1 "k8s.io/api/batch/v1beta1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -32,68 +43,338 @@ var (
 	podIDRegex  = regexp.MustCompile("(.*)(-[0-9A-Za-z]+-[0-9A-Za-z]+)")
 	podID2Regex = regexp.MustCompile("(.*)(-[0-9A-Za-z]+)")
 
-	admissionCounter = promauto.NewCounterVec(prometheus.CounterOpts{Name: "admission_requests_total"}, []string{"allowed"})
+	// admissionCounter is labeled by dry_run, so that server side dry runs (kubectl --dry-run=server) can be told from real requests
+	admissionCounter = promauto.NewCounterVec(prometheus.CounterOpts{Name: "admission_requests_total"}, []string{"allowed", "mode", "dry_run"})
 	errorsCounter    = promauto.NewCounter(prometheus.CounterOpts{Name: "errors_total"})
+	warmupCounter    = promauto.NewCounter(prometheus.CounterOpts{Name: "warmup_allowed_total"})
+
+	latencyBudgetExceededCounter = promauto.NewCounter(prometheus.CounterOpts{Name: "latency_budget_exceeded_total"})
+
+	// namespaceDenialsCounter is labeled by namespace, so it's updated only if enabled by Options.NamespaceDenialMetric
+	namespaceDenialsCounter = promauto.NewCounterVec(prometheus.CounterOpts{Name: "admission_namespace_denials_total"}, []string{"namespace"})
+	// decisionsCounter is labeled by kind of the object, decision (allowed or denied) and denialReason, which is none if allowed
+	decisionsCounter = promauto.NewCounterVec(prometheus.CounterOpts{Name: "admission_decisions_total"}, []string{"kind", "decision", "reason"})
+	// durationHistogram buckets range from 1ms to 2s, API server's webhook timeout is 10s by default
+	durationHistogram = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "admission_request_duration_seconds",
+		Buckets: prometheus.ExponentialBuckets(0.001, 2, 12),
+	}, []string{"kind"})
+)
+
+const (
+	decisionAllowed = "allowed"
+	decisionDenied  = "denied"
+	reasonNone      = "none"
 )
 
 func init() {
 	_ = corev1.AddToScheme(runtimeScheme)
+	_ = admissionv1.AddToScheme(runtimeScheme)
+	_ = v1beta1.AddToScheme(runtimeScheme)
 	_ = admissionregistrationv1beta1.AddToScheme(runtimeScheme)
 	// defaulting with webhooks:
 	// https://github.com/kubernetes/kubernetes/issues/57982
 	_ = v1.AddToScheme(runtimeScheme)
+	_ = appsv1beta1.AddToScheme(runtimeScheme)
+	_ = appsv1beta2.AddToScheme(runtimeScheme)
+	_ = batchv1.AddToScheme(runtimeScheme)
+	_ = batchv1beta1.AddToScheme(runtimeScheme)
 }
 
 const (
-	deploymentKind  = "Deployment"
-	statefulsetKind = "Statefulset"
-	daemonsetKind   = "DaemonSet"
-	podKind         = "Pod"
-	jobKind         = "Job"
-	cronJobKind     = "CronJob"
-	pvcKind         = "PersistentVolumeClaim"
+	deploymentKind            = "Deployment"
+	statefulsetKind           = "StatefulSet"
+	daemonsetKind             = "DaemonSet"
+	podKind                   = "Pod"
+	jobKind                   = "Job"
+	cronJobKind               = "CronJob"
+	pvcKind                   = "PersistentVolumeClaim"
+	replicationControllerKind = "ReplicationController"
+)
+
+// modeAnnotation is the audit annotation of responses in warn mode
+const modeAnnotation = "mode"
+
+// grandfatheredAnnotation is the audit annotation of updates allowed, since the workload was created before GrandfatherCutoff
+const grandfatheredAnnotation = "grandfathered"
+
+// workloadClassLabel of the pod template overrides workload class derived from kind
+const workloadClassLabel = "resource-requests-admission-controller.devopy.io/class"
+
+const (
+	workloadClassJob     = "job"
+	workloadClassService = "service"
 )
 
+// validatedSubResources carry the whole pod, other subresources are allowed without validation
+var validatedSubResources = map[string]bool{
+	"ephemeralcontainers": true,
+	"resize":              true,
+}
+
 // Conf get configuration intercace
 type Conf interface {
-	GetPodLimit(nn NameNamespace) (cpuLimit, memLimit, cpuRequest, memRequest *resource.Quantity, unlimited bool)
-	GetMaxPVCSize(nn NameNamespace) (pvc *resource.Quantity, unlimited bool)
+	PodConf
+	PVCConf
+}
+
+// PodConf gets pod resource limits, LimitResource.Unlimited applies only to pods
+type PodConf interface {
+	GetPodLimit(nn NameNamespace, groups ...string) LimitResource
+	GetPodLimitByLabels(nn NameNamespace, labels map[string]string, groups ...string) LimitResource
+	GetMaxNamespaceGPUs(namespace string) (gpus *resource.Quantity, unlimited bool)
+}
+
+// PVCConf gets PVC size limits, unlimited is independent from pod unlimited
+type PVCConf interface {
+	GetMaxStorageClassPVCSize(nn NameNamespace, storageClass *string) (pvc *resource.Quantity, unlimited bool)
+	GetMinPVCSize(nn NameNamespace) (pvc *resource.Quantity, unlimited bool)
+	GetMaxNamespacePVCSize(namespace string) (pvc *resource.Quantity, unlimited bool)
 }
 
 // ResourceRequestsAdmission handles admission based on resourcer returned by Conf
 type ResourceRequestsAdmission struct {
-	conf Conf
+	conf     Conf
+	opts     Options
+	started  time.Time
+	now      func() time.Time
+	pvcUsage *usageTracker
+	gpuUsage *usageTracker
+	// denials throttles denial logs per namespace, it's nil if they are not throttled
+	denials *denialThrottle
+}
+
+// Options configures ResourceRequestsAdmission behaviour
+type Options struct {
+	// ValidateCronJobJobs validates Jobs owned by CronJobs using CronJob's limits, instead of skipping them
+	ValidateCronJobJobs bool
+	// Warmup allows all requests during the first Warmup after start
+	Warmup time.Duration
+	// MaxQuotaPercent denies workloads, which use more than this percentage of namespace ResourceQuota, 0 disables it
+	MaxQuotaPercent int
+	// Quotas lists namespace ResourceQuotas, required by MaxQuotaPercent
+	Quotas QuotaGetter
+	// MaxClusterPercent denies workloads, whose limits of all replicas exceed this percentage of cluster allocatable, 0 disables it
+	MaxClusterPercent int
+	// Capacity sums allocatable resources of nodes, required by MaxClusterPercent
+	Capacity CapacityGetter
+	// Scales fetches pod templates of workloads scaled through scale subresource, MaxQuotaPercent and MaxClusterPercent
+	// are enforced on them if set
+	Scales PodTemplateGetter
+	// CustomResources select containers of custom resources, e.g. Tekton TaskRuns, which are validated as pods
+	CustomResources []CustomResource
+	// SuggestPatches adds JSONPatch, which fixes resources exceeding the limits, to warnings
+	SuggestPatches bool
+	// LogDenied logs redacted pod spec of denied workloads at debug level
+	LogDenied bool
+	// RequireMemoryLimit denies containers without memory limit, unless namespace is unlimited
+	RequireMemoryLimit bool
+	// RejectNegativeQuantities denies containers with negative requests or limits
+	RejectNegativeQuantities bool
+	// RequireEphemeralStorageLimit denies containers without ephemeral-storage limit in pods with disk backed emptyDir volumes
+	RequireEphemeralStorageLimit bool
+	// Claims looks up device classes of pod resource claims, required by allowedDeviceClasses
+	Claims DeviceClassGetter
+	// WarnTemplateResourceChanges warns on workload updates, which change container resources and so restart pods
+	WarnTemplateResourceChanges bool
+	// NamespaceDenialMetric counts denials per namespace in admission_namespace_denials_total
+	NamespaceDenialMetric bool
+	// ContainerVerdicts adds verdict of every container to audit annotation, which is served as response header too
+	ContainerVerdicts bool
+	// ApprovalSecret verifies approval tokens, which raise workload limits, empty secret disables approvals
+	ApprovalSecret []byte
+	// GrandfatherCutoff skips enforcement on updates of workloads created before it, zero disables it
+	GrandfatherCutoff time.Time
+	// DenialLogBurst limits denial logs per namespace to a burst, which is refilled by DenialLogRate per second, 0 disables it
+	DenialLogBurst int
+	DenialLogRate  float64
+	// DecisionLog writes every decision to audit stream, it's nil if disabled
+	DecisionLog *DecisionLog
+	// DefaultAction is allow (default) or deny of objects, whose kind isn't handled, unless defaultAction is configured
+	DefaultAction string
+	// LatencyBudgetAllow allows requests, whose admission didn't finish before HandleAdmission context deadline, by default they are denied
+	LatencyBudgetAllow bool
 }
 
 // New Creates new ResourceRequestsAdmission
-func New(conf Conf) *ResourceRequestsAdmission {
-	admissionCounter.WithLabelValues("true")
-	admissionCounter.WithLabelValues("false")
+func New(conf Conf, opts Options) *ResourceRequestsAdmission {
+	for _, mode := range []string{modeEnforce, modeWarn} {
+		for _, dryRun := range []string{"true", "false"} {
+			admissionCounter.WithLabelValues("true", mode, dryRun)
+			admissionCounter.WithLabelValues("false", mode, dryRun)
+		}
+	}
+	approvalCounter.WithLabelValues("true")
+	approvalCounter.WithLabelValues("false")
+	for _, kind := range []string{podKind, deploymentKind, statefulsetKind, daemonsetKind, replicationControllerKind, jobKind, cronJobKind, pvcKind} {
+		decisionsCounter.WithLabelValues(kind, decisionAllowed, reasonNone)
+		for _, reason := range denialReasons {
+			decisionsCounter.WithLabelValues(kind, decisionDenied, string(reason.Code))
+		}
+	}
 
-	return &ResourceRequestsAdmission{
-		conf: conf,
+	rra := &ResourceRequestsAdmission{
+		conf:     conf,
+		opts:     opts,
+		started:  time.Now(),
+		now:      time.Now,
+		pvcUsage: newUsageTracker(),
+		gpuUsage: newUsageTracker(),
+	}
+	if opts.DenialLogBurst > 0 {
+		rra.denials = newDenialThrottle(opts.DenialLogBurst, opts.DenialLogRate)
 	}
+
+	return rra
 }
 
-// HandleAdmission handles admission request and denies if limits < resources requests
-func (rra *ResourceRequestsAdmission) HandleAdmission(req *v1beta1.AdmissionRequest) (*v1beta1.AdmissionResponse, error) {
-	resp, err := rra.handleAdmission(req)
-	if err != nil {
+// HandleAdmission handles admission request and denies if limits < resources requests.
+// If ctx deadline passes before the decision, the admission is abandoned and latency budget response is returned instead.
+func (rra *ResourceRequestsAdmission) HandleAdmission(ctx context.Context, req *v1beta1.AdmissionRequest) (*v1beta1.AdmissionResponse, error) {
+	if req == nil {
 		errorsCounter.Inc()
-		log.WithError(err).Errorf("unable to handle request: %v", req)
-		return resp, err
+		return nil, errors.New("admission request is empty")
+	}
+
+	start := time.Now()
+	defer func() {
+		durationHistogram.WithLabelValues(req.Kind.Kind).Observe(time.Since(start).Seconds())
+	}()
+
+	type result struct {
+		resp *v1beta1.AdmissionResponse
+		err  error
+	}
+	// buffered, so that abandoned admission finishes without a receiver, its result is dropped
+	done := make(chan result, 1)
+	budget := &latencyBudget{}
+	go func() {
+		resp, err := rra.handleAdmission(context.WithValue(ctx, latencyBudgetKey{}, budget), req)
+		done <- result{resp: resp, err: err}
+	}()
+
+	var resp *v1beta1.AdmissionResponse
+	select {
+	case res := <-done:
+		if res.err != nil {
+			errorsCounter.Inc()
+			log.WithError(res.err).Errorf("unable to handle request: %v", req)
+			return res.resp, res.err
+		}
+		resp = res.resp
+	case <-ctx.Done():
+		// admission, which already reserved namespace usage, is about to finish, its response is sent,
+		// so that the reservation isn't kept for a denied object
+		if budget.abandon() {
+			res := <-done
+			if res.err != nil {
+				errorsCounter.Inc()
+				log.WithError(res.err).Errorf("unable to handle request: %v", req)
+				return res.resp, res.err
+			}
+			resp = res.resp
+			break
+		}
+
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, errors.Wrap(ctx.Err(), "admission abandoned")
+		}
+
+		deadline, _ := ctx.Deadline()
+		resp = rra.latencyBudgetExceeded(req, deadline.Sub(start).Round(time.Millisecond))
+	}
+
+	rra.record(req, resp)
+
+	return resp, nil
+}
+
+type latencyBudgetKey struct{}
+
+// latencyBudget guards reservations of namespace usage of an admission, which may be abandoned,
+// so that abandoned admission doesn't reserve usage for an object, which wasn't admitted
+type latencyBudget struct {
+	abandoned bool
+	reserved  bool
+	m         sync.Mutex
+}
+
+// abandon marks admission as abandoned, returns true if it already reserved usage
+func (lb *latencyBudget) abandon() bool {
+	lb.m.Lock()
+	defer lb.m.Unlock()
+
+	lb.abandoned = true
+	return lb.reserved
+}
+
+// reserve reserves q in tracker like usageTracker.reserve, admission which was abandoned is only checked like dry run
+func reserve(ctx context.Context, tracker *usageTracker, namespace, name string, q, max resource.Quantity, dryRun bool) (total resource.Quantity, ok bool) {
+	lb, _ := ctx.Value(latencyBudgetKey{}).(*latencyBudget)
+	if lb == nil {
+		return tracker.reserve(namespace, name, q, max, dryRun)
+	}
+
+	lb.m.Lock()
+	defer lb.m.Unlock()
+
+	total, ok = tracker.reserve(namespace, name, q, max, dryRun || lb.abandoned)
+	if ok && !dryRun && !lb.abandoned {
+		lb.reserved = true
+	}
+
+	return total, ok
+}
+
+// latencyBudgetExceeded returns response to request, whose admission didn't finish within latency budget
+func (rra *ResourceRequestsAdmission) latencyBudgetExceeded(req *v1beta1.AdmissionRequest, budget time.Duration) *v1beta1.AdmissionResponse {
+	latencyBudgetExceededCounter.Inc()
+	log.Errorf("admission of %s namespace: %s didn't finish within latency budget %s, allowed: %t", req.Kind.Kind, req.Namespace, budget, rra.opts.LatencyBudgetAllow)
+
+	return &v1beta1.AdmissionResponse{
+		UID:     req.UID,
+		Allowed: rra.opts.LatencyBudgetAllow,
+		Result: &metav1.Status{
+			Message: "admission didn't finish within latency budget " + budget.String(),
+		},
+		AuditAnnotations: reasonAnnotations(reasonLatencyBudgetExceeded),
+	}
+}
+
+// record counts the decision in metrics and writes it to decision log, healthchecks are denied on purpose, so they are not counted
+func (rra *ResourceRequestsAdmission) record(req *v1beta1.AdmissionRequest, resp *v1beta1.AdmissionResponse) {
+	if req.UID == healthcheckUID {
+		return
+	}
+
+	mode := modeEnforce
+	if resp.AuditAnnotations[modeAnnotation] == modeWarn {
+		mode = modeWarn
 	}
 
+	// dry runs are decided like real requests, only the metric tells them apart
+	dryRun := strconv.FormatBool(req.DryRun != nil && *req.DryRun)
+
 	if resp.Allowed {
-		admissionCounter.WithLabelValues("true").Inc()
+		admissionCounter.WithLabelValues("true", mode, dryRun).Inc()
+		decisionsCounter.WithLabelValues(req.Kind.Kind, decisionAllowed, reasonNone).Inc()
 	} else {
-		admissionCounter.WithLabelValues("false").Inc()
+		admissionCounter.WithLabelValues("false", mode, dryRun).Inc()
+		if reason, ok := resp.AuditAnnotations[denialReasonAnnotation]; ok {
+			denialsCounter.WithLabelValues(reason).Inc()
+			decisionsCounter.WithLabelValues(req.Kind.Kind, decisionDenied, reason).Inc()
+		}
+		if rra.opts.NamespaceDenialMetric {
+			namespaceDenialsCounter.WithLabelValues(req.Namespace).Inc()
+		}
 	}
 
-	return resp, nil
+	if rra.opts.DecisionLog != nil {
+		rra.opts.DecisionLog.Write(newDecision(rra.now(), req, resp, mode))
+	}
 }
 
-func (rra *ResourceRequestsAdmission) handleAdmission(req *v1beta1.AdmissionRequest) (*v1beta1.AdmissionResponse, error) {
+func (rra *ResourceRequestsAdmission) handleAdmission(ctx context.Context, req *v1beta1.AdmissionRequest) (*v1beta1.AdmissionResponse, error) {
 	resp := &v1beta1.AdmissionResponse{
 		UID:     req.UID,
 		Allowed: true,
@@ -103,6 +384,267 @@ func (rra *ResourceRequestsAdmission) handleAdmission(req *v1beta1.AdmissionRequ
 		return resp, nil
 	}
 
+	if req.SubResource == scaleSubResource {
+		return rra.handleScale(req)
+	}
+
+	// subresources like binding and eviction don't carry pod spec
+	if req.SubResource != "" && !validatedSubResources[req.SubResource] {
+		return resp, nil
+	}
+
+	if rra.opts.Warmup > 0 && rra.now().Sub(rra.started) < rra.opts.Warmup {
+		warmupCounter.Inc()
+		log.Infof("allowing request during warmup for %s, namespace: %s, userInfo: %v", req.Kind.Kind, req.Namespace, req.UserInfo)
+		return resp, nil
+	}
+
+	if req.Kind.Kind == pvcKind {
+		return rra.handlePVC(ctx, req)
+	}
+
+	if !rra.handlesKind(req.Kind) {
+		return rra.handleUnhandledKind(req)
+	}
+
+	w, err := rra.decodeWorkload(req)
+	if err != nil {
+		return nil, err
+	}
+	if w == nil {
+		return resp, nil
+	}
+
+	if rra.grandfathered(req, w) {
+		log.Debugf("allowing update of grandfathered %s name: %s, namespace: %s, created: %s", strings.ToLower(w.kind), w.name, req.Namespace, w.created)
+		resp.AuditAnnotations = map[string]string{grandfatheredAnnotation: "true"}
+		return resp, nil
+	}
+
+	// PVC limits are independent from pod limits, so they are checked before the workload can be found unlimited
+	if denyResp := rra.validateClaimTemplates(req, w); denyResp != nil {
+		rra.logDenial(req, "denying request for %s name: %s, namespace: %s, userInfo: %v", strings.ToLower(w.kind), w.name, req.Namespace, req.UserInfo)
+		return denyResp, nil
+	}
+
+	limit, exempt, annotations := rra.resolveLimit(req, w)
+	if exempt {
+		resp.AuditAnnotations = annotations
+		return resp, nil
+	}
+
+	if w.kind == podKind && limit.ForbidEphemeralContainers {
+		added, err := addedEphemeralContainers(req, w.podSpec)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(added) > 0 && limit.Mode == modeWarn {
+			return warnOnly(&v1beta1.AdmissionResponse{
+				UID: req.UID,
+				Result: &metav1.Status{
+					Message: fmt.Sprintf("error ephemeral containers are forbidden in namespace %s: %s", req.Namespace, strings.Join(added, ", ")),
+				},
+				AuditAnnotations: reasonAnnotations(reasonEphemeralContainersForbidden),
+			}), nil
+		}
+
+		if len(added) > 0 {
+			rra.logDenial(req, "denying request for pod name: %s, namespace: %s, userInfo: %v", w.name, req.Namespace, req.UserInfo)
+			return &v1beta1.AdmissionResponse{
+				UID:     req.UID,
+				Allowed: false,
+				Result: &metav1.Status{
+					Message: fmt.Sprintf("error ephemeral containers are forbidden in namespace %s: %s", req.Namespace, strings.Join(added, ", ")),
+				},
+				AuditAnnotations: reasonAnnotations(reasonEphemeralContainersForbidden),
+			}, nil
+		}
+	}
+
+	if denyResp := validateReplicas(req, w, limit); denyResp != nil {
+		if limit.Mode == modeWarn {
+			return warnOnly(denyResp), nil
+		}
+
+		rra.logDenial(req, "denying request for %s name: %s, namespace: %s, userInfo: %v", strings.ToLower(w.kind), w.name, req.Namespace, req.UserInfo)
+		return denyResp, nil
+	}
+
+	// limits are injected before validation, so that the pod is validated as it will be created
+	var patch []byte
+	if limit.Mutate {
+		patch, err = injectLimits(req.Kind.Kind, &w.podSpec, limit)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	warnings, verdicts, denyResp := rra.validatePodSpec(req, w.podSpec, limit)
+	if denyResp == nil {
+		denyResp = rra.validateResourceClaims(req, w.podSpec, limit)
+		if denyResp != nil {
+			denyResp.Warnings = warnings
+		}
+	}
+	if denyResp == nil {
+		denyResp = validateTopologySpread(req, w, limit)
+		switch {
+		case denyResp != nil && limit.TopologySpreadPolicy == topologySpreadWarn:
+			warnings = append(warnings, denyResp.Result.Message)
+			denyResp = nil
+		case denyResp != nil:
+			denyResp.Warnings = warnings
+		}
+	}
+	if denyResp == nil && !limit.Uncapped && rra.opts.MaxQuotaPercent > 0 && rra.opts.Quotas != nil {
+		denyResp = rra.validateQuota(req, w)
+		if denyResp != nil {
+			denyResp.Warnings = warnings
+		}
+	}
+	if denyResp == nil && !limit.Uncapped && rra.opts.MaxClusterPercent > 0 && rra.opts.Capacity != nil {
+		denyResp = rra.validateClusterCapacity(req, w)
+		if denyResp != nil {
+			denyResp.Warnings = warnings
+		}
+	}
+	// namespace GPUs are reserved, so they are checked last
+	if denyResp == nil && rra.gpuUsage != nil && !w.controlled {
+		denyResp = rra.validateNamespaceGPUs(ctx, req, w)
+		if denyResp != nil {
+			denyResp.Warnings = warnings
+		}
+	}
+	if denyResp != nil && limit.Mode == modeWarn {
+		log.Infof("allowing request in warn mode for %s name: %s, namespace: %s, userInfo: %v", strings.ToLower(w.kind), w.name, req.Namespace, req.UserInfo)
+		return withVerdicts(withPatch(warnOnly(denyResp), patch), verdicts), nil
+	}
+	if denyResp != nil {
+		logged := rra.logDenial(req, "denying request for %s name: %s, namespace: %s, userInfo: %v", strings.ToLower(w.kind), w.name, req.Namespace, req.UserInfo)
+		if logged && rra.opts.LogDenied {
+			logDenied(w, req.Namespace)
+		}
+		return withVerdicts(denyResp, verdicts), nil
+	}
+
+	if rra.opts.WarnTemplateResourceChanges && w.kind != podKind && req.Operation == v1beta1.Update && len(req.OldObject.Raw) > 0 {
+		changed, err := rra.templateResourceChanges(req, w)
+		if err != nil {
+			return nil, err
+		}
+		warnings = append(warnings, changed...)
+	}
+
+	resp.Warnings = warnings
+	if limit.Mode == modeWarn {
+		resp.AuditAnnotations = map[string]string{modeAnnotation: modeWarn}
+	}
+	return withVerdicts(withPatch(resp, patch), verdicts), nil
+}
+
+// resolveLimit returns limit of the workload, exempt is true if the workload is allowed without validation,
+// i.e. it's unlimited, its user is exempt or it tolerates an exempt taint, annotations tell why
+func (rra *ResourceRequestsAdmission) resolveLimit(req *v1beta1.AdmissionRequest, w *workload) (limit LimitResource, exempt bool, annotations map[string]string) {
+	limit = rra.conf.GetPodLimitByLabels(NameNamespace{
+		Name:      w.name,
+		Namespace: req.Namespace,
+	}, w.objectLabels, req.UserInfo.Groups...).ForLabels(w.labels).ForWorkloadClass(w.class()).ForRestartPolicy(w.podSpec.RestartPolicy).ForUserNamespace(w.podSpec.HostUsers)
+	if limit.Unlimited {
+		return limit, true, nil
+	}
+
+	if annotations, ok := limit.exemptUser(req.UserInfo); ok {
+		log.Debugf("allowing %s name: %s, namespace: %s, exempt userInfo: %v", strings.ToLower(w.kind), w.name, req.Namespace, req.UserInfo)
+		return limit, true, annotations
+	}
+
+	if key, ok := limit.exemptToleration(w.podSpec); ok {
+		log.Debugf("allowing %s name: %s, namespace: %s, tolerating taint: %s", strings.ToLower(w.kind), w.name, req.Namespace, key)
+		return limit, true, map[string]string{exemptTolerationAnnotation: key}
+	}
+
+	limit = rra.approvedLimit(w, req.Namespace, limit)
+	if limit.Uncapped {
+		limit = limit.withoutCeilings()
+	}
+
+	return limit.ForProfile(w.annotations[profileAnnotation]), false, nil
+}
+
+// grandfathered returns true if the request updates workload created before GrandfatherCutoff
+func (rra *ResourceRequestsAdmission) grandfathered(req *v1beta1.AdmissionRequest, w *workload) bool {
+	if rra.opts.GrandfatherCutoff.IsZero() || req.Operation != v1beta1.Update || w.created.IsZero() {
+		return false
+	}
+
+	return w.created.Before(rra.opts.GrandfatherCutoff)
+}
+
+// warnOnly allows response denied in warn mode, denial message is returned as warning
+func warnOnly(resp *v1beta1.AdmissionResponse) *v1beta1.AdmissionResponse {
+	resp.Allowed = true
+	resp.Warnings = append(resp.Warnings, resp.Result.Message)
+	resp.Result = nil
+	if resp.AuditAnnotations == nil {
+		resp.AuditAnnotations = make(map[string]string)
+	}
+	resp.AuditAnnotations[modeAnnotation] = modeWarn
+
+	return resp
+}
+
+// workload is an object which runs pods
+type workload struct {
+	kind string
+	name string
+	// podName is metadata.name of bare pods, name is stripped of its generated suffix, so it's shared by pods of a name prefix
+	podName string
+	podSpec corev1.PodSpec
+	// annotations and labels of the pod template, since only they propagate to pods
+	annotations map[string]string
+	labels      map[string]string
+	// objectLabels are labels of the workload's metadata, they select customLabels
+	objectLabels map[string]string
+	// created is creationTimestamp of the object, zero on create
+	created  time.Time
+	replicas int64
+	// controlled is true for pods, which have controller, e.g. ReplicaSet, their resources are counted on the controller's workload
+	controlled bool
+	// claimTemplates are volumeClaimTemplates of StatefulSet, PVCs created from them are limited like standalone PVCs
+	claimTemplates []corev1.PersistentVolumeClaim
+}
+
+// class returns workload class from the class label, or derives it from kind and restartPolicy
+func (w *workload) class() string {
+	if class, ok := w.labels[workloadClassLabel]; ok {
+		return class
+	}
+
+	switch w.kind {
+	case jobKind, cronJobKind:
+		return workloadClassJob
+	case podKind:
+		if w.podSpec.RestartPolicy == corev1.RestartPolicyNever || w.podSpec.RestartPolicy == corev1.RestartPolicyOnFailure {
+			return workloadClassJob
+		}
+	}
+
+	return workloadClassService
+}
+
+// replicas returns number of desired pods, nil means default of 1
+func replicas(r *int32) int64 {
+	if r == nil {
+		return 1
+	}
+
+	return int64(*r)
+}
+
+// decodeWorkload decodes object and its pod template,
+// returns nil if kind is not handled or object is validated on its owner admission
+func (rra *ResourceRequestsAdmission) decodeWorkload(req *v1beta1.AdmissionRequest) (*workload, error) {
 	switch req.Kind.Kind {
 	case podKind:
 		var pod corev1.Pod
@@ -110,259 +652,1098 @@ func (rra *ResourceRequestsAdmission) handleAdmission(req *v1beta1.AdmissionRequ
 			return nil, errors.Wrapf(err, "unable to unmarshal json: %s", string(req.Object.Raw))
 		}
 
-		name := pod.Name
-		match := podIDRegex.FindStringSubmatch(name)
-		if len(match) == 3 {
-			name = match[1]
-		} else {
-			match := podID2Regex.FindStringSubmatch(name)
-			if len(match) == 3 {
-				name = match[1]
-			}
-		}
-
 		for _, owner := range pod.OwnerReferences {
 			if owner.Kind == jobKind {
-				return resp, nil
+				return nil, nil
 			}
 		}
 
-		cpuLimit, memLimit, cpuRequest, memRequest, unlimited := rra.conf.GetPodLimit(NameNamespace{
-			Name:      name,
-			Namespace: req.Namespace,
-		})
-		if unlimited {
-			return resp, nil
-		}
+		return &workload{
+			kind:         podKind,
+			name:         podName(pod.Name),
+			podName:      pod.Name,
+			podSpec:      pod.Spec,
+			annotations:  pod.Annotations,
+			labels:       pod.Labels,
+			objectLabels: pod.Labels,
+			created:      pod.CreationTimestamp.Time,
+			replicas:     1,
+			controlled:   metav1.GetControllerOf(&pod) != nil,
+		}, nil
+	case deploymentKind:
+		// clusters in the middle of migration might still send older apps versions
+		switch req.Kind.Version {
+		case "v1beta1":
+			var deployment appsv1beta1.Deployment
+			if err := json.Unmarshal(req.Object.Raw, &deployment); err != nil {
+				return nil, errors.Wrapf(err, "unable to unmarshal json: %s", string(req.Object.Raw))
+			}
 
-		if denyResp := rra.validatePodSpec(req, pod.Spec, cpuLimit, memLimit, cpuRequest, memRequest); denyResp != nil {
-			log.Infof("denying request for pod name: %s, namespace: %s, userInfo: %v", name, req.Namespace, req.UserInfo)
-			return denyResp, nil
+			return &workload{
+				kind:         deploymentKind,
+				name:         deployment.Name,
+				podSpec:      deployment.Spec.Template.Spec,
+				annotations:  deployment.Spec.Template.Annotations,
+				labels:       deployment.Spec.Template.Labels,
+				objectLabels: deployment.Labels,
+				created:      deployment.CreationTimestamp.Time,
+				replicas:     replicas(deployment.Spec.Replicas),
+			}, nil
+		case "v1beta2":
+			var deployment appsv1beta2.Deployment
+			if err := json.Unmarshal(req.Object.Raw, &deployment); err != nil {
+				return nil, errors.Wrapf(err, "unable to unmarshal json: %s", string(req.Object.Raw))
+			}
+
+			return &workload{
+				kind:         deploymentKind,
+				name:         deployment.Name,
+				podSpec:      deployment.Spec.Template.Spec,
+				annotations:  deployment.Spec.Template.Annotations,
+				labels:       deployment.Spec.Template.Labels,
+				objectLabels: deployment.Labels,
+				created:      deployment.CreationTimestamp.Time,
+				replicas:     replicas(deployment.Spec.Replicas),
+			}, nil
 		}
 
-		return resp, nil
-	case deploymentKind:
 		var deployment appsv1.Deployment
 		if err := json.Unmarshal(req.Object.Raw, &deployment); err != nil {
 			return nil, errors.Wrapf(err, "unable to unmarshal json: %s", string(req.Object.Raw))
 		}
 
-		cpuLimit, memLimit, cpuRequest, memRequest, unlimited := rra.conf.GetPodLimit(NameNamespace{
-			Name:      deployment.Name,
-			Namespace: req.Namespace,
-		})
-		if unlimited {
-			return resp, nil
-		}
-
-		if denyResp := rra.validatePodSpec(req, deployment.Spec.Template.Spec, cpuLimit, memLimit, cpuRequest, memRequest); denyResp != nil {
-			log.Infof("denying request for deployment name: %s, namespace: %s, userInfo: %v", deployment.Name, req.Namespace, req.UserInfo)
-			return denyResp, nil
-		}
-
-		return resp, nil
+		return &workload{
+			kind:         deploymentKind,
+			name:         deployment.Name,
+			podSpec:      deployment.Spec.Template.Spec,
+			annotations:  deployment.Spec.Template.Annotations,
+			labels:       deployment.Spec.Template.Labels,
+			objectLabels: deployment.Labels,
+			created:      deployment.CreationTimestamp.Time,
+			replicas:     replicas(deployment.Spec.Replicas),
+		}, nil
 	case statefulsetKind:
 		var sts appsv1.StatefulSet
 		if err := json.Unmarshal(req.Object.Raw, &sts); err != nil {
 			return nil, errors.Wrapf(err, "unable to unmarshal json: %s", string(req.Object.Raw))
 		}
 
-		cpuLimit, memLimit, cpuRequest, memRequest, unlimited := rra.conf.GetPodLimit(NameNamespace{
-			Name:      sts.Name,
-			Namespace: req.Namespace,
-		})
-		if unlimited {
-			return resp, nil
-		}
-
-		if denyResp := rra.validatePodSpec(req, sts.Spec.Template.Spec, cpuLimit, memLimit, cpuRequest, memRequest); denyResp != nil {
-			log.Infof("denying request for statefulset name: %s, namespace: %s, userInfo: %v", sts.Name, req.Namespace, req.UserInfo)
-			return denyResp, nil
-		}
-
-		return resp, nil
+		return &workload{
+			kind:           statefulsetKind,
+			name:           sts.Name,
+			podSpec:        sts.Spec.Template.Spec,
+			annotations:    sts.Spec.Template.Annotations,
+			labels:         sts.Spec.Template.Labels,
+			objectLabels:   sts.Labels,
+			created:        sts.CreationTimestamp.Time,
+			replicas:       replicas(sts.Spec.Replicas),
+			claimTemplates: sts.Spec.VolumeClaimTemplates,
+		}, nil
 	case daemonsetKind:
 		var ds appsv1.DaemonSet
 		if err := json.Unmarshal(req.Object.Raw, &ds); err != nil {
 			return nil, errors.Wrapf(err, "unable to unmarshal json: %s", string(req.Object.Raw))
 		}
 
-		cpuLimit, memLimit, cpuRequest, memRequest, unlimited := rra.conf.GetPodLimit(NameNamespace{
-			Name:      ds.Name,
-			Namespace: req.Namespace,
-		})
-		if unlimited {
-			return resp, nil
+		return &workload{
+			kind:         daemonsetKind,
+			name:         ds.Name,
+			podSpec:      ds.Spec.Template.Spec,
+			annotations:  ds.Spec.Template.Annotations,
+			labels:       ds.Spec.Template.Labels,
+			objectLabels: ds.Labels,
+			created:      ds.CreationTimestamp.Time,
+			replicas:     1,
+		}, nil
+	case replicationControllerKind:
+		var rc corev1.ReplicationController
+		if err := json.Unmarshal(req.Object.Raw, &rc); err != nil {
+			return nil, errors.Wrapf(err, "unable to unmarshal json: %s", string(req.Object.Raw))
 		}
 
-		if denyResp := rra.validatePodSpec(req, ds.Spec.Template.Spec, cpuLimit, memLimit, cpuRequest, memRequest); denyResp != nil {
-			log.Infof("denying request for daemonset name: %s, namespace: %s, userInfo: %v", ds.Name, req.Namespace, req.UserInfo)
-			return denyResp, nil
+		// template is optional, there is nothing to validate without it
+		if rc.Spec.Template == nil {
+			return nil, nil
 		}
 
-		return resp, nil
+		return &workload{
+			kind:         replicationControllerKind,
+			name:         rc.Name,
+			podSpec:      rc.Spec.Template.Spec,
+			annotations:  rc.Spec.Template.Annotations,
+			labels:       rc.Spec.Template.Labels,
+			objectLabels: rc.Labels,
+			created:      rc.CreationTimestamp.Time,
+			replicas:     replicas(rc.Spec.Replicas),
+		}, nil
 	case cronJobKind:
-		var cj batchv1beta1.CronJob
-		if err := json.Unmarshal(req.Object.Raw, &cj); err != nil {
-			return nil, errors.Wrapf(err, "unable to unmarshal json: %s", string(req.Object.Raw))
-		}
+		// both versions are looked up by CronJob name, so that limits don't change during API migration
+		if req.Kind.Version == "v1" {
+			var cj batchv1.CronJob
+			if err := json.Unmarshal(req.Object.Raw, &cj); err != nil {
+				return nil, errors.Wrapf(err, "unable to unmarshal json: %s", string(req.Object.Raw))
+			}
 
-		cpuLimit, memLimit, cpuRequest, memRequest, unlimited := rra.conf.GetPodLimit(NameNamespace{
-			Name:      cj.Name,
-			Namespace: req.Namespace,
-		})
-		if unlimited {
-			return resp, nil
+			return &workload{
+				kind:         cronJobKind,
+				name:         cj.Name,
+				podSpec:      cj.Spec.JobTemplate.Spec.Template.Spec,
+				annotations:  cj.Spec.JobTemplate.Spec.Template.Annotations,
+				labels:       cj.Spec.JobTemplate.Spec.Template.Labels,
+				objectLabels: cj.Labels,
+				created:      cj.CreationTimestamp.Time,
+				replicas:     replicas(cj.Spec.JobTemplate.Spec.Parallelism),
+			}, nil
 		}
 
-		if denyResp := rra.validatePodSpec(req, cj.Spec.JobTemplate.Spec.Template.Spec, cpuLimit, memLimit, cpuRequest, memRequest); denyResp != nil {
-			log.Infof("denying request for daemonset name: %s, namespace: %s, userInfo: %v", cj.Name, req.Namespace, req.UserInfo)
-			return denyResp, nil
+		var cj batchv1beta1.CronJob
+		if err := json.Unmarshal(req.Object.Raw, &cj); err != nil {
+			return nil, errors.Wrapf(err, "unable to unmarshal json: %s", string(req.Object.Raw))
 		}
 
-		return resp, nil
+		return &workload{
+			kind:         cronJobKind,
+			name:         cj.Name,
+			podSpec:      cj.Spec.JobTemplate.Spec.Template.Spec,
+			annotations:  cj.Spec.JobTemplate.Spec.Template.Annotations,
+			labels:       cj.Spec.JobTemplate.Spec.Template.Labels,
+			objectLabels: cj.Labels,
+			created:      cj.CreationTimestamp.Time,
+			replicas:     replicas(cj.Spec.JobTemplate.Spec.Parallelism),
+		}, nil
 	case jobKind:
 		var j batchv1.Job
 		if err := json.Unmarshal(req.Object.Raw, &j); err != nil {
 			return nil, errors.Wrapf(err, "unable to unmarshal json: %s", string(req.Object.Raw))
 		}
 
-		var cpuLimit, memLimit, cpuRequest, memRequest *resource.Quantity
-		var unlimited bool
-
-		// find cronjob limits
+		// jobs created by cronjobs are validated on cronjob admission,
+		// unless configured otherwise, then they are looked up by the owner name (of any CronJob version)
 		for _, owner := range j.OwnerReferences {
-			if owner.Kind == cronJobKind {
-				return resp, nil
-				/*
-					cpuLimit, memLimit, cpuRequest, memRequest, unlimited = rra.conf.GetPodLimit(NameNamespace{
-						Name:      owner.Name,
-						Namespace: req.Namespace,
-					})
-				*/
+			if owner.Kind != cronJobKind {
+				continue
 			}
-		}
 
-		// fallback to pod spec
-		if cpuLimit == nil || memLimit == nil || cpuRequest == nil || memRequest == nil {
-			cpuLimit, memLimit, cpuRequest, memRequest, unlimited = rra.conf.GetPodLimit(NameNamespace{
-				Name:      j.Name,
-				Namespace: req.Namespace,
-			})
-		}
+			if !rra.opts.ValidateCronJobJobs {
+				return nil, nil
+			}
 
-		if unlimited {
-			return resp, nil
+			return &workload{
+				kind:         jobKind,
+				name:         owner.Name,
+				podSpec:      j.Spec.Template.Spec,
+				annotations:  j.Spec.Template.Annotations,
+				labels:       j.Spec.Template.Labels,
+				objectLabels: j.Labels,
+				created:      j.CreationTimestamp.Time,
+				replicas:     replicas(j.Spec.Parallelism),
+			}, nil
 		}
 
-		if denyResp := rra.validatePodSpec(req, j.Spec.Template.Spec, cpuLimit, memLimit, cpuRequest, memRequest); denyResp != nil {
-			log.Infof("denying request for daemonset name: %s, namespace: %s, userInfo: %v", j.Name, req.Namespace, req.UserInfo)
-			return denyResp, nil
-		}
+		return &workload{
+			kind:         jobKind,
+			name:         j.Name,
+			podSpec:      j.Spec.Template.Spec,
+			annotations:  j.Spec.Template.Annotations,
+			labels:       j.Spec.Template.Labels,
+			objectLabels: j.Labels,
+			created:      j.CreationTimestamp.Time,
+			replicas:     replicas(j.Spec.Parallelism),
+		}, nil
+	}
 
-		return resp, nil
-	case pvcKind:
-		var pvc corev1.PersistentVolumeClaim
-		if err := json.Unmarshal(req.Object.Raw, &pvc); err != nil {
-			return nil, errors.Wrapf(err, "unable to unmarshal json: %s", string(req.Object.Raw))
-		}
+	return rra.decodeCustomResource(req)
+}
 
-		maxSize, unlimited := rra.conf.GetMaxPVCSize(NameNamespace{
-			Name:      pvc.Name,
-			Namespace: req.Namespace,
-		})
-		if unlimited {
-			return resp, nil
+// addedEphemeralContainers returns names of ephemeral containers, which are not present in the old pod
+func addedEphemeralContainers(req *v1beta1.AdmissionRequest, podSpec corev1.PodSpec) ([]string, error) {
+	existing := make(map[string]bool)
+	if req.Operation == v1beta1.Update && len(req.OldObject.Raw) > 0 {
+		var oldPod corev1.Pod
+		if err := json.Unmarshal(req.OldObject.Raw, &oldPod); err != nil {
+			return nil, errors.Wrapf(err, "unable to unmarshal json: %s", string(req.OldObject.Raw))
 		}
 
-		vSize, ok := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
-		if !ok {
-			log.Infof("denying request for pvc name: %s, namespace: %s, userInfo: %v", pvc.Name, req.Namespace, req.UserInfo)
-			return &v1beta1.AdmissionResponse{
-				UID:     req.UID,
-				Allowed: false,
-				Result: &metav1.Status{
-					Message: fmt.Sprintf("error persistentVolumeClaim %s size is empty", pvc.Name),
-				},
-			}, nil
+		for _, container := range oldPod.Spec.EphemeralContainers {
+			existing[container.Name] = true
 		}
+	}
 
-		if vSize.Cmp(*maxSize) > 0 {
-			log.Infof("denying request for pvc name: %s, namespace: %s, userInfo: %v", pvc.Name, req.Namespace, req.UserInfo)
-			return &v1beta1.AdmissionResponse{
-				UID:     req.UID,
-				Allowed: false,
-				Result: &metav1.Status{
-					Message: fmt.Sprintf("error persistentVolumeClaim %s size is %s > %s", pvc.Name, vSize.String(), maxSize.String()),
-				},
-			}, nil
+	var added []string
+	for _, container := range podSpec.EphemeralContainers {
+		if !existing[container.Name] {
+			added = append(added, container.Name)
 		}
 	}
 
-	return resp, nil
+	return added, nil
 }
 
-func (rra *ResourceRequestsAdmission) validatePodSpec(req *v1beta1.AdmissionRequest, podSpec corev1.PodSpec, cpuLimit, memLimit, cpuRequest, memRequest *resource.Quantity) *v1beta1.AdmissionResponse {
-	for _, container := range podSpec.Containers {
-		if _, ok := container.Resources.Requests[corev1.ResourceCPU]; !ok {
-			return &v1beta1.AdmissionResponse{
-				UID:     req.UID,
-				Allowed: false,
-				Result: &metav1.Status{
-					Message: fmt.Sprintf("error container %s requests.CPU is empty, must be 0", container.Name),
-				},
-			}
+// templateResourceChanges returns warnings for containers, whose resources differ from the old object,
+// since changed pod template is rolled out by restarting pods
+func (rra *ResourceRequestsAdmission) templateResourceChanges(req *v1beta1.AdmissionRequest, w *workload) ([]string, error) {
+	oldReq := *req
+	oldReq.Object = req.OldObject
+	old, err := rra.decodeWorkload(&oldReq)
+	if err != nil || old == nil {
+		return nil, err
+	}
+
+	oldResources := make(map[string]corev1.ResourceRequirements)
+	for _, container := range old.podSpec.InitContainers {
+		oldResources[container.Name] = container.Resources
+	}
+	for _, container := range old.podSpec.Containers {
+		oldResources[container.Name] = container.Resources
+	}
+
+	var warnings []string
+	for _, container := range append(append([]corev1.Container{}, w.podSpec.InitContainers...), w.podSpec.Containers...) {
+		resources, ok := oldResources[container.Name]
+		if !ok || equality.Semantic.DeepEqual(resources, container.Resources) {
+			continue
 		}
-		if _, ok := container.Resources.Requests[corev1.ResourceMemory]; !ok {
-			return &v1beta1.AdmissionResponse{
-				UID:     req.UID,
-				Allowed: false,
-				Result: &metav1.Status{
-					Message: fmt.Sprintf("error container %s requests.Memory is empty, must be 0", container.Name),
-				},
-			}
+
+		warnings = append(warnings, fmt.Sprintf("container %s resources changed, pods of %s %s will be restarted", container.Name, strings.ToLower(w.kind), w.name))
+	}
+
+	return warnings, nil
+}
+
+// podName strips generated suffixes, so that pod name matches its owner name
+func podName(name string) string {
+	match := podIDRegex.FindStringSubmatch(name)
+	if len(match) == 3 {
+		return match[1]
+	}
+
+	match = podID2Regex.FindStringSubmatch(name)
+	if len(match) == 3 {
+		return match[1]
+	}
+
+	return name
+}
+
+func (rra *ResourceRequestsAdmission) handlePVC(ctx context.Context, req *v1beta1.AdmissionRequest) (*v1beta1.AdmissionResponse, error) {
+	resp := &v1beta1.AdmissionResponse{
+		UID:     req.UID,
+		Allowed: true,
+	}
+
+	var pvc corev1.PersistentVolumeClaim
+	if err := json.Unmarshal(req.Object.Raw, &pvc); err != nil {
+		return nil, errors.Wrapf(err, "unable to unmarshal json: %s", string(req.Object.Raw))
+	}
+
+	maxSize, unlimited := rra.conf.GetMaxStorageClassPVCSize(NameNamespace{
+		Name:      pvc.Name,
+		Namespace: req.Namespace,
+	}, pvc.Spec.StorageClassName)
+	if unlimited {
+		return resp, nil
+	}
+
+	vSize, ok := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	if !ok {
+		rra.logDenial(req, "denying request for pvc name: %s, namespace: %s, userInfo: %v", pvc.Name, req.Namespace, req.UserInfo)
+		return &v1beta1.AdmissionResponse{
+			UID:     req.UID,
+			Allowed: false,
+			Result: &metav1.Status{
+				Message: fmt.Sprintf("error persistentVolumeClaim %s size is empty", pvc.Name),
+			},
+			AuditAnnotations: reasonAnnotations(reasonPVCSizeMissing),
+		}, nil
+	}
+
+	if maxSize != nil && vSize.Cmp(*maxSize) > 0 {
+		rra.logDenial(req, "denying request for pvc name: %s, namespace: %s, userInfo: %v", pvc.Name, req.Namespace, req.UserInfo)
+		return &v1beta1.AdmissionResponse{
+			UID:     req.UID,
+			Allowed: false,
+			Result: &metav1.Status{
+				Message: fmt.Sprintf("error persistentVolumeClaim %s size is %s", pvc.Name, formatComparison(corev1.ResourceStorage, vSize, ">", *maxSize)),
+			},
+			AuditAnnotations: reasonAnnotations(reasonPVCSizeExceeded),
+		}, nil
+	}
+
+	minSize, _ := rra.conf.GetMinPVCSize(NameNamespace{
+		Name:      pvc.Name,
+		Namespace: req.Namespace,
+	})
+	if minSize != nil && vSize.Cmp(*minSize) < 0 {
+		rra.logDenial(req, "denying request for pvc name: %s, namespace: %s, userInfo: %v", pvc.Name, req.Namespace, req.UserInfo)
+		return &v1beta1.AdmissionResponse{
+			UID:     req.UID,
+			Allowed: false,
+			Result: &metav1.Status{
+				Message: fmt.Sprintf("error persistentVolumeClaim %s size is %s", pvc.Name, formatComparison(corev1.ResourceStorage, vSize, "<", *minSize)),
+			},
+			AuditAnnotations: reasonAnnotations(reasonPVCSizeBelowMinimum),
+		}, nil
+	}
+
+	maxNamespaceSize, _ := rra.conf.GetMaxNamespacePVCSize(req.Namespace)
+	if maxNamespaceSize != nil && rra.pvcUsage != nil {
+		name := pvc.Name
+		if name == "" {
+			name = string(req.UID)
 		}
 
-		if cpuRequest != nil && container.Resources.Requests.Cpu().Cmp(*cpuRequest) > 0 {
+		dryRun := req.DryRun != nil && *req.DryRun
+		total, ok := reserve(ctx, rra.pvcUsage, req.Namespace, name, vSize, *maxNamespaceSize, dryRun)
+		if !ok {
+			rra.logDenial(req, "denying request for pvc name: %s, namespace: %s, userInfo: %v", pvc.Name, req.Namespace, req.UserInfo)
 			return &v1beta1.AdmissionResponse{
 				UID:     req.UID,
 				Allowed: false,
 				Result: &metav1.Status{
-					Message: fmt.Sprintf("error container %s requests.CPU: %s > %s", container.Name, container.Resources.Requests.Cpu(), cpuRequest),
+					Message: fmt.Sprintf("error namespace %s persistentVolumeClaims size would be %s", req.Namespace, formatComparison(corev1.ResourceStorage, total, ">", *maxNamespaceSize)),
 				},
-			}
+				AuditAnnotations: reasonAnnotations(reasonNamespacePVCSizeExceeded),
+			}, nil
 		}
+	}
 
-		if memRequest != nil && container.Resources.Requests.Memory().Cmp(*memRequest) > 0 {
-			return &v1beta1.AdmissionResponse{
-				UID:     req.UID,
-				Allowed: false,
-				Result: &metav1.Status{
-					Message: fmt.Sprintf("error container %s requests.Memory: %s > %s", container.Name, container.Resources.Requests.Memory(), memRequest),
-				},
+	return resp, nil
+}
+
+// validateClaimTemplates denies StatefulSet, whose volumeClaimTemplate requests more storage than maxPVCSize.
+// Limit is looked up by the StatefulSet name, since names of PVCs created from templates are derived from it.
+func (rra *ResourceRequestsAdmission) validateClaimTemplates(req *v1beta1.AdmissionRequest, w *workload) *v1beta1.AdmissionResponse {
+	for _, pvc := range w.claimTemplates {
+		maxSize, unlimited := rra.conf.GetMaxStorageClassPVCSize(NameNamespace{
+			Name:      w.name,
+			Namespace: req.Namespace,
+		}, pvc.Spec.StorageClassName)
+		if unlimited || maxSize == nil {
+			continue
+		}
+
+		// size is required by API server validation
+		vSize, ok := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+		if !ok || vSize.Cmp(*maxSize) <= 0 {
+			continue
+		}
+
+		return &v1beta1.AdmissionResponse{
+			UID:     req.UID,
+			Allowed: false,
+			Result: &metav1.Status{
+				Message: fmt.Sprintf("error %s %s volumeClaimTemplate %s size is %s", strings.ToLower(w.kind), w.name, pvc.Name, formatComparison(corev1.ResourceStorage, vSize, ">", *maxSize)),
+			},
+			AuditAnnotations: reasonAnnotations(reasonPVCSizeExceeded),
+		}
+	}
+
+	return nil
+}
+
+// violation describes container resource which doesn't satisfy the limit
+type violation struct {
+	// container is empty if violation applies to the pod
+	container string
+	resource  corev1.ResourceName
+	reason    denialReason
+	message   string
+	// fix is nil if violation can't be fixed by changing single resource value
+	fix *resourceFix
+}
+
+// validatePodSpec validates containers against limit,
+// violations of resources with warn severity are returned as warnings, others deny the request.
+// Verdicts summarize violations by container, if ContainerVerdicts is enabled.
+func (rra *ResourceRequestsAdmission) validatePodSpec(req *v1beta1.AdmissionRequest, podSpec corev1.PodSpec, limit LimitResource) ([]string, string, *v1beta1.AdmissionResponse) {
+	var violations []violation
+	if rra.opts.RejectNegativeQuantities {
+		for _, container := range podSpec.InitContainers {
+			violations = append(violations, inContainer(container.Name, negativeQuantities("init container", container))...)
+		}
+		for _, container := range podSpec.Containers {
+			violations = append(violations, inContainer(container.Name, negativeQuantities("container", container))...)
+		}
+	}
+
+	violations = append(violations, validatePodResources(podSpec, limit)...)
+	violations = append(violations, validatePodMemory(podSpec, limit)...)
+	violations = append(violations, validateGuaranteed(podSpec, limit)...)
+	violations = append(violations, validateProfile(podSpec, limit)...)
+
+	// containers are limited by their override in containers, native sidecars by the sidecar limit, if it's configured
+	for _, container := range podSpec.Containers {
+		violations = append(violations, inContainer(container.Name, validateContainer("container", container, limit.ForContainer(container).ForImage(container.Image), podSpec.Resources))...)
+	}
+
+	for _, container := range podSpec.InitContainers {
+		if isSidecar(container) {
+			violations = append(violations, inContainer(container.Name, validateContainer("sidecar container", container, limit.ForContainer(container).ForImage(container.Image), podSpec.Resources))...)
+		} else {
+			violations = append(violations, inContainer(container.Name, validateContainer("init container", container, limit.ForContainer(container).ForImage(container.Image), podSpec.Resources))...)
+		}
+	}
+
+	for _, container := range podSpec.EphemeralContainers {
+		violations = append(violations, inContainer(container.Name, validateEphemeralContainer(container, limit, podSpec.Resources))...)
+	}
+
+	for _, container := range podSpec.Containers {
+		violations = append(violations, inContainer(container.Name, validateCPULimitPolicy(container, limit.ForContainer(container).CPULimitPolicy))...)
+	}
+
+	for _, container := range podSpec.Containers {
+		violations = append(violations, inContainer(container.Name, requireProbes(container, limit.ForContainer(container)))...)
+	}
+
+	for _, container := range podSpec.InitContainers {
+		violations = append(violations, inContainer(container.Name, validateSwap(podSpec, container, limit.ForContainer(container).SwapPolicy))...)
+	}
+	for _, container := range podSpec.Containers {
+		violations = append(violations, inContainer(container.Name, validateSwap(podSpec, container, limit.ForContainer(container).SwapPolicy))...)
+	}
+
+	if rra.opts.RequireMemoryLimit && !hasPodMemoryLimit(podSpec) {
+		for _, container := range podSpec.InitContainers {
+			violations = append(violations, inContainer(container.Name, requireMemoryLimit("init container", container))...)
+		}
+		for _, container := range podSpec.Containers {
+			violations = append(violations, inContainer(container.Name, requireMemoryLimit("container", container))...)
+		}
+	}
+
+	if rra.opts.RequireEphemeralStorageLimit && hasDiskEmptyDir(podSpec) {
+		for _, container := range podSpec.InitContainers {
+			violations = append(violations, inContainer(container.Name, requireEphemeralStorageLimit("init container", container))...)
+		}
+		for _, container := range podSpec.Containers {
+			violations = append(violations, inContainer(container.Name, requireEphemeralStorageLimit("container", container))...)
+		}
+	}
+
+	warned := func(v violation) bool {
+		return limit.Mode == modeWarn || limit.Severity[v.resource] == severityWarn
+	}
+
+	var verdicts string
+	if rra.opts.ContainerVerdicts {
+		verdicts = containerVerdicts(podSpec, violations, warned)
+	}
+
+	var warnings []string
+	var deny *violation
+	for i, v := range violations {
+		if warned(v) {
+			warnings = append(warnings, v.message)
+			continue
+		}
+
+		if deny == nil {
+			deny = &violations[i]
+		}
+	}
+
+	if rra.opts.SuggestPatches {
+		if patch, ok := suggestPatch(req.Kind.Kind, podSpec, violations); ok {
+			warnings = append(warnings, patch)
+		}
+	}
+
+	if deny == nil {
+		return warnings, verdicts, nil
+	}
+
+	return nil, verdicts, &v1beta1.AdmissionResponse{
+		UID:     req.UID,
+		Allowed: false,
+		Result: &metav1.Status{
+			Message: deny.message,
+		},
+		AuditAnnotations: reasonAnnotations(deny.reason),
+		Warnings:         warnings,
+	}
+}
+
+// isSidecar returns true if init container is a native sidecar
+func isSidecar(container corev1.Container) bool {
+	return container.RestartPolicy != nil && *container.RestartPolicy == corev1.ContainerRestartPolicyAlways
+}
+
+// hasPodResource returns true if pod level resources (spec.resources) request or limit the resource
+func hasPodResource(podResources *corev1.ResourceRequirements, name corev1.ResourceName) bool {
+	if podResources == nil {
+		return false
+	}
+
+	_, hasRequest := podResources.Requests[name]
+	_, hasLimit := podResources.Limits[name]
+	return hasRequest || hasLimit
+}
+
+// hasPodMemoryLimit returns true if pod level resources (spec.resources) limit memory, so containers don't need memory limits
+func hasPodMemoryLimit(podSpec corev1.PodSpec) bool {
+	if podSpec.Resources == nil {
+		return false
+	}
+
+	_, ok := podSpec.Resources.Limits[corev1.ResourceMemory]
+	return ok
+}
+
+// podMemory returns memory limit of pod, pod level limit wins over containers. Otherwise it's podTotal of container limits,
+// containers without memory limit count as 0.
+func podMemory(podSpec corev1.PodSpec) resource.Quantity {
+	if podSpec.Resources != nil {
+		if q, ok := podSpec.Resources.Limits[corev1.ResourceMemory]; ok {
+			return q.DeepCopy()
+		}
+	}
+
+	return podTotal(podSpec, corev1.ResourceMemory, func(container corev1.Container) corev1.ResourceList {
+		return container.Resources.Limits
+	})
+}
+
+// podTotal returns the sum of the resource of containers and native sidecars, or of the largest init container,
+// if it's higher, since init containers run one by one before containers
+func podTotal(podSpec corev1.PodSpec, name corev1.ResourceName, resources func(corev1.Container) corev1.ResourceList) resource.Quantity {
+	var total resource.Quantity
+	for _, container := range podSpec.Containers {
+		total.Add(resources(container)[name])
+	}
+	for _, container := range podSpec.InitContainers {
+		if isSidecar(container) {
+			total.Add(resources(container)[name])
+		}
+	}
+
+	for _, container := range podSpec.InitContainers {
+		if q := resources(container)[name]; !isSidecar(container) && q.Cmp(total) > 0 {
+			total = q.DeepCopy()
+		}
+	}
+
+	return total
+}
+
+// validatePodMemory validates memory limit of pod against PodMemLimit, with CountMemoryEmptyDirs sizeLimit
+// of memory backed emptyDir volumes is added, since their files are charged to pod memory
+func validatePodMemory(podSpec corev1.PodSpec, limit LimitResource) []violation {
+	if limit.PodMemLimit == nil {
+		return nil
+	}
+
+	total := podMemory(podSpec)
+	field := "limits.Memory"
+
+	if limit.CountMemoryEmptyDirs {
+		var volumes []string
+		for _, volume := range podSpec.Volumes {
+			if volume.EmptyDir == nil || volume.EmptyDir.Medium != corev1.StorageMediumMemory || volume.EmptyDir.SizeLimit == nil {
+				continue
 			}
+
+			total.Add(*volume.EmptyDir.SizeLimit)
+			volumes = append(volumes, volume.Name)
 		}
 
-		if cpuLimit != nil && container.Resources.Limits.Cpu().Cmp(*cpuLimit) > 0 {
-			return &v1beta1.AdmissionResponse{
-				UID:     req.UID,
-				Allowed: false,
-				Result: &metav1.Status{
-					Message: fmt.Sprintf("error container %s limits.CPU: %s > %s", container.Name, container.Resources.Limits.Cpu(), cpuLimit),
-				},
+		if len(volumes) > 0 {
+			field = fmt.Sprintf("limits.Memory + emptyDir %s", strings.Join(volumes, ", "))
+		}
+	}
+
+	if total.Cmp(*limit.PodMemLimit) <= 0 {
+		return nil
+	}
+
+	return []violation{{
+		resource: corev1.ResourceMemory,
+		reason:   reasonPodMemoryExceeded,
+		message:  fmt.Sprintf("error pod memory %s: %s", field, formatComparison(corev1.ResourceMemory, total, ">", *limit.PodMemLimit)),
+	}}
+}
+
+// validatePodResources validates pod level resources (spec.resources) against the same ceilings and minimums as containers
+func validatePodResources(podSpec corev1.PodSpec, limit LimitResource) []violation {
+	if podSpec.Resources == nil {
+		return nil
+	}
+
+	var violations []violation
+	for _, c := range []struct {
+		name      corev1.ResourceName
+		field     string
+		resources corev1.ResourceList
+		max       *resource.Quantity
+		maxReason denialReason
+		min       *resource.Quantity
+	}{
+		{name: corev1.ResourceCPU, field: "requests.CPU", resources: podSpec.Resources.Requests, max: limit.CPURequest, maxReason: reasonRequestExceeded, min: limit.MinCPURequest},
+		{name: corev1.ResourceMemory, field: "requests.Memory", resources: podSpec.Resources.Requests, max: limit.MemRequest, maxReason: reasonRequestExceeded, min: limit.MinMemRequest},
+		{name: corev1.ResourceCPU, field: "limits.CPU", resources: podSpec.Resources.Limits, max: limit.CPULimit, maxReason: reasonLimitExceeded},
+		{name: corev1.ResourceMemory, field: "limits.Memory", resources: podSpec.Resources.Limits, max: limit.MemLimit, maxReason: reasonLimitExceeded},
+	} {
+		q, ok := c.resources[c.name]
+		if !ok {
+			continue
+		}
+
+		if c.max != nil && q.Cmp(*c.max) > 0 {
+			violations = append(violations, violation{
+				resource: c.name,
+				reason:   c.maxReason,
+				message:  fmt.Sprintf("error pod %s: %s", c.field, formatComparison(c.name, q, ">", *c.max)),
+			})
+		}
+		if c.min != nil && q.Cmp(*c.min) < 0 {
+			violations = append(violations, violation{
+				resource: c.name,
+				reason:   reasonRequestBelowMinimum,
+				message:  fmt.Sprintf("error pod %s: %s", c.field, formatComparison(c.name, q, "<", *c.min)),
+			})
+		}
+	}
+
+	return violations
+}
+
+// validateContainer validates container resources against limit,
+// requests may be omitted if pod level resources (spec.resources) are set for the resource
+func validateContainer(containerType string, container corev1.Container, limit LimitResource, podResources *corev1.ResourceRequirements) []violation {
+	var violations []violation
+
+	cpuRequest, hasCPURequest := container.Resources.Requests[corev1.ResourceCPU]
+	switch {
+	case !hasCPURequest && hasPodResource(podResources, corev1.ResourceCPU):
+	case !hasCPURequest && limit.MinCPURequest != nil:
+		violations = append(violations, violation{
+			resource: corev1.ResourceCPU,
+			reason:   reasonRequestBelowMinimum,
+			message:  fmt.Sprintf("error %s %s requests.CPU is empty, must be at least %s", containerType, container.Name, formatQuantity(corev1.ResourceCPU, *limit.MinCPURequest)),
+		})
+	case !hasCPURequest:
+		violations = append(violations, violation{
+			resource: corev1.ResourceCPU,
+			reason:   reasonMissingRequest,
+			message:  fmt.Sprintf("error %s %s requests.CPU is empty, must be 0", containerType, container.Name),
+		})
+	case limit.MinCPURequest != nil && cpuRequest.Cmp(*limit.MinCPURequest) < 0:
+		violations = append(violations, violation{
+			resource: corev1.ResourceCPU,
+			reason:   reasonRequestBelowMinimum,
+			message:  fmt.Sprintf("error %s %s requests.CPU: %s", containerType, container.Name, formatComparison(corev1.ResourceCPU, cpuRequest, "<", *limit.MinCPURequest)),
+			fix:      &resourceFix{container: container.Name, field: "requests", resource: corev1.ResourceCPU, value: *limit.MinCPURequest},
+		})
+	}
+
+	memRequest, hasMemRequest := container.Resources.Requests[corev1.ResourceMemory]
+	switch {
+	case !hasMemRequest && hasPodResource(podResources, corev1.ResourceMemory):
+	case !hasMemRequest && limit.MinMemRequest != nil:
+		violations = append(violations, violation{
+			resource: corev1.ResourceMemory,
+			reason:   reasonRequestBelowMinimum,
+			message:  fmt.Sprintf("error %s %s requests.Memory is empty, must be at least %s", containerType, container.Name, formatQuantity(corev1.ResourceMemory, *limit.MinMemRequest)),
+		})
+	case !hasMemRequest:
+		violations = append(violations, violation{
+			resource: corev1.ResourceMemory,
+			reason:   reasonMissingRequest,
+			message:  fmt.Sprintf("error %s %s requests.Memory is empty, must be 0", containerType, container.Name),
+		})
+	case limit.MinMemRequest != nil && memRequest.Cmp(*limit.MinMemRequest) < 0:
+		violations = append(violations, violation{
+			resource: corev1.ResourceMemory,
+			reason:   reasonRequestBelowMinimum,
+			message:  fmt.Sprintf("error %s %s requests.Memory: %s", containerType, container.Name, formatComparison(corev1.ResourceMemory, memRequest, "<", *limit.MinMemRequest)),
+			fix:      &resourceFix{container: container.Name, field: "requests", resource: corev1.ResourceMemory, value: *limit.MinMemRequest},
+		})
+	}
+
+	for _, name := range limit.PairedResources {
+		_, hasRequest := container.Resources.Requests[name]
+		_, hasLimit := container.Resources.Limits[name]
+		if hasRequest != hasLimit {
+			violations = append(violations, violation{
+				resource: name,
+				reason:   reasonUnpairedResource,
+				message:  fmt.Sprintf("error %s %s requests.%s and limits.%s must be both set or both empty", containerType, container.Name, name, name),
+			})
+		}
+	}
+
+	if limit.CPURequest != nil && container.Resources.Requests.Cpu().Cmp(*limit.CPURequest) > 0 {
+		violations = append(violations, violation{
+			resource: corev1.ResourceCPU,
+			reason:   reasonRequestExceeded,
+			message:  fmt.Sprintf("error %s %s requests.CPU: %s", containerType, container.Name, formatComparison(corev1.ResourceCPU, *container.Resources.Requests.Cpu(), ">", *limit.CPURequest)),
+			fix:      &resourceFix{container: container.Name, field: "requests", resource: corev1.ResourceCPU, value: *limit.CPURequest},
+		})
+	}
+
+	if limit.MemRequest != nil && container.Resources.Requests.Memory().Cmp(*limit.MemRequest) > 0 {
+		violations = append(violations, violation{
+			resource: corev1.ResourceMemory,
+			reason:   reasonRequestExceeded,
+			message:  fmt.Sprintf("error %s %s requests.Memory: %s", containerType, container.Name, formatComparison(corev1.ResourceMemory, *container.Resources.Requests.Memory(), ">", *limit.MemRequest)),
+			fix:      &resourceFix{container: container.Name, field: "requests", resource: corev1.ResourceMemory, value: *limit.MemRequest},
+		})
+	}
+
+	// without RequireLimits a missing limit passes, since it's compared as 0
+	if limit.RequireLimits {
+		violations = append(violations, requireLimits(containerType, container, limit, podResources)...)
+	}
+
+	if limit.CPULimit != nil && container.Resources.Limits.Cpu().Cmp(*limit.CPULimit) > 0 {
+		violations = append(violations, violation{
+			resource: corev1.ResourceCPU,
+			reason:   reasonLimitExceeded,
+			message:  fmt.Sprintf("error %s %s limits.CPU: %s", containerType, container.Name, formatComparison(corev1.ResourceCPU, *container.Resources.Limits.Cpu(), ">", *limit.CPULimit)),
+			fix:      &resourceFix{container: container.Name, field: "limits", resource: corev1.ResourceCPU, value: *limit.CPULimit},
+		})
+	}
+
+	if limit.MemLimit != nil && container.Resources.Limits.Memory().Cmp(*limit.MemLimit) > 0 {
+		violations = append(violations, violation{
+			resource: corev1.ResourceMemory,
+			reason:   reasonLimitExceeded,
+			message:  fmt.Sprintf("error %s %s limits.Memory: %s", containerType, container.Name, formatComparison(corev1.ResourceMemory, *container.Resources.Limits.Memory(), ">", *limit.MemLimit)),
+			fix:      &resourceFix{container: container.Name, field: "limits", resource: corev1.ResourceMemory, value: *limit.MemLimit},
+		})
+	}
+
+	if storage, ok := container.Resources.Limits[corev1.ResourceEphemeralStorage]; ok && limit.EphemeralStorageLimit != nil && storage.Cmp(*limit.EphemeralStorageLimit) > 0 {
+		violations = append(violations, violation{
+			resource: corev1.ResourceEphemeralStorage,
+			reason:   reasonLimitExceeded,
+			message:  fmt.Sprintf("error %s %s limits.EphemeralStorage: %s", containerType, container.Name, formatComparison(corev1.ResourceEphemeralStorage, storage, ">", *limit.EphemeralStorageLimit)),
+			fix:      &resourceFix{container: container.Name, field: "limits", resource: corev1.ResourceEphemeralStorage, value: *limit.EphemeralStorageLimit},
+		})
+	}
+
+	for _, name := range sortedResourceNames(limit.ExtendedResources) {
+		if q, ok := container.Resources.Limits[name]; ok && q.Cmp(*limit.ExtendedResources[name]) > 0 {
+			violations = append(violations, violation{
+				resource: name,
+				reason:   reasonLimitExceeded,
+				message:  fmt.Sprintf("error %s %s limits.%s: %s", containerType, container.Name, name, formatComparison(name, q, ">", *limit.ExtendedResources[name])),
+				fix:      &resourceFix{container: container.Name, field: "limits", resource: name, value: *limit.ExtendedResources[name]},
+			})
+		}
+	}
+
+	if limit.MaxContainerResources != nil {
+		if count := containerResourceCount(container); count > *limit.MaxContainerResources {
+			violations = append(violations, violation{
+				reason:  reasonTooManyContainerResources,
+				message: fmt.Sprintf("error %s %s distinct resources in requests and limits: %d > %d", containerType, container.Name, count, *limit.MaxContainerResources),
+			})
+		}
+	}
+
+	if burst, ok := containerBurst(container, corev1.ResourceCPU); ok && limit.CPUBurst != nil && burst.Cmp(*limit.CPUBurst) > 0 {
+		violations = append(violations, violation{
+			resource: corev1.ResourceCPU,
+			reason:   reasonBurstExceeded,
+			message:  fmt.Sprintf("error %s %s limits.CPU - requests.CPU: %s", containerType, container.Name, formatComparison(corev1.ResourceCPU, burst, ">", *limit.CPUBurst)),
+		})
+	}
+
+	if burst, ok := containerBurst(container, corev1.ResourceMemory); ok && limit.MemBurst != nil && burst.Cmp(*limit.MemBurst) > 0 {
+		violations = append(violations, violation{
+			resource: corev1.ResourceMemory,
+			reason:   reasonBurstExceeded,
+			message:  fmt.Sprintf("error %s %s limits.Memory - requests.Memory: %s", containerType, container.Name, formatComparison(corev1.ResourceMemory, burst, ">", *limit.MemBurst)),
+		})
+	}
+
+	if limit.MaxLimitRequestRatio != nil {
+		for _, r := range []struct {
+			name  corev1.ResourceName
+			field string
+		}{
+			{name: corev1.ResourceCPU, field: "CPU"},
+			{name: corev1.ResourceMemory, field: "Memory"},
+		} {
+			ratio, ok := limitRequestRatio(container, r.name)
+			switch {
+			case !ok:
+			case math.IsInf(ratio, 1):
+				if limit.ZeroRequestRatio == zeroRequestDeny {
+					violations = append(violations, violation{
+						resource: r.name,
+						reason:   reasonLimitRequestRatioExceeded,
+						message:  fmt.Sprintf("error %s %s limits.%s / requests.%s is infinite, requests.%s is 0", containerType, container.Name, r.field, r.field, r.field),
+					})
+				}
+			case ratio > *limit.MaxLimitRequestRatio:
+				violations = append(violations, violation{
+					resource: r.name,
+					reason:   reasonLimitRequestRatioExceeded,
+					message:  fmt.Sprintf("error %s %s limits.%s / requests.%s: %.2f > %.2f", containerType, container.Name, r.field, r.field, ratio, *limit.MaxLimitRequestRatio),
+				})
 			}
 		}
+	}
 
-		if memLimit != nil && container.Resources.Limits.Memory().Cmp(*memLimit) > 0 {
-			return &v1beta1.AdmissionResponse{
-				UID:     req.UID,
-				Allowed: false,
-				Result: &metav1.Status{
-					Message: fmt.Sprintf("error container %s limits.Memory: %s > %s", container.Name, container.Resources.Limits.Memory(), memLimit),
-				},
+	if limit.RequestFraction != nil {
+		for _, r := range []struct {
+			name  corev1.ResourceName
+			field string
+		}{
+			{name: corev1.ResourceCPU, field: "CPU"},
+			{name: corev1.ResourceMemory, field: "Memory"},
+		} {
+			if fraction, ok := requestFractionDeviation(container, r.name, *limit.RequestFraction, limit.RequestFractionTolerance); ok {
+				violations = append(violations, violation{
+					resource: r.name,
+					reason:   reasonRequestFractionMismatch,
+					message: fmt.Sprintf("error %s %s requests.%s / limits.%s: %.2f, must be %.2f ± %.2f",
+						containerType, container.Name, r.field, r.field, fraction, *limit.RequestFraction, limit.RequestFractionTolerance),
+				})
 			}
 		}
 	}
 
+	return violations
+}
+
+// validateEphemeralContainer validates ephemeral container like other containers, except that requests may be empty,
+// since API server doesn't allow resources of ephemeral containers to be set
+func validateEphemeralContainer(ephemeral corev1.EphemeralContainer, limit LimitResource, podResources *corev1.ResourceRequirements) []violation {
+	container := corev1.Container(ephemeral.EphemeralContainerCommon)
+
+	var violations []violation
+	for _, v := range validateContainer("ephemeral container", container, limit.ForImage(container.Image), podResources) {
+		if _, ok := container.Resources.Requests[v.resource]; !ok && (v.reason == reasonMissingRequest || v.reason == reasonRequestBelowMinimum) {
+			continue
+		}
+		violations = append(violations, v)
+	}
+
+	return violations
+}
+
+// validateCPULimitPolicy returns violation if container CPU limit presence doesn't match the policy
+func validateCPULimitPolicy(container corev1.Container, policy string) []violation {
+	_, hasLimit := container.Resources.Limits[corev1.ResourceCPU]
+	switch {
+	case policy == cpuLimitRequired && !hasLimit:
+		return []violation{{
+			resource: corev1.ResourceCPU,
+			reason:   reasonCPULimitPolicy,
+			message:  fmt.Sprintf("error container %s limits.CPU is empty, cpu limit is required", container.Name),
+		}}
+	case policy == cpuLimitForbidden && hasLimit:
+		return []violation{{
+			resource: corev1.ResourceCPU,
+			reason:   reasonCPULimitPolicy,
+			message:  fmt.Sprintf("error container %s limits.CPU is set, cpu limit is forbidden", container.Name),
+		}}
+	}
+
 	return nil
 }
+
+// sortedResourceNames returns names of limited resources in stable order
+func sortedResourceNames(limits map[corev1.ResourceName]*resource.Quantity) []corev1.ResourceName {
+	names := make([]corev1.ResourceName, 0, len(limits))
+	for name := range limits {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+
+	return names
+}
+
+// negativeQuantities returns violations for negative requests and limits of container,
+// they have no resource, so that severity can't turn them into warnings
+func negativeQuantities(containerType string, container corev1.Container) []violation {
+	var violations []violation
+	for _, r := range []struct {
+		field     string
+		resources corev1.ResourceList
+	}{
+		{field: "requests", resources: container.Resources.Requests},
+		{field: "limits", resources: container.Resources.Limits},
+	} {
+		names := make([]string, 0, len(r.resources))
+		for name := range r.resources {
+			names = append(names, string(name))
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if q := r.resources[corev1.ResourceName(name)]; q.Sign() < 0 {
+				violations = append(violations, violation{
+					reason:  reasonNegativeQuantity,
+					message: fmt.Sprintf("error %s %s %s.%s: %s must not be negative", containerType, container.Name, r.field, name, formatQuantity(corev1.ResourceName(name), q)),
+				})
+			}
+		}
+	}
+
+	return violations
+}
+
+// requireMemoryLimit returns violation if container has no memory limit
+func requireMemoryLimit(containerType string, container corev1.Container) []violation {
+	if _, ok := container.Resources.Limits[corev1.ResourceMemory]; ok {
+		return nil
+	}
+
+	return []violation{{
+		resource: corev1.ResourceMemory,
+		reason:   reasonMemoryLimitRequired,
+		message:  fmt.Sprintf("error %s %s limits.Memory is empty, memory limit is required", containerType, container.Name),
+	}}
+}
+
+// requireLimits returns violations for CPU and memory limits, which container doesn't set, although their maximum is configured.
+// Pod level limits (spec.resources) satisfy it.
+func requireLimits(containerType string, container corev1.Container, limit LimitResource, podResources *corev1.ResourceRequirements) []violation {
+	var violations []violation
+	for _, c := range []struct {
+		name  corev1.ResourceName
+		field string
+		max   *resource.Quantity
+	}{
+		{name: corev1.ResourceCPU, field: "limits.CPU", max: limit.CPULimit},
+		{name: corev1.ResourceMemory, field: "limits.Memory", max: limit.MemLimit},
+	} {
+		if c.max == nil {
+			continue
+		}
+		if _, ok := container.Resources.Limits[c.name]; ok {
+			continue
+		}
+		if podResources != nil {
+			if _, ok := podResources.Limits[c.name]; ok {
+				continue
+			}
+		}
+
+		violations = append(violations, violation{
+			resource: c.name,
+			reason:   reasonMissingLimit,
+			message:  fmt.Sprintf("error %s %s %s is empty, limit is required, must be at most %s", containerType, container.Name, c.field, formatQuantity(c.name, *c.max)),
+		})
+	}
+
+	return violations
+}
+
+// requireProbes returns violations for probes in limit.RequiredProbes, which container doesn't define
+func requireProbes(container corev1.Container, limit LimitResource) []violation {
+	if contains(limit.ProbeExemptContainers, container.Name) {
+		return nil
+	}
+
+	var violations []violation
+	for _, probe := range limit.RequiredProbes {
+		if (probe == probeReadiness && container.ReadinessProbe == nil) || (probe == probeLiveness && container.LivenessProbe == nil) {
+			violations = append(violations, violation{
+				reason:  reasonProbeRequired,
+				message: fmt.Sprintf("error container %s %sProbe is required", container.Name, probe),
+			})
+		}
+	}
+
+	return violations
+}
+
+// hasDiskEmptyDir returns true if pod declares emptyDir volume, which isn't backed by memory
+func hasDiskEmptyDir(podSpec corev1.PodSpec) bool {
+	for _, volume := range podSpec.Volumes {
+		if volume.EmptyDir != nil && volume.EmptyDir.Medium != corev1.StorageMediumMemory {
+			return true
+		}
+	}
+
+	return false
+}
+
+// requireEphemeralStorageLimit returns violation if container has no ephemeral-storage limit
+func requireEphemeralStorageLimit(containerType string, container corev1.Container) []violation {
+	if _, ok := container.Resources.Limits[corev1.ResourceEphemeralStorage]; ok {
+		return nil
+	}
+
+	return []violation{{
+		resource: corev1.ResourceEphemeralStorage,
+		reason:   reasonEphemeralStorageLimitRequired,
+		message:  fmt.Sprintf("error %s %s limits.ephemeral-storage is empty, ephemeral-storage limit is required with emptyDir volumes", containerType, container.Name),
+	}}
+}
+
+// containerResourceCount returns number of distinct resource names in container requests and limits
+func containerResourceCount(container corev1.Container) int {
+	names := make(map[corev1.ResourceName]bool, len(container.Resources.Requests)+len(container.Resources.Limits))
+	for name := range container.Resources.Requests {
+		names[name] = true
+	}
+	for name := range container.Resources.Limits {
+		names[name] = true
+	}
+
+	return len(names)
+}
+
+// limitRequestRatio returns limit divided by request of the resource, only if container limits the resource.
+// Missing or zero request results in +Inf ratio.
+func limitRequestRatio(container corev1.Container, name corev1.ResourceName) (float64, bool) {
+	l, ok := container.Resources.Limits[name]
+	if !ok {
+		return 0, false
+	}
+
+	r := container.Resources.Requests[name]
+	if name == corev1.ResourceCPU {
+		if r.MilliValue() == 0 {
+			return math.Inf(1), true
+		}
+		return float64(l.MilliValue()) / float64(r.MilliValue()), true
+	}
+
+	if r.Value() == 0 {
+		return math.Inf(1), true
+	}
+	return float64(l.Value()) / float64(r.Value()), true
+}
+
+// requestFractionDeviation returns request divided by limit of the resource and true,
+// if request differs from fraction of limit by more than tolerance of limit. Resources without limit are skipped.
+func requestFractionDeviation(container corev1.Container, name corev1.ResourceName, fraction, tolerance float64) (float64, bool) {
+	l, ok := container.Resources.Limits[name]
+	if !ok || l.IsZero() {
+		return 0, false
+	}
+
+	expected := resource.NewMilliQuantity(int64(math.Round(float64(l.MilliValue())*fraction)), l.Format)
+	allowed := resource.NewMilliQuantity(int64(math.Round(float64(l.MilliValue())*tolerance)), l.Format)
+
+	r := container.Resources.Requests[name]
+	deviation := r.DeepCopy()
+	deviation.Sub(*expected)
+	if deviation.Sign() < 0 {
+		deviation.Neg()
+	}
+
+	return float64(r.MilliValue()) / float64(l.MilliValue()), deviation.Cmp(*allowed) > 0
+}
+
+// containerBurst returns limit minus request of the resource, only if container limits the resource
+func containerBurst(container corev1.Container, name corev1.ResourceName) (resource.Quantity, bool) {
+	burst, ok := container.Resources.Limits[name]
+	if !ok {
+		return resource.Quantity{}, false
+	}
+
+	burst = burst.DeepCopy()
+	if request, ok := container.Resources.Requests[name]; ok {
+		burst.Sub(request)
+	}
+
+	return burst, true
+}
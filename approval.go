@@ -0,0 +1,98 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// approvalAnnotation carries a signed token, which raises limits of the workload
+const approvalAnnotation = "resource-requests-admission-controller.devopy.io/approval"
+
+var approvalCounter = promauto.NewCounterVec(prometheus.CounterOpts{Name: "approval_tokens_total"}, []string{"valid"})
+
+// approval is the payload of approval token
+type approval struct {
+	Namespace string `yaml:"namespace"`
+	Name      string `yaml:"name"`
+	// Expires is unix time after which token is not valid, 0 means token never expires
+	Expires int64 `yaml:"expires"`
+	Limit   Limit `yaml:"limit"`
+}
+
+// verifyApproval verifies token of form base64url(payload).base64url(HMAC-SHA256(secret, base64url(payload)))
+// and returns its payload, if token is valid for the workload
+func verifyApproval(token string, secret []byte, nn NameNamespace, now time.Time) (*approval, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, errors.New("token must consist of payload and signature")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to decode signature")
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(parts[0]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, errors.New("signature mismatch")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to decode payload")
+	}
+
+	var a approval
+	if err := yaml.UnmarshalStrict(payload, &a); err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal payload")
+	}
+
+	if a.Namespace != nn.Namespace || a.Name != nn.Name {
+		return nil, errors.Errorf("token is issued for %s/%s", a.Namespace, a.Name)
+	}
+
+	if a.Expires != 0 && now.After(time.Unix(a.Expires, 0)) {
+		return nil, errors.Errorf("token expired at %s", time.Unix(a.Expires, 0).UTC())
+	}
+
+	return &a, nil
+}
+
+// approvedLimit returns limit raised by workload's approval token, if token is missing or invalid limit is returned unchanged
+func (rra *ResourceRequestsAdmission) approvedLimit(w *workload, namespace string, limit LimitResource) LimitResource {
+	token, ok := w.annotations[approvalAnnotation]
+	if !ok || len(rra.opts.ApprovalSecret) == 0 {
+		return limit
+	}
+
+	a, err := verifyApproval(token, rra.opts.ApprovalSecret, NameNamespace{Name: w.name, Namespace: namespace}, rra.now())
+	if err != nil {
+		approvalCounter.WithLabelValues("false").Inc()
+		log.WithError(err).Warnf("ignoring invalid approval token of %s name: %s, namespace: %s", strings.ToLower(w.kind), w.name, namespace)
+		return limit
+	}
+
+	approved, err := convertLimitsToResources(a.Limit, limit)
+	if err != nil {
+		approvalCounter.WithLabelValues("false").Inc()
+		log.WithError(err).Warnf("ignoring invalid approval token limit of %s name: %s, namespace: %s", strings.ToLower(w.kind), w.name, namespace)
+		return limit
+	}
+	approved.ForbidEphemeralContainers = limit.ForbidEphemeralContainers
+	approved.Uncapped = approved.Uncapped || limit.Uncapped
+
+	approvalCounter.WithLabelValues("true").Inc()
+	log.Infof("applying approved limit to %s name: %s, namespace: %s", strings.ToLower(w.kind), w.name, namespace)
+
+	return *approved
+}
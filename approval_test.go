@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func signApproval(secret []byte, payload string) string {
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(encoded))
+
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyApproval(t *testing.T) {
+	secret := []byte("secret")
+	nn := NameNamespace{Name: "web", Namespace: "default"}
+	now := time.Unix(1000, 0)
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{
+			name:  "valid",
+			token: signApproval(secret, `{"namespace": "default", "name": "web", "expires": 2000, "limit": {"maxCPULimit": "4"}}`),
+			valid: true,
+		},
+		{
+			name:  "never expires",
+			token: signApproval(secret, `{"namespace": "default", "name": "web", "limit": {"maxCPULimit": "4"}}`),
+			valid: true,
+		},
+		{
+			name:  "other secret",
+			token: signApproval([]byte("other"), `{"namespace": "default", "name": "web", "limit": {"maxCPULimit": "4"}}`),
+		},
+		{
+			name:  "other workload",
+			token: signApproval(secret, `{"namespace": "default", "name": "api", "limit": {"maxCPULimit": "4"}}`),
+		},
+		{
+			name:  "expired",
+			token: signApproval(secret, `{"namespace": "default", "name": "web", "expires": 500, "limit": {"maxCPULimit": "4"}}`),
+		},
+		{
+			name:  "unknown field",
+			token: signApproval(secret, `{"namespace": "default", "name": "web", "limit": {"cpu": "4"}}`),
+		},
+		{
+			name:  "malformed",
+			token: "not-a-token",
+		},
+	}
+
+	for _, tt := range tests {
+		a, err := verifyApproval(tt.token, secret, nn, now)
+		if !tt.valid {
+			assert.Error(t, err, tt.name)
+			continue
+		}
+
+		if assert.NoError(t, err, tt.name) {
+			assert.Equal(t, "4", a.Limit.CPULimit, tt.name)
+		}
+	}
+}
+
+func TestHandleAdmissionApproval(t *testing.T) {
+	secret := []byte("secret")
+	valid := signApproval(secret, `{"namespace": "default", "name": "web", "limit": {"maxCPULimit": "4"}}`)
+	forged := signApproval([]byte("forged"), `{"namespace": "default", "name": "web", "limit": {"maxCPULimit": "4"}}`)
+
+	deployment := func(objectAnnotations, templateAnnotations string) []byte {
+		return []byte(`{"metadata": {"name": "web", "annotations": ` + objectAnnotations + `},
+			"spec": {"template": {"metadata": {"annotations": ` + templateAnnotations + `},
+			"spec": {"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"cpu": 3}}}]}}}}`)
+	}
+	annotation := func(token string) string {
+		return `{"` + approvalAnnotation + `": "` + token + `"}`
+	}
+
+	tests := []struct {
+		name    string
+		secret  []byte
+		object  []byte
+		message string
+	}{
+		{
+			name:   "valid token raises limit",
+			secret: secret,
+			object: deployment(`{}`, annotation(valid)),
+		},
+		{
+			name:    "invalid token keeps limit",
+			secret:  secret,
+			object:  deployment(`{}`, annotation(forged)),
+			message: "error container app limits.CPU: 3 > 2",
+		},
+		{
+			name:    "object annotation is ignored",
+			secret:  secret,
+			object:  deployment(annotation(valid), `{}`),
+			message: "error container app limits.CPU: 3 > 2",
+		},
+		{
+			name:    "approvals disabled",
+			object:  deployment(`{}`, annotation(valid)),
+			message: "error container app limits.CPU: 3 > 2",
+		},
+	}
+
+	cpu := resource.MustParse("2")
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{
+			conf: &MockConfiger{cpu: &cpu},
+			opts: Options{ApprovalSecret: tt.secret},
+			now:  time.Now,
+		}
+
+		resp, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+			UID:       "e911857d-c318-11e8-bbad-025000000001",
+			Kind:      metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+			Namespace: "default",
+			Operation: v1beta1.Create,
+			Object:    runtime.RawExtension{Raw: tt.object},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.message == "", resp.Allowed, tt.name)
+		if tt.message != "" {
+			assert.Equal(t, tt.message, resp.Result.Message, tt.name)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"crypto/tls"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	certReloadCounter       = promauto.NewCounter(prometheus.CounterOpts{Name: "cert_reload_total"})
+	certReloadErrorsCounter = promauto.NewCounter(prometheus.CounterOpts{Name: "cert_reload_errors_total"})
+)
+
+// CertReloader serves TLS certificate, which is reloaded when cert or key file changes, e.g. when cert-manager rotates it
+type CertReloader struct {
+	certFile       string
+	keyFile        string
+	reloadDebounce time.Duration
+	w              *fsnotify.Watcher
+
+	cert *tls.Certificate
+	m    sync.RWMutex
+}
+
+// NewCertReloader loads certificate and starts watching its files
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	// directories are watched, since mounted secrets are updated by swapping symlinks, which drops watches of the files
+	for _, dir := range []string{filepath.Dir(certFile), filepath.Dir(keyFile)} {
+		if err := w.Add(dir); err != nil {
+			w.Close()
+			return nil, errors.Wrapf(err, "unable to watch %s", dir)
+		}
+	}
+
+	cr := &CertReloader{
+		certFile:       certFile,
+		keyFile:        keyFile,
+		reloadDebounce: defaultReloadDebounce,
+		w:              w,
+	}
+
+	if err := cr.load(); err != nil {
+		w.Close()
+		return nil, err
+	}
+
+	go cr.Watch()
+
+	return cr, nil
+}
+
+func (cr *CertReloader) load() error {
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return errors.Wrap(err, "unable to load certificates")
+	}
+
+	cr.m.Lock()
+	cr.cert = &cert
+	cr.m.Unlock()
+
+	return nil
+}
+
+// GetCertificate returns the latest loaded certificate, it's tls.Config.GetCertificate callback
+func (cr *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.m.RLock()
+	defer cr.m.RUnlock()
+
+	return cr.cert, nil
+}
+
+// Watch reloads certificate on changes of the watched directories.
+// Reloads are debounced, so that cert and key written one after another are loaded together.
+// If reload fails, the previous certificate is served.
+func (cr *CertReloader) Watch() {
+	debounce := time.NewTimer(cr.reloadDebounce)
+	debounce.Stop()
+	defer debounce.Stop()
+
+	for {
+		select {
+		case _, ok := <-cr.w.Events:
+			if !ok {
+				return
+			}
+
+			debounce.Reset(cr.reloadDebounce)
+			continue
+		case err, ok := <-cr.w.Errors:
+			if !ok {
+				return
+			}
+			if err != nil {
+				log.WithError(err).Error("cert watch error")
+			}
+			continue
+		case <-debounce.C:
+		}
+
+		if err := cr.load(); err != nil {
+			certReloadErrorsCounter.Inc()
+			log.WithError(err).Error("cert reload error")
+			continue
+		}
+
+		certReloadCounter.Inc()
+		log.Info("reloaded certificates")
+	}
+}
+
+// Close stops the watching
+func (cr *CertReloader) Close() error {
+	return cr.w.Close()
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	resourcev1beta1 "k8s.io/api/resource/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DeviceClassGetter returns device classes requested by pod resource claim
+type DeviceClassGetter interface {
+	GetDeviceClasses(namespace string, claim corev1.PodResourceClaim) ([]string, error)
+}
+
+// ClaimLookup fetches ResourceClaims and ResourceClaimTemplates referenced by pods
+type ClaimLookup struct {
+	client      kubernetes.Interface
+	integration *Integration
+}
+
+// NewClaimLookup creates new ClaimLookup
+func NewClaimLookup(client kubernetes.Interface, integration *Integration) *ClaimLookup {
+	return &ClaimLookup{
+		client:      client,
+		integration: integration,
+	}
+}
+
+// GetDeviceClasses returns device classes of all device requests of the referenced claim or claim template
+func (cl *ClaimLookup) GetDeviceClasses(namespace string, claim corev1.PodResourceClaim) ([]string, error) {
+	var spec resourcev1beta1.ResourceClaimSpec
+	switch {
+	case claim.ResourceClaimName != nil:
+		var rc *resourcev1beta1.ResourceClaim
+		err := cl.integration.Do(func(ctx context.Context) error {
+			var err error
+			rc, err = cl.client.ResourceV1beta1().ResourceClaims(namespace).Get(ctx, *claim.ResourceClaimName, metav1.GetOptions{})
+			return err
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to get resourceclaim %s/%s", namespace, *claim.ResourceClaimName)
+		}
+		spec = rc.Spec
+	case claim.ResourceClaimTemplateName != nil:
+		var rct *resourcev1beta1.ResourceClaimTemplate
+		err := cl.integration.Do(func(ctx context.Context) error {
+			var err error
+			rct, err = cl.client.ResourceV1beta1().ResourceClaimTemplates(namespace).Get(ctx, *claim.ResourceClaimTemplateName, metav1.GetOptions{})
+			return err
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to get resourceclaimtemplate %s/%s", namespace, *claim.ResourceClaimTemplateName)
+		}
+		spec = rct.Spec.Spec
+	default:
+		return nil, nil
+	}
+
+	classes := make([]string, 0, len(spec.Devices.Requests))
+	for _, request := range spec.Devices.Requests {
+		classes = append(classes, request.DeviceClassName)
+	}
+
+	return classes, nil
+}
+
+// validateResourceClaims denies pods with too many resource claims or claims of device classes, which are not allowed.
+// Device classes are checked only if claim lookup is enabled, if lookup fails the claim is allowed.
+func (rra *ResourceRequestsAdmission) validateResourceClaims(req *v1beta1.AdmissionRequest, podSpec corev1.PodSpec, limit LimitResource) *v1beta1.AdmissionResponse {
+	deny := func(reason denialReason, message string) *v1beta1.AdmissionResponse {
+		return &v1beta1.AdmissionResponse{
+			UID:     req.UID,
+			Allowed: false,
+			Result: &metav1.Status{
+				Message: message,
+			},
+			AuditAnnotations: reasonAnnotations(reason),
+		}
+	}
+
+	if limit.MaxResourceClaims != nil && len(podSpec.ResourceClaims) > *limit.MaxResourceClaims {
+		return deny(reasonTooManyResourceClaims, fmt.Sprintf("error pod resourceClaims: %d > %d", len(podSpec.ResourceClaims), *limit.MaxResourceClaims))
+	}
+
+	if limit.AllowedDeviceClasses == nil || rra.opts.Claims == nil {
+		return nil
+	}
+
+	allowed := make(map[string]bool, len(limit.AllowedDeviceClasses))
+	for _, class := range limit.AllowedDeviceClasses {
+		allowed[class] = true
+	}
+
+	for _, claim := range podSpec.ResourceClaims {
+		classes, err := rra.opts.Claims.GetDeviceClasses(req.Namespace, claim)
+		if err != nil {
+			log.WithError(err).Errorf("unable to get device classes of resource claim %s", claim.Name)
+			continue
+		}
+
+		for _, class := range classes {
+			if !allowed[class] {
+				return deny(reasonDeviceClassNotAllowed, fmt.Sprintf("error pod resourceClaim %s device class %s is not allowed", claim.Name, class))
+			}
+		}
+	}
+
+	return nil
+}
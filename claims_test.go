@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	resourcev1beta1 "k8s.io/api/resource/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestClaimLookup(t *testing.T) {
+	client := fake.NewSimpleClientset(&resourcev1beta1.ResourceClaimTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "gpu", Namespace: "default"},
+		Spec: resourcev1beta1.ResourceClaimTemplateSpec{
+			Spec: resourcev1beta1.ResourceClaimSpec{
+				Devices: resourcev1beta1.DeviceClaim{
+					Requests: []resourcev1beta1.DeviceRequest{{Name: "gpu", DeviceClassName: "gpu.example.com"}},
+				},
+			},
+		},
+	})
+	cl := NewClaimLookup(client, NewIntegration("test_claims", DefaultIntegrationConfig()))
+
+	template := "gpu"
+	classes, err := cl.GetDeviceClasses("default", corev1.PodResourceClaim{Name: "gpu", ResourceClaimTemplateName: &template})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"gpu.example.com"}, classes)
+
+	claim := "missing"
+	_, err = cl.GetDeviceClasses("default", corev1.PodResourceClaim{Name: "gpu", ResourceClaimName: &claim})
+	assert.Error(t, err)
+}
+
+type mockClaims map[string][]string
+
+func (mc mockClaims) GetDeviceClasses(namespace string, claim corev1.PodResourceClaim) ([]string, error) {
+	return mc[claim.Name], nil
+}
+
+func TestHandleAdmissionResourceClaims(t *testing.T) {
+	pod := func(claims string) []byte {
+		return []byte(`{"metadata": {"name": "test"}, "spec": {"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}}}],
+			"resourceClaims": [` + claims + `]}}`)
+	}
+	gpu := `{"name": "gpu", "resourceClaimTemplateName": "gpu"}`
+	fpga := `{"name": "fpga", "resourceClaimTemplateName": "fpga"}`
+
+	maxClaims := 1
+	tests := []struct {
+		name    string
+		conf    *MockConfiger
+		object  []byte
+		message string
+	}{
+		{
+			name:   "within claim count",
+			conf:   &MockConfiger{maxClaims: &maxClaims},
+			object: pod(gpu),
+		},
+		{
+			name:    "claim count exceeded",
+			conf:    &MockConfiger{maxClaims: &maxClaims},
+			object:  pod(gpu + "," + fpga),
+			message: "error pod resourceClaims: 2 > 1",
+		},
+		{
+			name:   "allowed device class",
+			conf:   &MockConfiger{classes: []string{"gpu.example.com"}},
+			object: pod(gpu),
+		},
+		{
+			name:    "device class not allowed",
+			conf:    &MockConfiger{classes: []string{"gpu.example.com"}},
+			object:  pod(gpu + "," + fpga),
+			message: "error pod resourceClaim fpga device class fpga.example.com is not allowed",
+		},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{
+			conf: tt.conf,
+			opts: Options{Claims: mockClaims{"gpu": {"gpu.example.com"}, "fpga": {"fpga.example.com"}}},
+		}
+
+		resp, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+			UID:       "e911857d-c318-11e8-bbad-025000000001",
+			Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+			Namespace: "default",
+			Operation: v1beta1.Create,
+			Object:    runtime.RawExtension{Raw: tt.object},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.message == "", resp.Allowed, tt.name)
+		if tt.message != "" {
+			assert.Equal(t, tt.message, resp.Result.Message, tt.name)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// CapacityGetter returns allocatable resources of the whole cluster
+type CapacityGetter interface {
+	GetAllocatable() (corev1.ResourceList, error)
+}
+
+// NodeLister sums allocatable resources of nodes from informer cache
+type NodeLister struct {
+	factory informers.SharedInformerFactory
+	lister  corelisters.NodeLister
+	synced  cache.InformerSynced
+}
+
+// NewNodeLister creates new NodeLister, Start must be called before use
+func NewNodeLister(client kubernetes.Interface, resync time.Duration) *NodeLister {
+	factory := informers.NewSharedInformerFactory(client, resync)
+	informer := factory.Core().V1().Nodes()
+
+	return &NodeLister{
+		factory: factory,
+		lister:  informer.Lister(),
+		synced:  informer.Informer().HasSynced,
+	}
+}
+
+// Start starts informer and waits for its cache to sync
+func (nl *NodeLister) Start(stop <-chan struct{}) error {
+	nl.factory.Start(stop)
+	if !cache.WaitForCacheSync(stop, nl.synced) {
+		return errors.New("unable to sync nodes cache")
+	}
+
+	return nil
+}
+
+// GetAllocatable returns allocatable resources summed across all nodes
+func (nl *NodeLister) GetAllocatable() (corev1.ResourceList, error) {
+	nodes, err := nl.lister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	allocatable := corev1.ResourceList{}
+	for _, node := range nodes {
+		addResources(allocatable, node.Status.Allocatable, 1)
+	}
+
+	return allocatable, nil
+}
+
+// clusterResources are resources, whose workload limits are compared to cluster allocatable
+var clusterResources = []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory}
+
+// clusterCapacityViolation returns message of the first resource, whose workload limits exceed percent of cluster allocatable
+func clusterCapacityViolation(allocatable, limits corev1.ResourceList, percent int64) (string, bool) {
+	for _, name := range clusterResources {
+		total, ok := allocatable[name]
+		if !ok {
+			continue
+		}
+
+		usage := limits[name]
+		max := resource.NewMilliQuantity(total.MilliValue()*percent/100, total.Format)
+		if usage.Cmp(*max) > 0 {
+			return fmt.Sprintf("error workload limits.%s: %s, %d%% of cluster allocatable %s", name, formatComparison(name, usage, ">", *max), percent, formatQuantity(name, total)), true
+		}
+	}
+
+	return "", false
+}
+
+// validateClusterCapacity denies workload, whose limits of all replicas exceed MaxClusterPercent of cluster allocatable.
+// If nodes can't be listed, request is allowed.
+func (rra *ResourceRequestsAdmission) validateClusterCapacity(req *v1beta1.AdmissionRequest, w *workload) *v1beta1.AdmissionResponse {
+	allocatable, err := rra.opts.Capacity.GetAllocatable()
+	if err != nil {
+		log.WithError(err).Error("unable to list nodes")
+		return nil
+	}
+
+	_, limits := workloadUsage(w.podSpec, w.replicas)
+	message, ok := clusterCapacityViolation(allocatable, limits, int64(rra.opts.MaxClusterPercent))
+	if !ok {
+		return nil
+	}
+
+	return &v1beta1.AdmissionResponse{
+		UID:     req.UID,
+		Allowed: false,
+		Result: &metav1.Status{
+			Message: message,
+		},
+		AuditAnnotations: reasonAnnotations(reasonClusterCapacityExceeded),
+	}
+}
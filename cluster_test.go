@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func testNode(name, cpu, mem string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{
+			Allocatable: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse(cpu),
+				corev1.ResourceMemory: resource.MustParse(mem),
+			},
+		},
+	}
+}
+
+func TestNodeLister(t *testing.T) {
+	client := fake.NewSimpleClientset(testNode("a", "4", "16Gi"), testNode("b", "3500m", "8Gi"))
+
+	stop := make(chan struct{})
+	defer close(stop)
+
+	nl := NewNodeLister(client, time.Minute)
+	if err := nl.Start(stop); err != nil {
+		t.Fatal(err)
+	}
+
+	allocatable, err := nl.GetAllocatable()
+	assert.NoError(t, err)
+	cpu := allocatable[corev1.ResourceCPU]
+	mem := allocatable[corev1.ResourceMemory]
+	assert.Equal(t, int64(7500), cpu.MilliValue())
+	assert.Equal(t, int64(24*1024*1024*1024), mem.Value())
+}
+
+type mockCapacity corev1.ResourceList
+
+func (mc mockCapacity) GetAllocatable() (corev1.ResourceList, error) {
+	if mc == nil {
+		return nil, errors.New("nodes are not synced")
+	}
+
+	return corev1.ResourceList(mc), nil
+}
+
+func TestHandleAdmissionClusterPercent(t *testing.T) {
+	capacity := mockCapacity{
+		corev1.ResourceCPU:    resource.MustParse("100"),
+		corev1.ResourceMemory: resource.MustParse("400Gi"),
+	}
+	deployment := func(replicas, cpu, mem string) string {
+		return `{"metadata": {"name": "web"}, "spec": {"replicas": ` + replicas + `, "template": {"spec": {"containers": [{"name": "app",
+			"resources": {"requests": {"cpu": "100m", "memory": "1Gi"}, "limits": {"cpu": "` + cpu + `", "memory": "` + mem + `"}}}]}}}}`
+	}
+
+	tests := []struct {
+		name     string
+		object   string
+		percent  int
+		capacity mockCapacity
+		message  string
+	}{
+		{
+			name:     "within cluster percentage",
+			object:   deployment("5", "2", "8Gi"),
+			percent:  10,
+			capacity: capacity,
+		},
+		{
+			name:     "limits.cpu over cluster percentage",
+			object:   deployment("6", "2", "8Gi"),
+			percent:  10,
+			capacity: capacity,
+			message:  "error workload limits.cpu: 12 > 10, 10% of cluster allocatable 100",
+		},
+		{
+			name:     "limits.memory over cluster percentage",
+			object:   deployment("5", "1", "10Gi"),
+			percent:  10,
+			capacity: capacity,
+			message:  "error workload limits.memory: 50Gi > 40Gi, 10% of cluster allocatable 400Gi",
+		},
+		{
+			name:    "nodes can't be listed",
+			object:  deployment("50", "2", "8Gi"),
+			percent: 10,
+		},
+		{
+			name:     "disabled",
+			object:   deployment("50", "2", "8Gi"),
+			capacity: capacity,
+		},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{
+			conf: &MockConfiger{},
+			opts: Options{
+				MaxClusterPercent: tt.percent,
+				Capacity:          tt.capacity,
+			},
+		}
+
+		resp, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+			UID:       "e911857d-c318-11e8-bbad-025000000001",
+			Kind:      metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+			Namespace: "default",
+			Operation: v1beta1.Create,
+			Object:    runtime.RawExtension{Raw: []byte(tt.object)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.message == "", resp.Allowed, tt.name)
+		if tt.message != "" {
+			assert.Equal(t, tt.message, resp.Result.Message, tt.name)
+			assert.Equal(t, string(reasonClusterCapacityExceeded), resp.AuditAnnotations[denialReasonAnnotation], tt.name)
+		}
+	}
+}
+
+func TestHandleAdmissionScaleClusterPercent(t *testing.T) {
+	rra := &ResourceRequestsAdmission{
+		conf: &MockConfiger{},
+		opts: Options{
+			MaxClusterPercent: 10,
+			Capacity:          mockCapacity{corev1.ResourceCPU: resource.MustParse("100")},
+			Scales: mockScales{
+				"web": &corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{Containers: []corev1.Container{{
+						Name: "app",
+						Resources: corev1.ResourceRequirements{
+							Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+						},
+					}}},
+				},
+			},
+		},
+	}
+
+	for replicas, message := range map[string]string{
+		"5": "",
+		"6": "error workload limits.cpu: 12 > 10, 10% of cluster allocatable 100",
+	} {
+		resp, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+			UID:         "e911857d-c318-11e8-bbad-025000000001",
+			Kind:        metav1.GroupVersionKind{Group: "autoscaling", Version: "v1", Kind: "Scale"},
+			Resource:    metav1.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"},
+			SubResource: scaleSubResource,
+			Name:        "web",
+			Namespace:   "default",
+			Operation:   v1beta1.Update,
+			Object:      runtime.RawExtension{Raw: []byte(`{"apiVersion": "autoscaling/v1", "kind": "Scale", "metadata": {"name": "web"}, "spec": {"replicas": ` + replicas + `}}`)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, message == "", resp.Allowed, replicas)
+		if message != "" {
+			assert.Equal(t, message, resp.Result.Message, replicas)
+		}
+	}
+}
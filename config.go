@@ -2,6 +2,9 @@ package main
 
 import (
 	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"sync"
 	"time"
 
@@ -11,18 +14,25 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	log "github.com/sirupsen/logrus"
 	yaml "gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	k8syaml "sigs.k8s.io/yaml"
 )
 
 var (
 	reloadCounter       = promauto.NewCounter(prometheus.CounterOpts{Name: "reload_total"})
 	reloadErrorsCounter = promauto.NewCounter(prometheus.CounterOpts{Name: "reload_errors_total"})
+	// configLoadedGauge is 1 if the last config load succeeded, 0 if the config in use is stale, since reload failed.
+	// Readiness doesn't depend on it, since previous config is still served.
+	configLoadedGauge = promauto.NewGauge(prometheus.GaugeOpts{Name: "config_loaded"})
 )
 
 // NameNamespace name + namespace combination, strings might be empty
 type NameNamespace struct {
 	Name      string `json:"name" yaml:"name"`
 	Namespace string `json:"namespace" yaml:"namespace"`
+	// NameRegex matches names in customNames keys instead of Name, it's anchored to the whole name
+	NameRegex string `json:"nameRegex,omitempty" yaml:"nameRegex,omitempty"`
 }
 
 // String nicely formats name and namespace
@@ -30,379 +40,2244 @@ func (nn NameNamespace) String() string {
 	return "Name: " + nn.Name + ", " + nn.Namespace
 }
 
+const (
+	precedenceMostSpecific    = "mostSpecific"
+	precedenceMostRestrictive = "mostRestrictive"
+)
+
+const (
+	severityWarn = "warn"
+	severityDeny = "deny"
+)
+
+const (
+	probeReadiness = "readiness"
+	probeLiveness  = "liveness"
+)
+
+const (
+	zeroRequestSkip = "skip"
+	zeroRequestDeny = "deny"
+)
+
+const defaultRequestFractionTolerance = 0.01
+
+const (
+	modeEnforce = "enforce"
+	modeWarn    = "warn"
+)
+
+const (
+	cpuLimitRequired  = "required"
+	cpuLimitForbidden = "forbidden"
+	cpuLimitOptional  = "optional"
+)
+
+const (
+	swapAllow  = "allow"
+	swapForbid = "forbid"
+)
+
+const (
+	topologySpreadDeny = "deny"
+	topologySpreadWarn = "warn"
+)
+
+const (
+	defaultActionAllow = "allow"
+	defaultActionDeny  = "deny"
+)
+
 // Limit describes limit configuration in yaml
 type Limit struct {
 	CPULimit   string `yaml:"maxCPULimit" json:"maxCPULimit"`
 	MemLimit   string `yaml:"maxMemLimit" json:"maxMemLimit"`
 	CPURequest string `yaml:"maxCPURequest" json:"maxCPURequest"`
 	MemRequest string `yaml:"maxMemRequest" json:"maxMemRequest"`
-	PVCSize    string `yaml:"maxPVCSize" json:"maxPVCSize"`
-	Unlimited  bool   `yaml:"unlimited" json:"unlimited"`
+	// ExtendedResources maps resource name (nvidia.com/gpu, hugepages-2Mi) to the highest container limit
+	ExtendedResources map[string]string `yaml:"maxExtendedResources" json:"maxExtendedResources"`
+	// EphemeralStorageLimit is the highest container ephemeral-storage limit
+	EphemeralStorageLimit string `yaml:"maxEphemeralStorageLimit" json:"maxEphemeralStorageLimit"`
+	// PodMemLimit is the highest memory limit of pod, pod level limit or sum of container limits
+	PodMemLimit string `yaml:"maxPodMemLimit" json:"maxPodMemLimit"`
+	// GuaranteedCPU and GuaranteedMem are the highest pod requests of Guaranteed QoS pods, which reserve the full limit
+	GuaranteedCPU string `yaml:"maxGuaranteedCPU" json:"maxGuaranteedCPU"`
+	GuaranteedMem string `yaml:"maxGuaranteedMem" json:"maxGuaranteedMem"`
+	// CountMemoryEmptyDirs adds sizeLimit of memory backed emptyDir volumes to the pod memory, it's inherited from top level
+	CountMemoryEmptyDirs *bool `yaml:"countMemoryEmptyDirs" json:"countMemoryEmptyDirs"`
+	// RequireLimits denies containers without CPU or memory limit, if MaxCPULimit or MaxMemLimit is configured,
+	// it's inherited from top level
+	RequireLimits *bool `yaml:"requireLimits" json:"requireLimits"`
+	// MinCPURequest and MinMemRequest are the lowest container requests, requests must be set even without them
+	MinCPURequest string `yaml:"minCPURequest" json:"minCPURequest"`
+	MinMemRequest string `yaml:"minMemRequest" json:"minMemRequest"`
+	PVCSize       string `yaml:"maxPVCSize" json:"maxPVCSize"`
+	MinPVCSize    string `yaml:"minPVCSize" json:"minPVCSize"`
+	// PVCSizePerStorageClass maps storage class name to PVC size limit, which replaces maxPVCSize for PVCs of the class
+	PVCSizePerStorageClass map[string]string `yaml:"maxPVCSizePerStorageClass" json:"maxPVCSizePerStorageClass"`
+	// CPUBurst and MemBurst limit difference between container limit and request
+	CPUBurst string `yaml:"maxCPUBurst" json:"maxCPUBurst"`
+	MemBurst string `yaml:"maxMemBurst" json:"maxMemBurst"`
+	// NamespacePVCSize is total size of PVCs in namespace, only namespace and top level declarations apply
+	NamespacePVCSize string `yaml:"maxNamespacePVCSize" json:"maxNamespacePVCSize"`
+	// NamespaceGPUs is total of GPU requests in namespace, only namespace and top level declarations apply
+	NamespaceGPUs string `yaml:"maxNamespaceGPUs" json:"maxNamespaceGPUs"`
+	// Unlimited disables pod resource limits, PVC limits are disabled by UnlimitedPVC
+	Unlimited    bool `yaml:"unlimited" json:"unlimited"`
+	UnlimitedPVC bool `yaml:"unlimitedPVC" json:"unlimitedPVC"`
+	// Uncapped waives pod resource ceilings, but unlike Unlimited keeps other checks, e.g. requests must be set
+	Uncapped bool `yaml:"uncapped" json:"uncapped"`
+	// Severity maps resource name (cpu, memory) to warn or deny, default is deny
+	Severity map[string]string `yaml:"severity" json:"severity"`
+	// PairedResources lists resources, which must have both request and limit set or neither of them
+	PairedResources []string `yaml:"pairedResources" json:"pairedResources"`
+	// Sidecar limits native sidecars (init containers with restartPolicy Always)
+	Sidecar *Limit `yaml:"sidecar" json:"sidecar"`
+	// Mode is enforce (default) or warn, which allows pods violating the limits with warnings, e.g. during rollout
+	Mode string `yaml:"mode" json:"mode"`
+	// DefaultAction is allow or deny of objects, whose kind isn't handled, it overrides --default-action
+	DefaultAction string `yaml:"defaultAction" json:"defaultAction"`
+	// Mutate injects limits.cpu and limits.memory set to the maximum into containers missing them, it's inherited from top level
+	Mutate *bool `yaml:"mutate" json:"mutate"`
+	// CPULimitPolicy is required, forbidden (e.g. to avoid throttling) or optional (default)
+	CPULimitPolicy string `yaml:"cpuLimitPolicy" json:"cpuLimitPolicy"`
+	// SwapPolicy is allow (default) or forbid, which denies containers able to use swap on LimitedSwap nodes
+	SwapPolicy string `yaml:"swapPolicy" json:"swapPolicy"`
+	// MaxLimitRequestRatio limits container limit divided by request of CPU and memory, e.g. 4.0
+	MaxLimitRequestRatio *float64 `yaml:"maxLimitRequestRatio" json:"maxLimitRequestRatio"`
+	// ZeroRequestRatio is skip (default) or deny, it applies to limited resources with zero request, whose ratio is infinite
+	ZeroRequestRatio string `yaml:"zeroRequestRatio" json:"zeroRequestRatio"`
+	// RequestFraction requires container requests of CPU and memory to be this fraction of limits, e.g. 0.5
+	RequestFraction *float64 `yaml:"requestFraction" json:"requestFraction"`
+	// RequestFractionTolerance is allowed deviation from RequestFraction as fraction of limit, defaults to 0.01
+	RequestFractionTolerance *float64 `yaml:"requestFractionTolerance" json:"requestFractionTolerance"`
+	// MaxResourceClaims limits number of pod level (DRA) resource claims
+	MaxResourceClaims *int `yaml:"maxResourceClaims" json:"maxResourceClaims"`
+	// MaxContainerResources limits number of distinct resource names in container requests and limits
+	MaxContainerResources *int `yaml:"maxContainerResources" json:"maxContainerResources"`
+	// MaxReplicas limits replicas of Deployments, StatefulSets and ReplicationControllers, missing replicas default to 1
+	MaxReplicas *int `yaml:"maxReplicas" json:"maxReplicas"`
+	// TopologySpreadReplicas requires Deployments, StatefulSets and ReplicationControllers with at least this many replicas
+	// to declare topologySpreadConstraints
+	TopologySpreadReplicas *int `yaml:"topologySpreadReplicas" json:"topologySpreadReplicas"`
+	// TopologySpreadPolicy is deny (default) or warn, which only warns about workloads missing topologySpreadConstraints
+	TopologySpreadPolicy string `yaml:"topologySpreadPolicy" json:"topologySpreadPolicy"`
+	// AllowedDeviceClasses lists device classes, which resource claims may request, requires resource claim lookup
+	AllowedDeviceClasses []string `yaml:"allowedDeviceClasses" json:"allowedDeviceClasses"`
+	// ForbidEphemeralContainers denies pod updates which add ephemeral (debug) containers, it's not inherited from top level
+	ForbidEphemeralContainers bool `yaml:"forbidEphemeralContainers" json:"forbidEphemeralContainers"`
+	// RequiredProbes lists probes (readiness, liveness), which every container must define
+	RequiredProbes []string `yaml:"requiredProbes" json:"requiredProbes"`
+	// ProbeExemptContainers lists container names, e.g. sidecars, which don't need RequiredProbes
+	ProbeExemptContainers []string `yaml:"probeExemptContainers" json:"probeExemptContainers"`
+	// ExemptTolerations lists taint keys, pods tolerating any of them, e.g. dedicated nodes, are not limited
+	ExemptTolerations []string `yaml:"exemptTolerations" json:"exemptTolerations"`
+	// ExemptUsers lists usernames, e.g. service accounts of platform controllers, whose requests are not limited.
+	// Globs are supported, e.g. system:serviceaccount:kube-system:*
+	ExemptUsers []string `yaml:"exemptUsers" json:"exemptUsers"`
+	// ExemptGroups lists groups, whose members' requests are not limited
+	ExemptGroups []string `yaml:"exemptGroups" json:"exemptGroups"`
+	// ImageMemLimits replace memory ceilings of containers by image prefix, first matching prefix wins
+	ImageMemLimits []ImageMemLimit `yaml:"imageMemLimits" json:"imageMemLimits"`
+	// Profiles are named container sizes, workloads naming a profile in profile annotation must match it exactly
+	Profiles map[string]ResourceProfile `yaml:"profiles" json:"profiles"`
+	// RestartPolicies adjust limits of pods by restartPolicy (Always, OnFailure or Never), fields which are not set are taken from this limit
+	RestartPolicies map[string]Limit `yaml:"restartPolicies" json:"restartPolicies"`
+	// WorkloadClasses adjust limits by workload class (job, service or a custom class from the class label),
+	// fields which are not set are taken from this limit
+	WorkloadClasses map[string]Limit `yaml:"workloadClasses" json:"workloadClasses"`
+	// LabelLimits adjust limits of workloads by pod template labels, e.g. track: canary, the first match wins,
+	// fields which are not set are taken from this limit
+	LabelLimits []LabelLimit `yaml:"labelLimits" json:"labelLimits"`
+	// Containers override limits of containers by name, e.g. istio-proxy, fields which are not set are taken from this limit
+	Containers map[string]Limit `yaml:"containers" json:"containers"`
+	// UserNamespace adjusts limits of pods with hostUsers: false, which run in a user namespace,
+	// fields which are not set are taken from this limit
+	UserNamespace *Limit `yaml:"userNamespace" json:"userNamespace"`
+}
+
+// LabelLimit adjusts limits of workloads, whose pod template has all labels of MatchLabels
+type LabelLimit struct {
+	MatchLabels map[string]string `yaml:"matchLabels" json:"matchLabels"`
+	Limit       Limit             `yaml:"limit" json:"limit"`
+}
+
+type labelLimit struct {
+	matchLabels map[string]string
+	limit       LimitResource
+}
+
+// matches returns true if all labels match
+func (ll labelLimit) matches(labels map[string]string) bool {
+	for k, v := range ll.matchLabels {
+		if value, ok := labels[k]; !ok || value != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Config describes Config files structure
+type Config struct {
+	Namespaces                map[string]Limit        `yaml:"customNamespaces" json:"namespaces"`
+	Names                     map[NameNamespace]Limit `yaml:"customNames" json:"names"`
+	MaxCPULimit               string                  `yaml:"maxCPULimit" json:"maxCPULimit"`
+	MaxMemLimit               string                  `yaml:"maxMemLimit" json:"maxMemLimit"`
+	MaxCPURequest             string                  `yaml:"maxCPURequest" json:"maxCPURequest"`
+	MaxMemRequest             string                  `yaml:"maxMemRequest" json:"maxMemRequest"`
+	MaxExtendedResources      map[string]string       `yaml:"maxExtendedResources" json:"maxExtendedResources"`
+	MaxEphemeralStorageLimit  string                  `yaml:"maxEphemeralStorageLimit" json:"maxEphemeralStorageLimit"`
+	MaxPodMemLimit            string                  `yaml:"maxPodMemLimit" json:"maxPodMemLimit"`
+	MaxGuaranteedCPU          string                  `yaml:"maxGuaranteedCPU" json:"maxGuaranteedCPU"`
+	MaxGuaranteedMem          string                  `yaml:"maxGuaranteedMem" json:"maxGuaranteedMem"`
+	CountMemoryEmptyDirs      *bool                   `yaml:"countMemoryEmptyDirs" json:"countMemoryEmptyDirs"`
+	RequireLimits             *bool                   `yaml:"requireLimits" json:"requireLimits"`
+	MinCPURequest             string                  `yaml:"minCPURequest" json:"minCPURequest"`
+	MinMemRequest             string                  `yaml:"minMemRequest" json:"minMemRequest"`
+	MaxPvcSize                string                  `yaml:"maxPVCSize" json:"maxPVCSize"`
+	MinPvcSize                string                  `yaml:"minPVCSize" json:"minPVCSize"`
+	MaxNamespacePvcSize       string                  `yaml:"maxNamespacePVCSize" json:"maxNamespacePVCSize"`
+	MaxPvcSizePerStorageClass map[string]string       `yaml:"maxPVCSizePerStorageClass" json:"maxPVCSizePerStorageClass"`
+	MaxNamespaceGPUs          string                  `yaml:"maxNamespaceGPUs" json:"maxNamespaceGPUs"`
+	MaxCPUBurst               string                  `yaml:"maxCPUBurst" json:"maxCPUBurst"`
+	MaxMemBurst               string                  `yaml:"maxMemBurst" json:"maxMemBurst"`
+	Sidecar                   *Limit                  `yaml:"sidecar" json:"sidecar"`
+	Severity                  map[string]string       `yaml:"severity" json:"severity"`
+	PairedResources           []string                `yaml:"pairedResources" json:"pairedResources"`
+	CPULimitPolicy            string                  `yaml:"cpuLimitPolicy" json:"cpuLimitPolicy"`
+	Mode                      string                  `yaml:"mode" json:"mode"`
+	DefaultAction             string                  `yaml:"defaultAction" json:"defaultAction"`
+	Mutate                    *bool                   `yaml:"mutate" json:"mutate"`
+	MaxLimitRequestRatio      *float64                `yaml:"maxLimitRequestRatio" json:"maxLimitRequestRatio"`
+	ZeroRequestRatio          string                  `yaml:"zeroRequestRatio" json:"zeroRequestRatio"`
+	RequestFraction           *float64                `yaml:"requestFraction" json:"requestFraction"`
+	RequestFractionTolerance  *float64                `yaml:"requestFractionTolerance" json:"requestFractionTolerance"`
+	MaxResourceClaims         *int                    `yaml:"maxResourceClaims" json:"maxResourceClaims"`
+	MaxContainerResources     *int                    `yaml:"maxContainerResources" json:"maxContainerResources"`
+	MaxReplicas               *int                    `yaml:"maxReplicas" json:"maxReplicas"`
+	TopologySpreadReplicas    *int                    `yaml:"topologySpreadReplicas" json:"topologySpreadReplicas"`
+	TopologySpreadPolicy      string                  `yaml:"topologySpreadPolicy" json:"topologySpreadPolicy"`
+	AllowedDeviceClasses      []string                `yaml:"allowedDeviceClasses" json:"allowedDeviceClasses"`
+	RestartPolicies           map[string]Limit        `yaml:"restartPolicies" json:"restartPolicies"`
+	ImageMemLimits            []ImageMemLimit         `yaml:"imageMemLimits" json:"imageMemLimits"`
+	// WorkloadClasses adjust limits by workload class, see Limit.WorkloadClasses
+	WorkloadClasses map[string]Limit `yaml:"workloadClasses" json:"workloadClasses"`
+	// Containers override limits of containers by name, see Limit.Containers
+	Containers map[string]Limit `yaml:"containers" json:"containers"`
+	// UserNamespace adjusts limits of pods in a user namespace, see Limit.UserNamespace
+	UserNamespace *Limit `yaml:"userNamespace" json:"userNamespace"`
+	// Profiles are named container sizes, see Limit.Profiles
+	Profiles map[string]ResourceProfile `yaml:"profiles" json:"profiles"`
+	// SwapPolicy is allow (default) or forbid, see Limit.SwapPolicy
+	SwapPolicy string `yaml:"swapPolicy" json:"swapPolicy"`
+	// ExemptTolerations are taint keys, see Limit.ExemptTolerations
+	ExemptTolerations []string `yaml:"exemptTolerations" json:"exemptTolerations"`
+	// ExemptUsers and ExemptGroups are not limited, see Limit.ExemptUsers
+	ExemptUsers  []string `yaml:"exemptUsers" json:"exemptUsers"`
+	ExemptGroups []string `yaml:"exemptGroups" json:"exemptGroups"`
+	// ResourceTemplateFile is a path to golden container resources block, which is used as a default ceiling.
+	// Relative path is resolved against config file directory.
+	ResourceTemplateFile string `yaml:"resourceTemplateFile" json:"resourceTemplateFile"`
+	// NamespaceSelectors apply to namespaces which are not in customNamespaces, requires namespace lookup
+	NamespaceSelectors []NamespaceSelector `yaml:"namespaceSelectors" json:"namespaceSelectors"`
+	// Groups apply to requests of users in these groups, first matching group wins
+	Groups []GroupLimit `yaml:"customGroups" json:"groups"`
+	// Labels apply to workloads, whose metadata has all labels of MatchLabels, first matching entry wins
+	Labels []LabelLimit `yaml:"customLabels" json:"labels"`
+	// Precedence of customNames over customNamespaces, when both match:
+	// mostSpecific (default) uses customNames, mostRestrictive uses the lower of each limit
+	Precedence string `yaml:"precedence" json:"precedence"`
+	// Policies are evaluated in declared order before other declarations, first matching policy wins
+	Policies []Policy `yaml:"policies" json:"policies"`
+}
+
+// ImageMemLimit replaces memory ceilings of containers, whose image starts with ImagePrefix, e.g. JVM or ML images.
+// Empty MemLimit or MemRequest removes the ceiling.
+type ImageMemLimit struct {
+	ImagePrefix string `yaml:"imagePrefix" json:"imagePrefix"`
+	MemLimit    string `yaml:"maxMemLimit" json:"maxMemLimit"`
+	MemRequest  string `yaml:"maxMemRequest" json:"maxMemRequest"`
+}
+
+type imageMemLimit struct {
+	imagePrefix string
+	memLimit    *resource.Quantity
+	memRequest  *resource.Quantity
+}
+
+// GroupLimit overrides limits for requests of users in the group
+type GroupLimit struct {
+	Group string `yaml:"group" json:"group"`
+	Limit Limit  `yaml:"limit" json:"limit"`
+}
+
+type groupLimit struct {
+	group string
+	limit LimitResource
+}
+
+// NamespaceSelector selects namespaces by labels, annotations and phase
+type NamespaceSelector struct {
+	MatchLabels      map[string]string `yaml:"matchLabels" json:"matchLabels"`
+	MatchAnnotations map[string]string `yaml:"matchAnnotations" json:"matchAnnotations"`
+	// MatchPhase is Active or Terminating, any phase matches if it's empty
+	MatchPhase string `yaml:"matchPhase" json:"matchPhase"`
+	Limit      Limit  `yaml:"limit" json:"limit"`
+}
+
+type namespaceSelectorLimit struct {
+	matchLabels      map[string]string
+	matchAnnotations map[string]string
+	matchPhase       corev1.NamespacePhase
+	limit            LimitResource
+}
+
+// matches returns true if all labels, annotations and phase match
+func (nsl namespaceSelectorLimit) matches(labels, annotations map[string]string, phase corev1.NamespacePhase) bool {
+	if nsl.matchPhase != "" && nsl.matchPhase != phase {
+		return false
+	}
+
+	for k, v := range nsl.matchLabels {
+		if value, ok := labels[k]; !ok || value != v {
+			return false
+		}
+	}
+
+	for k, v := range nsl.matchAnnotations {
+		if value, ok := annotations[k]; !ok || value != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// defaultLimit returns top level declaration as Limit
+func (config Config) defaultLimit() Limit {
+	requestFractionTolerance := config.RequestFractionTolerance
+	if requestFractionTolerance == nil {
+		tolerance := defaultRequestFractionTolerance
+		requestFractionTolerance = &tolerance
+	}
+
+	return Limit{
+		CPULimit:                 config.MaxCPULimit,
+		MemLimit:                 config.MaxMemLimit,
+		CPURequest:               config.MaxCPURequest,
+		MemRequest:               config.MaxMemRequest,
+		EphemeralStorageLimit:    config.MaxEphemeralStorageLimit,
+		PodMemLimit:              config.MaxPodMemLimit,
+		GuaranteedCPU:            config.MaxGuaranteedCPU,
+		GuaranteedMem:            config.MaxGuaranteedMem,
+		CountMemoryEmptyDirs:     config.CountMemoryEmptyDirs,
+		RequireLimits:            config.RequireLimits,
+		ExtendedResources:        config.MaxExtendedResources,
+		MinCPURequest:            config.MinCPURequest,
+		MinMemRequest:            config.MinMemRequest,
+		PVCSize:                  config.MaxPvcSize,
+		MinPVCSize:               config.MinPvcSize,
+		NamespacePVCSize:         config.MaxNamespacePvcSize,
+		PVCSizePerStorageClass:   config.MaxPvcSizePerStorageClass,
+		NamespaceGPUs:            config.MaxNamespaceGPUs,
+		CPUBurst:                 config.MaxCPUBurst,
+		MemBurst:                 config.MaxMemBurst,
+		Severity:                 config.Severity,
+		PairedResources:          config.PairedResources,
+		CPULimitPolicy:           config.CPULimitPolicy,
+		SwapPolicy:               config.SwapPolicy,
+		Mode:                     config.Mode,
+		DefaultAction:            config.DefaultAction,
+		Mutate:                   config.Mutate,
+		MaxLimitRequestRatio:     config.MaxLimitRequestRatio,
+		ZeroRequestRatio:         config.ZeroRequestRatio,
+		RequestFraction:          config.RequestFraction,
+		RequestFractionTolerance: requestFractionTolerance,
+		MaxResourceClaims:        config.MaxResourceClaims,
+		MaxContainerResources:    config.MaxContainerResources,
+		MaxReplicas:              config.MaxReplicas,
+		TopologySpreadReplicas:   config.TopologySpreadReplicas,
+		TopologySpreadPolicy:     config.TopologySpreadPolicy,
+		AllowedDeviceClasses:     config.AllowedDeviceClasses,
+		Sidecar:                  config.Sidecar,
+		RestartPolicies:          config.RestartPolicies,
+		WorkloadClasses:          config.WorkloadClasses,
+		ImageMemLimits:           config.ImageMemLimits,
+		Profiles:                 config.Profiles,
+		ExemptTolerations:        config.ExemptTolerations,
+		ExemptUsers:              config.ExemptUsers,
+		ExemptGroups:             config.ExemptGroups,
+		Containers:               config.Containers,
+		UserNamespace:            config.UserNamespace,
+	}
+}
+
+// LimitResource resource limits
+type LimitResource struct {
+	CPULimit              *resource.Quantity
+	MemLimit              *resource.Quantity
+	CPURequest            *resource.Quantity
+	MemRequest            *resource.Quantity
+	EphemeralStorageLimit *resource.Quantity
+	PodMemLimit           *resource.Quantity
+	GuaranteedCPU         *resource.Quantity
+	GuaranteedMem         *resource.Quantity
+	MinCPURequest         *resource.Quantity
+	MinMemRequest         *resource.Quantity
+	PVCSize               *resource.Quantity
+	MinPVCSize            *resource.Quantity
+	NamespacePVCSize      *resource.Quantity
+	// PVCSizePerStorageClass is nil if PVC size doesn't depend on storage class
+	PVCSizePerStorageClass map[string]*resource.Quantity
+	NamespaceGPUs          *resource.Quantity
+	CPUBurst               *resource.Quantity
+	MemBurst               *resource.Quantity
+	Unlimited              bool
+	UnlimitedPVC           bool
+	Uncapped               bool
+	Severity               map[corev1.ResourceName]string
+	// ExtendedResources is nil if extended resources are not limited
+	ExtendedResources map[corev1.ResourceName]*resource.Quantity
+	PairedResources   []corev1.ResourceName
+	CPULimitPolicy    string
+	SwapPolicy        string
+	Mode              string
+	Mutate            bool
+	// DefaultAction is empty, if objects of unhandled kinds follow --default-action
+	DefaultAction string
+	// CountMemoryEmptyDirs adds sizeLimit of memory backed emptyDir volumes to the pod memory limited by PodMemLimit
+	CountMemoryEmptyDirs bool
+	// RequireLimits denies containers without CPU limit, if CPULimit is set, or without memory limit, if MemLimit is set
+	RequireLimits bool
+	// MaxLimitRequestRatio is nil if limit to request ratio is not limited
+	MaxLimitRequestRatio *float64
+	ZeroRequestRatio     string
+	// RequestFraction is nil if requests are not required to be fraction of limits
+	RequestFraction          *float64
+	RequestFractionTolerance float64
+	// MaxResourceClaims is nil if number of resource claims is not limited
+	MaxResourceClaims *int
+	// MaxContainerResources is nil if number of distinct container resource names is not limited
+	MaxContainerResources *int
+	// MaxReplicas is nil if replicas are not limited
+	MaxReplicas *int
+	// TopologySpreadReplicas is nil if topologySpreadConstraints are not required
+	TopologySpreadReplicas *int
+	TopologySpreadPolicy   string
+	// AllowedDeviceClasses is nil if any device class is allowed
+	AllowedDeviceClasses []string
+	// Sidecar is nil if native sidecars are not limited
+	Sidecar                   *LimitResource
+	ForbidEphemeralContainers bool
+	// RequiredProbes is nil if probes are not required
+	RequiredProbes        []string
+	ProbeExemptContainers []string
+	// ExemptTolerations is nil if tolerations don't exempt pods
+	ExemptTolerations []string
+	// ExemptUsers are compiled username globs, ExemptUsers and ExemptGroups are nil if no user is exempt
+	ExemptUsers  []*regexp.Regexp
+	ExemptGroups []string
+	// ImageMemLimits is nil if memory ceilings don't depend on container image
+	ImageMemLimits []imageMemLimit
+	// Profiles is nil if no resource profiles are configured
+	Profiles map[string]resourceProfile
+	// Profile is the profile named by the workload's profile annotation, empty if it doesn't name any
+	Profile string
+	// RestartPolicies is nil if limits don't depend on pod restartPolicy
+	RestartPolicies map[corev1.RestartPolicy]LimitResource
+	// WorkloadClasses is nil if limits don't depend on workload class
+	WorkloadClasses map[string]LimitResource
+	// LabelLimits is nil if limits don't depend on pod template labels
+	LabelLimits []labelLimit
+	// Containers is nil if limits don't depend on container name
+	Containers map[string]LimitResource
+	// UserNamespace is nil if pods in a user namespace are limited like other pods
+	UserNamespace *LimitResource
+}
+
+// DeepCopy returns deep copy of LimitResource
+func (l LimitResource) DeepCopy() LimitResource {
+	out := LimitResource{
+		CPULimit:              copyQuantity(l.CPULimit),
+		MemLimit:              copyQuantity(l.MemLimit),
+		CPURequest:            copyQuantity(l.CPURequest),
+		MemRequest:            copyQuantity(l.MemRequest),
+		EphemeralStorageLimit: copyQuantity(l.EphemeralStorageLimit),
+		PodMemLimit:           copyQuantity(l.PodMemLimit),
+		GuaranteedCPU:         copyQuantity(l.GuaranteedCPU),
+		GuaranteedMem:         copyQuantity(l.GuaranteedMem),
+		MinCPURequest:         copyQuantity(l.MinCPURequest),
+		MinMemRequest:         copyQuantity(l.MinMemRequest),
+		PVCSize:               copyQuantity(l.PVCSize),
+		MinPVCSize:            copyQuantity(l.MinPVCSize),
+		NamespacePVCSize:      copyQuantity(l.NamespacePVCSize),
+		NamespaceGPUs:         copyQuantity(l.NamespaceGPUs),
+		CPUBurst:              copyQuantity(l.CPUBurst),
+		MemBurst:              copyQuantity(l.MemBurst),
+		Unlimited:             l.Unlimited,
+		UnlimitedPVC:          l.UnlimitedPVC,
+		Uncapped:              l.Uncapped,
+		CPULimitPolicy:        l.CPULimitPolicy,
+		SwapPolicy:            l.SwapPolicy,
+		Profile:               l.Profile,
+		TopologySpreadPolicy:  l.TopologySpreadPolicy,
+
+		ZeroRequestRatio:          l.ZeroRequestRatio,
+		Mode:                      l.Mode,
+		Mutate:                    l.Mutate,
+		DefaultAction:             l.DefaultAction,
+		CountMemoryEmptyDirs:      l.CountMemoryEmptyDirs,
+		RequireLimits:             l.RequireLimits,
+		RequestFractionTolerance:  l.RequestFractionTolerance,
+		ForbidEphemeralContainers: l.ForbidEphemeralContainers,
+	}
+
+	if l.Severity != nil {
+		out.Severity = make(map[corev1.ResourceName]string, len(l.Severity))
+		for k, v := range l.Severity {
+			out.Severity[k] = v
+		}
+	}
+
+	if l.ExtendedResources != nil {
+		out.ExtendedResources = make(map[corev1.ResourceName]*resource.Quantity, len(l.ExtendedResources))
+		for k, v := range l.ExtendedResources {
+			out.ExtendedResources[k] = copyQuantity(v)
+		}
+	}
+
+	if l.PVCSizePerStorageClass != nil {
+		out.PVCSizePerStorageClass = make(map[string]*resource.Quantity, len(l.PVCSizePerStorageClass))
+		for k, v := range l.PVCSizePerStorageClass {
+			out.PVCSizePerStorageClass[k] = copyQuantity(v)
+		}
+	}
+
+	if l.PairedResources != nil {
+		out.PairedResources = append([]corev1.ResourceName{}, l.PairedResources...)
+	}
+
+	if l.MaxResourceClaims != nil {
+		maxResourceClaims := *l.MaxResourceClaims
+		out.MaxResourceClaims = &maxResourceClaims
+	}
+
+	if l.MaxLimitRequestRatio != nil {
+		maxLimitRequestRatio := *l.MaxLimitRequestRatio
+		out.MaxLimitRequestRatio = &maxLimitRequestRatio
+	}
+
+	if l.RequestFraction != nil {
+		requestFraction := *l.RequestFraction
+		out.RequestFraction = &requestFraction
+	}
+
+	if l.MaxContainerResources != nil {
+		maxContainerResources := *l.MaxContainerResources
+		out.MaxContainerResources = &maxContainerResources
+	}
+
+	if l.MaxReplicas != nil {
+		maxReplicas := *l.MaxReplicas
+		out.MaxReplicas = &maxReplicas
+	}
+
+	if l.TopologySpreadReplicas != nil {
+		topologySpreadReplicas := *l.TopologySpreadReplicas
+		out.TopologySpreadReplicas = &topologySpreadReplicas
+	}
+
+	if l.AllowedDeviceClasses != nil {
+		out.AllowedDeviceClasses = append([]string{}, l.AllowedDeviceClasses...)
+	}
+
+	if l.RequiredProbes != nil {
+		out.RequiredProbes = append([]string{}, l.RequiredProbes...)
+	}
+
+	if l.ProbeExemptContainers != nil {
+		out.ProbeExemptContainers = append([]string{}, l.ProbeExemptContainers...)
+	}
+
+	if l.ExemptTolerations != nil {
+		out.ExemptTolerations = append([]string{}, l.ExemptTolerations...)
+	}
+
+	// compiled regular expressions are immutable, so they are shared
+	if l.ExemptUsers != nil {
+		out.ExemptUsers = append([]*regexp.Regexp{}, l.ExemptUsers...)
+	}
+
+	if l.ExemptGroups != nil {
+		out.ExemptGroups = append([]string{}, l.ExemptGroups...)
+	}
+
+	if l.ImageMemLimits != nil {
+		out.ImageMemLimits = make([]imageMemLimit, 0, len(l.ImageMemLimits))
+		for _, iml := range l.ImageMemLimits {
+			out.ImageMemLimits = append(out.ImageMemLimits, imageMemLimit{
+				imagePrefix: iml.imagePrefix,
+				memLimit:    copyQuantity(iml.memLimit),
+				memRequest:  copyQuantity(iml.memRequest),
+			})
+		}
+	}
+
+	if l.Profiles != nil {
+		out.Profiles = make(map[string]resourceProfile, len(l.Profiles))
+		for name, profile := range l.Profiles {
+			out.Profiles[name] = profile.DeepCopy()
+		}
+	}
+
+	if l.Sidecar != nil {
+		sidecar := l.Sidecar.DeepCopy()
+		out.Sidecar = &sidecar
+	}
+
+	if l.RestartPolicies != nil {
+		out.RestartPolicies = make(map[corev1.RestartPolicy]LimitResource, len(l.RestartPolicies))
+		for policy, limit := range l.RestartPolicies {
+			out.RestartPolicies[policy] = limit.DeepCopy()
+		}
+	}
+
+	if l.WorkloadClasses != nil {
+		out.WorkloadClasses = make(map[string]LimitResource, len(l.WorkloadClasses))
+		for class, limit := range l.WorkloadClasses {
+			out.WorkloadClasses[class] = limit.DeepCopy()
+		}
+	}
+
+	if l.UserNamespace != nil {
+		userNamespace := l.UserNamespace.DeepCopy()
+		out.UserNamespace = &userNamespace
+	}
+
+	if l.Containers != nil {
+		out.Containers = make(map[string]LimitResource, len(l.Containers))
+		for name, limit := range l.Containers {
+			out.Containers[name] = limit.DeepCopy()
+		}
+	}
+
+	if l.LabelLimits != nil {
+		out.LabelLimits = make([]labelLimit, 0, len(l.LabelLimits))
+		for _, ll := range l.LabelLimits {
+			out.LabelLimits = append(out.LabelLimits, labelLimit{
+				matchLabels: ll.matchLabels,
+				limit:       ll.limit.DeepCopy(),
+			})
+		}
+	}
+
+	return out
+}
+
+// ForLabels returns limit of workloads with the pod template labels, the first matching LabelLimits wins
+func (l LimitResource) ForLabels(labels map[string]string) LimitResource {
+	for _, ll := range l.LabelLimits {
+		if ll.matches(labels) {
+			return ll.limit
+		}
+	}
+
+	return l
+}
+
+// ForWorkloadClass returns limit of workloads of the class
+func (l LimitResource) ForWorkloadClass(class string) LimitResource {
+	if limit, ok := l.WorkloadClasses[class]; ok {
+		return limit
+	}
+
+	return l
+}
+
+// ForUserNamespace returns limit of pods with the hostUsers, pods with hostUsers: false get UserNamespace limit
+func (l LimitResource) ForUserNamespace(hostUsers *bool) LimitResource {
+	if hostUsers != nil && !*hostUsers && l.UserNamespace != nil {
+		return *l.UserNamespace
+	}
+
+	return l
+}
+
+// ForContainer returns limit of the container, Containers override by name wins over Sidecar limit of native sidecars
+func (l LimitResource) ForContainer(container corev1.Container) LimitResource {
+	if limit, ok := l.Containers[container.Name]; ok {
+		return limit
+	}
+
+	if isSidecar(container) && l.Sidecar != nil {
+		return *l.Sidecar
+	}
+
+	return l
+}
+
+// ForImage returns limit of containers with the image, memory ceilings are replaced by the first matching ImageMemLimits prefix
+func (l LimitResource) ForImage(image string) LimitResource {
+	for _, iml := range l.ImageMemLimits {
+		if strings.HasPrefix(image, iml.imagePrefix) {
+			l.MemLimit = iml.memLimit
+			l.MemRequest = iml.memRequest
+			return l
+		}
+	}
+
+	return l
+}
+
+// withoutCeilings returns copy of limit without pod resource ceilings, which are waived by Uncapped
+func (l LimitResource) withoutCeilings() LimitResource {
+	out := l.DeepCopy()
+	out.CPULimit = nil
+	out.MemLimit = nil
+	out.CPURequest = nil
+	out.MemRequest = nil
+	out.EphemeralStorageLimit = nil
+	out.PodMemLimit = nil
+	out.GuaranteedCPU = nil
+	out.GuaranteedMem = nil
+	out.CPUBurst = nil
+	out.MemBurst = nil
+	out.ImageMemLimits = nil
+	out.ExtendedResources = nil
+
+	if out.Sidecar != nil {
+		sidecar := out.Sidecar.withoutCeilings()
+		out.Sidecar = &sidecar
+	}
+
+	for name, limit := range out.Containers {
+		out.Containers[name] = limit.withoutCeilings()
+	}
+
+	return out
+}
+
+// ForRestartPolicy returns limit of pods with the restartPolicy, empty restartPolicy defaults to Always
+func (l LimitResource) ForRestartPolicy(policy corev1.RestartPolicy) LimitResource {
+	if policy == "" {
+		policy = corev1.RestartPolicyAlways
+	}
+
+	if limit, ok := l.RestartPolicies[policy]; ok {
+		return limit
+	}
+
+	return l
+}
+
+func copyQuantity(q *resource.Quantity) *resource.Quantity {
+	if q == nil {
+		return nil
+	}
+
+	c := q.DeepCopy()
+	return &c
+}
+
+// defaultReloadDebounce is how long Watch waits for more changes before reloading
+const defaultReloadDebounce = 500 * time.Millisecond
+
+// Configurer configures resource limits
+type Configurer struct {
+	filePath        string
+	refreshInterval time.Duration
+	reloadDebounce  time.Duration
+	strict          bool
+	configuredOnly  bool
+	w               *fsnotify.Watcher
+	// target is the config file with resolved symlinks, its change means the file was swapped, e.g. by ConfigMap update
+	target string
+	// templateTarget is the resource template file with resolved symlinks
+	templateTarget string
+
+	excludedNames      map[NameNamespace]LimitResource
+	namePatterns       []namePattern
+	excludedNamespaces map[string]LimitResource
+	namespaceSelectors []namespaceSelectorLimit
+	groups             []groupLimit
+	labels             []labelLimit
+	policies           []policyLimit
+	policyLabels       bool
+	precedence         string
+	defaultLimit       LimitResource
+	// template is the watched resource template file, it's empty if config has no resourceTemplateFile
+	template string
+	nsLookup NamespaceMetaGetter
+	// loaded is set once a config is loaded, failed reloads keep previous config in use
+	loaded bool
+	m      sync.RWMutex
+}
+
+// NewConfigurer returns new Limits Configurer, strict rejects unknown keys in config file
+func NewConfigurer(filePath string, refreshInterval time.Duration, strict bool) (*Configurer, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	filePath = filepath.Clean(filePath)
+	if err := w.Add(filePath); err != nil {
+		w.Close()
+		return nil, err
+	}
+	// directory is watched too, since mounted ConfigMaps are updated by swapping symlinks and replaced files drop the watch
+	if err := w.Add(filepath.Dir(filePath)); err != nil {
+		w.Close()
+		return nil, errors.Wrapf(err, "unable to watch %s", filepath.Dir(filePath))
+	}
+
+	c := &Configurer{
+		filePath:           filePath,
+		target:             resolveTarget(filePath),
+		w:                  w,
+		refreshInterval:    refreshInterval,
+		reloadDebounce:     defaultReloadDebounce,
+		strict:             strict,
+		excludedNamespaces: nil,
+		excludedNames:      nil,
+	}
+
+	if err := c.load(); err != nil {
+		w.Close()
+		return nil, err
+	}
+
+	go c.Watch()
+
+	return c, nil
+}
+
+// parseQuantity parses non-negative value, if value is empty it returns copy of def
+func parseQuantity(value string, def *resource.Quantity) (*resource.Quantity, error) {
+	if value == "" {
+		return copyQuantity(def), nil
+	}
+
+	q, err := resource.ParseQuantity(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if q.Sign() < 0 {
+		return nil, errors.Errorf("quantity %s must not be negative", value)
+	}
+
+	return &q, nil
+}
+
+// convertLimitsToResources parses limit, fields which are not set are taken from defaults
+func convertLimitsToResources(limit Limit, defaults LimitResource) (*LimitResource, error) {
+	cpu, err := parseQuantity(limit.CPULimit, defaults.CPULimit)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse CPULimit")
+	}
+
+	mem, err := parseQuantity(limit.MemLimit, defaults.MemLimit)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse MemLimit")
+	}
+
+	cpuRequest, err := parseQuantity(limit.CPURequest, defaults.CPURequest)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse CPURequest")
+	}
+
+	memRequest, err := parseQuantity(limit.MemRequest, defaults.MemRequest)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse MemRequest")
+	}
+
+	ephemeralStorage, err := parseQuantity(limit.EphemeralStorageLimit, defaults.EphemeralStorageLimit)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse EphemeralStorageLimit")
+	}
+
+	podMem, err := parseQuantity(limit.PodMemLimit, defaults.PodMemLimit)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse PodMemLimit")
+	}
+
+	guaranteedCPU, err := parseQuantity(limit.GuaranteedCPU, defaults.GuaranteedCPU)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse GuaranteedCPU")
+	}
+
+	guaranteedMem, err := parseQuantity(limit.GuaranteedMem, defaults.GuaranteedMem)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse GuaranteedMem")
+	}
+
+	minCPURequest, err := parseQuantity(limit.MinCPURequest, defaults.MinCPURequest)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse MinCPURequest")
+	}
+
+	minMemRequest, err := parseQuantity(limit.MinMemRequest, defaults.MinMemRequest)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse MinMemRequest")
+	}
+
+	pvc, err := parseQuantity(limit.PVCSize, defaults.PVCSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse PVCSize")
+	}
+
+	minPvc, err := parseQuantity(limit.MinPVCSize, defaults.MinPVCSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse MinPVCSize")
+	}
+
+	namespacePvc, err := parseQuantity(limit.NamespacePVCSize, defaults.NamespacePVCSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse NamespacePVCSize")
+	}
+
+	namespaceGPUs, err := parseQuantity(limit.NamespaceGPUs, defaults.NamespaceGPUs)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse NamespaceGPUs")
+	}
+
+	cpuBurst, err := parseQuantity(limit.CPUBurst, defaults.CPUBurst)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse CPUBurst")
+	}
+
+	memBurst, err := parseQuantity(limit.MemBurst, defaults.MemBurst)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse MemBurst")
+	}
+
+	severity := defaults.DeepCopy().Severity
+	if limit.Severity != nil {
+		severity = make(map[corev1.ResourceName]string, len(limit.Severity))
+		for name, value := range limit.Severity {
+			if value != severityWarn && value != severityDeny {
+				return nil, errors.Errorf("severity of %s must be %s or %s, got: %s", name, severityWarn, severityDeny, value)
+			}
+
+			severity[corev1.ResourceName(name)] = value
+		}
+	}
+
+	extendedResources := defaults.DeepCopy().ExtendedResources
+	if limit.ExtendedResources != nil {
+		extendedResources = make(map[corev1.ResourceName]*resource.Quantity, len(limit.ExtendedResources))
+		for name, value := range limit.ExtendedResources {
+			q, err := parseQuantity(value, nil)
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not parse maxExtendedResources %s", name)
+			}
+			if q == nil {
+				return nil, errors.Errorf("maxExtendedResources %s must not be empty", name)
+			}
+
+			extendedResources[corev1.ResourceName(name)] = q
+		}
+	}
+
+	pvcSizePerStorageClass := defaults.DeepCopy().PVCSizePerStorageClass
+	if limit.PVCSizePerStorageClass != nil {
+		pvcSizePerStorageClass = make(map[string]*resource.Quantity, len(limit.PVCSizePerStorageClass))
+		for class, value := range limit.PVCSizePerStorageClass {
+			q, err := parseQuantity(value, nil)
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not parse maxPVCSizePerStorageClass %s", class)
+			}
+			if q == nil {
+				return nil, errors.Errorf("maxPVCSizePerStorageClass %s must not be empty", class)
+			}
+
+			pvcSizePerStorageClass[class] = q
+		}
+	}
+
+	pairedResources := defaults.PairedResources
+	if limit.PairedResources != nil {
+		pairedResources = make([]corev1.ResourceName, 0, len(limit.PairedResources))
+		for _, name := range limit.PairedResources {
+			pairedResources = append(pairedResources, corev1.ResourceName(name))
+		}
+	}
+
+	cpuLimitPolicy := defaults.CPULimitPolicy
+	switch limit.CPULimitPolicy {
+	case "":
+	case cpuLimitRequired, cpuLimitForbidden, cpuLimitOptional:
+		cpuLimitPolicy = limit.CPULimitPolicy
+	default:
+		return nil, errors.Errorf("cpuLimitPolicy must be %s, %s or %s, got: %s", cpuLimitRequired, cpuLimitForbidden, cpuLimitOptional, limit.CPULimitPolicy)
+	}
+
+	swapPolicy := defaults.SwapPolicy
+	switch limit.SwapPolicy {
+	case "":
+	case swapAllow, swapForbid:
+		swapPolicy = limit.SwapPolicy
+	default:
+		return nil, errors.Errorf("swapPolicy must be %s or %s, got: %s", swapAllow, swapForbid, limit.SwapPolicy)
+	}
+
+	maxLimitRequestRatio := defaults.MaxLimitRequestRatio
+	if limit.MaxLimitRequestRatio != nil {
+		if *limit.MaxLimitRequestRatio < 1 {
+			return nil, errors.Errorf("maxLimitRequestRatio must be at least 1, got: %g", *limit.MaxLimitRequestRatio)
+		}
+		maxLimitRequestRatio = limit.MaxLimitRequestRatio
+	}
+
+	mode := defaults.Mode
+	switch limit.Mode {
+	case "":
+	case modeEnforce, modeWarn:
+		mode = limit.Mode
+	default:
+		return nil, errors.Errorf("mode must be %s or %s, got: %s", modeEnforce, modeWarn, limit.Mode)
+	}
+
+	defaultAction := defaults.DefaultAction
+	switch limit.DefaultAction {
+	case "":
+	case defaultActionAllow, defaultActionDeny:
+		defaultAction = limit.DefaultAction
+	default:
+		return nil, errors.Errorf("defaultAction must be %s or %s, got: %s", defaultActionAllow, defaultActionDeny, limit.DefaultAction)
+	}
+
+	mutate := defaults.Mutate
+	if limit.Mutate != nil {
+		mutate = *limit.Mutate
+	}
+
+	countMemoryEmptyDirs := defaults.CountMemoryEmptyDirs
+	if limit.CountMemoryEmptyDirs != nil {
+		countMemoryEmptyDirs = *limit.CountMemoryEmptyDirs
+	}
+
+	requireLimits := defaults.RequireLimits
+	if limit.RequireLimits != nil {
+		requireLimits = *limit.RequireLimits
+	}
+
+	zeroRequestRatio := defaults.ZeroRequestRatio
+	switch limit.ZeroRequestRatio {
+	case "":
+	case zeroRequestSkip, zeroRequestDeny:
+		zeroRequestRatio = limit.ZeroRequestRatio
+	default:
+		return nil, errors.Errorf("zeroRequestRatio must be %s or %s, got: %s", zeroRequestSkip, zeroRequestDeny, limit.ZeroRequestRatio)
+	}
+
+	requestFraction := defaults.RequestFraction
+	if limit.RequestFraction != nil {
+		if *limit.RequestFraction <= 0 || *limit.RequestFraction > 1 {
+			return nil, errors.Errorf("requestFraction must be greater than 0 and at most 1, got: %g", *limit.RequestFraction)
+		}
+		requestFraction = limit.RequestFraction
+	}
+
+	requestFractionTolerance := defaults.RequestFractionTolerance
+	if limit.RequestFractionTolerance != nil {
+		if *limit.RequestFractionTolerance < 0 || *limit.RequestFractionTolerance >= 1 {
+			return nil, errors.Errorf("requestFractionTolerance must be at least 0 and less than 1, got: %g", *limit.RequestFractionTolerance)
+		}
+		requestFractionTolerance = *limit.RequestFractionTolerance
+	}
+
+	maxResourceClaims := defaults.MaxResourceClaims
+	if limit.MaxResourceClaims != nil {
+		maxResourceClaims = limit.MaxResourceClaims
+	}
+
+	maxContainerResources := defaults.MaxContainerResources
+	if limit.MaxContainerResources != nil {
+		maxContainerResources = limit.MaxContainerResources
+	}
+
+	maxReplicas := defaults.MaxReplicas
+	if limit.MaxReplicas != nil {
+		if *limit.MaxReplicas < 0 {
+			return nil, errors.Errorf("maxReplicas must not be negative, got: %d", *limit.MaxReplicas)
+		}
+		maxReplicas = limit.MaxReplicas
+	}
+
+	topologySpreadReplicas := defaults.TopologySpreadReplicas
+	if limit.TopologySpreadReplicas != nil {
+		if *limit.TopologySpreadReplicas < 1 {
+			return nil, errors.Errorf("topologySpreadReplicas must be at least 1, got: %d", *limit.TopologySpreadReplicas)
+		}
+		topologySpreadReplicas = limit.TopologySpreadReplicas
+	}
+
+	topologySpreadPolicy := defaults.TopologySpreadPolicy
+	switch limit.TopologySpreadPolicy {
+	case "":
+	case topologySpreadDeny, topologySpreadWarn:
+		topologySpreadPolicy = limit.TopologySpreadPolicy
+	default:
+		return nil, errors.Errorf("topologySpreadPolicy must be %s or %s, got: %s", topologySpreadDeny, topologySpreadWarn, limit.TopologySpreadPolicy)
+	}
+
+	allowedDeviceClasses := defaults.AllowedDeviceClasses
+	if limit.AllowedDeviceClasses != nil {
+		allowedDeviceClasses = limit.AllowedDeviceClasses
+	}
+
+	requiredProbes := defaults.RequiredProbes
+	if limit.RequiredProbes != nil {
+		for _, probe := range limit.RequiredProbes {
+			if probe != probeReadiness && probe != probeLiveness {
+				return nil, errors.Errorf("requiredProbes must be %s or %s, got: %s", probeReadiness, probeLiveness, probe)
+			}
+		}
+		requiredProbes = limit.RequiredProbes
+	}
+
+	probeExemptContainers := defaults.ProbeExemptContainers
+	if limit.ProbeExemptContainers != nil {
+		probeExemptContainers = limit.ProbeExemptContainers
+	}
+
+	exemptTolerations := defaults.ExemptTolerations
+	if limit.ExemptTolerations != nil {
+		for _, key := range limit.ExemptTolerations {
+			if key == "" {
+				return nil, errors.New("exemptTolerations key must not be empty")
+			}
+		}
+		exemptTolerations = limit.ExemptTolerations
+	}
+
+	exemptUsers := defaults.ExemptUsers
+	if limit.ExemptUsers != nil {
+		exemptUsers = make([]*regexp.Regexp, 0, len(limit.ExemptUsers))
+		for _, user := range limit.ExemptUsers {
+			if user == "" {
+				return nil, errors.New("exemptUsers username must not be empty")
+			}
+
+			re, err := regexp.Compile("^(?:" + globToRegex(user) + ")$")
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to compile exemptUsers glob %s", user)
+			}
+			exemptUsers = append(exemptUsers, re)
+		}
+	}
+
+	exemptGroups := defaults.ExemptGroups
+	if limit.ExemptGroups != nil {
+		for _, group := range limit.ExemptGroups {
+			if group == "" {
+				return nil, errors.New("exemptGroups group must not be empty")
+			}
+		}
+		exemptGroups = limit.ExemptGroups
+	}
+
+	imageMemLimits := defaults.DeepCopy().ImageMemLimits
+	if limit.ImageMemLimits != nil {
+		imageMemLimits = make([]imageMemLimit, 0, len(limit.ImageMemLimits))
+		for _, iml := range limit.ImageMemLimits {
+			if iml.ImagePrefix == "" {
+				return nil, errors.New("imageMemLimits imagePrefix must not be empty")
+			}
+
+			memLimit, err := parseQuantity(iml.MemLimit, nil)
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not parse imageMemLimits %s MemLimit", iml.ImagePrefix)
+			}
+
+			memRequest, err := parseQuantity(iml.MemRequest, nil)
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not parse imageMemLimits %s MemRequest", iml.ImagePrefix)
+			}
+
+			imageMemLimits = append(imageMemLimits, imageMemLimit{
+				imagePrefix: iml.ImagePrefix,
+				memLimit:    memLimit,
+				memRequest:  memRequest,
+			})
+		}
+	}
+
+	profiles := defaults.DeepCopy().Profiles
+	if limit.Profiles != nil {
+		profiles = make(map[string]resourceProfile, len(limit.Profiles))
+		for name, profile := range limit.Profiles {
+			rProfile, err := convertProfile(profile)
+			if err != nil {
+				return nil, errors.Wrapf(err, "profiles %s", name)
+			}
+			profiles[name] = rProfile
+		}
+	}
+
+	rLimit := &LimitResource{
+		CPULimit:               cpu,
+		MemLimit:               mem,
+		CPURequest:             cpuRequest,
+		MemRequest:             memRequest,
+		EphemeralStorageLimit:  ephemeralStorage,
+		PodMemLimit:            podMem,
+		GuaranteedCPU:          guaranteedCPU,
+		GuaranteedMem:          guaranteedMem,
+		MinCPURequest:          minCPURequest,
+		MinMemRequest:          minMemRequest,
+		PVCSize:                pvc,
+		MinPVCSize:             minPvc,
+		NamespacePVCSize:       namespacePvc,
+		PVCSizePerStorageClass: pvcSizePerStorageClass,
+		NamespaceGPUs:          namespaceGPUs,
+		CPUBurst:               cpuBurst,
+		MemBurst:               memBurst,
+		Unlimited:              limit.Unlimited,
+		UnlimitedPVC:           limit.UnlimitedPVC,
+		Uncapped:               limit.Uncapped,
+		Severity:               severity,
+		ExtendedResources:      extendedResources,
+		PairedResources:        pairedResources,
+		CPULimitPolicy:         cpuLimitPolicy,
+		SwapPolicy:             swapPolicy,
+
+		MaxLimitRequestRatio:      maxLimitRequestRatio,
+		ZeroRequestRatio:          zeroRequestRatio,
+		Mode:                      mode,
+		DefaultAction:             defaultAction,
+		Mutate:                    mutate,
+		CountMemoryEmptyDirs:      countMemoryEmptyDirs,
+		RequireLimits:             requireLimits,
+		RequestFraction:           requestFraction,
+		RequestFractionTolerance:  requestFractionTolerance,
+		MaxResourceClaims:         maxResourceClaims,
+		MaxContainerResources:     maxContainerResources,
+		MaxReplicas:               maxReplicas,
+		TopologySpreadReplicas:    topologySpreadReplicas,
+		TopologySpreadPolicy:      topologySpreadPolicy,
+		RequiredProbes:            requiredProbes,
+		ProbeExemptContainers:     probeExemptContainers,
+		ExemptTolerations:         exemptTolerations,
+		ExemptUsers:               exemptUsers,
+		ExemptGroups:              exemptGroups,
+		ImageMemLimits:            imageMemLimits,
+		Profiles:                  profiles,
+		AllowedDeviceClasses:      allowedDeviceClasses,
+		ForbidEphemeralContainers: limit.ForbidEphemeralContainers,
+	}
+
+	switch {
+	case limit.Sidecar != nil:
+		var sidecarDefaults LimitResource
+		if defaults.Sidecar != nil {
+			sidecarDefaults = *defaults.Sidecar
+		}
+
+		sidecar, err := convertLimitsToResources(*limit.Sidecar, sidecarDefaults)
+		if err != nil {
+			return nil, errors.Wrap(err, "sidecar")
+		}
+		rLimit.Sidecar = sidecar
+	case defaults.Sidecar != nil:
+		sidecar := defaults.Sidecar.DeepCopy()
+		rLimit.Sidecar = &sidecar
+	}
+
+	// containers are converted before restartPolicies, classes and label limits, so that they inherit container overrides
+	switch {
+	case limit.Containers != nil:
+		base := rLimit.DeepCopy()
+		rLimit.Containers = make(map[string]LimitResource, len(limit.Containers))
+		for name, l := range limit.Containers {
+			if name == "" {
+				return nil, errors.New("containers name must not be empty")
+			}
+			if l.Containers != nil {
+				return nil, errors.Errorf("containers %s can't declare containers", name)
+			}
+
+			containerLimit, err := convertLimitsToResources(l, base)
+			if err != nil {
+				return nil, errors.Wrapf(err, "containers %s", name)
+			}
+			rLimit.Containers[name] = *containerLimit
+		}
+	case defaults.Containers != nil:
+		rLimit.Containers = defaults.DeepCopy().Containers
+	}
+
+	// userNamespace is converted before restartPolicies, classes and label limits, so that they inherit it
+	switch {
+	case limit.UserNamespace != nil:
+		if limit.UserNamespace.UserNamespace != nil {
+			return nil, errors.New("userNamespace can't declare userNamespace")
+		}
+
+		userNamespace, err := convertLimitsToResources(*limit.UserNamespace, rLimit.DeepCopy())
+		if err != nil {
+			return nil, errors.Wrap(err, "userNamespace")
+		}
+		userNamespace.ForbidEphemeralContainers = userNamespace.ForbidEphemeralContainers || rLimit.ForbidEphemeralContainers
+		rLimit.UserNamespace = userNamespace
+	case defaults.UserNamespace != nil:
+		userNamespace := defaults.UserNamespace.DeepCopy()
+		rLimit.UserNamespace = &userNamespace
+	}
+
+	switch {
+	case limit.RestartPolicies != nil:
+		base := rLimit.DeepCopy()
+		rLimit.RestartPolicies = make(map[corev1.RestartPolicy]LimitResource, len(limit.RestartPolicies))
+		for policy, l := range limit.RestartPolicies {
+			switch corev1.RestartPolicy(policy) {
+			case corev1.RestartPolicyAlways, corev1.RestartPolicyOnFailure, corev1.RestartPolicyNever:
+			default:
+				return nil, errors.Errorf("restartPolicies key must be %s, %s or %s, got: %s",
+					corev1.RestartPolicyAlways, corev1.RestartPolicyOnFailure, corev1.RestartPolicyNever, policy)
+			}
+
+			if l.RestartPolicies != nil {
+				return nil, errors.Errorf("restartPolicies %s can't declare restartPolicies", policy)
+			}
+
+			policyLimit, err := convertLimitsToResources(l, base)
+			if err != nil {
+				return nil, errors.Wrapf(err, "restartPolicies %s", policy)
+			}
+			policyLimit.ForbidEphemeralContainers = policyLimit.ForbidEphemeralContainers || base.ForbidEphemeralContainers
+			rLimit.RestartPolicies[corev1.RestartPolicy(policy)] = *policyLimit
+		}
+	case defaults.RestartPolicies != nil:
+		rLimit.RestartPolicies = defaults.DeepCopy().RestartPolicies
+	}
+
+	// classes are converted after restartPolicies, so that classes inherit them
+	switch {
+	case limit.WorkloadClasses != nil:
+		base := rLimit.DeepCopy()
+		rLimit.WorkloadClasses = make(map[string]LimitResource, len(limit.WorkloadClasses))
+		for class, l := range limit.WorkloadClasses {
+			if l.WorkloadClasses != nil {
+				return nil, errors.Errorf("workloadClasses %s can't declare workloadClasses", class)
+			}
+
+			classLimit, err := convertLimitsToResources(l, base)
+			if err != nil {
+				return nil, errors.Wrapf(err, "workloadClasses %s", class)
+			}
+			classLimit.ForbidEphemeralContainers = classLimit.ForbidEphemeralContainers || base.ForbidEphemeralContainers
+			rLimit.WorkloadClasses[class] = *classLimit
+		}
+	case defaults.WorkloadClasses != nil:
+		rLimit.WorkloadClasses = defaults.DeepCopy().WorkloadClasses
+	}
+
+	// label limits apply before classes and restartPolicies, so they don't inherit them,
+	// otherwise classes of this limit would replace the label limit
+	switch {
+	case limit.LabelLimits != nil:
+		base := rLimit.DeepCopy()
+		base.RestartPolicies = nil
+		base.WorkloadClasses = nil
+		rLimit.LabelLimits = make([]labelLimit, 0, len(limit.LabelLimits))
+		for i, ll := range limit.LabelLimits {
+			if len(ll.MatchLabels) == 0 {
+				return nil, errors.Errorf("labelLimits %d: matchLabels must not be empty", i)
+			}
+			if ll.Limit.LabelLimits != nil {
+				return nil, errors.Errorf("labelLimits %d can't declare labelLimits", i)
+			}
+
+			labelsLimit, err := convertLimitsToResources(ll.Limit, base)
+			if err != nil {
+				return nil, errors.Wrapf(err, "labelLimits %d", i)
+			}
+			labelsLimit.ForbidEphemeralContainers = labelsLimit.ForbidEphemeralContainers || base.ForbidEphemeralContainers
+			rLimit.LabelLimits = append(rLimit.LabelLimits, labelLimit{
+				matchLabels: ll.MatchLabels,
+				limit:       *labelsLimit,
+			})
+		}
+	case defaults.LabelLimits != nil:
+		rLimit.LabelLimits = defaults.DeepCopy().LabelLimits
+	}
+
+	return rLimit, nil
+}
+
+// configState is configuration parsed from the config file
+type configState struct {
+	defaultLimit       LimitResource
+	excludedNamespaces map[string]LimitResource
+	excludedNames      map[NameNamespace]LimitResource
+	namePatterns       []namePattern
+	namespaceSelectors []namespaceSelectorLimit
+	groups             []groupLimit
+	labels             []labelLimit
+	policies           []policyLimit
+	policyLabels       bool
+	precedence         string
+	template           string
+}
+
+// load loads configuration
+func (c *Configurer) load() error {
+	state, err := c.parse()
+	if err != nil {
+		return err
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if state.template != c.template {
+		c.watchTemplate(c.template, state.template)
+	}
+
+	c.template = state.template
+	c.defaultLimit = state.defaultLimit
+	c.excludedNamespaces = state.excludedNamespaces
+	c.excludedNames = state.excludedNames
+	c.namePatterns = state.namePatterns
+	c.namespaceSelectors = state.namespaceSelectors
+	c.groups = state.groups
+	c.labels = state.labels
+	c.policies = state.policies
+	c.policyLabels = state.policyLabels
+	c.precedence = state.precedence
+	c.loaded = true
+	configLoadedGauge.Set(1)
+
+	return nil
+}
+
+// Loaded returns true if a config was loaded, it stays in use even if later reloads fail
+func (c *Configurer) Loaded() bool {
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	return c.loaded
+}
+
+// state returns configuration in use
+func (c *Configurer) state() *configState {
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	return &configState{
+		defaultLimit:       c.defaultLimit,
+		excludedNamespaces: c.excludedNamespaces,
+		excludedNames:      c.excludedNames,
+		namePatterns:       c.namePatterns,
+		namespaceSelectors: c.namespaceSelectors,
+		groups:             c.groups,
+		labels:             c.labels,
+		policies:           c.policies,
+		policyLabels:       c.policyLabels,
+		precedence:         c.precedence,
+		template:           c.template,
+	}
+}
+
+// parse reads and parses the config file
+func (c *Configurer) parse() (*configState, error) {
+	configFile, err := ioutil.ReadFile(c.filePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read file")
+	}
+
+	unmarshal := yaml.Unmarshal
+	if c.strict {
+		unmarshal = yaml.UnmarshalStrict
+	}
+
+	var config Config
+	if err := unmarshal(configFile, &config); err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal yaml file")
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid config")
+	}
+
+	var templateLimit LimitResource
+	var templateFile string
+	if config.ResourceTemplateFile != "" {
+		templateFile = filepath.Clean(config.ResourceTemplateFile)
+		if !filepath.IsAbs(templateFile) {
+			templateFile = filepath.Join(filepath.Dir(c.filePath), templateFile)
+		}
+
+		templateLimit, err = loadResourceTemplate(templateFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	defaultLimit, err := convertLimitsToResources(config.defaultLimit(), templateLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	excludedNamespaces := make(map[string]LimitResource)
+	excludedNames := make(map[NameNamespace]LimitResource)
+	for ns, limit := range config.Namespaces {
+		rLimit, err := convertLimitsToResources(limit, *defaultLimit)
+		if err != nil {
+			return nil, errors.Wrapf(err, "namespace: %s", ns)
+		}
+
+		excludedNamespaces[ns] = *rLimit
+	}
+
+	var namePatterns []namePattern
+	for nn, limit := range config.Names {
+		rLimit, err := convertLimitsToResources(limit, *defaultLimit)
+		if err != nil {
+			return nil, errors.Wrapf(err, "nn: %s", nn)
+		}
+
+		if nn.NameRegex == "" && !isNameGlob(nn.Name) {
+			excludedNames[nn] = *rLimit
+			continue
+		}
+
+		pattern, err := newNamePattern(nn, *rLimit)
+		if err != nil {
+			return nil, errors.Wrapf(err, "nn: %s", nn)
+		}
+		namePatterns = append(namePatterns, pattern)
+	}
+	sortNamePatterns(namePatterns)
+
+	namespaceSelectors := make([]namespaceSelectorLimit, 0, len(config.NamespaceSelectors))
+	for i, selector := range config.NamespaceSelectors {
+		rLimit, err := convertLimitsToResources(selector.Limit, *defaultLimit)
+		if err != nil {
+			return nil, errors.Wrapf(err, "namespaceSelectors[%d]", i)
+		}
+
+		phase := corev1.NamespacePhase(selector.MatchPhase)
+		if phase != "" && phase != corev1.NamespaceActive && phase != corev1.NamespaceTerminating {
+			return nil, errors.Errorf("namespaceSelectors[%d]: matchPhase must be %s or %s, got: %s", i, corev1.NamespaceActive, corev1.NamespaceTerminating, phase)
+		}
+
+		namespaceSelectors = append(namespaceSelectors, namespaceSelectorLimit{
+			matchLabels:      selector.MatchLabels,
+			matchAnnotations: selector.MatchAnnotations,
+			matchPhase:       phase,
+			limit:            *rLimit,
+		})
+	}
+
+	precedence := config.Precedence
+	switch precedence {
+	case "":
+		precedence = precedenceMostSpecific
+	case precedenceMostSpecific, precedenceMostRestrictive:
+	default:
+		return nil, errors.Errorf("precedence must be %s or %s, got: %s", precedenceMostSpecific, precedenceMostRestrictive, precedence)
+	}
+
+	groups := make([]groupLimit, 0, len(config.Groups))
+	for i, group := range config.Groups {
+		rLimit, err := convertLimitsToResources(group.Limit, *defaultLimit)
+		if err != nil {
+			return nil, errors.Wrapf(err, "customGroups[%d]", i)
+		}
+
+		groups = append(groups, groupLimit{
+			group: group.Group,
+			limit: *rLimit,
+		})
+	}
+
+	labels := make([]labelLimit, 0, len(config.Labels))
+	for i, label := range config.Labels {
+		if len(label.MatchLabels) == 0 {
+			return nil, errors.Errorf("customLabels[%d]: matchLabels must not be empty", i)
+		}
+
+		rLimit, err := convertLimitsToResources(label.Limit, *defaultLimit)
+		if err != nil {
+			return nil, errors.Wrapf(err, "customLabels[%d]", i)
+		}
+
+		labels = append(labels, labelLimit{
+			matchLabels: label.MatchLabels,
+			limit:       *rLimit,
+		})
+	}
+
+	policies, err := convertPolicies(config.Policies, *defaultLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	policyLabels := false
+	for _, policy := range policies {
+		policyLabels = policyLabels || len(policy.match.NamespaceLabels) > 0
+	}
+
+	log.Debugf("exluding namespaces: %v, names: %v, default limit: %+v", config.Namespaces, config.Names, config.defaultLimit())
+	return &configState{
+		defaultLimit:       *defaultLimit,
+		excludedNamespaces: excludedNamespaces,
+		excludedNames:      excludedNames,
+		namePatterns:       namePatterns,
+		namespaceSelectors: namespaceSelectors,
+		groups:             groups,
+		labels:             labels,
+		policies:           policies,
+		policyLabels:       policyLabels,
+		precedence:         precedence,
+		template:           templateFile,
+	}, nil
 }
 
-// Config describes Config files structure
-type Config struct {
-	Namespaces    map[string]Limit        `yaml:"customNamespaces" json:"namespaces"`
-	Names         map[NameNamespace]Limit `yaml:"customNames" json:"names"`
-	MaxCPULimit   string                  `yaml:"maxCPULimit" json:"maxCPULimit"`
-	MaxMemLimit   string                  `yaml:"maxMemLimit" json:"maxMemLimit"`
-	MaxCPURequest string                  `yaml:"maxCPURequest" json:"maxCPURequest"`
-	MaxMemRequest string                  `yaml:"maxMemRequest" json:"maxMemRequest"`
-	MaxPvcSize    string                  `yaml:"maxPVCSize" json:"maxPVCSize"`
+// SetNamespaceLookup enables namespaceSelectors, must be called before serving requests
+func (c *Configurer) SetNamespaceLookup(nsLookup NamespaceMetaGetter) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.nsLookup = nsLookup
 }
 
-// LimitResource resource limits
-type LimitResource struct {
-	CPULimit   *resource.Quantity
-	MemLimit   *resource.Quantity
-	CPURequest *resource.Quantity
-	MemRequest *resource.Quantity
-	PVCSize    *resource.Quantity
-	Unlimited  bool
+// SetReloadDebounce sets how long Watch waits for more file changes before reloading,
+// changes which happen within debounce are coalesced into a single reload
+func (c *Configurer) SetReloadDebounce(debounce time.Duration) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.reloadDebounce = debounce
 }
 
-// Configurer configures resource limits
-type Configurer struct {
-	filePath        string
-	refreshInterval time.Duration
-	w               *fsnotify.Watcher
+// SetConfiguredOnly enforces limits only in namespaces listed in customNamespaces, matched by namespaceSelectors
+// or having objects in customNames, other namespaces are unlimited instead of getting top level limits
+func (c *Configurer) SetConfiguredOnly(configuredOnly bool) {
+	c.m.Lock()
+	defer c.m.Unlock()
 
-	excludedNames      map[NameNamespace]LimitResource
-	excludedNamespaces map[string]LimitResource
-	maxCPULimit        *resource.Quantity
-	maxMemLimit        *resource.Quantity
-	maxCPURequest      *resource.Quantity
-	maxMemRequest      *resource.Quantity
-	maxPvcSize         *resource.Quantity
-	m                  sync.RWMutex
+	c.configuredOnly = configuredOnly
 }
 
-// NewConfigurer returns new Limits Configurer
-func NewConfigurer(filePath string, refreshInterval time.Duration) (*Configurer, error) {
-	w, err := fsnotify.NewWatcher()
-	if err != nil {
-		return nil, err
+func (c *Configurer) getReloadDebounce() time.Duration {
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	return c.reloadDebounce
+}
+
+func (c *Configurer) getTemplate() string {
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	return c.template
+}
+
+type namespaceMeta struct {
+	labels      map[string]string
+	annotations map[string]string
+	phase       corev1.NamespacePhase
+}
+
+// getNamespaceMeta fetches namespace metadata only if namespace can be matched by namespaceSelectors or policies.
+// It's called without holding the lock, so that slow lookups don't block config reloads.
+func (c *Configurer) getNamespaceMeta(namespace string) *namespaceMeta {
+	c.m.RLock()
+	_, configured := c.excludedNamespaces[namespace]
+	nsLookup := c.nsLookup
+	matchable := (len(c.namespaceSelectors) > 0 && !configured) || c.policyLabels
+	c.m.RUnlock()
+
+	if nsLookup == nil || !matchable {
+		return nil
 	}
-	if err := w.Add(filePath); err != nil {
-		return nil, err
+
+	labels, annotations, phase, err := nsLookup.GetNamespaceMeta(namespace)
+	if err != nil {
+		log.WithError(err).Warnf("namespace lookup failed, falling back to default limits for namespace: %s", namespace)
+		return nil
 	}
 
-	c := &Configurer{
-		filePath:           filePath,
-		w:                  w,
-		refreshInterval:    refreshInterval,
-		excludedNamespaces: nil,
-		excludedNames:      nil,
+	return &namespaceMeta{
+		labels:      labels,
+		annotations: annotations,
+		phase:       phase,
 	}
+}
 
-	if err := c.load(); err != nil {
-		return nil, err
+// selectNamespace returns limit of the first namespaceSelector which matches meta, must be called with read lock held.
+func (c *Configurer) selectNamespace(meta *namespaceMeta) (LimitResource, bool) {
+	if meta == nil {
+		return LimitResource{}, false
 	}
 
-	go c.Watch()
+	for _, selector := range c.namespaceSelectors {
+		if selector.matches(meta.labels, meta.annotations, meta.phase) {
+			return selector.limit, true
+		}
+	}
 
-	return c, nil
+	return LimitResource{}, false
 }
 
-func (c *Configurer) convertLimitsToResources(limit Limit) (*LimitResource, error) {
-	var cpu, mem, cpuRequest, memRequest, pvc *resource.Quantity
-	switch {
-	case limit.CPULimit != "":
-		q, err := resource.ParseQuantity(limit.CPULimit)
-		if err != nil {
-			return nil, errors.Wrap(err, "could not parse CPULimit")
-		}
-		cpu = &q
-	case c.maxCPULimit != nil:
-		q := c.maxCPULimit.DeepCopy()
-		cpu = &q
+// loadResourceTemplate reads container resources block, e.g.:
+//
+//	limits:
+//	  cpu: 2
+//	requests:
+//	  memory: 1Gi
+func loadResourceTemplate(filePath string) (LimitResource, error) {
+	templateFile, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return LimitResource{}, errors.Wrap(err, "unable to read resource template file")
 	}
 
-	switch {
-	case limit.MemLimit != "":
-		q, err := resource.ParseQuantity(limit.MemLimit)
-		if err != nil {
-			return nil, errors.Wrap(err, "could not parse MemLimit")
+	var resources corev1.ResourceRequirements
+	if err := k8syaml.UnmarshalStrict(templateFile, &resources); err != nil {
+		return LimitResource{}, errors.Wrap(err, "unable to unmarshal resource template file")
+	}
+
+	// other resources would be silently ignored, instead of capping containers
+	for name := range resources.Limits {
+		if name != corev1.ResourceCPU && name != corev1.ResourceMemory && name != corev1.ResourceEphemeralStorage {
+			return LimitResource{}, errors.Errorf("resource template limits %s is not supported, only cpu, memory and ephemeral-storage are", name)
 		}
+	}
+	for name := range resources.Requests {
+		if name != corev1.ResourceCPU && name != corev1.ResourceMemory {
+			return LimitResource{}, errors.Errorf("resource template requests %s is not supported, only cpu and memory are", name)
+		}
+	}
 
-		mem = &q
-	case c.maxMemLimit != nil:
-		q := c.maxMemLimit.DeepCopy()
-		mem = &q
+	limit := LimitResource{}
+	if q, ok := resources.Limits[corev1.ResourceCPU]; ok {
+		limit.CPULimit = &q
+	}
+	if q, ok := resources.Limits[corev1.ResourceMemory]; ok {
+		limit.MemLimit = &q
+	}
+	if q, ok := resources.Limits[corev1.ResourceEphemeralStorage]; ok {
+		limit.EphemeralStorageLimit = &q
+	}
+	if q, ok := resources.Requests[corev1.ResourceCPU]; ok {
+		limit.CPURequest = &q
+	}
+	if q, ok := resources.Requests[corev1.ResourceMemory]; ok {
+		limit.MemRequest = &q
 	}
 
-	switch {
-	case limit.CPURequest != "":
-		q, err := resource.ParseQuantity(limit.CPURequest)
-		if err != nil {
-			return nil, errors.Wrap(err, "could not parse CPURequest")
+	return limit, nil
+}
+
+// selectGroup returns limit of the first configured group, which user is in, must be called with read lock held.
+func (c *Configurer) selectGroup(userGroups []string) (LimitResource, bool) {
+	for _, group := range c.groups {
+		for _, userGroup := range userGroups {
+			if group.group == userGroup {
+				return group.limit, true
+			}
 		}
-		cpuRequest = &q
-	case c.maxCPURequest != nil:
-		q := c.maxCPURequest.DeepCopy()
-		cpuRequest = &q
 	}
 
-	switch {
-	case limit.MemRequest != "":
-		q, err := resource.ParseQuantity(limit.MemRequest)
-		if err != nil {
-			return nil, errors.Wrap(err, "could not parse MemLimit")
+	return LimitResource{}, false
+}
+
+// selectName returns limit of customNames entry of nn, exact names win over patterns,
+// must be called with read lock held.
+func (c *Configurer) selectName(nn NameNamespace) (LimitResource, bool) {
+	if limit, ok := c.excludedNames[nn]; ok {
+		return limit, true
+	}
+
+	for _, pattern := range c.namePatterns {
+		if pattern.matches(nn) {
+			return pattern.limit, true
 		}
+	}
 
-		memRequest = &q
-	case c.maxMemRequest != nil:
-		q := c.maxMemRequest.DeepCopy()
-		memRequest = &q
+	return LimitResource{}, false
+}
+
+// selectLabels returns limit of the first customLabels entry, which matches labels, must be called with read lock held.
+func (c *Configurer) selectLabels(labels map[string]string) (LimitResource, bool) {
+	if len(labels) == 0 {
+		return LimitResource{}, false
 	}
 
-	switch {
-	case limit.PVCSize != "":
-		q, err := resource.ParseQuantity(limit.PVCSize)
-		if err != nil {
-			return nil, errors.Wrap(err, "could not parse PVCSize")
+	for _, label := range c.labels {
+		if label.matches(labels) {
+			return label.limit, true
 		}
-		pvc = &q
-	case c.maxPvcSize != nil:
-		q := c.maxPvcSize.DeepCopy()
-		pvc = &q
 	}
 
-	return &LimitResource{
-		CPULimit:   cpu,
-		MemLimit:   mem,
-		CPURequest: cpuRequest,
-		MemRequest: memRequest,
-		PVCSize:    pvc,
-		Unlimited:  limit.Unlimited,
-	}, nil
+	return LimitResource{}, false
 }
 
-// load loads configuration
-func (c *Configurer) load() error {
-	configFile, err := ioutil.ReadFile(c.filePath)
-	if err != nil {
-		return errors.Wrap(err, "unable to read file")
+// unconfigured returns true if limits are enforced only in configured namespaces and nn is not configured
+// by name, labels or namespace, must be called with read lock held.
+func (c *Configurer) unconfigured(nn NameNamespace, labels map[string]string, meta *namespaceMeta) bool {
+	if !c.configuredOnly {
+		return false
 	}
 
-	var config Config
-	if err := yaml.Unmarshal(configFile, &config); err != nil {
-		return errors.Wrap(err, "unable to unmarshal yaml file")
+	if _, ok := c.selectName(nn); ok {
+		return false
 	}
 
-	c.m.Lock()
-	defer c.m.Unlock()
+	if _, ok := c.selectLabels(labels); ok {
+		return false
+	}
 
-	if config.MaxCPULimit != "" {
-		q, err := resource.ParseQuantity(config.MaxCPULimit)
-		if err != nil {
-			return errors.Wrap(err, "could not parse MaxCPULimit")
-		}
-		c.maxCPULimit = &q
+	if _, ok := c.excludedNamespaces[nn.Namespace]; ok {
+		return false
 	}
 
-	if config.MaxMemLimit != "" {
-		q, err := resource.ParseQuantity(config.MaxMemLimit)
-		if err != nil {
-			return errors.Wrap(err, "could not parse MaxMemLimit")
-		}
+	_, selected := c.selectNamespace(meta)
+	return !selected
+}
 
-		c.maxMemLimit = &q
+// restrictiveLimit merges customNames and customNamespaces limits of nn in mostRestrictive precedence,
+// returns false if precedence is mostSpecific or they don't both match. Must be called with read lock held.
+func (c *Configurer) restrictiveLimit(nn NameNamespace) (LimitResource, bool) {
+	if c.precedence != precedenceMostRestrictive {
+		return LimitResource{}, false
 	}
-	if config.MaxCPURequest != "" {
-		q, err := resource.ParseQuantity(config.MaxCPURequest)
-		if err != nil {
-			return errors.Wrap(err, "could not parse MaxCPURequest")
-		}
-		c.maxCPURequest = &q
+
+	nameLimit, ok := c.selectName(nn)
+	if !ok {
+		return LimitResource{}, false
 	}
 
-	if config.MaxMemRequest != "" {
-		q, err := resource.ParseQuantity(config.MaxMemRequest)
-		if err != nil {
-			return errors.Wrap(err, "could not parse MaxMemRequest")
-		}
+	namespaceLimit, ok := c.excludedNamespaces[nn.Namespace]
+	if !ok {
+		return LimitResource{}, false
+	}
 
-		c.maxMemRequest = &q
+	return mostRestrictive(nameLimit, namespaceLimit), true
+}
+
+// mostRestrictive returns lower of each limit, it's unlimited only if both are unlimited.
+// Other settings are taken from specific.
+func mostRestrictive(specific, general LimitResource) LimitResource {
+	out := specific.DeepCopy()
+	out.Unlimited = specific.Unlimited && general.Unlimited
+	out.UnlimitedPVC = specific.UnlimitedPVC && general.UnlimitedPVC
+	out.Uncapped = (specific.Unlimited || specific.Uncapped) && (general.Unlimited || general.Uncapped) && !out.Unlimited
+	out.ForbidEphemeralContainers = specific.ForbidEphemeralContainers || general.ForbidEphemeralContainers
+	if specific.Mode != modeWarn || general.Mode != modeWarn {
+		out.Mode = modeEnforce
 	}
+	out.Mutate = specific.Mutate || general.Mutate
+	out.CountMemoryEmptyDirs = specific.CountMemoryEmptyDirs || general.CountMemoryEmptyDirs
+	out.RequireLimits = specific.RequireLimits || general.RequireLimits
 
-	if config.MaxPvcSize != "" {
-		q, err := resource.ParseQuantity(config.MaxPvcSize)
-		if err != nil {
-			return errors.Wrap(err, "could not parse MaxPvcSize")
+	// pick returns lower quantity, quantities of unlimited side are ignored
+	pick := func(s, g *resource.Quantity, sUnlimited, gUnlimited bool) *resource.Quantity {
+		switch {
+		case sUnlimited:
+			return copyQuantity(g)
+		case gUnlimited, g == nil:
+			return copyQuantity(s)
+		case s == nil || g.Cmp(*s) < 0:
+			return copyQuantity(g)
+		default:
+			return copyQuantity(s)
 		}
+	}
 
-		c.maxPvcSize = &q
+	out.CPULimit = pick(specific.CPULimit, general.CPULimit, specific.Unlimited || specific.Uncapped, general.Unlimited || general.Uncapped)
+	out.MemLimit = pick(specific.MemLimit, general.MemLimit, specific.Unlimited || specific.Uncapped, general.Unlimited || general.Uncapped)
+	out.CPURequest = pick(specific.CPURequest, general.CPURequest, specific.Unlimited || specific.Uncapped, general.Unlimited || general.Uncapped)
+	out.MemRequest = pick(specific.MemRequest, general.MemRequest, specific.Unlimited || specific.Uncapped, general.Unlimited || general.Uncapped)
+	out.EphemeralStorageLimit = pick(specific.EphemeralStorageLimit, general.EphemeralStorageLimit, specific.Unlimited || specific.Uncapped, general.Unlimited || general.Uncapped)
+	out.PodMemLimit = pick(specific.PodMemLimit, general.PodMemLimit, specific.Unlimited || specific.Uncapped, general.Unlimited || general.Uncapped)
+	out.GuaranteedCPU = pick(specific.GuaranteedCPU, general.GuaranteedCPU, specific.Unlimited || specific.Uncapped, general.Unlimited || general.Uncapped)
+	out.GuaranteedMem = pick(specific.GuaranteedMem, general.GuaranteedMem, specific.Unlimited || specific.Uncapped, general.Unlimited || general.Uncapped)
+	out.CPUBurst = pick(specific.CPUBurst, general.CPUBurst, specific.Unlimited || specific.Uncapped, general.Unlimited || general.Uncapped)
+	out.MemBurst = pick(specific.MemBurst, general.MemBurst, specific.Unlimited || specific.Uncapped, general.Unlimited || general.Uncapped)
+	out.PVCSize = pick(specific.PVCSize, general.PVCSize, specific.UnlimitedPVC, general.UnlimitedPVC)
+	out.NamespacePVCSize = pick(specific.NamespacePVCSize, general.NamespacePVCSize, specific.UnlimitedPVC, general.UnlimitedPVC)
+	out.NamespaceGPUs = pick(specific.NamespaceGPUs, general.NamespaceGPUs, specific.Unlimited, general.Unlimited)
+
+	// storage classes are picked by name like extended resources
+	out.PVCSizePerStorageClass = nil
+	for _, side := range []LimitResource{specific, general} {
+		if side.UnlimitedPVC || side.PVCSizePerStorageClass == nil {
+			continue
+		}
+		if out.PVCSizePerStorageClass == nil {
+			out.PVCSizePerStorageClass = make(map[string]*resource.Quantity, len(side.PVCSizePerStorageClass))
+		}
+		for class, q := range side.PVCSizePerStorageClass {
+			out.PVCSizePerStorageClass[class] = pick(out.PVCSizePerStorageClass[class], q, false, false)
+		}
 	}
 
-	c.excludedNamespaces = make(map[string]LimitResource)
-	c.excludedNames = make(map[NameNamespace]LimitResource)
-	for ns, limit := range config.Namespaces {
-		rLimit, err := c.convertLimitsToResources(limit)
-		if err != nil {
-			return errors.Wrapf(err, "namespace: %s", ns)
+	// extended resources are picked by name, resources limited only on one side keep its limit
+	out.ExtendedResources = nil
+	for _, side := range []LimitResource{specific, general} {
+		if side.Unlimited || side.Uncapped || side.ExtendedResources == nil {
+			continue
+		}
+		if out.ExtendedResources == nil {
+			out.ExtendedResources = make(map[corev1.ResourceName]*resource.Quantity, len(side.ExtendedResources))
+		}
+		for name, q := range side.ExtendedResources {
+			out.ExtendedResources[name] = pick(out.ExtendedResources[name], q, false, false)
+		}
+	}
+
+	switch {
+	case specific.Unlimited || specific.Uncapped:
+		if general.MaxLimitRequestRatio != nil {
+			ratio := *general.MaxLimitRequestRatio
+			out.MaxLimitRequestRatio = &ratio
 		}
+	case general.Unlimited || general.Uncapped, general.MaxLimitRequestRatio == nil:
+	case out.MaxLimitRequestRatio == nil || *general.MaxLimitRequestRatio < *out.MaxLimitRequestRatio:
+		ratio := *general.MaxLimitRequestRatio
+		out.MaxLimitRequestRatio = &ratio
+	}
 
-		c.excludedNamespaces[ns] = *rLimit
+	// fraction is a standard, not a ceiling, so the specific one applies if it's set
+	if out.RequestFraction == nil && !specific.Unlimited && !general.Unlimited && general.RequestFraction != nil {
+		fraction := *general.RequestFraction
+		out.RequestFraction = &fraction
+		out.RequestFractionTolerance = general.RequestFractionTolerance
 	}
 
-	for nn, limit := range config.Names {
-		rLimit, err := c.convertLimitsToResources(limit)
-		if err != nil {
-			return errors.Wrapf(err, "nn: %s", nn)
+	// higher minimum is more restrictive
+	pickHigher := func(s, g *resource.Quantity, sUnlimited, gUnlimited bool) *resource.Quantity {
+		switch {
+		case sUnlimited:
+			return copyQuantity(g)
+		case gUnlimited, g == nil:
+			return copyQuantity(s)
+		case s == nil || g.Cmp(*s) > 0:
+			return copyQuantity(g)
+		default:
+			return copyQuantity(s)
 		}
+	}
 
-		c.excludedNames[nn] = *rLimit
+	out.MinCPURequest = pickHigher(specific.MinCPURequest, general.MinCPURequest, specific.Unlimited, general.Unlimited)
+	out.MinMemRequest = pickHigher(specific.MinMemRequest, general.MinMemRequest, specific.Unlimited, general.Unlimited)
+
+	switch {
+	case specific.UnlimitedPVC:
+		out.MinPVCSize = copyQuantity(general.MinPVCSize)
+	case general.UnlimitedPVC, general.MinPVCSize == nil:
+	case out.MinPVCSize == nil || general.MinPVCSize.Cmp(*out.MinPVCSize) > 0:
+		out.MinPVCSize = copyQuantity(general.MinPVCSize)
 	}
 
-	log.Debugf("exluding namespaces: %v, names: %v, maxCPULimit: %v, maxMemLimit: %v, maxPvcSize: %v", config.Namespaces, config.Names, c.maxCPULimit, c.maxMemLimit, c.maxPvcSize)
-	return nil
+	return out
+}
+
+// GetPodLimit gets pod resource limits from configmap, groups are groups of the requesting user.
+func (c *Configurer) GetPodLimit(nn NameNamespace, groups ...string) LimitResource {
+	return c.GetPodLimitByLabels(nn, nil, groups...)
 }
 
-// GetPodLimit gets pod CPU and memory limit from configmap.
-func (c *Configurer) GetPodLimit(nn NameNamespace) (cpuLimit, memLimit, cpuRequest, memRequest *resource.Quantity, unlimited bool) {
+// GetPodLimitByLabels returns limit of pod, labels are the workload's metadata labels matched against customLabels.
+// Precedence is customNames, customLabels, customGroups, customNamespaces and default limit.
+func (c *Configurer) GetPodLimitByLabels(nn NameNamespace, labels map[string]string, groups ...string) LimitResource {
+	meta := c.getNamespaceMeta(nn.Namespace)
+
 	c.m.RLock()
 	defer c.m.RUnlock()
 
+	if policy, ok := c.selectPolicy(nn, groups, meta); ok {
+		log.Debugf("policy %s applies to name: %s, namespace: %s", policy.name, nn.Name, nn.Namespace)
+		return policy.limit.DeepCopy()
+	}
+
+	if c.unconfigured(nn, labels, meta) {
+		return LimitResource{Unlimited: true}
+	}
+
+	if limit, ok := c.restrictiveLimit(nn); ok {
+		return limit
+	}
+
 	if limit, ok := c.excludedNamespaces[nn.Namespace]; ok {
 		if limit.Unlimited {
-			return nil, nil, nil, nil, true
+			return LimitResource{Unlimited: true}
 		}
 	}
 
-	if limit, ok := c.excludedNames[nn]; ok {
+	if limit, ok := c.selectName(nn); ok {
 		if limit.Unlimited {
-			return nil, nil, nil, nil, true
+			return LimitResource{Unlimited: true}
 		}
 
-		if limit.CPULimit != nil {
-			q := limit.CPULimit.DeepCopy()
-			cpuLimit = &q
-		}
-		if limit.MemLimit != nil {
-			q := limit.MemLimit.DeepCopy()
-			memLimit = &q
-		}
-		if limit.CPURequest != nil {
-			q := limit.CPURequest.DeepCopy()
-			cpuRequest = &q
-		}
+		return limit.DeepCopy()
+	}
 
-		if limit.MemRequest != nil {
-			q := limit.MemRequest.DeepCopy()
-			memRequest = &q
-		}
+	if limit, ok := c.selectLabels(labels); ok {
+		return limit.DeepCopy()
+	}
 
-		return cpuLimit, memLimit, cpuRequest, memRequest, false
+	if limit, ok := c.selectGroup(groups); ok {
+		return limit.DeepCopy()
 	}
 
 	if limit, ok := c.excludedNamespaces[nn.Namespace]; ok {
-		if limit.CPULimit != nil {
-			q := limit.CPULimit.DeepCopy()
-			cpuLimit = &q
-		}
+		return limit.DeepCopy()
+	}
 
-		if limit.MemLimit != nil {
-			q := limit.MemLimit.DeepCopy()
-			memLimit = &q
-		}
-		if limit.CPURequest != nil {
-			q := limit.CPURequest.DeepCopy()
-			cpuRequest = &q
-		}
+	if limit, ok := c.selectNamespace(meta); ok {
+		return limit.DeepCopy()
+	}
 
-		if limit.MemRequest != nil {
-			q := limit.MemRequest.DeepCopy()
-			memRequest = &q
-		}
+	return c.defaultLimit.DeepCopy()
+}
 
-		return cpuLimit, memLimit, cpuRequest, memRequest, false
+// pvcLimit returns limit which applies to PVC, must be called with read lock held.
+func (c *Configurer) pvcLimit(nn NameNamespace, meta *namespaceMeta) LimitResource {
+	if policy, ok := c.selectPolicy(nn, nil, meta); ok {
+		return policy.limit
 	}
 
-	if c.maxCPULimit != nil {
-		q := c.maxCPULimit.DeepCopy()
-		cpuLimit = &q
+	if c.unconfigured(nn, nil, meta) {
+		return LimitResource{UnlimitedPVC: true}
 	}
-	if c.maxMemLimit != nil {
-		q := c.maxMemLimit.DeepCopy()
-		memLimit = &q
+
+	if limit, ok := c.restrictiveLimit(nn); ok {
+		return limit
+	}
+
+	if limit, ok := c.selectName(nn); ok {
+		return limit
 	}
-	if c.maxCPURequest != nil {
-		q := c.maxCPURequest.DeepCopy()
-		cpuRequest = &q
+
+	if limit, ok := c.excludedNamespaces[nn.Namespace]; ok {
+		return limit
 	}
-	if c.maxMemRequest != nil {
-		q := c.maxMemRequest.DeepCopy()
-		memRequest = &q
+
+	if limit, ok := c.selectNamespace(meta); ok {
+		return limit
 	}
 
-	return cpuLimit, memLimit, cpuRequest, memRequest, false
+	return c.defaultLimit
 }
 
 // GetMaxPVCSize returns PVC limit, might return nil if both maxPvcSize and custom pvc size is not set
 func (c *Configurer) GetMaxPVCSize(nn NameNamespace) (pvc *resource.Quantity, unlimited bool) {
+	return c.GetMaxStorageClassPVCSize(nn, nil)
+}
+
+// GetMaxStorageClassPVCSize returns PVC limit of the storage class, falling back to maxPVCSize if the class isn't limited.
+// Nil storage class, which is the cluster default class, and empty storage class, which disables dynamic provisioning,
+// are limited by maxPVCSize.
+func (c *Configurer) GetMaxStorageClassPVCSize(nn NameNamespace, storageClass *string) (pvc *resource.Quantity, unlimited bool) {
+	meta := c.getNamespaceMeta(nn.Namespace)
+
 	c.m.RLock()
 	defer c.m.RUnlock()
 
-	if limit, ok := c.excludedNames[nn]; ok {
-		if limit.Unlimited {
-			return nil, true
-		}
+	limit := c.pvcLimit(nn, meta)
+	if limit.UnlimitedPVC {
+		return nil, true
+	}
 
-		if limit.PVCSize != nil {
-			q := limit.PVCSize.DeepCopy()
-			pvc = &q
+	if storageClass != nil && *storageClass != "" {
+		if size, ok := limit.PVCSizePerStorageClass[*storageClass]; ok {
+			return copyQuantity(size), false
 		}
+	}
+
+	return copyQuantity(limit.PVCSize), false
+}
 
-		return pvc, false
+// GetMaxNamespaceGPUs returns total GPU requests limit of the namespace, might return nil if it's not set
+func (c *Configurer) GetMaxNamespaceGPUs(namespace string) (gpus *resource.Quantity, unlimited bool) {
+	meta := c.getNamespaceMeta(namespace)
+
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	limit := c.namespaceLimit(namespace, meta)
+	if limit.Unlimited {
+		return nil, true
 	}
 
-	if limit, ok := c.excludedNamespaces[nn.Namespace]; ok {
-		if limit.Unlimited {
-			return nil, true
-		}
+	return copyQuantity(limit.NamespaceGPUs), false
+}
 
-		if limit.PVCSize != nil {
-			q := limit.PVCSize.DeepCopy()
-			pvc = &q
-		}
-		return pvc, false
+// GetMaxNamespacePVCSize returns total PVC size limit of the namespace, might return nil if it's not set
+func (c *Configurer) GetMaxNamespacePVCSize(namespace string) (pvc *resource.Quantity, unlimited bool) {
+	meta := c.getNamespaceMeta(namespace)
+
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	limit := c.namespaceLimit(namespace, meta)
+	if limit.UnlimitedPVC {
+		return nil, true
+	}
+
+	return copyQuantity(limit.NamespacePVCSize), false
+}
+
+// namespaceLimit returns limit of the namespace as a whole, e.g. of namespace GPU and PVC budgets. customNames are not matched,
+// so that a name pattern (e.g. *) doesn't decide the budget of the namespace. Must be called with read lock held.
+func (c *Configurer) namespaceLimit(namespace string, meta *namespaceMeta) LimitResource {
+	if policy, ok := c.selectPolicy(NameNamespace{Namespace: namespace}, nil, meta); ok {
+		return policy.limit
+	}
+
+	if limit, ok := c.excludedNamespaces[namespace]; ok {
+		return limit
+	}
+
+	if limit, ok := c.selectNamespace(meta); ok {
+		return limit
+	}
+
+	if c.configuredOnly {
+		return LimitResource{Unlimited: true, UnlimitedPVC: true}
 	}
-	if c.maxPvcSize != nil {
-		q := c.maxPvcSize.DeepCopy()
-		pvc = &q
+
+	return c.defaultLimit
+}
+
+// GetMinPVCSize returns minimum PVC size, might return nil if both minPvcSize and custom min pvc size is not set
+func (c *Configurer) GetMinPVCSize(nn NameNamespace) (pvc *resource.Quantity, unlimited bool) {
+	meta := c.getNamespaceMeta(nn.Namespace)
+
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	limit := c.pvcLimit(nn, meta)
+	if limit.UnlimitedPVC {
+		return nil, true
 	}
 
-	return pvc, false
+	return copyQuantity(limit.MinPVCSize), false
 }
 
 // Watch starts the watching of filepath changes and reloads configuration.
+// Reloads are debounced, so that rapid file changes result in a single reload.
 func (c *Configurer) Watch() {
 	tick := time.NewTicker(c.refreshInterval)
 	defer tick.Stop()
 
+	debounce := time.NewTimer(c.getReloadDebounce())
+	debounce.Stop()
+	defer debounce.Stop()
+
 	for {
 		select {
 		case <-tick.C:
-		case event := <-c.w.Events:
-			if event.Name != c.filePath {
+			debounce.Reset(c.getReloadDebounce())
+			continue
+		case event, ok := <-c.w.Events:
+			if !ok {
+				return
+			}
+			template := c.getTemplate()
+			if event.Name == c.filePath || (template != "" && event.Name == template) {
+				// replaced file is no longer watched
+				if event.Op&(fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0 {
+					c.rewatch(event.Name)
+				}
+
+				debounce.Reset(c.getReloadDebounce())
 				continue
 			}
-		case err := <-c.w.Errors:
+
+			// other files of the directories matter only if they change where the config or template file points to
+			if target := resolveTarget(c.filePath); target != c.target {
+				c.target = target
+				debounce.Reset(c.getReloadDebounce())
+			}
+			if template != "" {
+				if target := resolveTarget(template); target != c.templateTarget {
+					c.templateTarget = target
+					debounce.Reset(c.getReloadDebounce())
+				}
+			}
+			continue
+		case err, ok := <-c.w.Errors:
+			if !ok {
+				return
+			}
 			if err != nil {
 				log.WithError(err).Error("watch error")
 			}
 			continue
+		case <-debounce.C:
 		}
 
-		err := c.load()
-		if err != nil {
-			reloadErrorsCounter.Inc()
-			log.WithError(err).Error("config load error")
+		if err := c.Reload(); err != nil {
+			log.WithError(err).Error("config load error, previous config stays in use")
+		}
+	}
+}
+
+// Reload loads configuration file, previous configuration stays in use if it fails
+func (c *Configurer) Reload() error {
+	defer reloadCounter.Inc()
+
+	if err := c.load(); err != nil {
+		configLoadedGauge.Set(0)
+		reloadErrorsCounter.Inc()
+		return err
+	}
+	return nil
+}
+
+// rewatch adds watch of the config or template file again, it fails if the file is removed and not yet replaced,
+// then directory's create event adds it later
+func (c *Configurer) rewatch(path string) {
+	if err := c.w.Add(path); err != nil {
+		log.WithError(err).Debugf("unable to watch %s", path)
+	}
+}
+
+// watchTemplate moves watch from previous resource template file to template, its directory is watched too,
+// like the config file's, must be called with lock held
+func (c *Configurer) watchTemplate(previous, template string) {
+	configDir := filepath.Dir(c.filePath)
+	if previous != "" {
+		if err := c.w.Remove(previous); err != nil {
+			log.WithError(err).Debugf("unable to stop watching %s", previous)
+		}
+		if dir := filepath.Dir(previous); dir != configDir && (template == "" || dir != filepath.Dir(template)) {
+			if err := c.w.Remove(dir); err != nil {
+				log.WithError(err).Debugf("unable to stop watching %s", dir)
+			}
+		}
+	}
+
+	c.templateTarget = ""
+	if template == "" {
+		return
+	}
+
+	c.templateTarget = resolveTarget(template)
+	c.rewatch(template)
+	if dir := filepath.Dir(template); dir != configDir {
+		if err := c.w.Add(dir); err != nil {
+			log.WithError(err).Errorf("unable to watch %s, resource template changes are picked up every refresh interval", dir)
 		}
+	}
+}
 
-		reloadCounter.Inc()
+// resolveTarget returns path with symlinks resolved, or path itself if it can't be resolved
+func resolveTarget(path string) string {
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return path
 	}
+
+	return target
 }
 
 // Close stop the inotify watching
@@ -1,15 +1,27 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"k8s.io/api/admission/v1beta1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 func TestConfigGetKubeSystem(t *testing.T) {
 	configFile := "./testdata/test.yaml"
-	configer, err := NewConfigurer(configFile, 1*time.Hour)
+	configer, err := NewConfigurer(configFile, 1*time.Hour, false)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -24,22 +36,30 @@ func TestConfigGetKubeSystem(t *testing.T) {
 	// Value is in bytes, expected Limit 50Gb
 	assert.Equal(t, int64(50*1024*1024*1024), pvc.Value())
 
-	cpu, mem, cpuRequest, memRequest, unlimited := configer.GetPodLimit(NameNamespace{
+	minPvc, unlimited := configer.GetMinPVCSize(NameNamespace{
 		Name:      "",
 		Namespace: "kube-system",
 	})
 
 	assert.Equal(t, false, unlimited)
-	assert.Equal(t, int64(1), cpu.Value())
-	assert.Equal(t, int64(2*1024*1024*1024), mem.Value())
+	assert.Equal(t, int64(1024*1024*1024), minPvc.Value())
 
-	assert.Equal(t, int64(500), cpuRequest.MilliValue())
-	assert.Equal(t, int64(1*1024*1024*1024), memRequest.Value())
+	limit := configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "kube-system",
+	})
+
+	assert.Equal(t, false, limit.Unlimited)
+	assert.Equal(t, int64(1), limit.CPULimit.Value())
+	assert.Equal(t, int64(2*1024*1024*1024), limit.MemLimit.Value())
+
+	assert.Equal(t, int64(500), limit.CPURequest.MilliValue())
+	assert.Equal(t, int64(1*1024*1024*1024), limit.MemRequest.Value())
 }
 
 func TestConfigGetMonitoring(t *testing.T) {
 	configFile := "./testdata/test.yaml"
-	configer, err := NewConfigurer(configFile, 1*time.Hour)
+	configer, err := NewConfigurer(configFile, 1*time.Hour, false)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -54,22 +74,30 @@ func TestConfigGetMonitoring(t *testing.T) {
 	// Value is in bytes, expected Limit 50Gb
 	assert.Equal(t, int64(50*1024*1024*1024), pvc.Value())
 
-	cpu, mem, cpuRequest, memRequest, unlimited := configer.GetPodLimit(NameNamespace{
+	limit := configer.GetPodLimit(NameNamespace{
 		Name:      "",
 		Namespace: "monitoring",
 	})
 
-	assert.Equal(t, false, unlimited)
-	assert.Equal(t, int64(2), cpu.Value())
-	assert.Equal(t, int64(3*1024*1024*1024), mem.Value())
+	assert.Equal(t, false, limit.Unlimited)
+	assert.Equal(t, int64(2), limit.CPULimit.Value())
+	assert.Equal(t, int64(3*1024*1024*1024), limit.MemLimit.Value())
+
+	assert.Equal(t, int64(1), limit.CPURequest.Value())
+	assert.Equal(t, int64(3*1024*1024*1024), limit.MemRequest.Value())
+
+	assert.Equal(t, int64(1*1024*1024*1024), limit.MemBurst.Value())
+	assert.Nil(t, limit.CPUBurst)
 
-	assert.Equal(t, int64(1), cpuRequest.Value())
-	assert.Equal(t, int64(3*1024*1024*1024), memRequest.Value())
+	assert.Equal(t, 1, *limit.MaxResourceClaims)
+	assert.Nil(t, limit.AllowedDeviceClasses)
+	assert.Equal(t, "forbidden", limit.CPULimitPolicy)
+	assert.Equal(t, "forbid", limit.SwapPolicy)
 }
 
 func TestConfigGetDefault(t *testing.T) {
 	configFile := "./testdata/test.yaml"
-	configer, err := NewConfigurer(configFile, 1*time.Hour)
+	configer, err := NewConfigurer(configFile, 1*time.Hour, false)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -83,22 +111,68 @@ func TestConfigGetDefault(t *testing.T) {
 	assert.Equal(t, true, unlimited)
 	assert.Nil(t, pvc)
 
-	cpu, mem, cpuRequest, memRequest, unlimited := configer.GetPodLimit(NameNamespace{
+	limit := configer.GetPodLimit(NameNamespace{
 		Name:      "",
 		Namespace: "default",
 	})
 
-	assert.Equal(t, true, unlimited)
-	assert.Nil(t, cpu)
-	assert.Nil(t, mem)
+	assert.Equal(t, true, limit.Unlimited)
+	assert.Nil(t, limit.CPULimit)
+	assert.Nil(t, limit.MemLimit)
+
+	assert.Nil(t, limit.CPURequest)
+	assert.Nil(t, limit.MemRequest)
+}
+
+func TestConfigGetUnlimitedIndependent(t *testing.T) {
+	configFile := "./testdata/test.yaml"
+	configer, err := NewConfigurer(configFile, 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	nn := NameNamespace{
+		Name:      "",
+		Namespace: "ci",
+	}
+
+	limit := configer.GetPodLimit(nn)
+	assert.Equal(t, true, limit.Unlimited)
+
+	pvc, unlimited := configer.GetMaxPVCSize(nn)
+	assert.Equal(t, false, unlimited)
+	assert.Equal(t, int64(50*1024*1024*1024), pvc.Value())
+
+	minPvc, unlimited := configer.GetMinPVCSize(nn)
+	assert.Equal(t, false, unlimited)
+	assert.Equal(t, int64(1024*1024*1024), minPvc.Value())
+}
+
+func TestConfigGetUncapped(t *testing.T) {
+	configFile := "./testdata/test.yaml"
+	configer, err := NewConfigurer(configFile, 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	nn := NameNamespace{Name: "", Namespace: "sandbox"}
+	limit := configer.GetPodLimit(nn)
+	assert.True(t, limit.Uncapped)
+	assert.False(t, limit.Unlimited)
+	assert.Nil(t, limit.withoutCeilings().CPULimit)
+	assert.Nil(t, limit.withoutCeilings().Sidecar.MemLimit)
 
-	assert.Nil(t, cpuRequest)
-	assert.Nil(t, memRequest)
+	// PVCs are limited by top level declaration
+	pvc, unlimited := configer.GetMaxPVCSize(nn)
+	assert.False(t, unlimited)
+	assert.Equal(t, int64(50*1024*1024*1024), pvc.Value())
 }
 
 func TestConfigGetTestNamespace(t *testing.T) {
 	configFile := "./testdata/test.yaml"
-	configer, err := NewConfigurer(configFile, 1*time.Hour)
+	configer, err := NewConfigurer(configFile, 1*time.Hour, false)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -112,21 +186,37 @@ func TestConfigGetTestNamespace(t *testing.T) {
 	assert.Equal(t, false, unlimited)
 	assert.Equal(t, int64(10*1024*1024*1024), pvc.Value())
 
-	cpu, mem, cpuRequest, memRequest, unlimited := configer.GetPodLimit(NameNamespace{
+	minPvc, unlimited := configer.GetMinPVCSize(NameNamespace{
 		Name:      "",
 		Namespace: "test-namespace",
 	})
 
 	assert.Equal(t, false, unlimited)
-	assert.Equal(t, int64(1), cpu.Value())
-	assert.Equal(t, int64(1*1024*1024*1024), mem.Value())
-	assert.Equal(t, int64(500), cpuRequest.MilliValue())
-	assert.Equal(t, int64(500*1024*1024), memRequest.Value())
+	assert.Equal(t, int64(100*1024*1024), minPvc.Value())
+
+	nsPvc, unlimited := configer.GetMaxNamespacePVCSize("test-namespace")
+	assert.Equal(t, false, unlimited)
+	assert.Equal(t, int64(100*1024*1024*1024), nsPvc.Value())
+
+	nsPvc, _ = configer.GetMaxNamespacePVCSize("kube-system")
+	assert.Nil(t, nsPvc)
+
+	limit := configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "test-namespace",
+	})
+
+	assert.Equal(t, false, limit.Unlimited)
+	assert.Equal(t, int64(1), limit.CPULimit.Value())
+	assert.Equal(t, int64(1*1024*1024*1024), limit.MemLimit.Value())
+	assert.Equal(t, int64(500), limit.CPURequest.MilliValue())
+	assert.Equal(t, int64(500*1024*1024), limit.MemRequest.Value())
+	assert.Equal(t, true, limit.ForbidEphemeralContainers)
 }
 
 func TestConfigGetTestPod(t *testing.T) {
 	configFile := "./testdata/test.yaml"
-	configer, err := NewConfigurer(configFile, 1*time.Hour)
+	configer, err := NewConfigurer(configFile, 1*time.Hour, false)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -140,14 +230,1766 @@ func TestConfigGetTestPod(t *testing.T) {
 	assert.Equal(t, false, unlimited)
 	assert.Equal(t, int64(15*1024*1024*1024), pvc.Value())
 
-	cpu, mem, cpuRequest, memRequest, unlimited := configer.GetPodLimit(NameNamespace{
+	limit := configer.GetPodLimit(NameNamespace{
 		Name:      "deployment-name",
 		Namespace: "test-namespace",
 	})
 
-	assert.Equal(t, false, unlimited)
-	assert.Equal(t, int64(3), cpu.Value())
-	assert.Equal(t, int64(5*1024*1024*1024), mem.Value())
-	assert.Equal(t, int64(2), cpuRequest.Value())
-	assert.Equal(t, int64(3*1024*1024*1024), memRequest.Value())
+	assert.Equal(t, false, limit.Unlimited)
+	assert.Equal(t, int64(3), limit.CPULimit.Value())
+	assert.Equal(t, int64(5*1024*1024*1024), limit.MemLimit.Value())
+	assert.Equal(t, int64(2), limit.CPURequest.Value())
+	assert.Equal(t, int64(3*1024*1024*1024), limit.MemRequest.Value())
+}
+
+func TestConfigGetSidecar(t *testing.T) {
+	configFile := "./testdata/test.yaml"
+	configer, err := NewConfigurer(configFile, 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	limit := configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "kube-system",
+	})
+
+	assert.Equal(t, int64(500), limit.Sidecar.CPULimit.MilliValue())
+	assert.Equal(t, int64(256*1024*1024), limit.Sidecar.MemLimit.Value())
+
+	limit = configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "test-namespace",
+	})
+
+	assert.Equal(t, int64(500), limit.Sidecar.CPULimit.MilliValue())
+	assert.Equal(t, int64(128*1024*1024), limit.Sidecar.MemLimit.Value())
+	assert.Nil(t, limit.Sidecar.CPURequest)
+}
+
+func TestConfigRestartPolicies(t *testing.T) {
+	configFile := "./testdata/test.yaml"
+	configer, err := NewConfigurer(configFile, 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	limit := configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "jobs",
+	})
+
+	never := limit.ForRestartPolicy(corev1.RestartPolicyNever)
+	assert.Equal(t, int64(4), never.CPULimit.Value())
+	assert.Equal(t, int64(2*1024*1024*1024), never.MemLimit.Value())
+
+	assert.Equal(t, int64(2), limit.ForRestartPolicy(corev1.RestartPolicyOnFailure).CPULimit.Value())
+	assert.Equal(t, int64(1), limit.ForRestartPolicy(corev1.RestartPolicyAlways).CPULimit.Value())
+	assert.Equal(t, int64(1), limit.ForRestartPolicy("").CPULimit.Value())
+
+	limit = configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "kube-system",
+	})
+	assert.Nil(t, limit.RestartPolicies)
+	assert.Equal(t, int64(1), limit.ForRestartPolicy(corev1.RestartPolicyNever).CPULimit.Value())
+}
+
+func TestConfigWorkloadClasses(t *testing.T) {
+	configFile := "./testdata/test.yaml"
+	configer, err := NewConfigurer(configFile, 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	limit := configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "classes",
+	})
+
+	job := limit.ForWorkloadClass(workloadClassJob)
+	assert.Equal(t, int64(1), job.CPULimit.Value())
+	assert.Equal(t, int64(8*1024*1024*1024), job.MemLimit.Value())
+
+	service := limit.ForWorkloadClass(workloadClassService)
+	assert.Equal(t, int64(4), service.CPULimit.Value())
+	assert.Equal(t, int64(1024*1024*1024), service.MemLimit.Value())
+
+	// unknown class uses namespace limit
+	assert.Equal(t, int64(2), limit.ForWorkloadClass("ml").CPULimit.Value())
+}
+
+func TestConfigTopLevelWorkloadClasses(t *testing.T) {
+	f, err := ioutil.TempFile("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString(`
+maxCPULimit: 2
+maxMemLimit: 1Gi
+workloadClasses:
+  job:
+    maxMemLimit: 8Gi
+customNamespaces:
+  team-a:
+    maxPVCSize: 10Gi
+  team-b:
+    maxMemLimit: 2Gi
+    workloadClasses:
+      job:
+        maxCPULimit: 1
+`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	configer, err := NewConfigurer(f.Name(), 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	// top level classes apply cluster-wide
+	job := configer.GetPodLimit(NameNamespace{Namespace: "default"}).ForWorkloadClass(workloadClassJob)
+	assert.Equal(t, int64(8*1024*1024*1024), job.MemLimit.Value())
+	assert.Equal(t, int64(2), job.CPULimit.Value())
+	service := configer.GetPodLimit(NameNamespace{Namespace: "default"}).ForWorkloadClass(workloadClassService)
+	assert.Equal(t, int64(1024*1024*1024), service.MemLimit.Value())
+
+	// declarations without workloadClasses inherit top level ones as a whole
+	job = configer.GetPodLimit(NameNamespace{Namespace: "team-a"}).ForWorkloadClass(workloadClassJob)
+	assert.Equal(t, int64(8*1024*1024*1024), job.MemLimit.Value())
+
+	// declared classes replace top level ones and are based on their declaration
+	job = configer.GetPodLimit(NameNamespace{Namespace: "team-b"}).ForWorkloadClass(workloadClassJob)
+	assert.Equal(t, int64(1), job.CPULimit.Value())
+	assert.Equal(t, int64(2*1024*1024*1024), job.MemLimit.Value())
+}
+
+func TestConfigInvalidRestartPolicies(t *testing.T) {
+	for _, config := range []string{
+		"restartPolicies: {Sometimes: {maxCPULimit: 4}}",
+		"restartPolicies: {Never: {restartPolicies: {OnFailure: {maxCPULimit: 4}}}}",
+	} {
+		f, err := ioutil.TempFile("", "config")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+
+		if _, err := f.WriteString(config); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		_, err = NewConfigurer(f.Name(), 1*time.Hour, false)
+		assert.Error(t, err, config)
+	}
+}
+
+func TestConfigContainers(t *testing.T) {
+	configer, err := NewConfigurer("./testdata/test.yaml", 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	limit := configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "mesh",
+	})
+
+	proxy := limit.ForContainer(corev1.Container{Name: "istio-proxy"})
+	assert.Equal(t, int64(500), proxy.CPULimit.MilliValue())
+	assert.Equal(t, int64(256*1024*1024), proxy.MemLimit.Value())
+	// taken from top level declaration
+	assert.Equal(t, int64(1024*1024*1024), proxy.MemRequest.Value())
+
+	app := limit.ForContainer(corev1.Container{Name: "app"})
+	assert.Equal(t, int64(4), app.CPULimit.Value())
+	assert.Equal(t, int64(2*1024*1024*1024), app.MemLimit.Value())
+
+	// uncapped limits waive ceilings of overrides too
+	assert.Nil(t, limit.withoutCeilings().ForContainer(corev1.Container{Name: "istio-proxy"}).CPULimit)
+}
+
+func TestConfigUserNamespace(t *testing.T) {
+	configer, err := NewConfigurer("./testdata/test.yaml", 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	limit := configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "sandboxed",
+	})
+
+	hostUsers := false
+	userns := limit.ForUserNamespace(&hostUsers)
+	assert.Equal(t, int64(2), userns.CPULimit.Value())
+	assert.Equal(t, cpuLimitRequired, userns.CPULimitPolicy)
+	// taken from top level declaration
+	assert.Equal(t, int64(2*1024*1024*1024), userns.MemLimit.Value())
+
+	hostUsers = true
+	assert.Equal(t, int64(1), limit.ForUserNamespace(&hostUsers).CPULimit.Value())
+	assert.Equal(t, "", limit.ForUserNamespace(&hostUsers).CPULimitPolicy)
+	// hostUsers defaults to true
+	assert.Equal(t, int64(1), limit.ForUserNamespace(nil).CPULimit.Value())
+}
+
+func TestConfigInvalidUserNamespace(t *testing.T) {
+	for _, config := range []string{
+		"userNamespace: {maxCPULimit: half}",
+		"userNamespace: {userNamespace: {maxCPULimit: 4}}",
+		"customNamespaces: {sandboxed: {userNamespace: {cpuLimitPolicy: sometimes}}}",
+	} {
+		f, err := ioutil.TempFile("", "config")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+
+		if _, err := f.WriteString(config); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		_, err = NewConfigurer(f.Name(), 1*time.Hour, false)
+		assert.Error(t, err, config)
+	}
+}
+
+func TestConfigInvalidContainers(t *testing.T) {
+	for _, config := range []string{
+		"containers: {istio-proxy: {maxCPULimit: half}}",
+		"containers: {istio-proxy: {containers: {app: {maxCPULimit: 4}}}}",
+		"containers: {istio-proxy: {unlimited: true, maxCPULimit: 1}}",
+	} {
+		f, err := ioutil.TempFile("", "config")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+
+		if _, err := f.WriteString(config); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		_, err = NewConfigurer(f.Name(), 1*time.Hour, false)
+		assert.Error(t, err, config)
+	}
+}
+
+func TestConfigLabelLimits(t *testing.T) {
+	configFile := "./testdata/test.yaml"
+	configer, err := NewConfigurer(configFile, 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	limit := configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "tracks",
+	})
+
+	canary := limit.ForLabels(map[string]string{"app": "web", "track": "canary"})
+	assert.Equal(t, "500m", canary.CPULimit.String())
+	assert.Equal(t, "2Gi", canary.MemLimit.String())
+	// label limits don't inherit classes
+	assert.Equal(t, "500m", canary.ForWorkloadClass(workloadClassJob).CPULimit.String())
+
+	stable := limit.ForLabels(map[string]string{"track": "stable"})
+	assert.Equal(t, "2", stable.CPULimit.String())
+	assert.Equal(t, "4Gi", stable.MemLimit.String())
+
+	other := limit.ForLabels(map[string]string{"app": "web"})
+	assert.Equal(t, "2", other.CPULimit.String())
+	assert.Equal(t, "4", other.ForWorkloadClass(workloadClassJob).CPULimit.String())
+}
+
+func TestConfigInvalidLabelLimits(t *testing.T) {
+	for _, config := range []string{
+		"customNamespaces: {web: {labelLimits: [{limit: {maxCPULimit: 1}}]}}",
+		"customNamespaces: {web: {labelLimits: [{matchLabels: {track: canary}, limit: {labelLimits: [{matchLabels: {tier: web}}]}}]}}",
+		"customNamespaces: {web: {labelLimits: [{matchLabels: {track: canary}, limit: {maxCPULimit: one}}]}}",
+	} {
+		f, err := ioutil.TempFile("", "config")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+
+		if _, err := f.WriteString(config); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		_, err = NewConfigurer(f.Name(), 1*time.Hour, false)
+		assert.Error(t, err, config)
+	}
+}
+
+func TestConfigRequiredProbes(t *testing.T) {
+	configFile := "./testdata/test.yaml"
+	configer, err := NewConfigurer(configFile, 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	limit := configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "prod",
+	})
+	assert.Equal(t, []string{probeReadiness}, limit.RequiredProbes)
+	assert.Equal(t, []string{"istio-proxy"}, limit.ProbeExemptContainers)
+
+	limit = configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "kube-system",
+	})
+	assert.Nil(t, limit.RequiredProbes)
+}
+
+func TestConfigInvalidRequiredProbes(t *testing.T) {
+	f, err := ioutil.TempFile("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString("customNamespaces: {prod: {requiredProbes: [startup]}}"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	_, err = NewConfigurer(f.Name(), 1*time.Hour, false)
+	assert.Error(t, err)
+}
+
+func TestConfigImageMemLimits(t *testing.T) {
+	configFile := "./testdata/test.yaml"
+	configer, err := NewConfigurer(configFile, 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	limit := configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "ml",
+	})
+	assert.Equal(t, "2Gi", limit.MemLimit.String())
+	assert.Equal(t, "16Gi", limit.ForImage("eclipse-temurin:21").MemLimit.String())
+	assert.Nil(t, limit.ForImage("pytorch/pytorch:2.3").MemLimit)
+	assert.Equal(t, "2Gi", limit.ForImage("nginx").MemLimit.String())
+	assert.Equal(t, "2Gi", limit.MemLimit.String())
+}
+
+func TestConfigExtendedResources(t *testing.T) {
+	configFile := "./testdata/test.yaml"
+	configer, err := NewConfigurer(configFile, 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	limit := configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "ml",
+	})
+	assert.Len(t, limit.ExtendedResources, 2)
+	assert.Equal(t, "2", limit.ExtendedResources["nvidia.com/gpu"].String())
+	assert.Equal(t, "1Gi", limit.ExtendedResources["hugepages-2Mi"].String())
+
+	limit = configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "kube-system",
+	})
+	assert.Nil(t, limit.ExtendedResources)
+}
+
+func TestConfigInvalidExtendedResources(t *testing.T) {
+	f, err := ioutil.TempFile("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString(`maxExtendedResources: {nvidia.com/gpu: ""}`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	_, err = NewConfigurer(f.Name(), 1*time.Hour, false)
+	assert.EqualError(t, err, "maxExtendedResources nvidia.com/gpu must not be empty")
+}
+
+func TestConfigNamespaceGPUs(t *testing.T) {
+	configFile := "./testdata/test.yaml"
+	configer, err := NewConfigurer(configFile, 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	gpus, unlimited := configer.GetMaxNamespaceGPUs("ml")
+	assert.False(t, unlimited)
+	assert.Equal(t, "8", gpus.String())
+
+	gpus, unlimited = configer.GetMaxNamespaceGPUs("kube-system")
+	assert.False(t, unlimited)
+	assert.Nil(t, gpus)
+
+	gpus, unlimited = configer.GetMaxNamespaceGPUs("default")
+	assert.True(t, unlimited)
+	assert.Nil(t, gpus)
+}
+
+func TestConfigNamespaceBudgetsIgnoreNames(t *testing.T) {
+	f, err := ioutil.TempFile("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString(`
+maxNamespaceGPUs: 4
+maxNamespacePVCSize: 100Gi
+customNamespaces:
+  ml:
+    maxNamespaceGPUs: 8
+customNames:
+  {name: "*", namespace: ml}:
+    maxNamespaceGPUs: 100
+  {name: "*", namespace: research}:
+    maxNamespaceGPUs: 100
+    maxNamespacePVCSize: 1Ti
+`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	configer, err := NewConfigurer(f.Name(), 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	gpus, _ := configer.GetMaxNamespaceGPUs("ml")
+	assert.Equal(t, "8", gpus.String())
+
+	gpus, _ = configer.GetMaxNamespaceGPUs("research")
+	assert.Equal(t, "4", gpus.String())
+
+	pvc, _ := configer.GetMaxNamespacePVCSize("research")
+	assert.Equal(t, "100Gi", pvc.String())
+}
+
+func TestConfigMinRequests(t *testing.T) {
+	configFile := "./testdata/test.yaml"
+	configer, err := NewConfigurer(configFile, 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	limit := configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "reserved",
+	})
+	assert.Equal(t, "100m", limit.MinCPURequest.String())
+	assert.Equal(t, "64Mi", limit.MinMemRequest.String())
+
+	limit = configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "kube-system",
+	})
+	assert.Nil(t, limit.MinCPURequest)
+	assert.Nil(t, limit.MinMemRequest)
+}
+
+func TestConfigEphemeralStorageLimit(t *testing.T) {
+	configFile := "./testdata/test.yaml"
+	configer, err := NewConfigurer(configFile, 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	limit := configer.GetPodLimit(NameNamespace{
+		Name:      "deployment-name",
+		Namespace: "test-namespace",
+	})
+	assert.Equal(t, "4Gi", limit.EphemeralStorageLimit.String())
+
+	limit = configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "test-namespace",
+	})
+	assert.Equal(t, "2Gi", limit.EphemeralStorageLimit.String())
+
+	limit = configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "kube-system",
+	})
+	assert.Equal(t, "10Gi", limit.EphemeralStorageLimit.String())
+
+	limit = configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "default",
+	})
+	assert.Nil(t, limit.EphemeralStorageLimit)
+}
+
+func TestConfigMode(t *testing.T) {
+	configFile := "./testdata/test.yaml"
+	configer, err := NewConfigurer(configFile, 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	limit := configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "rollout",
+	})
+	assert.Equal(t, modeWarn, limit.Mode)
+
+	limit = configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "kube-system",
+	})
+	assert.NotEqual(t, modeWarn, limit.Mode)
+
+	f, err := ioutil.TempFile("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString("customNamespaces: {rollout: {mode: audit}}"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	_, err = NewConfigurer(f.Name(), 1*time.Hour, false)
+	assert.EqualError(t, err, "namespace: rollout: mode must be enforce or warn, got: audit")
+}
+
+func TestConfigPodMemLimit(t *testing.T) {
+	configFile := "./testdata/test.yaml"
+	configer, err := NewConfigurer(configFile, 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	limit := configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "ml",
+	})
+	assert.Equal(t, "8Gi", limit.PodMemLimit.String())
+	assert.True(t, limit.CountMemoryEmptyDirs)
+
+	limit = configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "kube-system",
+	})
+	assert.Nil(t, limit.PodMemLimit)
+	assert.False(t, limit.CountMemoryEmptyDirs)
+}
+
+func TestConfigRequireLimits(t *testing.T) {
+	configer, err := NewConfigurer("./testdata/test.yaml", 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	assert.True(t, configer.GetPodLimit(NameNamespace{Name: "", Namespace: "monitoring"}).RequireLimits)
+	assert.False(t, configer.GetPodLimit(NameNamespace{Name: "", Namespace: "kube-system"}).RequireLimits)
+}
+
+func TestConfigGuaranteed(t *testing.T) {
+	configFile := "./testdata/test.yaml"
+	configer, err := NewConfigurer(configFile, 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	limit := configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "standard",
+	})
+	assert.Equal(t, "4", limit.GuaranteedCPU.String())
+	assert.Equal(t, "8Gi", limit.GuaranteedMem.String())
+
+	limit = configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "kube-system",
+	})
+	assert.Nil(t, limit.GuaranteedCPU)
+	assert.Nil(t, limit.GuaranteedMem)
+}
+
+func TestConfigMutate(t *testing.T) {
+	configFile := "./testdata/test.yaml"
+	configer, err := NewConfigurer(configFile, 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	limit := configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "injected",
+	})
+	assert.True(t, limit.Mutate)
+	assert.Equal(t, "2", limit.CPULimit.String())
+	assert.Equal(t, "2Gi", limit.MemLimit.String())
+
+	limit = configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "kube-system",
+	})
+	assert.False(t, limit.Mutate)
+}
+
+func TestConfigRequestFraction(t *testing.T) {
+	configFile := "./testdata/test.yaml"
+	configer, err := NewConfigurer(configFile, 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	limit := configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "standard",
+	})
+	if assert.NotNil(t, limit.RequestFraction) {
+		assert.Equal(t, 0.5, *limit.RequestFraction)
+	}
+	assert.Equal(t, 0.05, limit.RequestFractionTolerance)
+
+	limit = configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "kube-system",
+	})
+	assert.Nil(t, limit.RequestFraction)
+	assert.Equal(t, defaultRequestFractionTolerance, limit.RequestFractionTolerance)
+
+	for _, content := range []string{
+		"customNamespaces: {standard: {requestFraction: 1.5}}",
+		"customNamespaces: {standard: {requestFraction: 0.5, requestFractionTolerance: -0.1}}",
+	} {
+		f, err := ioutil.TempFile("", "config")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+
+		if _, err := f.WriteString(content); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		_, err = NewConfigurer(f.Name(), 1*time.Hour, false)
+		assert.Error(t, err, content)
+	}
+}
+
+func TestConfigLimitRequestRatio(t *testing.T) {
+	configFile := "./testdata/test.yaml"
+	configer, err := NewConfigurer(configFile, 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	limit := configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "reserved",
+	})
+	assert.Equal(t, 4.0, *limit.MaxLimitRequestRatio)
+	assert.Equal(t, zeroRequestDeny, limit.ZeroRequestRatio)
+
+	limit = configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "kube-system",
+	})
+	assert.Nil(t, limit.MaxLimitRequestRatio)
+}
+
+func TestConfigNegativeQuantity(t *testing.T) {
+	f, err := ioutil.TempFile("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString("customNamespaces: {prod: {maxMemLimit: -1Gi}}"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	_, err = NewConfigurer(f.Name(), 1*time.Hour, false)
+	assert.EqualError(t, err, "namespace: prod: could not parse MemLimit: quantity -1Gi must not be negative")
+}
+
+func TestConfigSeverity(t *testing.T) {
+	configFile := "./testdata/test.yaml"
+	configer, err := NewConfigurer(configFile, 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	limit := configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "kube-system",
+	})
+	assert.Equal(t, map[corev1.ResourceName]string{corev1.ResourceCPU: severityWarn}, limit.Severity)
+
+	limit = configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "monitoring",
+	})
+	assert.Nil(t, limit.Severity)
+}
+
+func TestConfigInvalidSeverity(t *testing.T) {
+	f, err := ioutil.TempFile("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString("severity: {cpu: ignore}"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	_, err = NewConfigurer(f.Name(), 1*time.Hour, false)
+	assert.Error(t, err)
+}
+
+func TestConfigGroups(t *testing.T) {
+	configer, err := NewConfigurer("./testdata/test.yaml", 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	nn := NameNamespace{
+		Name:      "",
+		Namespace: "monitoring",
+	}
+
+	limit := configer.GetPodLimit(nn, "system:authenticated")
+	assert.Equal(t, int64(2), limit.CPULimit.Value())
+
+	limit = configer.GetPodLimit(nn, "system:authenticated", "platform-admins")
+	assert.Equal(t, int64(8), limit.CPULimit.Value())
+	assert.Equal(t, int64(16*1024*1024*1024), limit.MemLimit.Value())
+	// taken from top level declaration
+	assert.Equal(t, int64(1), limit.CPURequest.Value())
+
+	// names override groups
+	limit = configer.GetPodLimit(NameNamespace{
+		Name:      "deployment-name",
+		Namespace: "test-namespace",
+	}, "platform-admins")
+	assert.Equal(t, int64(3), limit.CPULimit.Value())
+
+	// unlimited namespace stays unlimited
+	limit = configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "default",
+	}, "platform-admins")
+	assert.Equal(t, true, limit.Unlimited)
+}
+
+func TestConfigProfiles(t *testing.T) {
+	configer, err := NewConfigurer("./testdata/test.yaml", 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	// taken from top level declaration
+	limit := configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "monitoring",
+	})
+	if assert.Contains(t, limit.Profiles, "large") {
+		large := limit.Profiles["large"]
+		assert.Equal(t, int64(4), large.cpuRequest.Value())
+		assert.Equal(t, int64(8*1024*1024*1024), large.memLimit.Value())
+		assert.Nil(t, large.cpuLimit)
+	}
+
+	profiled := limit.ForProfile("large")
+	assert.Equal(t, "large", profiled.Profile)
+	assert.Nil(t, profiled.CPULimit)
+	assert.Nil(t, profiled.MemRequest)
+
+	// unknown profile keeps ceilings
+	profiled = limit.ForProfile("huge")
+	assert.Equal(t, "huge", profiled.Profile)
+	assert.Equal(t, int64(2), profiled.CPULimit.Value())
+}
+
+func TestConfigExemptTolerations(t *testing.T) {
+	configer, err := NewConfigurer("./testdata/test.yaml", 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	// taken from top level declaration
+	limit := configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "monitoring",
+	})
+	assert.Equal(t, []string{"dedicated"}, limit.ExemptTolerations)
+
+	limit = configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "kube-system",
+	})
+	assert.Equal(t, []string{"node-role.kubernetes.io/control-plane"}, limit.ExemptTolerations)
+}
+
+func TestConfigInvalidExemptTolerations(t *testing.T) {
+	f, err := ioutil.TempFile("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString("exemptTolerations: ['']"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	_, err = NewConfigurer(f.Name(), 1*time.Hour, false)
+	assert.Error(t, err)
+}
+
+func TestConfigExemptUsers(t *testing.T) {
+	configer, err := NewConfigurer("./testdata/test.yaml", 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	// taken from top level declaration
+	limit := configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "monitoring",
+	})
+	_, ok := limit.exemptUser(authenticationv1.UserInfo{Username: "system:serviceaccount:kube-system:replicaset-controller"})
+	assert.True(t, ok)
+	_, ok = limit.exemptUser(authenticationv1.UserInfo{Username: "system:node:worker-1"})
+	assert.False(t, ok)
+	_, ok = limit.exemptUser(authenticationv1.UserInfo{Username: "jane", Groups: []string{"platform:admins"}})
+	assert.True(t, ok)
+
+	limit = configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "kube-system",
+	})
+	_, ok = limit.exemptUser(authenticationv1.UserInfo{Username: "system:node:worker-1"})
+	assert.True(t, ok)
+	assert.Equal(t, []string{"platform:admins"}, limit.ExemptGroups)
+}
+
+func TestConfigInvalidExemptUsers(t *testing.T) {
+	for _, config := range []string{"exemptUsers: ['']", "exemptGroups: ['']"} {
+		f, err := ioutil.TempFile("", "config")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+
+		if _, err := f.WriteString(config); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		_, err = NewConfigurer(f.Name(), 1*time.Hour, false)
+		assert.Error(t, err, config)
+	}
+}
+
+func TestConfigPVCSizePerStorageClass(t *testing.T) {
+	configer, err := NewConfigurer("./testdata/test.yaml", 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	class := func(name string) *string {
+		return &name
+	}
+
+	tests := []struct {
+		namespace    string
+		storageClass *string
+		expected     string
+	}{
+		{namespace: "test", storageClass: class("io2"), expected: "20Gi"},
+		{namespace: "test", storageClass: class("gp3"), expected: "100Gi"},
+		// other classes, the default class and no class fall back to maxPVCSize
+		{namespace: "test", storageClass: class("standard"), expected: "50Gi"},
+		{namespace: "test", storageClass: nil, expected: "50Gi"},
+		{namespace: "test", storageClass: class(""), expected: "50Gi"},
+		// namespace declaration replaces top level classes
+		{namespace: "monitoring", storageClass: class("io2"), expected: "10Gi"},
+		{namespace: "monitoring", storageClass: class("gp3"), expected: "50Gi"},
+	}
+
+	for _, tt := range tests {
+		pvc, unlimited := configer.GetMaxStorageClassPVCSize(NameNamespace{Name: "data", Namespace: tt.namespace}, tt.storageClass)
+		assert.False(t, unlimited)
+		assert.Equal(t, tt.expected, pvc.String(), tt.namespace)
+	}
+
+	_, unlimited := configer.GetMaxStorageClassPVCSize(NameNamespace{Name: "data", Namespace: "default"}, class("io2"))
+	assert.True(t, unlimited)
+}
+
+func TestConfigInvalidPVCSizePerStorageClass(t *testing.T) {
+	for _, config := range []string{"maxPVCSizePerStorageClass: {io2: abc}", "maxPVCSizePerStorageClass: {io2: ''}"} {
+		f, err := ioutil.TempFile("", "config")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+
+		if _, err := f.WriteString(config); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		_, err = NewConfigurer(f.Name(), 1*time.Hour, false)
+		assert.Error(t, err, config)
+	}
+}
+
+func TestConfigInvalidProfiles(t *testing.T) {
+	f, err := ioutil.TempFile("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString("profiles: {small: {cpuRequest: quarter}}"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	_, err = NewConfigurer(f.Name(), 1*time.Hour, false)
+	assert.Error(t, err)
+}
+
+func TestConfigNamePatterns(t *testing.T) {
+	configer, err := NewConfigurer("./testdata/test.yaml", 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	// both workloads are matched by one pattern, longer literal prefix wins over ingest-*
+	for _, name := range []string{"ingest-worker-eu", "ingest-worker-us"} {
+		limit := configer.GetPodLimit(NameNamespace{
+			Name:      name,
+			Namespace: "ingest",
+		})
+		assert.Equal(t, int64(4), limit.CPULimit.Value(), name)
+		// taken from top level declaration
+		assert.Equal(t, int64(1), limit.CPURequest.Value(), name)
+	}
+
+	limit := configer.GetPodLimit(NameNamespace{
+		Name:      "ingest-api",
+		Namespace: "ingest",
+	})
+	assert.Equal(t, int64(2), limit.CPULimit.Value())
+
+	limit = configer.GetPodLimit(NameNamespace{
+		Name:      "batch-42",
+		Namespace: "ingest",
+	})
+	assert.Equal(t, int64(6), limit.CPULimit.Value())
+
+	// regex is anchored to the whole name
+	limit = configer.GetPodLimit(NameNamespace{
+		Name:      "batch-42-retry",
+		Namespace: "ingest",
+	})
+	assert.Equal(t, int64(2), limit.CPULimit.Value())
+
+	// patterns apply only in their namespace
+	limit = configer.GetPodLimit(NameNamespace{
+		Name:      "ingest-worker-eu",
+		Namespace: "monitoring",
+	})
+	assert.Equal(t, int64(2), limit.CPULimit.Value())
+
+	consistent, err := configer.CheckConsistency()
+	assert.NoError(t, err)
+	assert.True(t, consistent)
+}
+
+func TestConfigInvalidNamePatterns(t *testing.T) {
+	for _, config := range []string{
+		"customNames: {{nameRegex: \"batch-[\", namespace: ingest}: {maxCPULimit: 1}}",
+		"customNames: {{name: batch, nameRegex: \"batch-.*\", namespace: ingest}: {maxCPULimit: 1}}",
+	} {
+		f, err := ioutil.TempFile("", "config")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+
+		if _, err := f.WriteString(config); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		_, err = NewConfigurer(f.Name(), 1*time.Hour, false)
+		assert.Error(t, err, config)
+	}
+}
+
+func TestConfigCustomLabels(t *testing.T) {
+	configer, err := NewConfigurer("./testdata/test.yaml", 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	labels := map[string]string{"team": "data", "app": "etl"}
+
+	// matched solely by labels
+	limit := configer.GetPodLimitByLabels(NameNamespace{
+		Name:      "etl",
+		Namespace: "monitoring",
+	}, labels)
+	assert.Equal(t, int64(6), limit.CPULimit.Value())
+	assert.Equal(t, int64(12*1024*1024*1024), limit.MemLimit.Value())
+	// taken from top level declaration
+	assert.Equal(t, int64(1), limit.CPURequest.Value())
+
+	limit = configer.GetPodLimitByLabels(NameNamespace{
+		Name:      "etl",
+		Namespace: "monitoring",
+	}, map[string]string{"team": "web"})
+	assert.Equal(t, int64(2), limit.CPULimit.Value())
+
+	// labels override groups
+	limit = configer.GetPodLimitByLabels(NameNamespace{
+		Name:      "etl",
+		Namespace: "monitoring",
+	}, labels, "platform-admins")
+	assert.Equal(t, int64(6), limit.CPULimit.Value())
+
+	// names override labels
+	limit = configer.GetPodLimitByLabels(NameNamespace{
+		Name:      "deployment-name",
+		Namespace: "test-namespace",
+	}, labels)
+	assert.Equal(t, int64(3), limit.CPULimit.Value())
+
+	// unlimited namespace stays unlimited
+	limit = configer.GetPodLimitByLabels(NameNamespace{
+		Name:      "etl",
+		Namespace: "default",
+	}, labels)
+	assert.Equal(t, true, limit.Unlimited)
+
+	// labels configure workloads of unlisted namespaces
+	configer.SetConfiguredOnly(true)
+	limit = configer.GetPodLimitByLabels(NameNamespace{
+		Name:      "etl",
+		Namespace: "unlisted",
+	}, labels)
+	assert.Equal(t, false, limit.Unlimited)
+	assert.Equal(t, int64(6), limit.CPULimit.Value())
+}
+
+func TestConfigInvalidCustomLabels(t *testing.T) {
+	for _, config := range []string{
+		"customLabels: [{limit: {maxCPULimit: 1}}]",
+		"customLabels: [{matchLabels: {team: data}, limit: {maxCPULimit: one}}]",
+	} {
+		f, err := ioutil.TempFile("", "config")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+
+		if _, err := f.WriteString(config); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		_, err = NewConfigurer(f.Name(), 1*time.Hour, false)
+		assert.Error(t, err, config)
+	}
+}
+
+func TestConfigConfiguredOnly(t *testing.T) {
+	configer, err := NewConfigurer("./testdata/test.yaml", 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+	configer.SetConfiguredOnly(true)
+
+	// listed namespace
+	limit := configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "monitoring",
+	})
+	assert.Equal(t, false, limit.Unlimited)
+	assert.Equal(t, int64(2), limit.CPULimit.Value())
+
+	pvc, unlimited := configer.GetMaxPVCSize(NameNamespace{
+		Name:      "",
+		Namespace: "monitoring",
+	})
+	assert.Equal(t, false, unlimited)
+	assert.Equal(t, int64(50*1024*1024*1024), pvc.Value())
+
+	// unlisted namespace
+	limit = configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "unlisted",
+	}, "platform-admins")
+	assert.Equal(t, true, limit.Unlimited)
+
+	pvc, unlimited = configer.GetMaxPVCSize(NameNamespace{
+		Name:      "",
+		Namespace: "unlisted",
+	})
+	assert.Equal(t, true, unlimited)
+	assert.Nil(t, pvc)
+
+	// without the mode top level limits apply
+	configer.SetConfiguredOnly(false)
+	limit = configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "unlisted",
+	})
+	assert.Equal(t, false, limit.Unlimited)
+	assert.Equal(t, int64(2), limit.CPULimit.Value())
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	restrictive, err := ioutil.ReadFile("./testdata/precedence.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := ioutil.TempFile("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write([]byte(strings.Replace(string(restrictive), "precedence: mostRestrictive", "precedence: mostSpecific", 1))); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	debug := NameNamespace{Name: "debug", Namespace: "locked-down"}
+	web := NameNamespace{Name: "web", Namespace: "locked-down"}
+	sandboxWeb := NameNamespace{Name: "web", Namespace: "sandbox"}
+
+	specific, err := NewConfigurer(f.Name(), 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer specific.Close()
+
+	limit := specific.GetPodLimit(debug)
+	assert.Equal(t, true, limit.Unlimited)
+	_, unlimited := specific.GetMaxPVCSize(debug)
+	assert.Equal(t, true, unlimited)
+
+	limit = specific.GetPodLimit(web)
+	assert.Equal(t, int64(4), limit.CPULimit.Value())
+	assert.Equal(t, int64(512*1024*1024), limit.MemLimit.Value())
+
+	limit = specific.GetPodLimit(sandboxWeb)
+	assert.Equal(t, true, limit.Unlimited)
+
+	restrictiveConf, err := NewConfigurer("./testdata/precedence.yaml", 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restrictiveConf.Close()
+
+	limit = restrictiveConf.GetPodLimit(debug)
+	assert.Equal(t, false, limit.Unlimited)
+	assert.Equal(t, int64(1), limit.CPULimit.Value())
+	assert.Equal(t, int64(1024*1024*1024), limit.MemLimit.Value())
+	pvc, unlimited := restrictiveConf.GetMaxPVCSize(debug)
+	assert.Equal(t, false, unlimited)
+	assert.Equal(t, int64(10*1024*1024*1024), pvc.Value())
+
+	limit = restrictiveConf.GetPodLimit(web)
+	assert.Equal(t, int64(1), limit.CPULimit.Value())
+	assert.Equal(t, int64(512*1024*1024), limit.MemLimit.Value())
+
+	limit = restrictiveConf.GetPodLimit(sandboxWeb)
+	assert.Equal(t, false, limit.Unlimited)
+	assert.Equal(t, int64(3), limit.CPULimit.Value())
+}
+
+func TestConfigInvalidCPULimitPolicy(t *testing.T) {
+	f, err := ioutil.TempFile("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString("customNamespaces: {latency: {cpuLimitPolicy: never}}"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	_, err = NewConfigurer(f.Name(), 1*time.Hour, false)
+	assert.Error(t, err)
+}
+
+func TestConfigMaxReplicas(t *testing.T) {
+	configer, err := NewConfigurer("./testdata/test.yaml", 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	for _, tt := range []struct {
+		nn          NameNamespace
+		maxReplicas int
+	}{
+		// taken from top level declaration
+		{nn: NameNamespace{Name: "web", Namespace: "kube-system"}, maxReplicas: 100},
+		{nn: NameNamespace{Name: "web", Namespace: "monitoring"}, maxReplicas: 10},
+		{nn: NameNamespace{Name: "ingest-worker-eu", Namespace: "ingest"}, maxReplicas: 500},
+		{nn: NameNamespace{Name: "ingest-api", Namespace: "ingest"}, maxReplicas: 100},
+	} {
+		limit := configer.GetPodLimit(tt.nn)
+		if assert.NotNil(t, limit.MaxReplicas, tt.nn.Name) {
+			assert.Equal(t, tt.maxReplicas, *limit.MaxReplicas, tt.nn.Namespace+"/"+tt.nn.Name)
+		}
+	}
+}
+
+func TestConfigInvalidMaxReplicas(t *testing.T) {
+	f, err := ioutil.TempFile("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString("customNamespaces: {web: {maxReplicas: -1}}"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	_, err = NewConfigurer(f.Name(), 1*time.Hour, false)
+	assert.Error(t, err)
+}
+
+func TestConfigTopologySpread(t *testing.T) {
+	configer, err := NewConfigurer("./testdata/test.yaml", 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	limit := configer.GetPodLimit(NameNamespace{Name: "web", Namespace: "prod"})
+	if assert.NotNil(t, limit.TopologySpreadReplicas) {
+		assert.Equal(t, 3, *limit.TopologySpreadReplicas)
+	}
+	assert.Equal(t, "", limit.TopologySpreadPolicy)
+
+	assert.Nil(t, configer.GetPodLimit(NameNamespace{Name: "web", Namespace: "monitoring"}).TopologySpreadReplicas)
+}
+
+func TestConfigInvalidTopologySpread(t *testing.T) {
+	for _, config := range []string{
+		"customNamespaces: {web: {topologySpreadReplicas: 0}}",
+		"customNamespaces: {web: {topologySpreadReplicas: 3, topologySpreadPolicy: audit}}",
+	} {
+		f, err := ioutil.TempFile("", "config")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+
+		if _, err := f.WriteString(config); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		_, err = NewConfigurer(f.Name(), 1*time.Hour, false)
+		assert.Error(t, err, config)
+	}
+}
+
+func TestConfigDefaultAction(t *testing.T) {
+	configer, err := NewConfigurer("./testdata/test.yaml", 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	assert.Equal(t, defaultActionDeny, configer.GetPodLimit(NameNamespace{Name: "web", Namespace: "prod"}).DefaultAction)
+	// empty action follows --default-action
+	assert.Equal(t, "", configer.GetPodLimit(NameNamespace{Name: "web", Namespace: "monitoring"}).DefaultAction)
+}
+
+func TestConfigInvalidDefaultAction(t *testing.T) {
+	f, err := ioutil.TempFile("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString("defaultAction: reject"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	_, err = NewConfigurer(f.Name(), 1*time.Hour, false)
+	assert.Error(t, err)
+}
+
+func TestConfigInvalidSwapPolicy(t *testing.T) {
+	f, err := ioutil.TempFile("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString("customNamespaces: {latency: {swapPolicy: account}}"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	_, err = NewConfigurer(f.Name(), 1*time.Hour, false)
+	assert.Error(t, err)
+}
+
+func TestConfigInvalidPrecedence(t *testing.T) {
+	f, err := ioutil.TempFile("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString("precedence: leastSpecific"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	_, err = NewConfigurer(f.Name(), 1*time.Hour, false)
+	assert.Error(t, err)
+}
+
+func TestConfigStrict(t *testing.T) {
+	f, err := ioutil.TempFile("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString("maxCpuLimit: 1"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	_, err = NewConfigurer(f.Name(), 1*time.Hour, true)
+	assert.Error(t, err)
+
+	configer, err := NewConfigurer(f.Name(), 1*time.Hour, false)
+	if assert.NoError(t, err) {
+		configer.Close()
+	}
+
+	for _, configFile := range []string{"./testdata/test.yaml", "./testdata/template/config.yaml", "./testdata/precedence.yaml"} {
+		configer, err := NewConfigurer(configFile, 1*time.Hour, true)
+		if assert.NoError(t, err, configFile) {
+			configer.Close()
+		}
+	}
+}
+
+func TestConfigReloadDebounce(t *testing.T) {
+	f, err := ioutil.TempFile("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString("maxCPULimit: 1"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	configer, err := NewConfigurer(f.Name(), 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+	configer.SetReloadDebounce(200 * time.Millisecond)
+
+	reloads := testutil.ToFloat64(reloadCounter)
+	for i := 2; i <= 20; i++ {
+		if err := ioutil.WriteFile(f.Name(), []byte(fmt.Sprintf("maxCPULimit: %d", i)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(1 * time.Second)
+
+	assert.Equal(t, reloads+1, testutil.ToFloat64(reloadCounter))
+
+	limit := configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "default",
+	})
+	assert.Equal(t, int64(20), limit.CPULimit.Value())
+}
+
+// swapConfigMap updates config file the way kubelet updates mounted ConfigMaps: data is written to a new directory
+// and ..data symlink is atomically renamed to point to it
+func swapConfigMap(t *testing.T, dir, version, config string) {
+	if err := os.Mkdir(filepath.Join(dir, version), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, version, "config.yaml"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(version, filepath.Join(dir, "..data_tmp")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfigReloadSymlinkSwap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configmap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	swapConfigMap(t, dir, "..v1", "maxCPULimit: 1")
+	if err := os.Symlink(filepath.Join("..data", "config.yaml"), filepath.Join(dir, "config.yaml")); err != nil {
+		t.Fatal(err)
+	}
+
+	configer, err := NewConfigurer(filepath.Join(dir, "config.yaml"), 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+	configer.SetReloadDebounce(10 * time.Millisecond)
+
+	cpuLimit := func() int64 {
+		return configer.GetPodLimit(NameNamespace{Namespace: "default"}).CPULimit.Value()
+	}
+	assert.Equal(t, int64(1), cpuLimit())
+
+	// every swap is picked up long before refreshInterval
+	swapConfigMap(t, dir, "..v2", "maxCPULimit: 2")
+	if err := os.RemoveAll(filepath.Join(dir, "..v1")); err != nil {
+		t.Fatal(err)
+	}
+	assert.Eventually(t, func() bool { return cpuLimit() == 2 }, 5*time.Second, 10*time.Millisecond)
+
+	swapConfigMap(t, dir, "..v3", "maxCPULimit: 3")
+	if err := os.RemoveAll(filepath.Join(dir, "..v2")); err != nil {
+		t.Fatal(err)
+	}
+	assert.Eventually(t, func() bool { return cpuLimit() == 3 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestConfigReloadFileReplace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	configFile := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(configFile, []byte("maxCPULimit: 1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	configer, err := NewConfigurer(configFile, 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+	configer.SetReloadDebounce(10 * time.Millisecond)
+
+	// files replaced by rename drop the watch of the original file
+	for i := int64(2); i <= 3; i++ {
+		tmp := filepath.Join(dir, "config.yaml.tmp")
+		if err := ioutil.WriteFile(tmp, []byte(fmt.Sprintf("maxCPULimit: %d", i)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, configFile); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Eventually(t, func() bool {
+			return configer.GetPodLimit(NameNamespace{Namespace: "default"}).CPULimit.Value() == i
+		}, 5*time.Second, 10*time.Millisecond)
+	}
+}
+
+func TestConfigReloadInvalid(t *testing.T) {
+	f, err := ioutil.TempFile("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString("maxCPULimit: 1\ncustomNamespaces: {web: {maxCPULimit: 2}}"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	configer, err := NewConfigurer(f.Name(), 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+	configer.SetReloadDebounce(10 * time.Millisecond)
+
+	// namespace is valid, but the rest isn't, so nothing of the new config may apply
+	reloadErrors := testutil.ToFloat64(reloadErrorsCounter)
+	if err := ioutil.WriteFile(f.Name(), []byte("maxCPULimit: 3\ncustomNamespaces: {web: {maxCPULimit: 4}, sandbox: {unlimited: true, maxCPULimit: 1}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(reloadErrorsCounter) > reloadErrors
+	}, 5*time.Second, 10*time.Millisecond)
+
+	limit := configer.GetPodLimit(NameNamespace{Namespace: "default"})
+	assert.Equal(t, int64(1), limit.CPULimit.Value())
+	limit = configer.GetPodLimit(NameNamespace{Namespace: "web"})
+	assert.Equal(t, int64(2), limit.CPULimit.Value())
+	limit = configer.GetPodLimit(NameNamespace{Namespace: "sandbox"})
+	assert.Equal(t, false, limit.Unlimited)
+
+	reloadErrors = testutil.ToFloat64(reloadErrorsCounter)
+	if err := ioutil.WriteFile(f.Name(), []byte("maxCPULimit: notaquantity"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(reloadErrorsCounter) > reloadErrors
+	}, 5*time.Second, 10*time.Millisecond)
+
+	limit = configer.GetPodLimit(NameNamespace{Namespace: "default"})
+	assert.Equal(t, int64(1), limit.CPULimit.Value())
+	// stale config is reported by the gauge, it stays in use, so readiness doesn't fail
+	assert.Equal(t, float64(0), testutil.ToFloat64(configLoadedGauge))
+	assert.True(t, configer.Loaded())
+
+	if err := ioutil.WriteFile(f.Name(), []byte("maxCPULimit: 5"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(configLoadedGauge) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestConfigResourceTemplate(t *testing.T) {
+	configer, err := NewConfigurer("./testdata/template/config.yaml", 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	limit := configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "default",
+	})
+	assert.Equal(t, int64(2), limit.CPULimit.Value())
+	assert.Equal(t, int64(2*1024*1024*1024), limit.MemLimit.Value())
+	assert.Equal(t, int64(250), limit.CPURequest.MilliValue())
+	assert.Equal(t, int64(1024*1024*1024), limit.MemRequest.Value())
+
+	limit = configer.GetPodLimit(NameNamespace{
+		Name:      "",
+		Namespace: "kube-system",
+	})
+	assert.Equal(t, int64(4), limit.CPULimit.Value())
+	assert.Equal(t, int64(2*1024*1024*1024), limit.MemLimit.Value())
+}
+
+func TestAdmissionResourceTemplate(t *testing.T) {
+	configer, err := NewConfigurer("./testdata/template/config.yaml", 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	tests := []struct {
+		name      string
+		namespace string
+		resources string
+		message   string
+	}{
+		{
+			name:      "within template",
+			namespace: "default",
+			resources: `{"limits": {"cpu": "2", "memory": "2Gi"}, "requests": {"cpu": "250m", "memory": "1Gi"}}`,
+		},
+		{
+			name:      "cpu limit over template",
+			namespace: "default",
+			resources: `{"limits": {"cpu": "3", "memory": "2Gi"}, "requests": {"cpu": "250m", "memory": "1Gi"}}`,
+			message:   "error container app limits.CPU: 3 > 2",
+		},
+		{
+			name:      "memory request over template",
+			namespace: "default",
+			resources: `{"limits": {"cpu": "2", "memory": "2Gi"}, "requests": {"cpu": "250m", "memory": "2Gi"}}`,
+			message:   "error container app requests.Memory: 2Gi > 1Gi",
+		},
+		{
+			name:      "cpu request over config, which overrides template",
+			namespace: "default",
+			resources: `{"limits": {"cpu": "2", "memory": "2Gi"}, "requests": {"cpu": "500m", "memory": "1Gi"}}`,
+			message:   "error container app requests.CPU: 500m > 250m",
+		},
+		{
+			name:      "namespace overrides template",
+			namespace: "kube-system",
+			resources: `{"limits": {"cpu": "4", "memory": "2Gi"}, "requests": {"cpu": "250m", "memory": "1Gi"}}`,
+		},
+	}
+
+	rra := &ResourceRequestsAdmission{conf: configer, now: time.Now}
+	for _, tt := range tests {
+		resp, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+			UID:       "e911857d-c318-11e8-bbad-025000000001",
+			Kind:      metav1.GroupVersionKind{Kind: "Pod"},
+			Namespace: tt.namespace,
+			Operation: v1beta1.Create,
+			Object: runtime.RawExtension{
+				Raw: []byte(`{"metadata": {"name": "test"}, "spec": {"containers": [{"name": "app", "resources": ` + tt.resources + `}]}}`),
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.message == "", resp.Allowed, tt.name)
+		if tt.message != "" {
+			assert.Equal(t, tt.message, resp.Result.Message, tt.name)
+		}
+	}
+}
+
+func TestConfigInvalidResourceTemplate(t *testing.T) {
+	for _, template := range []string{
+		"limits: {nvidia.com/gpu: 1}",
+		"requests: {ephemeral-storage: 1Gi}",
+		"limits: {cpu: 2}\nrequest: {cpu: 1}",
+	} {
+		dir, err := ioutil.TempDir("", "template")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		if err := ioutil.WriteFile(filepath.Join(dir, "config.yaml"), []byte("resourceTemplateFile: resources.yaml"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "resources.yaml"), []byte(template), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = NewConfigurer(filepath.Join(dir, "config.yaml"), 1*time.Hour, false)
+		assert.Error(t, err, template)
+	}
+}
+
+func TestConfigResourceTemplateReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// template is in other directory than the config
+	templateDir, err := ioutil.TempDir("", "template")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(templateDir)
+
+	template := filepath.Join(templateDir, "resources.yaml")
+	if err := ioutil.WriteFile(filepath.Join(dir, "config.yaml"), []byte("resourceTemplateFile: "+template), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(template, []byte("limits: {cpu: 1}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	configer, err := NewConfigurer(filepath.Join(dir, "config.yaml"), 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+	configer.SetReloadDebounce(10 * time.Millisecond)
+
+	cpuLimit := func() int64 {
+		return configer.GetPodLimit(NameNamespace{Namespace: "default"}).CPULimit.Value()
+	}
+	assert.Equal(t, int64(1), cpuLimit())
+
+	// template changes are picked up long before refreshInterval
+	if err := ioutil.WriteFile(template, []byte("limits: {cpu: 2}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	assert.Eventually(t, func() bool { return cpuLimit() == 2 }, 5*time.Second, 10*time.Millisecond)
+
+	// replaced template is watched again
+	if err := ioutil.WriteFile(template+".tmp", []byte("limits: {cpu: 3}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(template+".tmp", template); err != nil {
+		t.Fatal(err)
+	}
+	assert.Eventually(t, func() bool { return cpuLimit() == 3 }, 5*time.Second, 10*time.Millisecond)
+
+	if err := ioutil.WriteFile(template, []byte("limits: {cpu: 4}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	assert.Eventually(t, func() bool { return cpuLimit() == 4 }, 5*time.Second, 10*time.Millisecond)
 }
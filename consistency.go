@@ -0,0 +1,79 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+var (
+	consistencyChecksCounter = promauto.NewCounterVec(prometheus.CounterOpts{Name: "config_consistency_checks_total"}, []string{"result"})
+	configDriftGauge         = promauto.NewGauge(prometheus.GaugeOpts{Name: "config_drift"})
+)
+
+// stateComparer compares configState including unexported fields, quantities are compared by value,
+// since their cached string representation differs once they are printed, regexps by expression
+var stateComparer = []cmp.Option{
+	cmp.Exporter(func(reflect.Type) bool { return true }),
+	cmp.Comparer(func(a, b resource.Quantity) bool { return a.Cmp(b) == 0 }),
+	cmp.Comparer(func(a, b *regexp.Regexp) bool {
+		if a == nil || b == nil {
+			return a == b
+		}
+
+		return a.String() == b.String()
+	}),
+}
+
+// CheckConsistency re-parses the config file and compares it to the configuration in use, returns false if they differ.
+// Drift is expected briefly after the file changes, until it's reloaded.
+func (c *Configurer) CheckConsistency() (bool, error) {
+	parsed, err := c.parse()
+	if err != nil {
+		consistencyChecksCounter.WithLabelValues("error").Inc()
+		return false, err
+	}
+
+	consistent := cmp.Equal(parsed, c.state(), stateComparer...)
+	if consistent {
+		consistencyChecksCounter.WithLabelValues("consistent").Inc()
+		configDriftGauge.Set(0)
+	} else {
+		consistencyChecksCounter.WithLabelValues("drift").Inc()
+		configDriftGauge.Set(1)
+	}
+
+	return consistent, nil
+}
+
+// RunConsistencyCheck checks consistency every interval until stop is closed
+func (c *Configurer) RunConsistencyCheck(interval time.Duration, stop <-chan struct{}) {
+	for _, result := range []string{"consistent", "drift", "error"} {
+		consistencyChecksCounter.WithLabelValues(result)
+	}
+
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-tick.C:
+		}
+
+		consistent, err := c.CheckConsistency()
+		switch {
+		case err != nil:
+			log.WithError(err).Errorf("unable to check consistency of config file: %s", c.filePath)
+		case !consistent:
+			log.Warnf("configuration in use differs from config file: %s", c.filePath)
+		}
+	}
+}
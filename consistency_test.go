@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestConfigCheckConsistency(t *testing.T) {
+	configer, err := NewConfigurer("./testdata/test.yaml", 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	consistent, err := configer.CheckConsistency()
+	assert.NoError(t, err)
+	assert.True(t, consistent)
+	assert.Equal(t, float64(0), testutil.ToFloat64(configDriftGauge))
+
+	// in-memory configuration diverges, e.g. a swap failed silently
+	cpu := resource.MustParse("100")
+	configer.m.Lock()
+	configer.defaultLimit.CPULimit = &cpu
+	configer.m.Unlock()
+
+	consistent, err = configer.CheckConsistency()
+	assert.NoError(t, err)
+	assert.False(t, consistent)
+	assert.Equal(t, float64(1), testutil.ToFloat64(configDriftGauge))
+
+	if err := configer.load(); err != nil {
+		t.Fatal(err)
+	}
+
+	consistent, err = configer.CheckConsistency()
+	assert.NoError(t, err)
+	assert.True(t, consistent)
+	assert.Equal(t, float64(0), testutil.ToFloat64(configDriftGauge))
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+	"k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+const defaultResourcesField = "resources"
+
+// CustomResource points to containers of a custom resource, e.g. steps of Tekton TaskRun
+type CustomResource struct {
+	Group string `yaml:"group"`
+	// Version is optional, empty version matches all versions
+	Version string `yaml:"version"`
+	Kind    string `yaml:"kind"`
+	// ContainerPaths are JSONPath expressions, which select container-like objects, e.g. {.spec.taskSpec.steps[*]}
+	ContainerPaths []string `yaml:"containerPaths"`
+	// ResourcesField is the field of selected objects, which holds container resources, defaults to resources
+	ResourcesField string `yaml:"resourcesField"`
+}
+
+// LoadCustomResources reads custom resources from yaml file
+func LoadCustomResources(filePath string) ([]CustomResource, error) {
+	file, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read file")
+	}
+
+	var crs []CustomResource
+	if err := yaml.UnmarshalStrict(file, &crs); err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal yaml file")
+	}
+
+	for i, cr := range crs {
+		if cr.Group == "" || cr.Kind == "" {
+			return nil, errors.Errorf("custom resource %d: group and kind must not be empty", i)
+		}
+		if len(cr.ContainerPaths) == 0 {
+			return nil, errors.Errorf("custom resource %s/%s: containerPaths must not be empty", cr.Group, cr.Kind)
+		}
+		for _, path := range cr.ContainerPaths {
+			if _, err := parseContainerPath(path); err != nil {
+				return nil, errors.Wrapf(err, "custom resource %s/%s", cr.Group, cr.Kind)
+			}
+		}
+		if cr.ResourcesField == "" {
+			crs[i].ResourcesField = defaultResourcesField
+		}
+	}
+
+	return crs, nil
+}
+
+// parseContainerPath parses JSONPath, it's parsed on every use, since JSONPath is not safe for concurrent use
+func parseContainerPath(path string) (*jsonpath.JSONPath, error) {
+	jp := jsonpath.New("containerPath").AllowMissingKeys(true)
+	if err := jp.Parse(path); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse containerPath %s", path)
+	}
+
+	return jp, nil
+}
+
+func (cr CustomResource) matches(kind metav1.GroupVersionKind) bool {
+	return cr.Group == kind.Group && cr.Kind == kind.Kind && (cr.Version == "" || cr.Version == kind.Version)
+}
+
+// containers returns objects selected by ContainerPaths as containers, unnamed containers are named by their position
+func (cr CustomResource) containers(obj map[string]interface{}) ([]corev1.Container, error) {
+	var containers []corev1.Container
+	for _, path := range cr.ContainerPaths {
+		jp, err := parseContainerPath(path)
+		if err != nil {
+			return nil, err
+		}
+
+		results, err := jp.FindResults(obj)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to find containerPath %s", path)
+		}
+
+		for _, values := range results {
+			for _, value := range values {
+				fields, ok := value.Interface().(map[string]interface{})
+				if !ok {
+					return nil, errors.Errorf("containerPath %s selects %T, not an object", path, value.Interface())
+				}
+
+				container, err := cr.container(fields)
+				if err != nil {
+					return nil, errors.Wrapf(err, "unable to decode containerPath %s", path)
+				}
+				if container.Name == "" {
+					container.Name = fmt.Sprintf("unnamed-%d", len(containers))
+				}
+
+				containers = append(containers, container)
+			}
+		}
+	}
+
+	return containers, nil
+}
+
+func (cr CustomResource) container(fields map[string]interface{}) (corev1.Container, error) {
+	if cr.ResourcesField != defaultResourcesField {
+		renamed := make(map[string]interface{}, len(fields))
+		for k, v := range fields {
+			if k != defaultResourcesField {
+				renamed[k] = v
+			}
+		}
+		if resources, ok := fields[cr.ResourcesField]; ok {
+			renamed[defaultResourcesField] = resources
+		}
+		fields = renamed
+	}
+
+	var container corev1.Container
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return container, err
+	}
+
+	err = json.Unmarshal(b, &container)
+	return container, err
+}
+
+// decodeCustomResource decodes configured custom resource into workload of its containers,
+// returns nil if kind is not configured or no containers are found
+func (rra *ResourceRequestsAdmission) decodeCustomResource(req *v1beta1.AdmissionRequest) (*workload, error) {
+	for _, cr := range rra.opts.CustomResources {
+		if !cr.matches(req.Kind) {
+			continue
+		}
+
+		var obj unstructured.Unstructured
+		if err := json.Unmarshal(req.Object.Raw, &obj.Object); err != nil {
+			return nil, errors.Wrapf(err, "unable to unmarshal json: %s", string(req.Object.Raw))
+		}
+
+		containers, err := cr.containers(obj.Object)
+		if err != nil {
+			return nil, err
+		}
+		if len(containers) == 0 {
+			return nil, nil
+		}
+
+		return &workload{
+			kind:         req.Kind.Kind,
+			name:         obj.GetName(),
+			podSpec:      corev1.PodSpec{Containers: containers},
+			annotations:  obj.GetAnnotations(),
+			labels:       obj.GetLabels(),
+			objectLabels: obj.GetLabels(),
+			created:      obj.GetCreationTimestamp().Time,
+			replicas:     1,
+		}, nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestLoadCustomResources(t *testing.T) {
+	crs, err := LoadCustomResources("./docs/tekton.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, crs, 3)
+	assert.Equal(t, "computeResources", crs[0].ResourcesField)
+	assert.Equal(t, "resources", crs[1].ResourcesField)
+	assert.True(t, crs[0].matches(metav1.GroupVersionKind{Group: "tekton.dev", Version: "v1", Kind: "TaskRun"}))
+	assert.False(t, crs[0].matches(metav1.GroupVersionKind{Group: "tekton.dev", Version: "v1beta1", Kind: "TaskRun"}))
+
+	for _, tt := range []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{
+			name:    "missing kind",
+			content: `[{group: tekton.dev, containerPaths: ["{.spec.steps[*]}"]}]`,
+			err:     "custom resource 0: group and kind must not be empty",
+		},
+		{
+			name:    "missing paths",
+			content: `[{group: tekton.dev, kind: TaskRun}]`,
+			err:     "custom resource tekton.dev/TaskRun: containerPaths must not be empty",
+		},
+		{
+			name:    "invalid path",
+			content: `[{group: tekton.dev, kind: TaskRun, containerPaths: ["{.spec.steps[*]"]}]`,
+			err:     "custom resource tekton.dev/TaskRun: unable to parse containerPath {.spec.steps[*]",
+		},
+	} {
+		f, err := ioutil.TempFile("", "customresources")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+
+		if _, err := f.WriteString(tt.content); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		_, err = LoadCustomResources(f.Name())
+		if assert.Error(t, err, tt.name) {
+			assert.Contains(t, err.Error(), tt.err, tt.name)
+		}
+	}
+}
+
+func TestHandleAdmissionCustomResources(t *testing.T) {
+	crs, err := LoadCustomResources("./docs/tekton.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cpu := resource.MustParse("1")
+	mem := resource.MustParse("1Gi")
+
+	tests := []struct {
+		name    string
+		kind    metav1.GroupVersionKind
+		object  string
+		message string
+	}{
+		{
+			name: "taskrun within limits",
+			kind: metav1.GroupVersionKind{Group: "tekton.dev", Version: "v1", Kind: "TaskRun"},
+			object: `{"apiVersion": "tekton.dev/v1", "kind": "TaskRun", "metadata": {"name": "build"}, "spec": {"taskSpec": {"steps": [
+				{"name": "compile", "image": "golang", "computeResources": {"requests": {"cpu": "500m", "memory": "512Mi"}, "limits": {"cpu": 1, "memory": "1Gi"}}}]}}}`,
+		},
+		{
+			name: "taskrun step over limits",
+			kind: metav1.GroupVersionKind{Group: "tekton.dev", Version: "v1", Kind: "TaskRun"},
+			object: `{"apiVersion": "tekton.dev/v1", "kind": "TaskRun", "metadata": {"name": "build"}, "spec": {"taskSpec": {"steps": [
+				{"name": "compile", "image": "golang", "computeResources": {"requests": {"cpu": "500m", "memory": "512Mi"}, "limits": {"cpu": 4, "memory": "1Gi"}}}]}}}`,
+			message: "error container compile limits.CPU: 4 > 1",
+		},
+		{
+			name: "taskrun unnamed step without requests",
+			kind: metav1.GroupVersionKind{Group: "tekton.dev", Version: "v1", Kind: "TaskRun"},
+			object: `{"apiVersion": "tekton.dev/v1", "kind": "TaskRun", "metadata": {"name": "build"}, "spec": {"taskSpec": {"steps": [
+				{"image": "golang", "computeResources": {"requests": {"memory": "512Mi"}}}]}}}`,
+			message: "error container unnamed-0 requests.CPU is empty, must be 0",
+		},
+		{
+			name: "v1beta1 taskrun step override over limits",
+			kind: metav1.GroupVersionKind{Group: "tekton.dev", Version: "v1beta1", Kind: "TaskRun"},
+			object: `{"apiVersion": "tekton.dev/v1beta1", "kind": "TaskRun", "metadata": {"name": "build"}, "spec": {"taskRef": {"name": "build"}, "stepOverrides": [
+				{"name": "compile", "resources": {"requests": {"cpu": "500m", "memory": "4Gi"}}}]}}`,
+			message: "error container compile requests.Memory: 4Gi > 1Gi",
+		},
+		{
+			name: "pipelinerun task step over limits",
+			kind: metav1.GroupVersionKind{Group: "tekton.dev", Version: "v1", Kind: "PipelineRun"},
+			object: `{"apiVersion": "tekton.dev/v1", "kind": "PipelineRun", "metadata": {"name": "release"}, "spec": {"pipelineSpec": {"tasks": [
+				{"name": "test", "taskSpec": {"steps": [{"name": "unit", "computeResources": {"requests": {"cpu": "100m", "memory": "128Mi"}}}]}},
+				{"name": "build", "taskSpec": {"steps": [{"name": "compile", "computeResources": {"requests": {"cpu": "100m", "memory": "128Mi"}, "limits": {"memory": "8Gi"}}}]}}]}}}`,
+			message: "error container compile limits.Memory: 8Gi > 1Gi",
+		},
+		{
+			name:   "taskrun referencing task",
+			kind:   metav1.GroupVersionKind{Group: "tekton.dev", Version: "v1", Kind: "TaskRun"},
+			object: `{"apiVersion": "tekton.dev/v1", "kind": "TaskRun", "metadata": {"name": "build"}, "spec": {"taskRef": {"name": "build"}}}`,
+		},
+		{
+			name: "not configured kind",
+			kind: metav1.GroupVersionKind{Group: "tekton.dev", Version: "v1", Kind: "Task"},
+			object: `{"apiVersion": "tekton.dev/v1", "kind": "Task", "metadata": {"name": "build"}, "spec": {"steps": [
+				{"name": "compile", "computeResources": {"limits": {"cpu": 4}}}]}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{
+			conf: &MockConfiger{cpu: &cpu, mem: &mem, cpuRequest: &cpu, memRequest: &mem},
+			opts: Options{CustomResources: crs},
+		}
+
+		resp, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+			UID:       "e911857d-c318-11e8-bbad-025000000001",
+			Kind:      tt.kind,
+			Namespace: "default",
+			Operation: v1beta1.Create,
+			Object:    runtime.RawExtension{Raw: []byte(tt.object)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.message == "", resp.Allowed, tt.name)
+		if tt.message != "" {
+			assert.Equal(t, tt.message, resp.Result.Message, tt.name)
+		}
+	}
+}
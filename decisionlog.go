@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/version"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/api/admission/v1beta1"
+)
+
+const (
+	decisionLogJSONL  = "jsonl"
+	decisionLogCEF    = "cef"
+	decisionLogLogfmt = "logfmt"
+)
+
+// decisionLogErrorsCounter counts decisions, which couldn't be written to decision log
+var decisionLogErrorsCounter = promauto.NewCounter(prometheus.CounterOpts{Name: "decision_log_errors_total"})
+
+// Decision is a single admission decision written to decision log
+type Decision struct {
+	Time      time.Time `json:"time"`
+	UID       string    `json:"uid"`
+	Kind      string    `json:"kind"`
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	Operation string    `json:"operation"`
+	User      string    `json:"user"`
+	Allowed   bool      `json:"allowed"`
+	Mode      string    `json:"mode"`
+	DryRun    bool      `json:"dryRun"`
+	Reason    string    `json:"reason"`
+	Message   string    `json:"message,omitempty"`
+}
+
+// newDecision returns decision of the response to the request
+func newDecision(now time.Time, req *v1beta1.AdmissionRequest, resp *v1beta1.AdmissionResponse, mode string) Decision {
+	d := Decision{
+		Time:      now.UTC(),
+		UID:       string(req.UID),
+		Kind:      req.Kind.Kind,
+		Namespace: req.Namespace,
+		Name:      req.Name,
+		Operation: string(req.Operation),
+		User:      req.UserInfo.Username,
+		Allowed:   resp.Allowed,
+		Mode:      mode,
+		DryRun:    req.DryRun != nil && *req.DryRun,
+		Reason:    reasonNone,
+	}
+	if reason, ok := resp.AuditAnnotations[denialReasonAnnotation]; ok {
+		d.Reason = reason
+	}
+	if resp.Result != nil {
+		d.Message = resp.Result.Message
+	}
+
+	return d
+}
+
+// decision returns allowed or denied
+func (d Decision) decision() string {
+	if d.Allowed {
+		return decisionAllowed
+	}
+
+	return decisionDenied
+}
+
+// DecisionEncoder encodes a decision as a single line of decision log
+type DecisionEncoder interface {
+	Encode(d Decision) ([]byte, error)
+}
+
+// NewDecisionEncoder returns encoder of the format: jsonl, cef or logfmt
+func NewDecisionEncoder(format string) (DecisionEncoder, error) {
+	switch format {
+	case decisionLogJSONL:
+		return jsonlEncoder{}, nil
+	case decisionLogCEF:
+		return cefEncoder{}, nil
+	case decisionLogLogfmt:
+		return logfmtEncoder{}, nil
+	default:
+		return nil, errors.Errorf("unknown decision log format %q, must be %s, %s or %s", format, decisionLogJSONL, decisionLogCEF, decisionLogLogfmt)
+	}
+}
+
+// jsonlEncoder encodes decision as JSON object on a single line, messages are not HTML escaped, since they contain > signs
+type jsonlEncoder struct{}
+
+func (jsonlEncoder) Encode(d Decision) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(d); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// cefEncoder encodes decision in ArcSight Common Event Format, signature ID is the denial reason
+type cefEncoder struct{}
+
+func (cefEncoder) Encode(d Decision) ([]byte, error) {
+	severity := 3
+	if !d.Allowed {
+		severity = 7
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "CEF:0|devopyio|resource-requests-admission-controller|%s|%s|admission %s|%d|",
+		cefHeader(version.Version), cefHeader(d.Reason), d.decision(), severity)
+
+	extensions := [][2]string{
+		{"rt", strconv.FormatInt(d.Time.UnixNano()/int64(time.Millisecond), 10)},
+		{"externalId", d.UID},
+		{"act", d.decision()},
+		{"outcome", d.decision()},
+		{"suser", d.User},
+		{"cs1Label", "kind"},
+		{"cs1", d.Kind},
+		{"cs2Label", "namespace"},
+		{"cs2", d.Namespace},
+		{"cs3Label", "name"},
+		{"cs3", d.Name},
+		{"cs4Label", "operation"},
+		{"cs4", d.Operation},
+		{"cs5Label", "mode"},
+		{"cs5", d.Mode},
+		{"cs6Label", "dryRun"},
+		{"cs6", strconv.FormatBool(d.DryRun)},
+		{"reason", d.Reason},
+	}
+	if d.Message != "" {
+		extensions = append(extensions, [2]string{"msg", d.Message})
+	}
+
+	for i, e := range extensions {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(e[0])
+		b.WriteByte('=')
+		b.WriteString(cefExtension(e[1]))
+	}
+	b.WriteByte('\n')
+
+	return []byte(b.String()), nil
+}
+
+var (
+	cefHeaderReplacer    = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefExtensionReplacer = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+)
+
+// cefHeader escapes backslashes and pipes of CEF header field
+func cefHeader(s string) string {
+	return cefHeaderReplacer.Replace(s)
+}
+
+// cefExtension escapes backslashes, equal signs and newlines of CEF extension value
+func cefExtension(s string) string {
+	return cefExtensionReplacer.Replace(s)
+}
+
+// logfmtEncoder encodes decision as key=value pairs, values with spaces, quotes or equal signs are quoted
+type logfmtEncoder struct{}
+
+func (logfmtEncoder) Encode(d Decision) ([]byte, error) {
+	pairs := [][2]string{
+		{"time", d.Time.Format(time.RFC3339Nano)},
+		{"uid", d.UID},
+		{"kind", d.Kind},
+		{"namespace", d.Namespace},
+		{"name", d.Name},
+		{"operation", d.Operation},
+		{"user", d.User},
+		{"decision", d.decision()},
+		{"mode", d.Mode},
+		{"dry_run", strconv.FormatBool(d.DryRun)},
+		{"reason", d.Reason},
+	}
+	if d.Message != "" {
+		pairs = append(pairs, [2]string{"message", d.Message})
+	}
+
+	var b strings.Builder
+	for i, p := range pairs {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(p[0])
+		b.WriteByte('=')
+		b.WriteString(logfmtValue(p[1]))
+	}
+	b.WriteByte('\n')
+
+	return []byte(b.String()), nil
+}
+
+// logfmtValue quotes empty values and values, which can't be read back unquoted
+func logfmtValue(s string) string {
+	if s == "" || strings.ContainsAny(s, " =\"\\\t\r\n") {
+		return strconv.Quote(s)
+	}
+
+	return s
+}
+
+// DecisionLog writes admission decisions to audit stream, separate from operational logs
+type DecisionLog struct {
+	encoder DecisionEncoder
+	w       io.Writer
+	m       sync.Mutex
+}
+
+// NewDecisionLog creates DecisionLog writing decisions encoded by encoder to w
+func NewDecisionLog(encoder DecisionEncoder, w io.Writer) *DecisionLog {
+	return &DecisionLog{encoder: encoder, w: w}
+}
+
+// Write encodes and writes the decision, errors are logged and counted, so that they don't affect admission
+func (dl *DecisionLog) Write(d Decision) {
+	b, err := dl.encoder.Encode(d)
+	if err != nil {
+		decisionLogErrorsCounter.Inc()
+		log.WithError(err).Error("unable to encode decision")
+		return
+	}
+
+	dl.m.Lock()
+	defer dl.m.Unlock()
+
+	if _, err := dl.w.Write(b); err != nil {
+		decisionLogErrorsCounter.Inc()
+		log.WithError(err).Error("unable to write decision log")
+	}
+}
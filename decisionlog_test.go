@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func sampleDecision() Decision {
+	return Decision{
+		Time:      time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		UID:       "e911857d-c318-11e8-bbad-025000000001",
+		Kind:      "Pod",
+		Namespace: "team-a",
+		Name:      "web",
+		Operation: "CREATE",
+		User:      "system:serviceaccount:team-a|deployer",
+		Allowed:   false,
+		Mode:      modeEnforce,
+		Reason:    string(reasonLimitExceeded),
+		Message:   "error container app limits.CPU: 2 > 1",
+	}
+}
+
+func TestDecisionEncoders(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{
+			format:   decisionLogJSONL,
+			expected: `{"time":"2024-03-01T12:00:00Z","uid":"e911857d-c318-11e8-bbad-025000000001","kind":"Pod","namespace":"team-a","name":"web","operation":"CREATE","user":"system:serviceaccount:team-a|deployer","allowed":false,"mode":"enforce","dryRun":false,"reason":"limit_exceeded","message":"error container app limits.CPU: 2 > 1"}` + "\n",
+		},
+		{
+			format: decisionLogCEF,
+			expected: `CEF:0|devopyio|resource-requests-admission-controller||limit_exceeded|admission denied|7|` +
+				`rt=1709294400000 externalId=e911857d-c318-11e8-bbad-025000000001 act=denied outcome=denied suser=system:serviceaccount:team-a|deployer ` +
+				`cs1Label=kind cs1=Pod cs2Label=namespace cs2=team-a cs3Label=name cs3=web cs4Label=operation cs4=CREATE ` +
+				`cs5Label=mode cs5=enforce cs6Label=dryRun cs6=false reason=limit_exceeded msg=error container app limits.CPU: 2 > 1` + "\n",
+		},
+		{
+			format: decisionLogLogfmt,
+			expected: `time=2024-03-01T12:00:00Z uid=e911857d-c318-11e8-bbad-025000000001 kind=Pod namespace=team-a name=web operation=CREATE ` +
+				`user=system:serviceaccount:team-a|deployer decision=denied mode=enforce dry_run=false reason=limit_exceeded message="error container app limits.CPU: 2 > 1"` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		encoder, err := NewDecisionEncoder(tt.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := encoder.Encode(sampleDecision())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.expected, string(b), tt.format)
+	}
+}
+
+func TestDecisionEncodersEscaping(t *testing.T) {
+	d := sampleDecision()
+	d.Reason = "custom|reason"
+	d.Message = "a=b\nc\\d"
+
+	b, err := cefEncoder{}.Encode(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, string(b), `||custom\|reason|admission denied|7|`)
+	assert.Contains(t, string(b), `msg=a\=b\nc\\d`+"\n")
+
+	b, err = logfmtEncoder{}.Encode(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, string(b), `message="a=b\nc\\d"`+"\n")
+
+	d.Name = ""
+	d.Message = ""
+	b, err = logfmtEncoder{}.Encode(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, string(b), ` name="" `)
+	assert.NotContains(t, string(b), "message=")
+}
+
+func TestNewDecisionEncoderUnknown(t *testing.T) {
+	_, err := NewDecisionEncoder("xml")
+	assert.EqualError(t, err, `unknown decision log format "xml", must be jsonl, cef or logfmt`)
+}
+
+func TestHandleAdmissionDecisionLog(t *testing.T) {
+	cpu := resource.MustParse("1")
+	var buf bytes.Buffer
+	rra := New(&MockConfiger{cpu: &cpu}, Options{DecisionLog: NewDecisionLog(jsonlEncoder{}, &buf)})
+	rra.now = func() time.Time { return time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC) }
+
+	for _, spec := range []string{
+		`{"containers": [{"name": "app", "resources": {"requests": {"cpu": "500m", "memory": "1Gi"}, "limits": {"cpu": 2}}}]}`,
+		`{"containers": [{"name": "app", "resources": {"requests": {"cpu": "500m", "memory": "1Gi"}, "limits": {"cpu": 1}}}]}`,
+	} {
+		if _, err := rra.HandleAdmission(context.Background(), podReview(spec).Request); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dec := json.NewDecoder(&buf)
+	var denied, allowed Decision
+	if err := dec.Decode(&denied); err != nil {
+		t.Fatal(err)
+	}
+	if err := dec.Decode(&allowed); err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, dec.More())
+
+	assert.False(t, denied.Allowed)
+	assert.Equal(t, "Pod", denied.Kind)
+	assert.Equal(t, modeEnforce, denied.Mode)
+	assert.Equal(t, string(reasonLimitExceeded), denied.Reason)
+	assert.Equal(t, "error container app limits.CPU: 2 > 1", denied.Message)
+
+	assert.True(t, allowed.Allowed)
+	assert.Equal(t, reasonNone, allowed.Reason)
+	assert.Equal(t, "", allowed.Message)
+}
+
+func TestHandleAdmissionAuditLog(t *testing.T) {
+	cpu := resource.MustParse("1")
+	var buf bytes.Buffer
+	rra := New(&MockConfiger{cpu: &cpu}, Options{DecisionLog: NewDecisionLog(jsonlEncoder{}, &buf)})
+
+	req := podReview(`{"containers": [{"name": "app", "resources": {"requests": {"cpu": "500m", "memory": "1Gi"}, "limits": {"cpu": 2}}}]}`).Request
+	req.Name = "test"
+	req.Namespace = "team-a"
+	req.UserInfo.Username = "system:serviceaccount:team-a:deployer"
+	if _, err := rra.HandleAdmission(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+
+	// field names are the contract with log collectors
+	var fields map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &fields); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "e911857d-c318-11e8-bbad-025000000001", fields["uid"])
+	assert.Equal(t, "team-a", fields["namespace"])
+	assert.Equal(t, "Pod", fields["kind"])
+	assert.Equal(t, "test", fields["name"])
+	assert.Equal(t, "system:serviceaccount:team-a:deployer", fields["user"])
+	assert.Equal(t, false, fields["allowed"])
+	assert.Equal(t, string(reasonLimitExceeded), fields["reason"])
+	assert.Equal(t, 1, bytes.Count(buf.Bytes(), []byte("\n")))
+}
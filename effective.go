@@ -0,0 +1,406 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// EffectiveName is a limit of workloads matching the name, name glob or nameRegex in the namespace
+type EffectiveName struct {
+	NameNamespace
+	Limit Limit `json:"limit"`
+}
+
+// EffectiveConfig is configuration in use, as it was parsed from the config file. It has json keys of Config,
+// top level declaration is inlined like in the config file. Declarations have fields inherited from top level declaration
+// resolved, quantities are formatted in --cpu-units and --memory-units.
+type EffectiveConfig struct {
+	Limit
+	Namespaces         map[string]Limit    `json:"namespaces"`
+	Names              []EffectiveName     `json:"names"`
+	NamespaceSelectors []NamespaceSelector `json:"namespaceSelectors"`
+	Groups             []GroupLimit        `json:"groups"`
+	Labels             []LabelLimit        `json:"labels"`
+	Policies           []Policy            `json:"policies"`
+	Precedence         string              `json:"precedence"`
+}
+
+// effectiveLimit returns l as config file declaration, limits which are not set are left empty
+func effectiveLimit(l LimitResource) Limit {
+	el := Limit{
+		CPULimit:               quantityString(corev1.ResourceCPU, l.CPULimit),
+		MemLimit:               quantityString(corev1.ResourceMemory, l.MemLimit),
+		CPURequest:             quantityString(corev1.ResourceCPU, l.CPURequest),
+		MemRequest:             quantityString(corev1.ResourceMemory, l.MemRequest),
+		EphemeralStorageLimit:  quantityString(corev1.ResourceEphemeralStorage, l.EphemeralStorageLimit),
+		PodMemLimit:            quantityString(corev1.ResourceMemory, l.PodMemLimit),
+		GuaranteedCPU:          quantityString(corev1.ResourceCPU, l.GuaranteedCPU),
+		GuaranteedMem:          quantityString(corev1.ResourceMemory, l.GuaranteedMem),
+		CountMemoryEmptyDirs:   boolPtr(l.CountMemoryEmptyDirs),
+		RequireLimits:          boolPtr(l.RequireLimits),
+		MinCPURequest:          quantityString(corev1.ResourceCPU, l.MinCPURequest),
+		MinMemRequest:          quantityString(corev1.ResourceMemory, l.MinMemRequest),
+		PVCSize:                quantityString(corev1.ResourceStorage, l.PVCSize),
+		MinPVCSize:             quantityString(corev1.ResourceStorage, l.MinPVCSize),
+		PVCSizePerStorageClass: quantityStrings(corev1.ResourceStorage, l.PVCSizePerStorageClass),
+		CPUBurst:               quantityString(corev1.ResourceCPU, l.CPUBurst),
+		MemBurst:               quantityString(corev1.ResourceMemory, l.MemBurst),
+		NamespacePVCSize:       quantityString(corev1.ResourceStorage, l.NamespacePVCSize),
+		NamespaceGPUs:          quantityString(gpuResource, l.NamespaceGPUs),
+		Unlimited:              l.Unlimited,
+		UnlimitedPVC:           l.UnlimitedPVC,
+		Uncapped:               l.Uncapped,
+		Mode:                   l.Mode,
+		DefaultAction:          l.DefaultAction,
+		Mutate:                 boolPtr(l.Mutate),
+		CPULimitPolicy:         l.CPULimitPolicy,
+		SwapPolicy:             l.SwapPolicy,
+		MaxLimitRequestRatio:   l.MaxLimitRequestRatio,
+		ZeroRequestRatio:       l.ZeroRequestRatio,
+		RequestFraction:        l.RequestFraction,
+		MaxResourceClaims:      l.MaxResourceClaims,
+		MaxContainerResources:  l.MaxContainerResources,
+		MaxReplicas:            l.MaxReplicas,
+		TopologySpreadReplicas: l.TopologySpreadReplicas,
+		TopologySpreadPolicy:   l.TopologySpreadPolicy,
+		AllowedDeviceClasses:   l.AllowedDeviceClasses,
+
+		ForbidEphemeralContainers: l.ForbidEphemeralContainers,
+		RequiredProbes:            l.RequiredProbes,
+		ProbeExemptContainers:     l.ProbeExemptContainers,
+		ExemptTolerations:         l.ExemptTolerations,
+		ExemptGroups:              l.ExemptGroups,
+	}
+
+	if l.RequestFraction != nil {
+		el.RequestFractionTolerance = &l.RequestFractionTolerance
+	}
+
+	if l.ExtendedResources != nil {
+		el.ExtendedResources = make(map[string]string, len(l.ExtendedResources))
+		for name, q := range l.ExtendedResources {
+			el.ExtendedResources[string(name)] = quantityString(name, q)
+		}
+	}
+
+	if l.Severity != nil {
+		el.Severity = make(map[string]string, len(l.Severity))
+		for name, severity := range l.Severity {
+			el.Severity[string(name)] = severity
+		}
+	}
+
+	for _, name := range l.PairedResources {
+		el.PairedResources = append(el.PairedResources, string(name))
+	}
+
+	for _, re := range l.ExemptUsers {
+		el.ExemptUsers = append(el.ExemptUsers, regexToGlob(re))
+	}
+
+	for _, iml := range l.ImageMemLimits {
+		el.ImageMemLimits = append(el.ImageMemLimits, ImageMemLimit{
+			ImagePrefix: iml.imagePrefix,
+			MemLimit:    quantityString(corev1.ResourceMemory, iml.memLimit),
+			MemRequest:  quantityString(corev1.ResourceMemory, iml.memRequest),
+		})
+	}
+
+	if l.Profiles != nil {
+		el.Profiles = make(map[string]ResourceProfile, len(l.Profiles))
+		for name, profile := range l.Profiles {
+			el.Profiles[name] = ResourceProfile{
+				CPURequest: quantityString(corev1.ResourceCPU, profile.cpuRequest),
+				MemRequest: quantityString(corev1.ResourceMemory, profile.memRequest),
+				CPULimit:   quantityString(corev1.ResourceCPU, profile.cpuLimit),
+				MemLimit:   quantityString(corev1.ResourceMemory, profile.memLimit),
+			}
+		}
+	}
+
+	if l.Sidecar != nil {
+		sidecar := effectiveLimit(*l.Sidecar)
+		el.Sidecar = &sidecar
+	}
+
+	if l.UserNamespace != nil {
+		userNamespace := effectiveLimit(*l.UserNamespace)
+		el.UserNamespace = &userNamespace
+	}
+
+	if l.RestartPolicies != nil {
+		el.RestartPolicies = make(map[string]Limit, len(l.RestartPolicies))
+		for policy, limit := range l.RestartPolicies {
+			el.RestartPolicies[string(policy)] = effectiveLimit(limit)
+		}
+	}
+
+	el.WorkloadClasses = effectiveLimits(l.WorkloadClasses)
+	el.Containers = effectiveLimits(l.Containers)
+
+	for _, ll := range l.LabelLimits {
+		el.LabelLimits = append(el.LabelLimits, LabelLimit{MatchLabels: ll.matchLabels, Limit: effectiveLimit(ll.limit)})
+	}
+
+	return el
+}
+
+// effectiveLimits returns limits by name as config file declarations, it's nil if limits is nil
+func effectiveLimits(limits map[string]LimitResource) map[string]Limit {
+	if limits == nil {
+		return nil
+	}
+
+	out := make(map[string]Limit, len(limits))
+	for name, limit := range limits {
+		out[name] = effectiveLimit(limit)
+	}
+
+	return out
+}
+
+// quantityString returns quantity of the resource formatted in configured units, empty if it's nil
+func quantityString(name corev1.ResourceName, q *resource.Quantity) string {
+	if q == nil {
+		return ""
+	}
+
+	return formatQuantity(name, *q)
+}
+
+// quantityStrings returns formatted quantities of the resource, it's nil if quantities is nil
+func quantityStrings(name corev1.ResourceName, quantities map[string]*resource.Quantity) map[string]string {
+	if quantities == nil {
+		return nil
+	}
+
+	out := make(map[string]string, len(quantities))
+	for key, q := range quantities {
+		out[key] = quantityString(name, q)
+	}
+
+	return out
+}
+
+// boolPtr returns pointer to b, if it's set, so that false inherited values are omitted
+func boolPtr(b bool) *bool {
+	if !b {
+		return nil
+	}
+
+	return &b
+}
+
+// regexToGlob returns glob, which was compiled to re by globToRegex and anchored
+func regexToGlob(re *regexp.Regexp) string {
+	expr := strings.TrimSuffix(strings.TrimPrefix(re.String(), "^(?:"), ")$")
+
+	var b strings.Builder
+	for i := 0; i < len(expr); i++ {
+		switch {
+		case expr[i] == '\\' && i+1 < len(expr):
+			i++
+			b.WriteByte(expr[i])
+		case strings.HasPrefix(expr[i:], ".*"):
+			i++
+			b.WriteByte('*')
+		case expr[i] == '.':
+			b.WriteByte('?')
+		default:
+			b.WriteByte(expr[i])
+		}
+	}
+
+	return b.String()
+}
+
+// Effective returns configuration in use, names are sorted by namespace and name, patterns follow in the order they are matched
+func (c *Configurer) Effective() EffectiveConfig {
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	ec := EffectiveConfig{
+		Limit:      effectiveLimit(c.defaultLimit),
+		Namespaces: make(map[string]Limit, len(c.excludedNamespaces)),
+		Names:      make([]EffectiveName, 0, len(c.excludedNames)+len(c.namePatterns)),
+		Precedence: c.precedence,
+	}
+
+	for namespace, limit := range c.excludedNamespaces {
+		ec.Namespaces[namespace] = effectiveLimit(limit)
+	}
+
+	for nn, limit := range c.excludedNames {
+		ec.Names = append(ec.Names, EffectiveName{NameNamespace: nn, Limit: effectiveLimit(limit)})
+	}
+	sort.Slice(ec.Names, func(i, j int) bool {
+		if ec.Names[i].Namespace != ec.Names[j].Namespace {
+			return ec.Names[i].Namespace < ec.Names[j].Namespace
+		}
+
+		return ec.Names[i].Name < ec.Names[j].Name
+	})
+
+	for _, np := range c.namePatterns {
+		ec.Names = append(ec.Names, EffectiveName{
+			NameNamespace: NameNamespace{Namespace: np.namespace, NameRegex: np.re.String()},
+			Limit:         effectiveLimit(np.limit),
+		})
+	}
+
+	for _, nsl := range c.namespaceSelectors {
+		ec.NamespaceSelectors = append(ec.NamespaceSelectors, NamespaceSelector{
+			MatchLabels:      nsl.matchLabels,
+			MatchAnnotations: nsl.matchAnnotations,
+			MatchPhase:       string(nsl.matchPhase),
+			Limit:            effectiveLimit(nsl.limit),
+		})
+	}
+
+	for _, gl := range c.groups {
+		ec.Groups = append(ec.Groups, GroupLimit{Group: gl.group, Limit: effectiveLimit(gl.limit)})
+	}
+
+	for _, ll := range c.labels {
+		ec.Labels = append(ec.Labels, LabelLimit{MatchLabels: ll.matchLabels, Limit: effectiveLimit(ll.limit)})
+	}
+
+	for _, pl := range c.policies {
+		ec.Policies = append(ec.Policies, Policy{Name: pl.name, Match: pl.match, Limit: effectiveLimit(pl.limit)})
+	}
+
+	return ec
+}
+
+// compact removes empty values (empty strings, false, null and empty arrays) from objects of decoded JSON,
+// so that limits which are not set are omitted, like in the config file. Objects are kept, even if they are empty,
+// e.g. customNamespaces entry without limits.
+func compact(v interface{}) (interface{}, bool) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if compacted, ok := compact(value); ok {
+				v[key] = compacted
+			} else {
+				delete(v, key)
+			}
+		}
+		return v, true
+	case []interface{}:
+		out := v[:0]
+		for _, value := range v {
+			// list items keep their position, e.g. label limit without limits still matches first
+			compacted, _ := compact(value)
+			out = append(out, compacted)
+		}
+		return out, len(out) > 0
+	case string:
+		return v, v != ""
+	case bool:
+		return v, v
+	case nil:
+		return nil, false
+	default:
+		return v, true
+	}
+}
+
+// ConfigHandler serves configuration in use as JSON, so that operators can see which limits a workload got
+type ConfigHandler struct {
+	configer *Configurer
+	token    []byte
+}
+
+// NewConfigHandler creates ConfigHandler, requests must have bearer token unless it's empty
+func NewConfigHandler(configer *Configurer, token []byte) *ConfigHandler {
+	return &ConfigHandler{configer: configer, token: token}
+}
+
+// ServeHTTP serves configuration in use
+func (ch *ConfigHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !authorized(w, r, ch.token) {
+		return
+	}
+
+	body, err := json.Marshal(ch.configer.Effective())
+	if err != nil {
+		log.WithError(err).Error("unable to marshal config")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var config interface{}
+	if err := json.Unmarshal(body, &config); err != nil {
+		log.WithError(err).Error("unable to unmarshal config")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	config, _ = compact(config)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(config); err != nil {
+		log.WithError(err).Error("unable to write config response")
+	}
+}
+
+// authorized checks bearer token of ops server request, it responds with unauthorized if the token doesn't match
+func authorized(w http.ResponseWriter, r *http.Request, token []byte) bool {
+	if len(token) == 0 {
+		return true
+	}
+
+	bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(bearer), token) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// ReloadHandler reloads configuration on request, e.g. when config map update wasn't yet seen by the watch
+type ReloadHandler struct {
+	configer *Configurer
+	token    []byte
+}
+
+// NewReloadHandler creates ReloadHandler, requests must have bearer token unless it's empty
+func NewReloadHandler(configer *Configurer, token []byte) *ReloadHandler {
+	return &ReloadHandler{configer: configer, token: token}
+}
+
+// ServeHTTP reloads configuration, it responds with the load error, if previous configuration stays in use
+func (rh *ReloadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !authorized(w, r, rh.token) {
+		return
+	}
+
+	if err := rh.configer.Reload(); err != nil {
+		log.WithError(err).Error("config reload error, previous config stays in use")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Info("config reloaded")
+	w.WriteHeader(http.StatusOK)
+}
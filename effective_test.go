@@ -0,0 +1,288 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigHandler(t *testing.T) {
+	configer, err := NewConfigurer("./testdata/test.yaml", 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	server := httptest.NewServer(NewConfigHandler(configer, []byte("s3cr3t")))
+	defer server.Close()
+
+	tests := []struct {
+		name          string
+		method        string
+		authorization string
+		status        int
+	}{
+		{name: "no token", method: http.MethodGet, status: http.StatusUnauthorized},
+		{name: "wrong token", method: http.MethodGet, authorization: "Bearer wrong", status: http.StatusUnauthorized},
+		{name: "token without bearer", method: http.MethodGet, authorization: "s3cr3t", status: http.StatusUnauthorized},
+		{name: "post", method: http.MethodPost, authorization: "Bearer s3cr3t", status: http.StatusMethodNotAllowed},
+		{name: "token", method: http.MethodGet, authorization: "Bearer s3cr3t", status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.authorization != "" {
+			req.Header.Set("Authorization", tt.authorization)
+		}
+
+		r, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Body.Close()
+
+		assert.Equal(t, tt.status, r.StatusCode, tt.name)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer s3cr3t")
+
+	r, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Body.Close()
+	assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// keys are the same as in the config, top level declaration is inlined
+	var raw map[string]interface{}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, raw, "namespaces")
+	assert.Contains(t, raw, "names")
+	assert.Contains(t, raw, "maxCPULimit")
+	assert.NotContains(t, raw, "customNamespaces")
+	// limits which are not set are omitted
+	assert.NotContains(t, raw, "maxGuaranteedCPU")
+	assert.NotContains(t, raw, "unlimited")
+
+	var ec EffectiveConfig
+	if err := json.Unmarshal(body, &ec); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "2", ec.CPULimit)
+	assert.Equal(t, "2Gi", ec.MemLimit)
+	assert.Equal(t, "1", ec.CPURequest)
+	assert.Equal(t, "1Gi", ec.MemRequest)
+	assert.Equal(t, "50Gi", ec.PVCSize)
+	assert.Equal(t, "1Gi", ec.MinPVCSize)
+	assert.Equal(t, 100, *ec.MaxReplicas)
+	assert.Equal(t, "500m", ec.Sidecar.CPULimit)
+	assert.Equal(t, []string{"system:serviceaccount:kube-system:*"}, ec.ExemptUsers)
+	assert.Equal(t, []string{"platform:admins"}, ec.ExemptGroups)
+
+	// maxMemLimit and maxPVCSize are taken from top level declaration
+	kubeSystem := ec.Namespaces["kube-system"]
+	assert.Equal(t, "1", kubeSystem.CPULimit)
+	assert.Equal(t, "500m", kubeSystem.CPURequest)
+	assert.Equal(t, "2Gi", kubeSystem.MemLimit)
+	assert.Equal(t, "50Gi", kubeSystem.PVCSize)
+	assert.Equal(t, map[string]string{"cpu": "warn"}, kubeSystem.Severity)
+	assert.Equal(t, []string{"system:serviceaccount:kube-system:*", "system:node:*"}, kubeSystem.ExemptUsers)
+
+	assert.True(t, ec.Namespaces["default"].Unlimited)
+	assert.True(t, ec.Namespaces["default"].UnlimitedPVC)
+
+	// maxCPULimit of the sidecar is taken from top level sidecar declaration
+	testNamespace := ec.Namespaces["test-namespace"]
+	assert.Equal(t, "500m", testNamespace.Sidecar.CPULimit)
+	assert.Equal(t, "128Mi", testNamespace.Sidecar.MemLimit)
+	assert.True(t, testNamespace.ForbidEphemeralContainers)
+
+	assert.Equal(t, "500m", ec.Namespaces["mesh"].Containers["istio-proxy"].CPULimit)
+	assert.Equal(t, "2", ec.Namespaces["sandboxed"].UserNamespace.CPULimit)
+
+	names := map[NameNamespace]Limit{}
+	for _, name := range ec.Names {
+		names[name.NameNamespace] = name.Limit
+	}
+
+	deployment := names[NameNamespace{Name: "deployment-name", Namespace: "test-namespace"}]
+	assert.Equal(t, "3", deployment.CPULimit)
+	assert.Equal(t, "5Gi", deployment.MemLimit)
+	assert.Equal(t, "15Gi", deployment.PVCSize)
+	assert.Equal(t, "4Gi", deployment.EphemeralStorageLimit)
+
+	worker := names[NameNamespace{Namespace: "ingest", NameRegex: "^(?:ingest-worker-.*)$"}]
+	assert.Equal(t, "4", worker.CPULimit)
+	assert.Equal(t, 500, *worker.MaxReplicas)
+
+	if assert.Len(t, ec.NamespaceSelectors, 1) {
+		assert.Equal(t, map[string]string{"tier": "batch"}, ec.NamespaceSelectors[0].MatchLabels)
+		assert.Equal(t, "4", ec.NamespaceSelectors[0].Limit.CPULimit)
+	}
+	if assert.Len(t, ec.Groups, 1) {
+		assert.Equal(t, "platform-admins", ec.Groups[0].Group)
+		assert.Equal(t, "16Gi", ec.Groups[0].Limit.MemLimit)
+	}
+	if assert.Len(t, ec.Labels, 1) {
+		assert.Equal(t, "12Gi", ec.Labels[0].Limit.MemLimit)
+	}
+}
+
+func TestConfigHandlerUnits(t *testing.T) {
+	defer SetQuantityUnits(unitsAuto, unitsAuto)
+	if err := SetQuantityUnits(cpuUnitsMillicores, memoryUnitsDecimal); err != nil {
+		t.Fatal(err)
+	}
+
+	configer, err := NewConfigurer("./testdata/test.yaml", 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	server := httptest.NewServer(NewConfigHandler(configer, nil))
+	defer server.Close()
+
+	r, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Body.Close()
+
+	var ec EffectiveConfig
+	if err := json.NewDecoder(r.Body).Decode(&ec); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "2000m", ec.CPULimit)
+	assert.Equal(t, "2.147G", ec.MemLimit)
+	assert.Equal(t, "500m", ec.Namespaces["kube-system"].CPURequest)
+}
+
+func TestConfigHandlerWithoutToken(t *testing.T) {
+	configer, err := NewConfigurer("./testdata/test.yaml", 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	server := httptest.NewServer(NewConfigHandler(configer, nil))
+	defer server.Close()
+
+	r, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Body.Close()
+
+	assert.Equal(t, http.StatusOK, r.StatusCode)
+}
+
+func TestReloadHandler(t *testing.T) {
+	f, err := ioutil.TempFile("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString("maxCPULimit: 1"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	configer, err := NewConfigurer(f.Name(), 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+	// only the handler reloads
+	configer.SetReloadDebounce(1 * time.Hour)
+
+	server := httptest.NewServer(NewReloadHandler(configer, []byte("s3cr3t")))
+	defer server.Close()
+
+	if err := ioutil.WriteFile(f.Name(), []byte("maxCPULimit: 2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		method        string
+		authorization string
+		status        int
+		cpuLimit      int64
+	}{
+		{name: "no token", method: http.MethodPost, status: http.StatusUnauthorized, cpuLimit: 1},
+		{name: "wrong token", method: http.MethodPost, authorization: "Bearer wrong", status: http.StatusUnauthorized, cpuLimit: 1},
+		{name: "get", method: http.MethodGet, authorization: "Bearer s3cr3t", status: http.StatusMethodNotAllowed, cpuLimit: 1},
+		{name: "token", method: http.MethodPost, authorization: "Bearer s3cr3t", status: http.StatusOK, cpuLimit: 2},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.authorization != "" {
+			req.Header.Set("Authorization", tt.authorization)
+		}
+
+		r, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Body.Close()
+
+		assert.Equal(t, tt.status, r.StatusCode, tt.name)
+		limit := configer.GetPodLimit(NameNamespace{Namespace: "default"})
+		assert.Equal(t, tt.cpuLimit, limit.CPULimit.Value(), tt.name)
+	}
+
+	if err := ioutil.WriteFile(f.Name(), []byte("maxCPULimit: notaquantity"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer s3cr3t")
+
+	r, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Body.Close()
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusInternalServerError, r.StatusCode)
+	assert.True(t, strings.Contains(string(body), "notaquantity"), string(body))
+	limit := configer.GetPodLimit(NameNamespace{Namespace: "default"})
+	assert.Equal(t, int64(2), limit.CPULimit.Value())
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const gpuResource corev1.ResourceName = "nvidia.com/gpu"
+
+// containerGPUs returns GPU request of container, extended resources may set only limit, which is copied to request
+func containerGPUs(container corev1.Container) int64 {
+	if q, ok := container.Resources.Requests[gpuResource]; ok {
+		return q.Value()
+	}
+
+	q := container.Resources.Limits[gpuResource]
+	return q.Value()
+}
+
+// podGPUs returns GPUs requested by pod, native sidecars run alongside containers, so they are summed,
+// other init containers run before containers, so only the largest one counts
+func podGPUs(podSpec corev1.PodSpec) int64 {
+	gpus := podTotal(podSpec, gpuResource, func(container corev1.Container) corev1.ResourceList {
+		return corev1.ResourceList{gpuResource: *resource.NewQuantity(containerGPUs(container), resource.DecimalSI)}
+	})
+
+	return gpus.Value()
+}
+
+// validateNamespaceGPUs reserves GPUs of all workload replicas in namespace tally and denies workload,
+// if the tally would exceed namespace GPU limit
+func (rra *ResourceRequestsAdmission) validateNamespaceGPUs(ctx context.Context, req *v1beta1.AdmissionRequest, w *workload) *v1beta1.AdmissionResponse {
+	maxGPUs, _ := rra.conf.GetMaxNamespaceGPUs(req.Namespace)
+	if maxGPUs == nil {
+		return nil
+	}
+
+	// bare pods are counted by their own name, since pods of a name prefix are distinct workloads
+	name := w.name
+	if w.kind == podKind {
+		name = w.podName
+	}
+	if name == "" {
+		name = string(req.UID)
+	}
+
+	gpus := resource.NewQuantity(podGPUs(w.podSpec)*w.replicas, resource.DecimalSI)
+	dryRun := req.DryRun != nil && *req.DryRun
+	total, ok := reserve(ctx, rra.gpuUsage, req.Namespace, strings.ToLower(w.kind)+"/"+name, *gpus, *maxGPUs, dryRun)
+	if ok {
+		return nil
+	}
+
+	return &v1beta1.AdmissionResponse{
+		UID:     req.UID,
+		Allowed: false,
+		Result: &metav1.Status{
+			Message: fmt.Sprintf("error namespace %s %s requests would be %s", req.Namespace, gpuResource, formatComparison(gpuResource, total, ">", *maxGPUs)),
+		},
+		AuditAnnotations: reasonAnnotations(reasonNamespaceGPUsExceeded),
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestPodGPUs(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+		gpus int64
+	}{
+		{
+			name: "no gpus",
+			spec: `{"containers": [{"name": "app"}]}`,
+		},
+		{
+			name: "containers are summed",
+			spec: `{"containers": [{"name": "a", "resources": {"requests": {"nvidia.com/gpu": 1}}}, {"name": "b", "resources": {"requests": {"nvidia.com/gpu": 2}}}]}`,
+			gpus: 3,
+		},
+		{
+			name: "limit without request",
+			spec: `{"containers": [{"name": "app", "resources": {"limits": {"nvidia.com/gpu": 2}}}]}`,
+			gpus: 2,
+		},
+		{
+			name: "largest init container",
+			spec: `{"initContainers": [{"name": "warmup", "resources": {"limits": {"nvidia.com/gpu": 4}}}], "containers": [{"name": "app", "resources": {"limits": {"nvidia.com/gpu": 1}}}]}`,
+			gpus: 4,
+		},
+		{
+			name: "native sidecar is summed with containers",
+			spec: `{"initContainers": [{"name": "proxy", "restartPolicy": "Always", "resources": {"limits": {"nvidia.com/gpu": 1}}}], "containers": [{"name": "app", "resources": {"limits": {"nvidia.com/gpu": 2}}}]}`,
+			gpus: 3,
+		},
+		{
+			name: "init container larger than containers and sidecars",
+			spec: `{"initContainers": [{"name": "proxy", "restartPolicy": "Always", "resources": {"limits": {"nvidia.com/gpu": 1}}}, {"name": "warmup", "resources": {"limits": {"nvidia.com/gpu": 4}}}], "containers": [{"name": "app", "resources": {"limits": {"nvidia.com/gpu": 2}}}]}`,
+			gpus: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{}
+		w, err := rra.decodeWorkload(podReview(tt.spec).Request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.gpus, podGPUs(w.podSpec), tt.name)
+	}
+}
+
+func TestHandleAdmissionNamespaceGPUs(t *testing.T) {
+	maxGPUs := resource.MustParse("4")
+	rra := &ResourceRequestsAdmission{
+		conf:     &MockConfiger{nsGPUs: &maxGPUs},
+		gpuUsage: newUsageTracker(),
+	}
+
+	deployment := func(name, replicas, gpus string) []byte {
+		return []byte(`{"metadata": {"name": "` + name + `"}, "spec": {"replicas": ` + replicas + `, "template": {"spec": {"containers": [{"name": "train",
+			"resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"nvidia.com/gpu": ` + gpus + `}}}]}}}}`)
+	}
+	dryRun := true
+
+	tests := []struct {
+		name    string
+		kind    string
+		object  []byte
+		dryRun  *bool
+		message string
+	}{
+		{
+			name:   "first deployment",
+			kind:   "Deployment",
+			object: deployment("train", "2", "1"),
+		},
+		{
+			name:   "dry run up to budget is not reserved",
+			kind:   "Deployment",
+			object: deployment("eval", "2", "1"),
+			dryRun: &dryRun,
+		},
+		{
+			name:   "second deployment up to budget",
+			kind:   "Deployment",
+			object: deployment("eval", "1", "2"),
+		},
+		{
+			name: "controlled pod is counted on its deployment",
+			kind: "Pod",
+			object: []byte(`{"metadata": {"name": "train-5d8f7c9b4-x2x7k", "ownerReferences": [{"kind": "ReplicaSet", "name": "train-5d8f7c9b4", "controller": true}]},
+				"spec": {"containers": [{"name": "train", "resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"nvidia.com/gpu": 1}}}]}}`),
+		},
+		{
+			name:    "third deployment over budget",
+			kind:    "Deployment",
+			object:  deployment("infer", "1", "1"),
+			message: "error namespace ml nvidia.com/gpu requests would be 5 > 4",
+		},
+		{
+			name:    "scaling up over budget",
+			kind:    "Deployment",
+			object:  deployment("train", "3", "1"),
+			message: "error namespace ml nvidia.com/gpu requests would be 5 > 4",
+		},
+		{
+			name:   "scaling down frees budget",
+			kind:   "Deployment",
+			object: deployment("train", "1", "1"),
+		},
+		{
+			name:   "freed budget is used",
+			kind:   "Deployment",
+			object: deployment("infer", "1", "1"),
+		},
+		{
+			name:   "workload without gpus",
+			kind:   "Deployment",
+			object: deployment("web", "3", "0"),
+		},
+	}
+
+	for _, tt := range tests {
+		resp, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+			UID:       "e911857d-c318-11e8-bbad-025000000001",
+			Kind:      metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: tt.kind},
+			Namespace: "ml",
+			Operation: v1beta1.Create,
+			Object:    runtime.RawExtension{Raw: tt.object},
+			DryRun:    tt.dryRun,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.message == "", resp.Allowed, tt.name)
+		if tt.message != "" {
+			assert.Equal(t, tt.message, resp.Result.Message, tt.name)
+			assert.Equal(t, string(reasonNamespaceGPUsExceeded), resp.AuditAnnotations[denialReasonAnnotation], tt.name)
+		}
+	}
+}
+
+func TestHandleAdmissionNamespaceGPUsBarePods(t *testing.T) {
+	maxGPUs := resource.MustParse("3")
+	rra := &ResourceRequestsAdmission{
+		conf:     &MockConfiger{nsGPUs: &maxGPUs},
+		gpuUsage: newUsageTracker(),
+	}
+
+	pod := func(name string) []byte {
+		return []byte(`{"metadata": {"name": "` + name + `"}, "spec": {"containers": [{"name": "train",
+			"resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"nvidia.com/gpu": 2}}}]}}`)
+	}
+
+	tests := []struct {
+		name    string
+		object  []byte
+		message string
+	}{
+		{
+			name:   "first pod",
+			object: pod("gpu-job-1"),
+		},
+		{
+			// both pods would be named gpu-job after stripping the suffix
+			name:    "second pod of the same name prefix is counted separately",
+			object:  pod("gpu-job-2"),
+			message: "error namespace ml nvidia.com/gpu requests would be 4 > 3",
+		},
+		{
+			name:   "update of the same pod replaces its reservation",
+			object: pod("gpu-job-1"),
+		},
+	}
+
+	for _, tt := range tests {
+		resp, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+			UID:       "e911857d-c318-11e8-bbad-025000000001",
+			Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+			Namespace: "ml",
+			Operation: v1beta1.Create,
+			Object:    runtime.RawExtension{Raw: tt.object},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.message == "", resp.Allowed, tt.name)
+		if tt.message != "" {
+			assert.Equal(t, tt.message, resp.Result.Message, tt.name)
+		}
+	}
+}
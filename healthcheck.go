@@ -4,45 +4,97 @@ import (
 	"bytes"
 	"crypto/tls"
 	"encoding/json"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"time"
 
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
-// Healthchecker checks admission controller health
+// maxHealthcheckResponseBytes limits size of admission response read by healthcheck
+const maxHealthcheckResponseBytes = 1 << 20
+
+// healthcheckUID is UID of healthcheck requests, they are not counted in admission metrics, since they are denied on purpose
+const healthcheckUID = "00000000-0000-0000-0000-000000000000"
+
+// Healthchecker checks admission controller readiness by sending a pod without requests,
+// which must be denied in enforce mode, so that the check fails if validation is broken or config isn't loaded
 type Healthchecker struct {
-	port    string
-	client  *http.Client
-	reqBody []byte
+	url      string
+	client   *http.Client
+	configer *Configurer
+	rra      *ResourceRequestsAdmission
+	req      *v1beta1.AdmissionRequest
+	reqBody  []byte
+}
+
+// healthcheckVerdict is the expected response to healthcheck request
+type healthcheckVerdict struct {
+	allowed bool
+	// warned requires warnings, violations are returned as warnings in warn mode
+	warned bool
 }
 
-var req = v1beta1.AdmissionReview{
-	TypeMeta: v1.TypeMeta{
-		Kind: "AdmissionReview",
-	},
-	Request: &v1beta1.AdmissionRequest{
-		UID: "e911857d-c318-11e8-bbad-025000000001",
-		Kind: v1.GroupVersionKind{
-			Kind: "Pod",
+func healthcheckReview(namespace string) v1beta1.AdmissionReview {
+	dryRun := true
+	return v1beta1.AdmissionReview{
+		TypeMeta: v1.TypeMeta{
+			Kind: "AdmissionReview",
 		},
-		Operation: "CREATE",
-		Object: runtime.RawExtension{
-			Raw: []byte(`{"metadata": {
-        						"name": "test",
-        						"uid": "e911857d-c318-11e8-bbad-025000000001",
-						        "creationTimestamp": "2018-09-28T12:20:39Z"
-      						}}`),
+		Request: &v1beta1.AdmissionRequest{
+			UID: healthcheckUID,
+			Kind: v1.GroupVersionKind{
+				Kind: "Pod",
+			},
+			Namespace: namespace,
+			Operation: "CREATE",
+			DryRun:    &dryRun,
+			Object: runtime.RawExtension{
+				Raw: []byte(fmt.Sprintf(`{"metadata": {
+						"name": "resource-requests-admission-controller-healthcheck",
+						"namespace": %q,
+						"creationTimestamp": "2018-09-28T12:20:39Z"
+					},
+					"spec": {"containers": [{"name": "healthcheck", "image": "healthcheck"}]}}`, namespace)),
+			},
 		},
-	},
+	}
+}
+
+// healthcheckURL returns URL of admission server bound to addr. Unspecified hosts, which bind all interfaces,
+// are reached through loopback of the same IP version, other hosts are used as is, so that server bound
+// to a specific interface is reachable.
+func healthcheckURL(addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to parse address %s", addr)
+	}
+
+	switch host {
+	case "", "0.0.0.0":
+		host = "127.0.0.1"
+	case "::":
+		host = "::1"
+	}
+
+	return "https://" + net.JoinHostPort(host, port), nil
 }
 
-// NewHealthChecker creates New Healthchecker
-func NewHealthChecker(port string) (*Healthchecker, error) {
+// NewHealthChecker creates New Healthchecker of admission server bound to addr, healthcheck pod is admitted in the namespace
+func NewHealthChecker(addr, namespace string, configer *Configurer, rra *ResourceRequestsAdmission) (*Healthchecker, error) {
+	url, err := healthcheckURL(addr)
+	if err != nil {
+		return nil, err
+	}
+
 	defaultTransport := http.DefaultTransport.(*http.Transport)
 
 	// Create new Transport that ignores self-signed SSL
@@ -59,21 +111,65 @@ func NewHealthChecker(port string) (*Healthchecker, error) {
 		Transport: transport,
 		Timeout:   time.Second * 10,
 	}
-	reqBody, err := json.Marshal(req)
+	review := healthcheckReview(namespace)
+	reqBody, err := json.Marshal(review)
 	if err != nil {
 		return nil, err
 	}
 
 	return &Healthchecker{
-		port:    port,
-		client:  client,
-		reqBody: reqBody,
+		url:      url,
+		client:   client,
+		configer: configer,
+		rra:      rra,
+		req:      review.Request,
+		reqBody:  reqBody,
 	}, nil
 }
 
-// ServeHTTP serves HTTP request
+// healthcheckVerdict returns verdict expected for healthcheck request: allowed if pod is exempt from limits or during warmup,
+// allowed with warnings in warn mode or if missing requests are warnings by severity, otherwise denied
+func (rra *ResourceRequestsAdmission) healthcheckVerdict(req *v1beta1.AdmissionRequest) (healthcheckVerdict, error) {
+	if rra.opts.Warmup > 0 && rra.now().Sub(rra.started) < rra.opts.Warmup {
+		return healthcheckVerdict{allowed: true}, nil
+	}
+
+	w, err := rra.decodeWorkload(req)
+	if err != nil {
+		return healthcheckVerdict{}, err
+	}
+
+	limit, exempt, _ := rra.resolveLimit(req, w)
+
+	switch {
+	case exempt:
+		return healthcheckVerdict{allowed: true}, nil
+	case limit.Mode == modeWarn, limit.Severity[corev1.ResourceCPU] == severityWarn && limit.Severity[corev1.ResourceMemory] == severityWarn:
+		return healthcheckVerdict{allowed: true, warned: true}, nil
+	default:
+		return healthcheckVerdict{}, nil
+	}
+}
+
+// ServeLivez serves liveness check, it only shows that ops server is up, since admission round trip may time out under load
+func ServeLivez(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+// ServeHTTP serves readiness check, it fails only if no config was loaded, since failed reloads keep serving
+// previous config and failing readiness of all replicas at once would take the webhook down
 func (hc *Healthchecker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	resp, err := hc.client.Post("https://localhost:"+hc.port, "application/json", bytes.NewReader(hc.reqBody))
+	if !hc.configer.Loaded() {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, err := w.Write([]byte("error config is not loaded"))
+		if err != nil {
+			log.WithError(err).Warn("could not write error response")
+		}
+
+		return
+	}
+
+	resp, err := hc.client.Post(hc.url, "application/json", bytes.NewReader(hc.reqBody))
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		_, err := w.Write([]byte(err.Error()))
@@ -84,7 +180,7 @@ func (hc *Healthchecker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	defer resp.Body.Close()
 
-	response, err := ioutil.ReadAll(resp.Body)
+	response, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxHealthcheckResponseBytes))
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		_, err := w.Write([]byte(err.Error()))
@@ -107,9 +203,32 @@ func (hc *Healthchecker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !review.Response.Allowed {
+	verdict, err := hc.rra.healthcheckVerdict(hc.req)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, err := w.Write([]byte(err.Error()))
+		if err != nil {
+			log.WithError(err).Warn("could not write error response")
+		}
+
+		return
+	}
+
+	var msg string
+	switch {
+	case review.Response == nil:
+		msg = "error response is empty"
+	case verdict.allowed && !review.Response.Allowed:
+		msg = "error request not allowed"
+	case !verdict.allowed && review.Response.Allowed:
+		msg = "error request allowed, but must be denied"
+	case verdict.warned && len(review.Response.Warnings) == 0:
+		msg = "error request allowed without warnings"
+	}
+
+	if msg != "" {
 		w.WriteHeader(http.StatusInternalServerError)
-		_, err := w.Write([]byte("error request not allowed"))
+		_, err := w.Write([]byte(msg))
 		if err != nil {
 			log.WithError(err).Warn("could not write error response")
 		}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// allowAll is a broken admission controller, which allows everything
+type allowAll struct{}
+
+func (allowAll) HandleAdmission(ctx context.Context, req *v1beta1.AdmissionRequest) (*v1beta1.AdmissionResponse, error) {
+	return &v1beta1.AdmissionResponse{UID: req.UID, Allowed: true}, nil
+}
+
+func TestHealthchecker(t *testing.T) {
+	cpu := resource.MustParse("1")
+	defer configLoadedGauge.Set(testutil.ToFloat64(configLoadedGauge))
+
+	tests := []struct {
+		name       string
+		conf       *MockConfiger
+		opts       Options
+		controller func(rra *ResourceRequestsAdmission) AdmissionController
+		notLoaded  bool
+		stale      bool
+		code       int
+		body       string
+	}{
+		{
+			name: "enforce mode denies",
+			conf: &MockConfiger{cpu: &cpu},
+			code: http.StatusOK,
+		},
+		{
+			name: "warn mode warns",
+			conf: &MockConfiger{cpu: &cpu, mode: modeWarn},
+			code: http.StatusOK,
+		},
+		{
+			name: "warn severity warns",
+			conf: &MockConfiger{cpu: &cpu, severity: map[corev1.ResourceName]string{corev1.ResourceCPU: severityWarn, corev1.ResourceMemory: severityWarn}},
+			code: http.StatusOK,
+		},
+		{
+			name: "unlimited allows",
+			conf: &MockConfiger{unlimited: true},
+			code: http.StatusOK,
+		},
+		{
+			name: "exempt user allows",
+			conf: &MockConfiger{cpu: &cpu, exemptUsers: []*regexp.Regexp{regexp.MustCompile(`^(?:.*)$`)}},
+			code: http.StatusOK,
+		},
+		{
+			name: "warmup allows",
+			conf: &MockConfiger{cpu: &cpu},
+			opts: Options{Warmup: time.Hour},
+			code: http.StatusOK,
+		},
+		{
+			name:       "broken validation in enforce mode",
+			conf:       &MockConfiger{cpu: &cpu},
+			controller: func(*ResourceRequestsAdmission) AdmissionController { return allowAll{} },
+			code:       http.StatusInternalServerError,
+			body:       "error request allowed, but must be denied",
+		},
+		{
+			name:       "broken validation in warn mode",
+			conf:       &MockConfiger{cpu: &cpu, mode: modeWarn},
+			controller: func(*ResourceRequestsAdmission) AdmissionController { return allowAll{} },
+			code:       http.StatusInternalServerError,
+			body:       "error request allowed without warnings",
+		},
+		{
+			// previous config is served, so failed reload doesn't take all replicas out of service at once
+			name:  "config reload failed",
+			conf:  &MockConfiger{cpu: &cpu},
+			stale: true,
+			code:  http.StatusOK,
+		},
+		{
+			name:      "config not loaded",
+			conf:      &MockConfiger{cpu: &cpu},
+			notLoaded: true,
+			code:      http.StatusInternalServerError,
+			body:      "error config is not loaded",
+		},
+	}
+
+	for _, tt := range tests {
+		configLoadedGauge.Set(1)
+		if tt.stale {
+			configLoadedGauge.Set(0)
+		}
+		configer := &Configurer{loaded: !tt.notLoaded}
+
+		rra := New(tt.conf, tt.opts)
+
+		var controller AdmissionController = rra
+		if tt.controller != nil {
+			controller = tt.controller(rra)
+		}
+
+		server := httptest.NewTLSServer(&AdmissionControllerServer{
+			AdmissionController: controller,
+			Decoder:             codecs.UniversalDeserializer(),
+		})
+
+		hc, err := NewHealthChecker(server.Listener.Addr().String(), "default", configer, rra)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		denied := testutil.ToFloat64(admissionCounter.WithLabelValues("false", modeEnforce, "true"))
+
+		w := httptest.NewRecorder()
+		hc.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		server.Close()
+
+		body, err := ioutil.ReadAll(w.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.code, w.Code, tt.name)
+		assert.Equal(t, tt.body, string(body), tt.name)
+		// healthchecks are not counted
+		assert.Equal(t, denied, testutil.ToFloat64(admissionCounter.WithLabelValues("false", modeEnforce, "true")), tt.name)
+	}
+}
+
+func TestHealthcheckURL(t *testing.T) {
+	tests := []struct {
+		addr     string
+		expected string
+	}{
+		{addr: "0.0.0.0:8443", expected: "https://127.0.0.1:8443"},
+		{addr: ":8443", expected: "https://127.0.0.1:8443"},
+		{addr: "10.0.0.5:8443", expected: "https://10.0.0.5:8443"},
+		{addr: "[::]:8443", expected: "https://[::1]:8443"},
+		{addr: "[fd00::5]:8443", expected: "https://[fd00::5]:8443"},
+		{addr: "[::1]:8443", expected: "https://[::1]:8443"},
+		{addr: "webhook.kube-system.svc:8443", expected: "https://webhook.kube-system.svc:8443"},
+	}
+
+	for _, tt := range tests {
+		url, err := healthcheckURL(tt.addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.expected, url, tt.addr)
+	}
+
+	_, err := healthcheckURL("fd00::5:8443")
+	assert.Error(t, err)
+}
+
+func TestHealthcheckerIPv6(t *testing.T) {
+	l, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skip("IPv6 loopback is not available: ", err)
+	}
+
+	cpu := resource.MustParse("1")
+	rra := New(&MockConfiger{cpu: &cpu}, Options{})
+	server := httptest.NewUnstartedServer(&AdmissionControllerServer{
+		AdmissionController: rra,
+		Decoder:             codecs.UniversalDeserializer(),
+	})
+	server.Listener = l
+	server.StartTLS()
+	defer server.Close()
+
+	hc, err := NewHealthChecker(server.Listener.Addr().String(), "default", &Configurer{loaded: true}, rra)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "https://"+server.Listener.Addr().String(), hc.url)
+
+	w := httptest.NewRecorder()
+	hc.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
+
+func TestServeLivez(t *testing.T) {
+	defer configLoadedGauge.Set(testutil.ToFloat64(configLoadedGauge))
+
+	// liveness doesn't depend on config, so that pods with stale config aren't restarted
+	for _, loaded := range []float64{0, 1} {
+		configLoadedGauge.Set(loaded)
+
+		w := httptest.NewRecorder()
+		ServeLivez(w, httptest.NewRequest(http.MethodGet, "/livez", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+}
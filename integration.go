@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+var integrationCallCounter = promauto.NewCounterVec(prometheus.CounterOpts{Name: "integration_calls_total"}, []string{"integration", "result"})
+
+var errCircuitOpen = errors.New("circuit breaker is open")
+
+// IntegrationConfig configures timeouts, retries and circuit breaking of calls to an external dependency
+type IntegrationConfig struct {
+	// Timeout of a single attempt
+	Timeout time.Duration
+	// Retries after the first failed attempt
+	Retries int
+	// Budget limits total time of all attempts, so that a slow dependency doesn't block admission
+	Budget time.Duration
+	// BreakerFailures is the number of consecutive failed calls which opens the circuit, 0 disables circuit breaking
+	BreakerFailures int
+	// BreakerCooldown is how long calls are rejected once the circuit is open
+	BreakerCooldown time.Duration
+}
+
+// DefaultIntegrationConfig returns IntegrationConfig with sane defaults
+func DefaultIntegrationConfig() IntegrationConfig {
+	return IntegrationConfig{
+		Timeout:         time.Second,
+		Retries:         1,
+		Budget:          2 * time.Second,
+		BreakerFailures: 5,
+		BreakerCooldown: 30 * time.Second,
+	}
+}
+
+// Integration calls an external dependency according to IntegrationConfig
+type Integration struct {
+	name string
+	conf IntegrationConfig
+	now  func() time.Time
+
+	failures  int
+	openUntil time.Time
+	m         sync.Mutex
+}
+
+// NewIntegration creates new Integration
+func NewIntegration(name string, conf IntegrationConfig) *Integration {
+	for _, result := range []string{"ok", "error", "timeout", "circuit_open"} {
+		integrationCallCounter.WithLabelValues(name, result)
+	}
+
+	return &Integration{
+		name: name,
+		conf: conf,
+		now:  time.Now,
+	}
+}
+
+// Do calls fn, retrying failed attempts until budget is spent. Attempts that don't return in time are abandoned,
+// even if fn ignores its context. NotFound errors are returned without retries and don't count as failures.
+func (i *Integration) Do(fn func(ctx context.Context) error) error {
+	if i.open() {
+		integrationCallCounter.WithLabelValues(i.name, "circuit_open").Inc()
+		return errors.Wrap(errCircuitOpen, i.name)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), i.conf.Budget)
+	defer cancel()
+
+	var err error
+	for attempt := 0; attempt <= i.conf.Retries; attempt++ {
+		err = i.attempt(ctx, fn)
+		if err == nil || apierrors.IsNotFound(err) || ctx.Err() != nil {
+			break
+		}
+	}
+
+	switch {
+	case err == nil:
+		integrationCallCounter.WithLabelValues(i.name, "ok").Inc()
+	case ctx.Err() != nil:
+		integrationCallCounter.WithLabelValues(i.name, "timeout").Inc()
+	default:
+		integrationCallCounter.WithLabelValues(i.name, "error").Inc()
+	}
+
+	i.record(err == nil || apierrors.IsNotFound(err))
+
+	return err
+}
+
+func (i *Integration) attempt(ctx context.Context, fn func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, i.conf.Timeout)
+	defer cancel()
+
+	result := make(chan error, 1)
+	go func() {
+		result <- fn(ctx)
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return errors.Wrapf(ctx.Err(), "%s call abandoned", i.name)
+	}
+}
+
+func (i *Integration) open() bool {
+	i.m.Lock()
+	defer i.m.Unlock()
+
+	return i.now().Before(i.openUntil)
+}
+
+func (i *Integration) record(success bool) {
+	i.m.Lock()
+	defer i.m.Unlock()
+
+	if success {
+		i.failures = 0
+		return
+	}
+
+	i.failures++
+	if i.conf.BreakerFailures > 0 && i.failures >= i.conf.BreakerFailures {
+		i.failures = 0
+		i.openUntil = i.now().Add(i.conf.BreakerCooldown)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestIntegrationAbandonsSlowDependency(t *testing.T) {
+	i := NewIntegration("test_slow", IntegrationConfig{
+		Timeout: 20 * time.Millisecond,
+		Retries: 10,
+		Budget:  50 * time.Millisecond,
+	})
+
+	release := make(chan struct{})
+	defer close(release)
+
+	var attempts int32
+	start := time.Now()
+	err := i.Do(func(ctx context.Context) error {
+		atomic.AddInt32(&attempts, 1)
+		// ignores ctx, so it must be abandoned
+		<-release
+		return nil
+	})
+
+	assert.Error(t, err)
+	assert.True(t, time.Since(start) < 500*time.Millisecond, "slow dependency wasn't abandoned within budget")
+	assert.True(t, atomic.LoadInt32(&attempts) <= 3, "retries exceeded budget")
+}
+
+func TestIntegrationRetries(t *testing.T) {
+	i := NewIntegration("test_retries", DefaultIntegrationConfig())
+
+	attempts := 0
+	err := i.Do(func(ctx context.Context) error {
+		attempts++
+		if attempts == 1 {
+			return errors.New("connection reset")
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+
+	// not found isn't retried
+	attempts = 0
+	err = i.Do(func(ctx context.Context) error {
+		attempts++
+		return apierrors.NewNotFound(schema.GroupResource{Resource: "namespaces"}, "missing")
+	})
+	assert.True(t, apierrors.IsNotFound(err))
+	assert.Equal(t, 1, attempts)
+}
+
+func TestIntegrationCircuitBreaker(t *testing.T) {
+	i := NewIntegration("test_breaker", IntegrationConfig{
+		Timeout:         time.Second,
+		Budget:          time.Second,
+		BreakerFailures: 2,
+		BreakerCooldown: time.Minute,
+	})
+	now := time.Unix(0, 0)
+	i.now = func() time.Time { return now }
+
+	calls := 0
+	fail := func(ctx context.Context) error {
+		calls++
+		return errors.New("unavailable")
+	}
+
+	assert.Error(t, i.Do(fail))
+	assert.Error(t, i.Do(fail))
+	assert.Equal(t, 2, calls)
+
+	// circuit is open, dependency isn't called
+	err := i.Do(fail)
+	assert.Equal(t, errCircuitOpen, errors.Cause(err))
+	assert.Equal(t, 2, calls)
+
+	// after cooldown dependency is called again
+	now = now.Add(2 * time.Minute)
+	assert.NoError(t, i.Do(func(ctx context.Context) error { return nil }))
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// handledKinds are decoded by handlePVC and decodeWorkload, other kinds are handled only if they are custom resources
+var handledKinds = map[string]bool{
+	podKind:                   true,
+	deploymentKind:            true,
+	statefulsetKind:           true,
+	daemonsetKind:             true,
+	replicationControllerKind: true,
+	jobKind:                   true,
+	cronJobKind:               true,
+	pvcKind:                   true,
+}
+
+// handlesKind returns true if objects of the kind are validated
+func (rra *ResourceRequestsAdmission) handlesKind(kind metav1.GroupVersionKind) bool {
+	if handledKinds[kind.Kind] {
+		return true
+	}
+
+	for _, cr := range rra.opts.CustomResources {
+		if cr.matches(kind) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// handleUnhandledKind applies defaultAction of the namespace, or Options.DefaultAction if it's not configured,
+// to objects, whose kind isn't handled, e.g. a CRD-backed workload. Unlimited namespaces and exempt users are allowed.
+func (rra *ResourceRequestsAdmission) handleUnhandledKind(req *v1beta1.AdmissionRequest) (*v1beta1.AdmissionResponse, error) {
+	resp := &v1beta1.AdmissionResponse{
+		UID:     req.UID,
+		Allowed: true,
+	}
+
+	limit := rra.conf.GetPodLimit(NameNamespace{
+		Name:      req.Name,
+		Namespace: req.Namespace,
+	}, req.UserInfo.Groups...)
+
+	action := limit.DefaultAction
+	if action == "" {
+		action = rra.opts.DefaultAction
+	}
+	if action != defaultActionDeny || limit.Unlimited {
+		return resp, nil
+	}
+
+	if annotations, ok := limit.exemptUser(req.UserInfo); ok {
+		resp.AuditAnnotations = annotations
+		return resp, nil
+	}
+
+	groupVersion := req.Kind.Version
+	if req.Kind.Group != "" {
+		groupVersion = req.Kind.Group + "/" + req.Kind.Version
+	}
+
+	denyResp := &v1beta1.AdmissionResponse{
+		UID:     req.UID,
+		Allowed: false,
+		Result: &metav1.Status{
+			Message: fmt.Sprintf("error kind %s of %s is not handled, default action is %s", req.Kind.Kind, groupVersion, defaultActionDeny),
+		},
+		AuditAnnotations: reasonAnnotations(reasonUnhandledKind),
+	}
+	if limit.Mode == modeWarn {
+		return warnOnly(denyResp), nil
+	}
+
+	rra.logDenial(req, "denying request for %s name: %s, namespace: %s, userInfo: %v", strings.ToLower(req.Kind.Kind), req.Name, req.Namespace, req.UserInfo)
+	return denyResp, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/admission/v1beta1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestHandleAdmissionUnhandledKind(t *testing.T) {
+	rolloutKind := v1.GroupVersionKind{Group: "argoproj.io", Version: "v1alpha1", Kind: "Rollout"}
+	message := "error kind Rollout of argoproj.io/v1alpha1 is not handled, default action is deny"
+
+	tests := []struct {
+		name    string
+		kind    v1.GroupVersionKind
+		conf    *MockConfiger
+		opts    Options
+		allowed bool
+		warning bool
+	}{
+		{name: "allowed by default", kind: rolloutKind, conf: &MockConfiger{}, allowed: true},
+		{name: "default action allow", kind: rolloutKind, conf: &MockConfiger{}, opts: Options{DefaultAction: defaultActionAllow}, allowed: true},
+		{name: "default action deny", kind: rolloutKind, conf: &MockConfiger{}, opts: Options{DefaultAction: defaultActionDeny}},
+		{name: "config deny overrides flag", kind: rolloutKind, conf: &MockConfiger{action: defaultActionDeny}, opts: Options{DefaultAction: defaultActionAllow}},
+		{name: "config allow overrides flag", kind: rolloutKind, conf: &MockConfiger{action: defaultActionAllow}, opts: Options{DefaultAction: defaultActionDeny}, allowed: true},
+		{name: "unlimited namespace", kind: rolloutKind, conf: &MockConfiger{unlimited: true}, opts: Options{DefaultAction: defaultActionDeny}, allowed: true},
+		{name: "warn mode", kind: rolloutKind, conf: &MockConfiger{mode: modeWarn}, opts: Options{DefaultAction: defaultActionDeny}, allowed: true, warning: true},
+		{
+			name: "custom resource is handled",
+			kind: rolloutKind,
+			conf: &MockConfiger{},
+			opts: Options{
+				DefaultAction:   defaultActionDeny,
+				CustomResources: []CustomResource{{Group: "argoproj.io", Kind: "Rollout", ContainerPaths: []string{"{.spec.template.spec.containers[*]}"}}},
+			},
+			allowed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{conf: tt.conf, opts: tt.opts}
+
+		resp, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+			UID:       "e911857d-c318-11e8-bbad-025000000001",
+			Kind:      tt.kind,
+			Name:      "web",
+			Namespace: "default",
+			Operation: v1beta1.Create,
+			Object:    runtime.RawExtension{Raw: []byte(`{"metadata": {"name": "web"}, "spec": {}}`)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.allowed, resp.Allowed, tt.name)
+		if !tt.allowed {
+			assert.Equal(t, message, resp.Result.Message, tt.name)
+			assert.Equal(t, string(reasonUnhandledKind), resp.AuditAnnotations[denialReasonAnnotation], tt.name)
+		}
+		if tt.warning {
+			assert.Equal(t, []string{message}, resp.Warnings, tt.name)
+		}
+	}
+}
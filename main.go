@@ -1,9 +1,11 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
-	"net"
+	"io"
+	"io/ioutil"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
@@ -18,23 +20,86 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/prometheus/common/version"
 	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
 )
 
+const handlerTimeout = 20 * time.Second
+
 func main() {
 	app := kingpin.New("resource-requests-admission-controller", "Validates Statefulset,Deployment,Daemoneset,Pod resource requests and limits")
 
 	app.Version(version.Print("resource-requests-admission-controller"))
 	app.HelpFlag.Short('h')
 
-	certFile := app.Flag("tls-cert-file", "").Envar("TLS_CERT_FILE").Required().String()
-	keyFile := app.Flag("tls-private-key-file", "").Envar("TLS_KEY_FILE").Required().String()
+	certFile := app.Flag("tls-cert-file", "").Envar("TLS_CERT_FILE").String()
+	keyFile := app.Flag("tls-private-key-file", "").Envar("TLS_KEY_FILE").String()
+	generateSelfSigned := app.Flag("generate-self-signed", "Serve with in-memory self-signed certificate for localhost, if cert files are not provided. Requires --dev-mode.").Envar("GENERATE_SELF_SIGNED").Bool()
+	devMode := app.Flag("dev-mode", "Enable features meant only for local development and e2e tests, never use in production.").Envar("DEV_MODE").Bool()
 	configFile := app.Flag("config-file", "File path to the config").Envar("CONFIG_FILE").Required().String()
 	refreshInterval := app.Flag("refresh-interval", "Refresh interval in if no file change happens.").Envar("REFRESH_INTERVAL").Default("5m").Duration()
+	strictConfig := app.Flag("strict-config", "Reject config files with unknown keys, e.g. misspelled limits.").Envar("STRICT_CONFIG").Bool()
+	configuredOnly := app.Flag("configured-namespaces-only", "Enforce limits only in namespaces which are configured, other namespaces are unlimited instead of getting top level limits.").Envar("CONFIGURED_NAMESPACES_ONLY").Bool()
+	consistencyCheckInterval := app.Flag("config-consistency-check-interval", "Periodically re-parse config file and report drift from configuration in use in config_drift metric, 0 disables it.").Envar("CONFIG_CONSISTENCY_CHECK_INTERVAL").Default("0s").Duration()
+	startupTimeout := app.Flag("startup-timeout", "Exit with an error, if config or certificates don't load within this period, e.g. due to a hanging mount, 0 disables it.").Envar("STARTUP_TIMEOUT").Default("1m").Duration()
+	reloadDebounce := app.Flag("reload-debounce", "Wait for more config file changes during this period before reloading.").Envar("RELOAD_DEBOUNCE").Default("500ms").Duration()
 	logLevel := app.Flag("log.level", "Log level.").Envar("LOG_LEVEL").
 		Default("info").Enum("error", "warn", "info", "debug")
 	logFormat := app.Flag("log.format", "Log format.").Envar("LOG_FORMAT").
 		Default("text").Enum("text", "json")
 
+	namespaceLookup := app.Flag("namespace-lookup", "Fetch namespace labels and annotations on demand, required by namespaceSelectors.").Envar("NAMESPACE_LOOKUP").Bool()
+	namespaceCacheTTL := app.Flag("namespace-cache-ttl", "How long fetched namespace labels and annotations are cached.").Envar("NAMESPACE_CACHE_TTL").Default("1m").Duration()
+	namespaceLookupTimeout := app.Flag("namespace-lookup-timeout", "Timeout of a single namespace lookup.").Envar("NAMESPACE_LOOKUP_TIMEOUT").Default("1s").Duration()
+	namespaceLookupRetries := app.Flag("namespace-lookup-retries", "Retries of a failed namespace lookup.").Envar("NAMESPACE_LOOKUP_RETRIES").Default("1").Int()
+	integrationBudget := app.Flag("integration-budget", "Maximum time of all attempts of a single external call, after which the dependency is abandoned.").Envar("INTEGRATION_BUDGET").Default("2s").Duration()
+	breakerFailures := app.Flag("circuit-breaker-failures", "Consecutive failed external calls, after which calls to the dependency are rejected for cooldown, 0 disables it.").Envar("CIRCUIT_BREAKER_FAILURES").Default("5").Int()
+	breakerCooldown := app.Flag("circuit-breaker-cooldown", "How long calls to a failing dependency are rejected.").Envar("CIRCUIT_BREAKER_COOLDOWN").Default("30s").Duration()
+	kubeconfig := app.Flag("kubeconfig", "Path to kubeconfig, in-cluster config is used if empty.").Envar("KUBECONFIG").String()
+
+	validateCronJobJobs := app.Flag("validate-cronjob-jobs", "Validate Jobs created by CronJobs using CronJob limits, by default they are validated only on CronJob admission.").Envar("VALIDATE_CRONJOB_JOBS").Bool()
+	healthcheckNamespace := app.Flag("healthcheck-namespace", "Namespace of the pod without requests, which healthcheck sends to the controller. It must be denied in enforce mode, allowed with warnings in warn mode and allowed if unlimited.").Envar("HEALTHCHECK_NAMESPACE").Default("default").String()
+	grandfatherCutoff := app.Flag("grandfather-cutoff", "Skip enforcement on updates of workloads created before this RFC3339 date, e.g. 2024-01-01T00:00:00Z, so that limits apply only to new workloads. Empty disables it.").Envar("GRANDFATHER_CUTOFF").String()
+	warmup := app.Flag("warmup", "Allow all requests during this period after start, e.g. while caches warm up.").Envar("WARMUP").Default("0s").Duration()
+	maxQuotaPercent := app.Flag("max-quota-percent", "Deny workloads, which use more than this percentage of any namespace ResourceQuota hard limit, 0 disables it.").Envar("MAX_QUOTA_PERCENT").Default("0").Int()
+	quotaResync := app.Flag("quota-resync", "Resync period of ResourceQuota informer.").Envar("QUOTA_RESYNC").Default("10m").Duration()
+	maxClusterPercent := app.Flag("max-cluster-percent", "Deny workloads, whose CPU or memory limits of all replicas exceed this percentage of cluster allocatable summed across nodes, 0 disables it. Requires node list and watch RBAC.").Envar("MAX_CLUSTER_PERCENT").Default("0").Int()
+	nodeResync := app.Flag("node-resync", "Resync period of Node informer.").Envar("NODE_RESYNC").Default("10m").Duration()
+	scaleLookup := app.Flag("scale-lookup", "Fetch Deployments and StatefulSets scaled through scale subresource, so that max-quota-percent and max-cluster-percent are enforced on scale ups.").Envar("SCALE_LOOKUP").Bool()
+	scaleLookupTimeout := app.Flag("scale-lookup-timeout", "Timeout of a single scaled workload lookup.").Envar("SCALE_LOOKUP_TIMEOUT").Default("1s").Duration()
+	scaleLookupRetries := app.Flag("scale-lookup-retries", "Retries of a failed scaled workload lookup.").Envar("SCALE_LOOKUP_RETRIES").Default("1").Int()
+	containerVerdicts := app.Flag("container-verdicts", "Add verdict of every container (pass, warn or fail with denial reason) to container-verdicts audit annotation and X-Container-Verdicts response header, e.g. for e2e tests.").Envar("CONTAINER_VERDICTS").Bool()
+	denialLogBurst := app.Flag("denial-log-burst", "Limit denial logs to this burst per namespace, so that a controller retrying a denied workload doesn't flood the logs, 0 disables it. Metrics count every denial.").Envar("DENIAL_LOG_BURST").Default("0").Int()
+	denialLogRate := app.Flag("denial-log-rate", "Refill rate of denial-log-burst in logs per second per namespace.").Envar("DENIAL_LOG_RATE").Default("0.1").Float64()
+	cpuUnitsFlag := app.Flag("cpu-units", "Display units of CPU in denial messages and warnings: auto (as written), cores or millicores.").Envar("CPU_UNITS").
+		Default(unitsAuto).Enum(unitsAuto, cpuUnitsCores, cpuUnitsMillicores)
+	memoryUnitsFlag := app.Flag("memory-units", "Display units of memory and storage in denial messages and warnings: auto (as written), binary (Mi, Gi) or decimal (M, G).").Envar("MEMORY_UNITS").
+		Default(unitsAuto).Enum(unitsAuto, memoryUnitsBinary, memoryUnitsDecimal)
+	defaultAction := app.Flag("default-action", "Allow or deny objects, whose kind isn't handled, e.g. CRD-backed workloads matched by webhook rules, defaultAction config overrides it.").Envar("DEFAULT_ACTION").
+		Default(defaultActionAllow).Enum(defaultActionAllow, defaultActionDeny)
+	decisionLogFile := app.Flag("decision-log", "File, which every admission decision is appended to, separately from operational logs, - writes to stdout, empty disables it.").Envar("DECISION_LOG").String()
+	decisionLogFormat := app.Flag("decision-log-format", "Format of decision log.").Envar("DECISION_LOG_FORMAT").
+		Default(decisionLogJSONL).Enum(decisionLogJSONL, decisionLogCEF, decisionLogLogfmt)
+	auditLogFile := app.Flag("audit-log", "Shorthand of --decision-log with jsonl format, a JSON line per decision, - writes to stdout.").Envar("AUDIT_LOG").String()
+	logDenied := app.Flag("log-denied", "Log pod spec of denied workloads, with env values and secret references redacted, at debug level.").Envar("LOG_DENIED").Bool()
+	claimLookup := app.Flag("resource-claim-lookup", "Fetch ResourceClaims and ResourceClaimTemplates referenced by pods, required by allowedDeviceClasses.").Envar("RESOURCE_CLAIM_LOOKUP").Bool()
+	claimLookupTimeout := app.Flag("resource-claim-lookup-timeout", "Timeout of a single resource claim lookup.").Envar("RESOURCE_CLAIM_LOOKUP_TIMEOUT").Default("1s").Duration()
+	claimLookupRetries := app.Flag("resource-claim-lookup-retries", "Retries of a failed resource claim lookup.").Envar("RESOURCE_CLAIM_LOOKUP_RETRIES").Default("1").Int()
+	requireMemoryLimit := app.Flag("require-memory-limit", "Deny containers without memory limit, unless namespace is unlimited.").Envar("REQUIRE_MEMORY_LIMIT").Bool()
+	suggestPatches := app.Flag("suggest-patches", "Add JSONPatch, which lowers resources exceeding the limits, to response warnings.").Envar("SUGGEST_PATCHES").Bool()
+	rejectNegativeQuantities := app.Flag("reject-negative-quantities", "Deny containers with negative resource requests or limits.").Envar("REJECT_NEGATIVE_QUANTITIES").Bool()
+	requireEphemeralStorageLimit := app.Flag("require-ephemeral-storage-limit", "Deny containers without ephemeral-storage limit in pods with emptyDir volumes, unless namespace is unlimited.").Envar("REQUIRE_EPHEMERAL_STORAGE_LIMIT").Bool()
+	approvalSecretFile := app.Flag("approval-secret-file", "File with HMAC secret verifying approval tokens, which raise workload limits, approvals are disabled if empty.").Envar("APPROVAL_SECRET_FILE").String()
+	namespaceDenialMetric := app.Flag("namespace-denial-metric", "Count denials per namespace in admission_namespace_denials_total metric, which has a namespace label.").Envar("NAMESPACE_DENIAL_METRIC").Bool()
+	warnTemplateResourceChanges := app.Flag("warn-template-resource-changes", "Warn on workload updates, which change container resources and so restart pods.").Envar("WARN_TEMPLATE_RESOURCE_CHANGES").Bool()
+	latencyBudget := app.Flag("latency-budget", "Respond early, if admission doesn't finish within this budget or API server's webhook timeout, 0 disables it.").Envar("LATENCY_BUDGET").Default("0s").Duration()
+	latencyBudgetAllow := app.Flag("latency-budget-allow", "Allow requests exceeding latency budget, by default they are denied.").Envar("LATENCY_BUDGET_ALLOW").Bool()
+	denialReasonsFile := app.Flag("denial-reasons-file", "YAML file mapping denial reason codes to descriptions served on /reasons, which override the defaults.").Envar("DENIAL_REASONS_FILE").String()
+	customResourcesFile := app.Flag("custom-resources-file", "YAML file listing custom resources with JSONPaths of their containers, which are validated like pods.").Envar("CUSTOM_RESOURCES_FILE").String()
+	opsTokenFile := app.Flag("ops-token-file", "File with bearer token required by /config and /reload endpoints on ops server, which are open if empty.").Envar("OPS_TOKEN_FILE").String()
+	maxRequestBytes := app.Flag("max-request-bytes", "Respond with 413 to admission requests, whose body is larger, 0 disables it.").Envar("MAX_REQUEST_BYTES").Default("1048576").Int64()
+	forbidNonReview := app.Flag("forbid-non-review", "Respond with generic 403 instead of decode error to requests which are not AdmissionReview.").Envar("FORBID_NON_REVIEW").Bool()
+
 	addr := app.Flag("addr", "Server address which will receive AdmissionReview requests.").Envar("ADDR").Default("0.0.0.0:8443").String()
 	opsAddr := app.Flag("ops-addr", "Server address which will serve prometheus metrics.").Envar("PROM_ADDR").Default("0.0.0.0:8090").String()
 
@@ -62,29 +127,236 @@ func main() {
 	}
 	log.SetOutput(os.Stdout)
 
-	configer, err := NewConfigurer(*configFile, *refreshInterval)
+	startupCtx := context.Background()
+	if *startupTimeout > 0 {
+		var cancel context.CancelFunc
+		startupCtx, cancel = context.WithTimeout(startupCtx, *startupTimeout)
+		defer cancel()
+	}
+
+	var configer *Configurer
+	err := runStartupStep(startupCtx, "config load", func() error {
+		var err error
+		configer, err = NewConfigurer(*configFile, *refreshInterval, *strictConfig)
+		return err
+	})
 	if err != nil {
 		log.WithError(err).Fatalf("unable to load config file: %s", *configFile)
 	}
 	defer configer.Close()
+	configer.SetReloadDebounce(*reloadDebounce)
+	configer.SetConfiguredOnly(*configuredOnly)
 
-	rra := New(configer)
+	if *consistencyCheckInterval > 0 {
+		stopConsistencyCheck := make(chan struct{})
+		defer close(stopConsistencyCheck)
+		go configer.RunConsistencyCheck(*consistencyCheckInterval, stopConsistencyCheck)
+	}
 
-	cert, err := tls.LoadX509KeyPair(*certFile, *keyFile)
-	if err != nil {
-		log.WithError(err).Fatal("unable to load certificates")
+	if *integrationBudget >= handlerTimeout {
+		log.Fatalf("integration budget %s must be lower than handler timeout %s", *integrationBudget, handlerTimeout)
 	}
-	_, port, err := net.SplitHostPort(*addr)
-	if err != nil {
-		log.WithError(err).Fatal("unable to parse address")
+	integrationConfig := func(timeout time.Duration, retries int) IntegrationConfig {
+		return IntegrationConfig{
+			Timeout:         timeout,
+			Retries:         retries,
+			Budget:          *integrationBudget,
+			BreakerFailures: *breakerFailures,
+			BreakerCooldown: *breakerCooldown,
+		}
+	}
+
+	var client kubernetes.Interface
+	if *namespaceLookup || *maxQuotaPercent > 0 || *maxClusterPercent > 0 || *claimLookup || *scaleLookup {
+		client, err = newKubeClient(*kubeconfig)
+		if err != nil {
+			log.WithError(err).Fatal("unable to create kubernetes client")
+		}
+	}
+
+	if *namespaceLookup {
+		configer.SetNamespaceLookup(NewNamespaceLookup(client, *namespaceCacheTTL, NewIntegration("namespace_lookup", integrationConfig(*namespaceLookupTimeout, *namespaceLookupRetries))))
+	}
+
+	var quotas QuotaGetter
+	if *maxQuotaPercent > 0 {
+		quotaLister := NewQuotaLister(client, *quotaResync)
+
+		stop := make(chan struct{})
+		defer close(stop)
+		if err := quotaLister.Start(stop); err != nil {
+			log.WithError(err).Fatal("unable to start resourcequota informer")
+		}
+
+		quotas = quotaLister
+	}
+
+	var capacity CapacityGetter
+	if *maxClusterPercent > 0 {
+		nodeLister := NewNodeLister(client, *nodeResync)
+
+		stop := make(chan struct{})
+		defer close(stop)
+		if err := nodeLister.Start(stop); err != nil {
+			log.WithError(err).Fatal("unable to start node informer")
+		}
+
+		capacity = nodeLister
+	}
+
+	var claims DeviceClassGetter
+	if *claimLookup {
+		claims = NewClaimLookup(client, NewIntegration("resource_claim_lookup", integrationConfig(*claimLookupTimeout, *claimLookupRetries)))
+	}
+
+	var scales PodTemplateGetter
+	if *scaleLookup {
+		scales = NewScaleLookup(client, NewIntegration("scale_lookup", integrationConfig(*scaleLookupTimeout, *scaleLookupRetries)))
+	}
+
+	if err := SetQuantityUnits(*cpuUnitsFlag, *memoryUnitsFlag); err != nil {
+		log.WithError(err).Fatal("unable to set quantity units")
+	}
+
+	if *denialReasonsFile != "" {
+		if err := LoadDenialReasons(*denialReasonsFile); err != nil {
+			log.WithError(err).Fatalf("unable to load denial reasons file: %s", *denialReasonsFile)
+		}
+	}
+
+	var customResources []CustomResource
+	if *customResourcesFile != "" {
+		customResources, err = LoadCustomResources(*customResourcesFile)
+		if err != nil {
+			log.WithError(err).Fatalf("unable to load custom resources file: %s", *customResourcesFile)
+		}
 	}
 
-	hc, err := NewHealthChecker(port)
+	var approvalSecret []byte
+	if *approvalSecretFile != "" {
+		approvalSecret, err = ioutil.ReadFile(*approvalSecretFile)
+		if err != nil {
+			log.WithError(err).Fatalf("unable to read approval secret file: %s", *approvalSecretFile)
+		}
+		approvalSecret = bytes.TrimSpace(approvalSecret)
+	}
+
+	var opsToken []byte
+	if *opsTokenFile != "" {
+		opsToken, err = ioutil.ReadFile(*opsTokenFile)
+		if err != nil {
+			log.WithError(err).Fatalf("unable to read ops token file: %s", *opsTokenFile)
+		}
+		opsToken = bytes.TrimSpace(opsToken)
+	}
+
+	var cutoff time.Time
+	if *grandfatherCutoff != "" {
+		cutoff, err = time.Parse(time.RFC3339, *grandfatherCutoff)
+		if err != nil {
+			log.WithError(err).Fatalf("unable to parse grandfather cutoff: %s", *grandfatherCutoff)
+		}
+	}
+
+	if *auditLogFile != "" {
+		if *decisionLogFile != "" {
+			log.Fatal("--audit-log and --decision-log are mutually exclusive")
+		}
+		*decisionLogFile = *auditLogFile
+		*decisionLogFormat = decisionLogJSONL
+	}
+
+	var decisionLog *DecisionLog
+	if *decisionLogFile != "" {
+		encoder, err := NewDecisionEncoder(*decisionLogFormat)
+		if err != nil {
+			log.WithError(err).Fatal("unable to create decision log encoder")
+		}
+
+		var w io.Writer = os.Stdout
+		if *decisionLogFile != "-" {
+			f, err := os.OpenFile(*decisionLogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+			if err != nil {
+				log.WithError(err).Fatalf("unable to open decision log: %s", *decisionLogFile)
+			}
+			defer f.Close()
+			w = f
+		}
+
+		decisionLog = NewDecisionLog(encoder, w)
+	}
+
+	rra := New(configer, Options{
+		ValidateCronJobJobs:          *validateCronJobJobs,
+		Warmup:                       *warmup,
+		MaxQuotaPercent:              *maxQuotaPercent,
+		Quotas:                       quotas,
+		MaxClusterPercent:            *maxClusterPercent,
+		Capacity:                     capacity,
+		Scales:                       scales,
+		LogDenied:                    *logDenied,
+		Claims:                       claims,
+		CustomResources:              customResources,
+		RequireMemoryLimit:           *requireMemoryLimit,
+		RequireEphemeralStorageLimit: *requireEphemeralStorageLimit,
+		RejectNegativeQuantities:     *rejectNegativeQuantities,
+		SuggestPatches:               *suggestPatches,
+		ApprovalSecret:               approvalSecret,
+		NamespaceDenialMetric:        *namespaceDenialMetric,
+		WarnTemplateResourceChanges:  *warnTemplateResourceChanges,
+		GrandfatherCutoff:            cutoff,
+		ContainerVerdicts:            *containerVerdicts,
+		DenialLogBurst:               *denialLogBurst,
+		DenialLogRate:                *denialLogRate,
+		DecisionLog:                  decisionLog,
+		DefaultAction:                *defaultAction,
+		LatencyBudgetAllow:           *latencyBudgetAllow,
+	})
+
+	tlsConfig := &tls.Config{}
+	switch {
+	case *certFile != "" && *keyFile != "":
+		var certs *CertReloader
+		err := runStartupStep(startupCtx, "certificate load", func() error {
+			var err error
+			certs, err = NewCertReloader(*certFile, *keyFile)
+			return err
+		})
+		if err != nil {
+			log.WithError(err).Fatal("unable to load certificates")
+		}
+		defer certs.Close()
+
+		tlsConfig.GetCertificate = certs.GetCertificate
+	case *generateSelfSigned:
+		if !*devMode {
+			log.Fatal("--generate-self-signed requires --dev-mode, it must not be used in production")
+		}
+
+		cert, err := generateSelfSignedCert([]string{"localhost", "127.0.0.1", "::1"}, 24*time.Hour)
+		if err != nil {
+			log.WithError(err).Fatal("unable to generate self-signed certificate")
+		}
+		log.Warn("serving with generated self-signed certificate, it must not be used in production")
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	default:
+		log.Fatal("--tls-cert-file and --tls-private-key-file are required")
+	}
+	hc, err := NewHealthChecker(*addr, *healthcheckNamespace, configer, rra)
 	if err != nil {
 		log.WithError(err).Fatal("unable to create healthcheck")
 	}
 	http.Handle("/metrics", promhttp.Handler())
+	http.HandleFunc("/livez", ServeLivez)
+	http.Handle("/readyz", hc)
+	// health is kept for deployments probing it, it's the readiness check
 	http.Handle("/health", hc)
+	http.HandleFunc("/schema", ServeSchema)
+	http.HandleFunc("/reasons", ServeDenialReasons)
+	http.HandleFunc("/version", ServeVersion)
+	http.Handle("/config", NewConfigHandler(configer, opsToken))
+	http.Handle("/reload", NewReloadHandler(configer, opsToken))
 
 	opsServer := &http.Server{
 		Addr:    *opsAddr,
@@ -113,11 +385,12 @@ func main() {
 		Handler: http.TimeoutHandler(&AdmissionControllerServer{
 			AdmissionController: rra,
 			Decoder:             codecs.UniversalDeserializer(),
-		}, 20*time.Second, "Service Unavailable"),
-		Addr: *addr,
-		TLSConfig: &tls.Config{
-			Certificates: []tls.Certificate{cert},
-		},
+			ForbidNonReview:     *forbidNonReview,
+			LatencyBudget:       *latencyBudget,
+			MaxRequestBytes:     *maxRequestBytes,
+		}, handlerTimeout, "Service Unavailable"),
+		Addr:      *addr,
+		TLSConfig: tlsConfig,
 	}
 	go func() {
 		err := server.ListenAndServeTLS("", "")
@@ -139,6 +412,15 @@ func main() {
 	waitForShutdown()
 }
 
+func newKubeClient(kubeconfig string) (kubernetes.Interface, error) {
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return kubernetes.NewForConfig(config)
+}
+
 func waitForShutdown() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
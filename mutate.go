@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// injectLimits sets missing limits.cpu and limits.memory of containers to the maximum of the limit and returns JSONPatch,
+// which injects them into the object. It returns nil if nothing is injected or kind has no known pod spec path.
+func injectLimits(kind string, podSpec *corev1.PodSpec, limit LimitResource) ([]byte, error) {
+	specPath, ok := podSpecPaths[kind]
+	if !ok {
+		return nil, nil
+	}
+
+	var patch []patchOperation
+	for i := range podSpec.InitContainers {
+		path := fmt.Sprintf("%s/initContainers/%d", specPath, i)
+		patch = append(patch, injectContainerLimits(path, &podSpec.InitContainers[i], limit.ForContainer(podSpec.InitContainers[i]), podSpec.Resources)...)
+	}
+	for i := range podSpec.Containers {
+		path := fmt.Sprintf("%s/containers/%d", specPath, i)
+		patch = append(patch, injectContainerLimits(path, &podSpec.Containers[i], limit.ForContainer(podSpec.Containers[i]), podSpec.Resources)...)
+	}
+
+	if len(patch) == 0 {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(patch)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to marshal patch")
+	}
+
+	return b, nil
+}
+
+// injectContainerLimits sets missing limits of container and returns patch operations of the container at path.
+// Limits set at pod level are not injected, nor limits lower than the container request, which would make the pod invalid.
+func injectContainerLimits(path string, container *corev1.Container, limit LimitResource, podResources *corev1.ResourceRequirements) []patchOperation {
+	limit = limit.ForImage(container.Image)
+
+	maxLimits := map[corev1.ResourceName]*resource.Quantity{
+		corev1.ResourceMemory: limit.MemLimit,
+	}
+	if limit.CPULimitPolicy != cpuLimitForbidden {
+		maxLimits[corev1.ResourceCPU] = limit.CPULimit
+	}
+
+	injected := make(corev1.ResourceList)
+	for _, name := range sortedResourceNames(maxLimits) {
+		maxLimit := maxLimits[name]
+		if maxLimit == nil || hasPodResource(podResources, name) {
+			continue
+		}
+		if _, ok := container.Resources.Limits[name]; ok {
+			continue
+		}
+		if request, ok := container.Resources.Requests[name]; ok && request.Cmp(*maxLimit) > 0 {
+			continue
+		}
+
+		injected[name] = maxLimit.DeepCopy()
+	}
+
+	if len(injected) == 0 {
+		return nil
+	}
+
+	// limits object is added as a whole, if container has none, since JSONPatch can't add keys to missing object
+	if container.Resources.Limits == nil {
+		container.Resources.Limits = injected
+		return []patchOperation{{
+			Op:    "add",
+			Path:  path + "/resources/limits",
+			Value: injected,
+		}}
+	}
+
+	var patch []patchOperation
+	for _, name := range sortedResourceNames(maxLimits) {
+		q, ok := injected[name]
+		if !ok {
+			continue
+		}
+
+		container.Resources.Limits[name] = q
+		patch = append(patch, patchOperation{
+			Op:    "add",
+			Path:  fmt.Sprintf("%s/resources/limits/%s", path, escapePointer(string(name))),
+			Value: q.String(),
+		})
+	}
+
+	return patch
+}
+
+// withPatch sets JSONPatch of the response
+func withPatch(resp *v1beta1.AdmissionResponse, patch []byte) *v1beta1.AdmissionResponse {
+	if patch == nil {
+		return resp
+	}
+
+	patchType := v1beta1.PatchTypeJSONPatch
+	resp.Patch = patch
+	resp.PatchType = &patchType
+
+	return resp
+}
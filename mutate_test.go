@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestHandleAdmissionMutate(t *testing.T) {
+	cpu := resource.MustParse("1")
+	mem := resource.MustParse("1Gi")
+	sidecarMem := resource.MustParse("128Mi")
+
+	tests := []struct {
+		name      string
+		kind      string
+		object    string
+		mutate    bool
+		cpuPolicy string
+		allowed   bool
+		patch     []patchOperation
+	}{
+		{
+			name:    "pod without limits",
+			kind:    "Pod",
+			object:  `{"metadata": {"name": "web"}, "spec": {"containers": [{"name": "app", "resources": {"requests": {"cpu": "500m", "memory": "512Mi"}}}]}}`,
+			mutate:  true,
+			allowed: true,
+			patch: []patchOperation{
+				{Op: "add", Path: "/spec/containers/0/resources/limits", Value: map[string]interface{}{"cpu": "1", "memory": "1Gi"}},
+			},
+		},
+		{
+			name: "deployment with cpu limit",
+			kind: "Deployment",
+			object: `{"metadata": {"name": "web"}, "spec": {"template": {"spec": {"containers": [
+				{"name": "proxy", "resources": {"requests": {"cpu": "100m", "memory": "64Mi"}, "limits": {"memory": "64Mi"}}},
+				{"name": "app", "resources": {"requests": {"cpu": "500m", "memory": "512Mi"}, "limits": {"cpu": "500m"}}}]}}}}`,
+			mutate:  true,
+			allowed: true,
+			patch: []patchOperation{
+				{Op: "add", Path: "/spec/template/spec/containers/0/resources/limits/cpu", Value: "1"},
+				{Op: "add", Path: "/spec/template/spec/containers/1/resources/limits/memory", Value: "1Gi"},
+			},
+		},
+		{
+			name: "sidecar limit",
+			kind: "Pod",
+			object: `{"metadata": {"name": "web"}, "spec": {
+				"initContainers": [{"name": "proxy", "restartPolicy": "Always", "resources": {"requests": {"cpu": "100m", "memory": "64Mi"}}}],
+				"containers": [{"name": "app", "resources": {"requests": {"cpu": "500m", "memory": "512Mi"}, "limits": {"cpu": 1, "memory": "1Gi"}}}]}}`,
+			mutate:  true,
+			allowed: true,
+			patch: []patchOperation{
+				{Op: "add", Path: "/spec/initContainers/0/resources/limits", Value: map[string]interface{}{"memory": "128Mi"}},
+			},
+		},
+		{
+			name:      "forbidden cpu limit",
+			kind:      "Pod",
+			object:    `{"metadata": {"name": "web"}, "spec": {"containers": [{"name": "app", "resources": {"requests": {"cpu": "500m", "memory": "512Mi"}}}]}}`,
+			mutate:    true,
+			cpuPolicy: cpuLimitForbidden,
+			allowed:   true,
+			patch: []patchOperation{
+				{Op: "add", Path: "/spec/containers/0/resources/limits", Value: map[string]interface{}{"memory": "1Gi"}},
+			},
+		},
+		{
+			name:    "request over the max",
+			kind:    "Pod",
+			object:  `{"metadata": {"name": "web"}, "spec": {"containers": [{"name": "app", "resources": {"requests": {"cpu": 2, "memory": "512Mi"}}}]}}`,
+			mutate:  true,
+			allowed: true,
+			patch: []patchOperation{
+				{Op: "add", Path: "/spec/containers/0/resources/limits", Value: map[string]interface{}{"memory": "1Gi"}},
+			},
+		},
+		{
+			name:    "limits over the max",
+			kind:    "Pod",
+			object:  `{"metadata": {"name": "web"}, "spec": {"containers": [{"name": "app", "resources": {"requests": {"cpu": "500m", "memory": "512Mi"}, "limits": {"memory": "2Gi"}}}]}}`,
+			mutate:  true,
+			allowed: false,
+		},
+		{
+			name:    "mutate disabled",
+			kind:    "Pod",
+			object:  `{"metadata": {"name": "web"}, "spec": {"containers": [{"name": "app", "resources": {"requests": {"cpu": "500m", "memory": "512Mi"}}}]}}`,
+			allowed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{conf: &MockConfiger{
+			cpu:       &cpu,
+			mem:       &mem,
+			mutate:    tt.mutate,
+			cpuPolicy: tt.cpuPolicy,
+			sidecar:   &LimitResource{MemLimit: &sidecarMem},
+		}}
+
+		resp, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+			UID:       "e911857d-c318-11e8-bbad-025000000001",
+			Kind:      metav1.GroupVersionKind{Kind: tt.kind},
+			Operation: v1beta1.Create,
+			Object:    runtime.RawExtension{Raw: []byte(tt.object)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.allowed, resp.Allowed, tt.name)
+		if tt.patch == nil {
+			assert.Nil(t, resp.Patch, tt.name)
+			assert.Nil(t, resp.PatchType, tt.name)
+			continue
+		}
+
+		var patch []patchOperation
+		if err := json.Unmarshal(resp.Patch, &patch); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.patch, patch, tt.name)
+		if assert.NotNil(t, resp.PatchType, tt.name) {
+			assert.Equal(t, v1beta1.PatchTypeJSONPatch, *resp.PatchType, tt.name)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// nameGlobChars are wildcards of customNames glob patterns, * matches any characters and ? a single character
+const nameGlobChars = "*?"
+
+// namePattern is customNames entry, whose name is a glob or nameRegex, it's compiled once on config load
+type namePattern struct {
+	namespace string
+	re        *regexp.Regexp
+	// prefix is the literal prefix of the pattern, longer prefix wins when multiple patterns match
+	prefix string
+	limit  LimitResource
+}
+
+// isNameGlob returns true if name contains glob wildcards
+func isNameGlob(name string) bool {
+	return strings.ContainsAny(name, nameGlobChars)
+}
+
+// globToRegex converts glob to regular expression matching the whole name
+func globToRegex(glob string) string {
+	var b strings.Builder
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	return b.String()
+}
+
+// newNamePattern compiles name glob or nameRegex of nn
+func newNamePattern(nn NameNamespace, limit LimitResource) (namePattern, error) {
+	if nn.Name != "" && nn.NameRegex != "" {
+		return namePattern{}, errors.New("name and nameRegex are mutually exclusive")
+	}
+
+	expr := nn.NameRegex
+	if expr == "" {
+		expr = globToRegex(nn.Name)
+	}
+
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return namePattern{}, errors.Wrap(err, "unable to compile name pattern")
+	}
+	prefix, _ := re.LiteralPrefix()
+
+	return namePattern{
+		namespace: nn.Namespace,
+		re:        re,
+		prefix:    prefix,
+		limit:     limit,
+	}, nil
+}
+
+// matches returns true if nn is in the pattern's namespace and its name matches
+func (np namePattern) matches(nn NameNamespace) bool {
+	return np.namespace == nn.Namespace && np.re.MatchString(nn.Name)
+}
+
+// sortNamePatterns orders patterns deterministically, longest literal prefix first, then by expression
+func sortNamePatterns(patterns []namePattern) {
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i].prefix) != len(patterns[j].prefix) {
+			return len(patterns[i].prefix) > len(patterns[j].prefix)
+		}
+
+		return patterns[i].re.String() < patterns[j].re.String()
+	})
+}
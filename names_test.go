@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGlobToRegex(t *testing.T) {
+	assert.Equal(t, `ingest-worker-.*`, globToRegex("ingest-worker-*"))
+	assert.Equal(t, `db-.\.internal`, globToRegex("db-?.internal"))
+}
+
+func TestSortNamePatterns(t *testing.T) {
+	var patterns []namePattern
+	for _, nn := range []NameNamespace{
+		{Name: "*"},
+		{Name: "ingest-*"},
+		{NameRegex: "ingest-(eu|us)"},
+		{Name: "ingest-worker-*"},
+	} {
+		pattern, err := newNamePattern(nn, LimitResource{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		patterns = append(patterns, pattern)
+	}
+
+	sortNamePatterns(patterns)
+
+	var exprs []string
+	for _, pattern := range patterns {
+		exprs = append(exprs, pattern.re.String())
+	}
+	assert.Equal(t, []string{`^(?:ingest-worker-.*)$`, `^(?:ingest-(eu|us))$`, `^(?:ingest-.*)$`, `^(?:.*)$`}, exprs)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var namespaceLookupCounter = promauto.NewCounterVec(prometheus.CounterOpts{Name: "namespace_lookups_total"}, []string{"cache"})
+
+// NamespaceMetaGetter returns namespace labels, annotations and phase
+type NamespaceMetaGetter interface {
+	GetNamespaceMeta(name string) (labels, annotations map[string]string, phase corev1.NamespacePhase, err error)
+}
+
+type namespaceCacheEntry struct {
+	labels      map[string]string
+	annotations map[string]string
+	phase       corev1.NamespacePhase
+	expires     time.Time
+}
+
+// NamespaceLookup fetches namespaces on demand and caches their metadata for ttl,
+// so that we don't need to keep an informer of all namespaces
+type NamespaceLookup struct {
+	client      kubernetes.Interface
+	ttl         time.Duration
+	integration *Integration
+	now         func() time.Time
+
+	cache map[string]namespaceCacheEntry
+	// evict is the time of the next eviction of expired entries, so that deleted namespaces don't accumulate
+	evict time.Time
+	m     sync.Mutex
+}
+
+// NewNamespaceLookup creates new NamespaceLookup
+func NewNamespaceLookup(client kubernetes.Interface, ttl time.Duration, integration *Integration) *NamespaceLookup {
+	namespaceLookupCounter.WithLabelValues("hit")
+	namespaceLookupCounter.WithLabelValues("miss")
+
+	return &NamespaceLookup{
+		client:      client,
+		ttl:         ttl,
+		integration: integration,
+		now:         time.Now,
+		cache:       make(map[string]namespaceCacheEntry),
+	}
+}
+
+// GetNamespaceMeta returns namespace labels, annotations and phase, fetching namespace if cache entry is missing or expired
+func (nl *NamespaceLookup) GetNamespaceMeta(name string) (labels, annotations map[string]string, phase corev1.NamespacePhase, err error) {
+	nl.m.Lock()
+	entry, ok := nl.cache[name]
+	nl.m.Unlock()
+
+	if ok && nl.now().Before(entry.expires) {
+		namespaceLookupCounter.WithLabelValues("hit").Inc()
+		return entry.labels, entry.annotations, entry.phase, nil
+	}
+	namespaceLookupCounter.WithLabelValues("miss").Inc()
+
+	var ns *corev1.Namespace
+	err = nl.integration.Do(func(ctx context.Context) error {
+		var err error
+		ns, err = nl.client.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		return err
+	})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			nl.m.Lock()
+			delete(nl.cache, name)
+			nl.m.Unlock()
+		}
+
+		return nil, nil, "", errors.Wrapf(err, "unable to get namespace %s", name)
+	}
+
+	now := nl.now()
+	entry = namespaceCacheEntry{
+		labels:      ns.Labels,
+		annotations: ns.Annotations,
+		phase:       ns.Status.Phase,
+		expires:     now.Add(nl.ttl),
+	}
+
+	nl.m.Lock()
+	nl.cache[name] = entry
+	if !now.Before(nl.evict) {
+		nl.evictExpired(now)
+	}
+	nl.m.Unlock()
+
+	return entry.labels, entry.annotations, entry.phase, nil
+}
+
+// evictExpired deletes expired entries at most once per ttl, must be called with lock held
+func (nl *NamespaceLookup) evictExpired(now time.Time) {
+	for name, entry := range nl.cache {
+		if !now.Before(entry.expires) {
+			delete(nl.cache, name)
+		}
+	}
+
+	nl.evict = now.Add(nl.ttl)
+}
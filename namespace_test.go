@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNamespaceLookupCache(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "batch",
+			Labels:      map[string]string{"tier": "batch"},
+			Annotations: map[string]string{"owner": "data"},
+		},
+		Status: corev1.NamespaceStatus{Phase: corev1.NamespaceActive},
+	})
+
+	now := time.Unix(0, 0)
+	nl := NewNamespaceLookup(client, time.Minute, NewIntegration("test_namespaces", DefaultIntegrationConfig()))
+	nl.now = func() time.Time { return now }
+
+	labels, annotations, phase, err := nl.GetNamespaceMeta("batch")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"tier": "batch"}, labels)
+	assert.Equal(t, map[string]string{"owner": "data"}, annotations)
+	assert.Equal(t, corev1.NamespaceActive, phase)
+	assert.Len(t, client.Actions(), 1)
+
+	// cache hit
+	now = now.Add(30 * time.Second)
+	_, _, _, err = nl.GetNamespaceMeta("batch")
+	assert.NoError(t, err)
+	assert.Len(t, client.Actions(), 1)
+
+	// expired
+	now = now.Add(time.Minute)
+	_, _, _, err = nl.GetNamespaceMeta("batch")
+	assert.NoError(t, err)
+	assert.Len(t, client.Actions(), 2)
+
+	_, _, _, err = nl.GetNamespaceMeta("missing")
+	assert.Error(t, err)
+}
+
+func TestNamespaceLookupEviction(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "deleted"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "batch"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "web"}},
+	)
+
+	now := time.Unix(0, 0)
+	nl := NewNamespaceLookup(client, time.Minute, NewIntegration("test_namespaces", DefaultIntegrationConfig()))
+	nl.now = func() time.Time { return now }
+
+	for _, name := range []string{"deleted", "batch"} {
+		if _, _, _, err := nl.GetNamespaceMeta(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assert.Len(t, nl.cache, 2)
+
+	if err := client.CoreV1().Namespaces().Delete(context.Background(), "deleted", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// expired entries are evicted, even if namespace isn't looked up again
+	now = now.Add(2 * time.Minute)
+	if _, _, _, err := nl.GetNamespaceMeta("web"); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"web"}, cachedNamespaces(nl))
+
+	// not found namespaces are evicted
+	if _, _, _, err := nl.GetNamespaceMeta("batch"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.CoreV1().Namespaces().Delete(context.Background(), "batch", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(2 * time.Minute)
+	_, _, _, err := nl.GetNamespaceMeta("batch")
+	assert.Error(t, err)
+	assert.Equal(t, []string{"web"}, cachedNamespaces(nl))
+}
+
+func cachedNamespaces(nl *NamespaceLookup) []string {
+	nl.m.Lock()
+	defer nl.m.Unlock()
+
+	var names []string
+	for name := range nl.cache {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func TestConfigNamespaceSelector(t *testing.T) {
+	configer, err := NewConfigurer("./testdata/test.yaml", 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	client := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "batch", Labels: map[string]string{"tier": "batch"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "web", Labels: map[string]string{"tier": "web"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "monitoring", Labels: map[string]string{"tier": "batch"}}},
+	)
+
+	// without namespace lookup selectors are ignored
+	limit := configer.GetPodLimit(NameNamespace{Name: "", Namespace: "batch"})
+	assert.Equal(t, int64(2), limit.CPULimit.Value())
+
+	configer.SetNamespaceLookup(NewNamespaceLookup(client, time.Minute, NewIntegration("test_namespaces", DefaultIntegrationConfig())))
+
+	limit = configer.GetPodLimit(NameNamespace{Name: "", Namespace: "batch"})
+	assert.Equal(t, int64(4), limit.CPULimit.Value())
+	assert.Equal(t, int64(2*1024*1024*1024), limit.MemLimit.Value())
+
+	limit = configer.GetPodLimit(NameNamespace{Name: "", Namespace: "web"})
+	assert.Equal(t, int64(2), limit.CPULimit.Value())
+
+	// customNamespaces take precedence and are not looked up
+	limit = configer.GetPodLimit(NameNamespace{Name: "", Namespace: "monitoring"})
+	assert.Equal(t, int64(2), limit.CPULimit.Value())
+	assert.Equal(t, int64(3*1024*1024*1024), limit.MemLimit.Value())
+	assert.Len(t, client.Actions(), 2)
+
+	// lookup errors fall back to default limits
+	limit = configer.GetPodLimit(NameNamespace{Name: "", Namespace: "missing"})
+	assert.Equal(t, int64(2), limit.CPULimit.Value())
+}
+
+func TestConfigNamespaceSelectorPhase(t *testing.T) {
+	f, err := ioutil.TempFile("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString(`
+maxCPULimit: 2
+namespaceSelectors:
+  - matchLabels: {tier: batch}
+    matchPhase: Terminating
+    limit:
+      maxCPULimit: 1
+  - matchLabels: {tier: batch}
+    limit:
+      maxCPULimit: 4
+`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	configer, err := NewConfigurer(f.Name(), 1*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	client := fake.NewSimpleClientset(
+		&corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: "batch", Labels: map[string]string{"tier": "batch"}},
+			Status:     corev1.NamespaceStatus{Phase: corev1.NamespaceActive},
+		},
+		&corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: "old-batch", Labels: map[string]string{"tier": "batch"}},
+			Status:     corev1.NamespaceStatus{Phase: corev1.NamespaceTerminating},
+		},
+	)
+	configer.SetNamespaceLookup(NewNamespaceLookup(client, time.Minute, NewIntegration("test_namespaces", DefaultIntegrationConfig())))
+
+	limit := configer.GetPodLimit(NameNamespace{Name: "", Namespace: "batch"})
+	assert.Equal(t, int64(4), limit.CPULimit.Value())
+
+	limit = configer.GetPodLimit(NameNamespace{Name: "", Namespace: "old-batch"})
+	assert.Equal(t, int64(1), limit.CPULimit.Value())
+}
+
+func TestConfigNamespaceSelectorInvalidPhase(t *testing.T) {
+	f, err := ioutil.TempFile("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString("namespaceSelectors: [{matchPhase: Deleted, limit: {maxCPULimit: 1}}]"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	_, err = NewConfigurer(f.Name(), 1*time.Hour, false)
+	assert.Error(t, err)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Policy is a named limit, which applies to requests matched by its matcher.
+// Policies are evaluated in declared order before other declarations, first matching policy wins.
+type Policy struct {
+	Name  string      `yaml:"name" json:"name"`
+	Match PolicyMatch `yaml:"match" json:"match"`
+	Limit Limit       `yaml:"limit" json:"limit"`
+}
+
+// PolicyMatch matches requests by all of its fields, fields which are not set match everything
+type PolicyMatch struct {
+	Namespaces []string `yaml:"namespaces" json:"namespaces"`
+	// Names are object names, e.g. Deployment name
+	Names []string `yaml:"names" json:"names"`
+	// Groups are groups of the requesting user, policies matching groups don't apply to PVCs
+	Groups []string `yaml:"groups" json:"groups"`
+	// NamespaceLabels require namespace lookup, if lookup fails policy doesn't match
+	NamespaceLabels map[string]string `yaml:"namespaceLabels" json:"namespaceLabels"`
+}
+
+type policyLimit struct {
+	name  string
+	match PolicyMatch
+	limit LimitResource
+}
+
+// convertPolicies converts policies in declared order, fields of policy limits which are not set are taken from defaults
+func convertPolicies(policies []Policy, defaults LimitResource) ([]policyLimit, error) {
+	out := make([]policyLimit, 0, len(policies))
+	names := make(map[string]bool, len(policies))
+	for i, policy := range policies {
+		if policy.Name == "" {
+			return nil, errors.Errorf("policies[%d] name is empty", i)
+		}
+		if names[policy.Name] {
+			return nil, errors.Errorf("policies[%d] name %s is not unique", i, policy.Name)
+		}
+		names[policy.Name] = true
+
+		rLimit, err := convertLimitsToResources(policy.Limit, defaults)
+		if err != nil {
+			return nil, errors.Wrapf(err, "policy: %s", policy.Name)
+		}
+
+		out = append(out, policyLimit{
+			name:  policy.Name,
+			match: policy.Match,
+			limit: *rLimit,
+		})
+	}
+
+	return out, nil
+}
+
+// matches returns true if all matcher fields match, groups are nil for PVCs
+func (p policyLimit) matches(nn NameNamespace, groups []string, meta *namespaceMeta) bool {
+	if len(p.match.Namespaces) > 0 && !contains(p.match.Namespaces, nn.Namespace) {
+		return false
+	}
+
+	if len(p.match.Names) > 0 && !contains(p.match.Names, nn.Name) {
+		return false
+	}
+
+	if len(p.match.Groups) > 0 {
+		matched := false
+		for _, group := range groups {
+			if contains(p.match.Groups, group) {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return false
+		}
+	}
+
+	if len(p.match.NamespaceLabels) > 0 {
+		if meta == nil {
+			return false
+		}
+
+		for k, v := range p.match.NamespaceLabels {
+			if meta.labels[k] != v {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// selectPolicy returns the first policy which matches, must be called with read lock held.
+func (c *Configurer) selectPolicy(nn NameNamespace, groups []string, meta *namespaceMeta) (policyLimit, bool) {
+	for _, policy := range c.policies {
+		if policy.matches(nn, groups, meta) {
+			return policy, true
+		}
+	}
+
+	return policyLimit{}, false
+}
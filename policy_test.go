@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestConfigPolicies(t *testing.T) {
+	configer, err := NewConfigurer("./testdata/policies.yaml", 1*time.Hour, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configer.Close()
+
+	client := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "web", Labels: map[string]string{"tier": "web"}}},
+	)
+	configer.SetNamespaceLookup(NewNamespaceLookup(client, time.Minute, NewIntegration("test_policies", DefaultIntegrationConfig())))
+
+	tests := []struct {
+		name   string
+		nn     NameNamespace
+		groups []string
+		cpu    int64
+	}{
+		{name: "first matching policy wins", nn: NameNamespace{Name: "api", Namespace: "batch"}, groups: []string{"admins"}, cpu: 8},
+		{name: "policy before customNamespaces", nn: NameNamespace{Name: "api", Namespace: "batch"}, cpu: 4},
+		{name: "later policy is shadowed", nn: NameNamespace{Name: "etl", Namespace: "batch"}, cpu: 4},
+		{name: "namespace labels", nn: NameNamespace{Name: "api", Namespace: "web"}, cpu: 3},
+		{name: "no policy matches", nn: NameNamespace{Name: "api", Namespace: "other"}, cpu: 2},
+	}
+
+	for _, tt := range tests {
+		limit := configer.GetPodLimit(tt.nn, tt.groups...)
+		assert.Equal(t, tt.cpu, limit.CPULimit.Value(), tt.name)
+	}
+
+	// group policies don't apply to PVCs
+	pvc, unlimited := configer.GetMaxPVCSize(NameNamespace{Name: "data", Namespace: "batch"})
+	assert.False(t, unlimited)
+	assert.Equal(t, int64(100*1024*1024*1024), pvc.Value())
+}
+
+func TestConfigInvalidPolicies(t *testing.T) {
+	for _, config := range []string{
+		"policies: [{match: {namespaces: [batch]}, limit: {maxCPULimit: 4}}]",
+		"policies: [{name: batch, limit: {maxCPULimit: 4}}, {name: batch, limit: {maxCPULimit: 2}}]",
+		"policies: [{name: batch, limit: {maxCPULimit: four}}]",
+	} {
+		f, err := ioutil.TempFile("", "config")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+
+		if _, err := f.WriteString(config); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		_, err = NewConfigurer(f.Name(), 1*time.Hour, false)
+		assert.Error(t, err, config)
+	}
+}
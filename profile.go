@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// profileAnnotation of pod template names resource profile, which the workload's containers must match exactly
+const profileAnnotation = "resource-requests-admission-controller.devopy.io/profile"
+
+// ResourceProfile is a named container size, e.g. small, medium or large. Empty fields must not be set by containers.
+type ResourceProfile struct {
+	CPURequest string `yaml:"cpuRequest" json:"cpuRequest"`
+	MemRequest string `yaml:"memRequest" json:"memRequest"`
+	CPULimit   string `yaml:"cpuLimit" json:"cpuLimit"`
+	MemLimit   string `yaml:"memLimit" json:"memLimit"`
+}
+
+type resourceProfile struct {
+	cpuRequest *resource.Quantity
+	memRequest *resource.Quantity
+	cpuLimit   *resource.Quantity
+	memLimit   *resource.Quantity
+}
+
+// DeepCopy returns deep copy of resourceProfile
+func (rp resourceProfile) DeepCopy() resourceProfile {
+	return resourceProfile{
+		cpuRequest: copyQuantity(rp.cpuRequest),
+		memRequest: copyQuantity(rp.memRequest),
+		cpuLimit:   copyQuantity(rp.cpuLimit),
+		memLimit:   copyQuantity(rp.memLimit),
+	}
+}
+
+func convertProfile(profile ResourceProfile) (resourceProfile, error) {
+	cpuRequest, err := parseQuantity(profile.CPURequest, nil)
+	if err != nil {
+		return resourceProfile{}, errors.Wrap(err, "could not parse cpuRequest")
+	}
+
+	memRequest, err := parseQuantity(profile.MemRequest, nil)
+	if err != nil {
+		return resourceProfile{}, errors.Wrap(err, "could not parse memRequest")
+	}
+
+	cpuLimit, err := parseQuantity(profile.CPULimit, nil)
+	if err != nil {
+		return resourceProfile{}, errors.Wrap(err, "could not parse cpuLimit")
+	}
+
+	memLimit, err := parseQuantity(profile.MemLimit, nil)
+	if err != nil {
+		return resourceProfile{}, errors.Wrap(err, "could not parse memLimit")
+	}
+
+	return resourceProfile{
+		cpuRequest: cpuRequest,
+		memRequest: memRequest,
+		cpuLimit:   cpuLimit,
+		memLimit:   memLimit,
+	}, nil
+}
+
+// ForProfile returns limit of workloads, which name the profile. Ceilings don't apply to them,
+// since containers must match the profile exactly. Unknown profile keeps ceilings and is denied by validateProfile.
+func (l LimitResource) ForProfile(name string) LimitResource {
+	if name == "" {
+		return l
+	}
+
+	out := l
+	if _, ok := l.Profiles[name]; ok {
+		out = l.withoutCeilings()
+	}
+	out.Profile = name
+
+	return out
+}
+
+// validateProfile validates that containers and sidecars match the profile named by the workload exactly
+func validateProfile(podSpec corev1.PodSpec, limit LimitResource) []violation {
+	if limit.Profile == "" {
+		return nil
+	}
+
+	profile, ok := limit.Profiles[limit.Profile]
+	if !ok {
+		return []violation{{
+			reason:  reasonProfileMismatch,
+			message: fmt.Sprintf("error resource profile %s is not configured", limit.Profile),
+		}}
+	}
+
+	var violations []violation
+	for _, container := range podSpec.Containers {
+		violations = append(violations, inContainer(container.Name, profileMismatches("container", container, limit.Profile, profile))...)
+	}
+	for _, container := range podSpec.InitContainers {
+		if isSidecar(container) {
+			violations = append(violations, inContainer(container.Name, profileMismatches("sidecar container", container, limit.Profile, profile))...)
+		}
+	}
+
+	return violations
+}
+
+// profileMismatches returns violations of container resources, which differ from the profile
+func profileMismatches(containerType string, container corev1.Container, name string, profile resourceProfile) []violation {
+	var violations []violation
+	for _, c := range []struct {
+		name      corev1.ResourceName
+		field     string
+		resources corev1.ResourceList
+		expected  *resource.Quantity
+	}{
+		{name: corev1.ResourceCPU, field: "requests.CPU", resources: container.Resources.Requests, expected: profile.cpuRequest},
+		{name: corev1.ResourceMemory, field: "requests.Memory", resources: container.Resources.Requests, expected: profile.memRequest},
+		{name: corev1.ResourceCPU, field: "limits.CPU", resources: container.Resources.Limits, expected: profile.cpuLimit},
+		{name: corev1.ResourceMemory, field: "limits.Memory", resources: container.Resources.Limits, expected: profile.memLimit},
+	} {
+		q, ok := c.resources[c.name]
+		switch {
+		case !ok && c.expected == nil:
+			continue
+		case ok && c.expected != nil && q.Cmp(*c.expected) == 0:
+			continue
+		}
+
+		var mismatch string
+		switch {
+		case !ok:
+			mismatch = "none != " + formatQuantity(c.name, *c.expected)
+		case c.expected == nil:
+			mismatch = formatQuantity(c.name, q) + " != none"
+		default:
+			mismatch = formatComparison(c.name, q, "!=", *c.expected)
+		}
+
+		violations = append(violations, violation{
+			resource: c.name,
+			reason:   reasonProfileMismatch,
+			message:  fmt.Sprintf("error %s %s profile %s %s: %s", containerType, container.Name, name, c.field, mismatch),
+		})
+	}
+
+	return violations
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestHandleAdmissionProfile(t *testing.T) {
+	cpu := resource.MustParse("1")
+	mem := resource.MustParse("1Gi")
+	largeCPU := resource.MustParse("4")
+	largeMem := resource.MustParse("8Gi")
+	conf := &MockConfiger{
+		cpu:        &cpu,
+		mem:        &mem,
+		cpuRequest: &cpu,
+		memRequest: &mem,
+		profiles: map[string]resourceProfile{
+			"large": {cpuRequest: &largeCPU, memRequest: &largeMem, memLimit: &largeMem},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		profile   string
+		resources string
+		message   string
+		reason    denialReason
+	}{
+		{
+			name:      "profile matched above ceilings",
+			profile:   "large",
+			resources: `{"requests": {"cpu": "4", "memory": "8Gi"}, "limits": {"memory": "8Gi"}}`,
+		},
+		{
+			name:      "profile request differs",
+			profile:   "large",
+			resources: `{"requests": {"cpu": "3", "memory": "8Gi"}, "limits": {"memory": "8Gi"}}`,
+			message:   "error container app profile large requests.CPU: 3 != 4",
+			reason:    reasonProfileMismatch,
+		},
+		{
+			name:      "limit not in profile",
+			profile:   "large",
+			resources: `{"requests": {"cpu": "4", "memory": "8Gi"}, "limits": {"cpu": "4", "memory": "8Gi"}}`,
+			message:   "error container app profile large limits.CPU: 4 != none",
+			reason:    reasonProfileMismatch,
+		},
+		{
+			name:      "unknown profile",
+			profile:   "huge",
+			resources: `{"requests": {"cpu": "4", "memory": "8Gi"}, "limits": {"memory": "8Gi"}}`,
+			message:   "error resource profile huge is not configured",
+			reason:    reasonProfileMismatch,
+		},
+		{
+			name:      "without profile ceilings apply",
+			resources: `{"requests": {"cpu": "4", "memory": "8Gi"}, "limits": {"memory": "8Gi"}}`,
+			message:   "error container app requests.CPU: 4 > 1",
+			reason:    reasonRequestExceeded,
+		},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{conf: conf}
+
+		annotations := `{}`
+		if tt.profile != "" {
+			annotations = `{"` + profileAnnotation + `": "` + tt.profile + `"}`
+		}
+		resp, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+			UID:       "e911857d-c318-11e8-bbad-025000000001",
+			Kind:      v1.GroupVersionKind{Kind: deploymentKind},
+			Namespace: "default",
+			Operation: v1beta1.Create,
+			Object: runtime.RawExtension{Raw: []byte(`{"metadata": {"name": "web"}, "spec": {"template": {
+				"metadata": {"annotations": ` + annotations + `},
+				"spec": {"containers": [{"name": "app", "resources": ` + tt.resources + `}]}}}}`)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.message == "", resp.Allowed, tt.name)
+		if tt.message != "" {
+			assert.Contains(t, resp.Result.Message, tt.message, tt.name)
+			assert.Equal(t, string(tt.reason), resp.AuditAnnotations[denialReasonAnnotation], tt.name)
+		}
+	}
+}
+
+func TestHandleAdmissionTemplateAnnotations(t *testing.T) {
+	cpu := resource.MustParse("1")
+	mem := resource.MustParse("1Gi")
+	largeCPU := resource.MustParse("4")
+	conf := &MockConfiger{
+		cpu:        &cpu,
+		mem:        &mem,
+		cpuRequest: &cpu,
+		memRequest: &mem,
+		profiles: map[string]resourceProfile{
+			"large": {cpuRequest: &largeCPU, memRequest: &mem},
+		},
+	}
+
+	profile := `{"` + profileAnnotation + `": "large"}`
+	podTemplate := func(annotations string) string {
+		return `{"metadata": {"annotations": ` + annotations + `}, "spec": {"containers": [{"name": "app", "resources": {"requests": {"cpu": "4", "memory": "1Gi"}}}]}}`
+	}
+	objects := map[v1.GroupVersionKind]func(objectAnnotations, templateAnnotations string) string{
+		{Group: "apps", Version: "v1", Kind: deploymentKind}: func(objectAnnotations, templateAnnotations string) string {
+			return `{"metadata": {"name": "web", "annotations": ` + objectAnnotations + `}, "spec": {"template": ` + podTemplate(templateAnnotations) + `}}`
+		},
+		{Group: "apps", Version: "v1", Kind: statefulsetKind}: func(objectAnnotations, templateAnnotations string) string {
+			return `{"metadata": {"name": "db", "annotations": ` + objectAnnotations + `}, "spec": {"template": ` + podTemplate(templateAnnotations) + `}}`
+		},
+		{Group: "batch", Version: "v1", Kind: cronJobKind}: func(objectAnnotations, templateAnnotations string) string {
+			return `{"metadata": {"name": "backup", "annotations": ` + objectAnnotations + `}, "spec": {"jobTemplate": {"spec": {"template": ` + podTemplate(templateAnnotations) + `}}}}`
+		},
+	}
+
+	tests := []struct {
+		name                string
+		objectAnnotations   string
+		templateAnnotations string
+		allowed             bool
+	}{
+		{
+			name:                "template annotation applies",
+			objectAnnotations:   `{}`,
+			templateAnnotations: profile,
+			allowed:             true,
+		},
+		{
+			// only template annotations propagate to pods
+			name:                "object annotation is ignored",
+			objectAnnotations:   profile,
+			templateAnnotations: `{}`,
+		},
+	}
+
+	for kind, object := range objects {
+		for _, tt := range tests {
+			rra := &ResourceRequestsAdmission{conf: conf}
+			resp, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+				UID:       "e911857d-c318-11e8-bbad-025000000001",
+				Kind:      kind,
+				Namespace: "default",
+				Operation: v1beta1.Create,
+				Object:    runtime.RawExtension{Raw: []byte(object(tt.objectAnnotations, tt.templateAnnotations))},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.allowed, resp.Allowed, kind.Kind+" "+tt.name)
+			if !tt.allowed {
+				assert.Contains(t, resp.Result.Message, "requests.CPU: 4 > 1", kind.Kind+" "+tt.name)
+			}
+		}
+	}
+}
+
+func TestValidateProfileSidecar(t *testing.T) {
+	cpu := resource.MustParse("250m")
+	limit := LimitResource{
+		Profiles: map[string]resourceProfile{"small": {cpuRequest: &cpu}},
+	}.ForProfile("small")
+
+	var pod corev1.PodSpec
+	if err := json.Unmarshal([]byte(`{
+		"initContainers": [
+			{"name": "migrate", "resources": {"requests": {"cpu": "1"}}},
+			{"name": "proxy", "restartPolicy": "Always", "resources": {"requests": {"cpu": "100m"}}}
+		],
+		"containers": [{"name": "app", "resources": {"requests": {"cpu": "250m"}}}]
+	}`), &pod); err != nil {
+		t.Fatal(err)
+	}
+
+	violations := validateProfile(pod, limit)
+	if assert.Len(t, violations, 1) {
+		assert.Equal(t, "error sidecar container proxy profile small requests.CPU: 100m != 250m", violations[0].message)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// podQOSClass returns QoS class of pod like kubelet does, pod level resources (spec.resources) win over containers
+func podQOSClass(podSpec corev1.PodSpec) corev1.PodQOSClass {
+	if podSpec.Resources != nil && (len(podSpec.Resources.Requests) > 0 || len(podSpec.Resources.Limits) > 0) {
+		return qosClass([]corev1.ResourceRequirements{*podSpec.Resources})
+	}
+
+	resources := make([]corev1.ResourceRequirements, 0, len(podSpec.InitContainers)+len(podSpec.Containers))
+	for _, container := range podSpec.InitContainers {
+		resources = append(resources, container.Resources)
+	}
+	for _, container := range podSpec.Containers {
+		resources = append(resources, container.Resources)
+	}
+
+	return qosClass(resources)
+}
+
+// qosClass returns Guaranteed if all resources limit CPU and memory and requests equal limits,
+// BestEffort if no CPU or memory is requested or limited, Burstable otherwise
+func qosClass(resources []corev1.ResourceRequirements) corev1.PodQOSClass {
+	guaranteed := true
+	bestEffort := true
+	for _, r := range resources {
+		for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+			request, hasRequest := r.Requests[name]
+			limit, hasLimit := r.Limits[name]
+			if (hasRequest && !request.IsZero()) || (hasLimit && !limit.IsZero()) {
+				bestEffort = false
+			}
+
+			// missing request defaults to limit, zero limit doesn't count
+			if !hasLimit || limit.IsZero() || (hasRequest && request.Cmp(limit) != 0) {
+				guaranteed = false
+			}
+		}
+	}
+
+	switch {
+	case bestEffort:
+		return corev1.PodQOSBestEffort
+	case guaranteed:
+		return corev1.PodQOSGuaranteed
+	default:
+		return corev1.PodQOSBurstable
+	}
+}
+
+// validateGuaranteed validates requests of Guaranteed pods against GuaranteedCPU and GuaranteedMem,
+// since they reserve the full limit on the node
+func validateGuaranteed(podSpec corev1.PodSpec, limit LimitResource) []violation {
+	if limit.GuaranteedCPU == nil && limit.GuaranteedMem == nil {
+		return nil
+	}
+	if podQOSClass(podSpec) != corev1.PodQOSGuaranteed {
+		return nil
+	}
+
+	var violations []violation
+	for _, c := range []struct {
+		name  corev1.ResourceName
+		field string
+		max   *resource.Quantity
+	}{
+		{name: corev1.ResourceCPU, field: "requests.CPU", max: limit.GuaranteedCPU},
+		{name: corev1.ResourceMemory, field: "requests.Memory", max: limit.GuaranteedMem},
+	} {
+		if c.max == nil {
+			continue
+		}
+
+		// requests of Guaranteed pods equal limits, which are always set
+		total := podTotal(podSpec, c.name, func(container corev1.Container) corev1.ResourceList {
+			return container.Resources.Limits
+		})
+		if podSpec.Resources != nil {
+			if q, ok := podSpec.Resources.Limits[c.name]; ok {
+				total = q.DeepCopy()
+			}
+		}
+
+		if total.Cmp(*c.max) > 0 {
+			violations = append(violations, violation{
+				resource: c.name,
+				reason:   reasonGuaranteedOverprovisioned,
+				message:  fmt.Sprintf("error pod Guaranteed %s: %s", c.field, formatComparison(c.name, total, ">", *c.max)),
+			})
+		}
+	}
+
+	return violations
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestPodQOSClass(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+		qos  corev1.PodQOSClass
+	}{
+		{
+			name: "no resources",
+			spec: `{"containers": [{"name": "app"}]}`,
+			qos:  corev1.PodQOSBestEffort,
+		},
+		{
+			name: "zero requests",
+			spec: `{"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}}}]}`,
+			qos:  corev1.PodQOSBestEffort,
+		},
+		{
+			name: "requests equal limits",
+			spec: `{"containers": [{"name": "app", "resources": {"requests": {"cpu": 1, "memory": "1Gi"}, "limits": {"cpu": 1, "memory": "1Gi"}}}]}`,
+			qos:  corev1.PodQOSGuaranteed,
+		},
+		{
+			name: "requests default to limits",
+			spec: `{"containers": [{"name": "app", "resources": {"limits": {"cpu": 1, "memory": "1Gi"}}}]}`,
+			qos:  corev1.PodQOSGuaranteed,
+		},
+		{
+			name: "request lower than limit",
+			spec: `{"containers": [{"name": "app", "resources": {"requests": {"cpu": "500m", "memory": "1Gi"}, "limits": {"cpu": 1, "memory": "1Gi"}}}]}`,
+			qos:  corev1.PodQOSBurstable,
+		},
+		{
+			name: "init container without memory limit",
+			spec: `{"initContainers": [{"name": "init", "resources": {"limits": {"cpu": 1}}}],
+				"containers": [{"name": "app", "resources": {"limits": {"cpu": 1, "memory": "1Gi"}}}]}`,
+			qos: corev1.PodQOSBurstable,
+		},
+		{
+			name: "pod level resources",
+			spec: `{"resources": {"limits": {"cpu": 2, "memory": "2Gi"}}, "containers": [{"name": "app", "resources": {"requests": {"cpu": 1}}}]}`,
+			qos:  corev1.PodQOSGuaranteed,
+		},
+	}
+
+	for _, tt := range tests {
+		var podSpec corev1.PodSpec
+		if err := json.Unmarshal([]byte(tt.spec), &podSpec); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.qos, podQOSClass(podSpec), tt.name)
+	}
+}
+
+func TestHandleAdmissionGuaranteed(t *testing.T) {
+	gCPU := resource.MustParse("2")
+	gMem := resource.MustParse("4Gi")
+
+	tests := []struct {
+		name     string
+		spec     string
+		severity map[corev1.ResourceName]string
+		allowed  bool
+		message  string
+		warnings []string
+	}{
+		{
+			name:    "guaranteed within max",
+			spec:    `{"containers": [{"name": "app", "resources": {"requests": {"cpu": 2, "memory": "4Gi"}, "limits": {"cpu": 2, "memory": "4Gi"}}}]}`,
+			allowed: true,
+		},
+		{
+			name: "guaranteed over cpu max",
+			spec: `{"containers": [
+				{"name": "app", "resources": {"requests": {"cpu": 2, "memory": "2Gi"}, "limits": {"cpu": 2, "memory": "2Gi"}}},
+				{"name": "proxy", "resources": {"requests": {"cpu": 1, "memory": "1Gi"}, "limits": {"cpu": 1, "memory": "1Gi"}}}]}`,
+			message: "error pod Guaranteed requests.CPU: 3 > 2",
+		},
+		{
+			name:    "burstable over max",
+			spec:    `{"containers": [{"name": "app", "resources": {"requests": {"cpu": 1, "memory": "1Gi"}, "limits": {"cpu": 8, "memory": "16Gi"}}}]}`,
+			allowed: true,
+		},
+		{
+			name:     "guaranteed over memory max with warn severity",
+			spec:     `{"containers": [{"name": "app", "resources": {"requests": {"cpu": 1, "memory": "8Gi"}, "limits": {"cpu": 1, "memory": "8Gi"}}}]}`,
+			severity: map[corev1.ResourceName]string{corev1.ResourceMemory: severityWarn},
+			allowed:  true,
+			warnings: []string{"error pod Guaranteed requests.Memory: 8Gi > 4Gi"},
+		},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{conf: &MockConfiger{gCPU: &gCPU, gMem: &gMem, severity: tt.severity}}
+
+		resp, err := rra.HandleAdmission(context.Background(), podReview(tt.spec).Request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.allowed, resp.Allowed, tt.name)
+		assert.Equal(t, tt.warnings, resp.Warnings, tt.name)
+		if tt.message != "" {
+			assert.Equal(t, tt.message, resp.Result.Message, tt.name)
+			assert.Equal(t, string(reasonGuaranteedOverprovisioned), resp.AuditAnnotations[denialReasonAnnotation], tt.name)
+		}
+	}
+}
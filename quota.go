@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// QuotaGetter returns ResourceQuotas of the namespace
+type QuotaGetter interface {
+	GetResourceQuotas(namespace string) ([]*corev1.ResourceQuota, error)
+}
+
+// QuotaLister lists ResourceQuotas from informer cache
+type QuotaLister struct {
+	factory informers.SharedInformerFactory
+	lister  corelisters.ResourceQuotaLister
+	synced  cache.InformerSynced
+}
+
+// NewQuotaLister creates new QuotaLister, Start must be called before use
+func NewQuotaLister(client kubernetes.Interface, resync time.Duration) *QuotaLister {
+	factory := informers.NewSharedInformerFactory(client, resync)
+	informer := factory.Core().V1().ResourceQuotas()
+
+	return &QuotaLister{
+		factory: factory,
+		lister:  informer.Lister(),
+		synced:  informer.Informer().HasSynced,
+	}
+}
+
+// Start starts informer and waits for its cache to sync
+func (ql *QuotaLister) Start(stop <-chan struct{}) error {
+	ql.factory.Start(stop)
+	if !cache.WaitForCacheSync(stop, ql.synced) {
+		return errors.New("unable to sync resourcequotas cache")
+	}
+
+	return nil
+}
+
+// GetResourceQuotas returns ResourceQuotas of the namespace
+func (ql *QuotaLister) GetResourceQuotas(namespace string) ([]*corev1.ResourceQuota, error) {
+	return ql.lister.ResourceQuotas(namespace).List(labels.Everything())
+}
+
+// quotaResources maps ResourceQuota hard resources to the container resource they limit
+var quotaResources = []struct {
+	name     corev1.ResourceName
+	resource corev1.ResourceName
+	limits   bool
+}{
+	{name: corev1.ResourceCPU, resource: corev1.ResourceCPU},
+	{name: corev1.ResourceRequestsCPU, resource: corev1.ResourceCPU},
+	{name: corev1.ResourceMemory, resource: corev1.ResourceMemory},
+	{name: corev1.ResourceRequestsMemory, resource: corev1.ResourceMemory},
+	{name: corev1.ResourceLimitsCPU, resource: corev1.ResourceCPU, limits: true},
+	{name: corev1.ResourceLimitsMemory, resource: corev1.ResourceMemory, limits: true},
+}
+
+// workloadUsage returns total requests and limits of all containers of all replicas,
+// pod level resources (spec.resources) replace sums of containers
+func workloadUsage(podSpec corev1.PodSpec, replicas int64) (requests, limits corev1.ResourceList) {
+	requests = corev1.ResourceList{}
+	limits = corev1.ResourceList{}
+	for _, container := range podSpec.Containers {
+		addResources(requests, container.Resources.Requests, replicas)
+		addResources(limits, container.Resources.Limits, replicas)
+	}
+
+	if podSpec.Resources != nil {
+		for name := range podSpec.Resources.Requests {
+			delete(requests, name)
+		}
+		for name := range podSpec.Resources.Limits {
+			delete(limits, name)
+		}
+		addResources(requests, podSpec.Resources.Requests, replicas)
+		addResources(limits, podSpec.Resources.Limits, replicas)
+	}
+
+	return requests, limits
+}
+
+func addResources(total, resources corev1.ResourceList, replicas int64) {
+	for name, q := range resources {
+		sum := total[name]
+		sum.Add(*resource.NewMilliQuantity(q.MilliValue()*replicas, q.Format))
+		total[name] = sum
+	}
+}
+
+// quotaViolation returns message of the first quota hard limit, of which workload uses more than percent
+func quotaViolation(quotas []*corev1.ResourceQuota, requests, limits corev1.ResourceList, percent int64) (string, bool) {
+	for _, quota := range quotas {
+		for _, qr := range quotaResources {
+			hard, ok := quota.Spec.Hard[qr.name]
+			if !ok {
+				continue
+			}
+
+			usage := requests[qr.resource]
+			if qr.limits {
+				usage = limits[qr.resource]
+			}
+
+			max := resource.NewMilliQuantity(hard.MilliValue()*percent/100, hard.Format)
+			if usage.Cmp(*max) > 0 {
+				return fmt.Sprintf("error resourceQuota %s %s: %s > %d%% of %s", quota.Name, qr.name, formatQuantity(qr.resource, usage), percent, formatQuantity(qr.resource, hard)), true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// validateQuota denies workload, which uses more than MaxQuotaPercent of any namespace ResourceQuota hard limit.
+// If quotas can't be listed, request is allowed.
+func (rra *ResourceRequestsAdmission) validateQuota(req *v1beta1.AdmissionRequest, w *workload) *v1beta1.AdmissionResponse {
+	quotas, err := rra.opts.Quotas.GetResourceQuotas(req.Namespace)
+	if err != nil {
+		log.WithError(err).Errorf("unable to list resourcequotas of namespace %s", req.Namespace)
+		return nil
+	}
+
+	requests, limits := workloadUsage(w.podSpec, w.replicas)
+	message, ok := quotaViolation(quotas, requests, limits, int64(rra.opts.MaxQuotaPercent))
+	if !ok {
+		return nil
+	}
+
+	return &v1beta1.AdmissionResponse{
+		UID:     req.UID,
+		Allowed: false,
+		Result: &metav1.Status{
+			Message: message,
+		},
+		AuditAnnotations: reasonAnnotations(reasonQuotaExceeded),
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var computeQuota = &corev1.ResourceQuota{
+	ObjectMeta: metav1.ObjectMeta{
+		Name:      "compute",
+		Namespace: "default",
+	},
+	Spec: corev1.ResourceQuotaSpec{
+		Hard: corev1.ResourceList{
+			corev1.ResourceRequestsCPU:    resource.MustParse("10"),
+			corev1.ResourceLimitsMemory:   resource.MustParse("20Gi"),
+			corev1.ResourceRequestsMemory: resource.MustParse("10Gi"),
+		},
+	},
+}
+
+func TestQuotaLister(t *testing.T) {
+	client := fake.NewSimpleClientset(computeQuota)
+
+	stop := make(chan struct{})
+	defer close(stop)
+
+	ql := NewQuotaLister(client, time.Minute)
+	if err := ql.Start(stop); err != nil {
+		t.Fatal(err)
+	}
+
+	quotas, err := ql.GetResourceQuotas("default")
+	assert.NoError(t, err)
+	assert.Len(t, quotas, 1)
+	assert.Equal(t, "compute", quotas[0].Name)
+
+	quotas, err = ql.GetResourceQuotas("kube-system")
+	assert.NoError(t, err)
+	assert.Empty(t, quotas)
+}
+
+type mockQuotas []*corev1.ResourceQuota
+
+func (mq mockQuotas) GetResourceQuotas(namespace string) ([]*corev1.ResourceQuota, error) {
+	return mq, nil
+}
+
+func TestHandleAdmissionQuotaPercent(t *testing.T) {
+	deployment := func(replicas, cpu, mem, memLimit string) string {
+		return `{"metadata": {"name": "web"}, "spec": {"replicas": ` + replicas + `, "template": {"spec": {"containers": [{"name": "app",
+			"resources": {"requests": {"cpu": "` + cpu + `", "memory": "` + mem + `"}, "limits": {"cpu": "` + cpu + `", "memory": "` + memLimit + `"}}}]}}}}`
+	}
+
+	tests := []struct {
+		name    string
+		object  string
+		percent int
+		message string
+	}{
+		{
+			name:    "within quota percentage",
+			object:  deployment("2", "1", "1Gi", "1Gi"),
+			percent: 20,
+		},
+		{
+			name:    "requests.cpu over quota percentage",
+			object:  deployment("3", "1", "1Gi", "1Gi"),
+			percent: 20,
+			message: "error resourceQuota compute requests.cpu: 3 > 20% of 10",
+		},
+		{
+			name:    "limits.memory over quota percentage",
+			object:  deployment("2", "500m", "1Gi", "3Gi"),
+			percent: 20,
+			message: "error resourceQuota compute limits.memory: 6Gi > 20% of 20Gi",
+		},
+		{
+			name: "pod level resources over quota percentage",
+			object: `{"metadata": {"name": "web"}, "spec": {"replicas": 2, "template": {"spec": {"resources": {"requests": {"cpu": "2", "memory": "1Gi"}},
+				"containers": [{"name": "app"}, {"name": "sidecar"}]}}}}`,
+			percent: 20,
+			message: "error resourceQuota compute requests.cpu: 4 > 20% of 10",
+		},
+		{
+			name:   "disabled",
+			object: deployment("10", "1", "1Gi", "1Gi"),
+		},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{
+			conf: &MockConfiger{},
+			opts: Options{
+				MaxQuotaPercent: tt.percent,
+				Quotas:          mockQuotas{computeQuota},
+			},
+		}
+
+		resp, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+			UID:       "e911857d-c318-11e8-bbad-025000000001",
+			Kind:      metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+			Namespace: "default",
+			Operation: v1beta1.Create,
+			Object:    runtime.RawExtension{Raw: []byte(tt.object)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.message == "", resp.Allowed, tt.name)
+		if tt.message != "" {
+			assert.Equal(t, tt.message, resp.Result.Message, tt.name)
+		}
+	}
+}
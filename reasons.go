@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// denialReason is a stable code of the denial, it's the reason label of admission_denials_total
+type denialReason string
+
+const (
+	reasonMissingRequest                denialReason = "missing_request"
+	reasonRequestBelowMinimum           denialReason = "request_below_minimum"
+	reasonRequestExceeded               denialReason = "request_exceeded"
+	reasonLimitExceeded                 denialReason = "limit_exceeded"
+	reasonPodMemoryExceeded             denialReason = "pod_memory_exceeded"
+	reasonGuaranteedOverprovisioned     denialReason = "guaranteed_overprovisioned"
+	reasonProfileMismatch               denialReason = "profile_mismatch"
+	reasonBurstExceeded                 denialReason = "burst_exceeded"
+	reasonLimitRequestRatioExceeded     denialReason = "limit_request_ratio_exceeded"
+	reasonRequestFractionMismatch       denialReason = "request_fraction_mismatch"
+	reasonUnpairedResource              denialReason = "unpaired_resource"
+	reasonTooManyContainerResources     denialReason = "too_many_container_resources"
+	reasonCPULimitPolicy                denialReason = "cpu_limit_policy"
+	reasonSwapForbidden                 denialReason = "swap_forbidden"
+	reasonMemoryLimitRequired           denialReason = "memory_limit_required"
+	reasonMissingLimit                  denialReason = "missing_limit"
+	reasonEphemeralStorageLimitRequired denialReason = "ephemeral_storage_limit_required"
+	reasonProbeRequired                 denialReason = "probe_required"
+	reasonNegativeQuantity              denialReason = "negative_quantity"
+	reasonEphemeralContainersForbidden  denialReason = "ephemeral_containers_forbidden"
+	reasonTooManyResourceClaims         denialReason = "too_many_resource_claims"
+	reasonDeviceClassNotAllowed         denialReason = "device_class_not_allowed"
+	reasonQuotaExceeded                 denialReason = "quota_exceeded"
+	reasonClusterCapacityExceeded       denialReason = "cluster_capacity_exceeded"
+	reasonReplicasExceeded              denialReason = "replicas_exceeded"
+	reasonTopologySpreadRequired        denialReason = "topology_spread_required"
+	reasonUnhandledKind                 denialReason = "unhandled_kind"
+	reasonPVCSizeMissing                denialReason = "pvc_size_missing"
+	reasonPVCSizeExceeded               denialReason = "pvc_size_exceeded"
+	reasonPVCSizeBelowMinimum           denialReason = "pvc_size_below_minimum"
+	reasonNamespacePVCSizeExceeded      denialReason = "namespace_pvc_size_exceeded"
+	reasonNamespaceGPUsExceeded         denialReason = "namespace_gpus_exceeded"
+	reasonLatencyBudgetExceeded         denialReason = "latency_budget_exceeded"
+)
+
+// denialReasonAnnotation is the audit annotation of denied responses, which carries denialReason
+const denialReasonAnnotation = "denial-reason"
+
+// DenialReason describes a denial reason code
+type DenialReason struct {
+	Code        denialReason `json:"code"`
+	Description string       `json:"description"`
+}
+
+// denialReasons lists every denialReason, descriptions can be overridden by LoadDenialReasons
+var denialReasons = []DenialReason{
+	{Code: reasonMissingRequest, Description: "Container doesn't set CPU or memory request."},
+	{Code: reasonRequestBelowMinimum, Description: "CPU or memory request is below minCPURequest or minMemRequest."},
+	{Code: reasonRequestExceeded, Description: "CPU or memory request exceeds maxCPURequest or maxMemRequest."},
+	{Code: reasonLimitExceeded, Description: "CPU, memory, ephemeral-storage or extended resource limit exceeds maxCPULimit, maxMemLimit, maxEphemeralStorageLimit or maxExtendedResources."},
+	{Code: reasonPodMemoryExceeded, Description: "Memory limit of pod, optionally with memory backed emptyDir volumes, exceeds maxPodMemLimit."},
+	{Code: reasonGuaranteedOverprovisioned, Description: "Pod of Guaranteed QoS class requests more CPU or memory than maxGuaranteedCPU or maxGuaranteedMem."},
+	{Code: reasonProfileMismatch, Description: "Workload names resource profile, which isn't configured, or its containers' requests and limits don't match the profile exactly."},
+	{Code: reasonBurstExceeded, Description: "Difference between limit and request exceeds maxCPUBurst or maxMemBurst."},
+	{Code: reasonLimitRequestRatioExceeded, Description: "Limit divided by request exceeds maxLimitRequestRatio."},
+	{Code: reasonRequestFractionMismatch, Description: "Request differs from requestFraction of limit by more than requestFractionTolerance."},
+	{Code: reasonUnpairedResource, Description: "Resource listed in pairedResources has only request or only limit."},
+	{Code: reasonTooManyContainerResources, Description: "Container declares more distinct resources than maxContainerResources."},
+	{Code: reasonCPULimitPolicy, Description: "CPU limit is missing, but required, or set, but forbidden by cpuLimitPolicy."},
+	{Code: reasonSwapForbidden, Description: "Container of a Burstable pod can use swap, which is forbidden by swapPolicy."},
+	{Code: reasonMemoryLimitRequired, Description: "Container doesn't set memory limit, which is required."},
+	{Code: reasonMissingLimit, Description: "Container doesn't set CPU or memory limit, which is required by requireLimits, since its maximum is configured."},
+	{Code: reasonEphemeralStorageLimitRequired, Description: "Container of a pod with emptyDir volume doesn't set ephemeral-storage limit."},
+	{Code: reasonProbeRequired, Description: "Container doesn't define a probe listed in requiredProbes."},
+	{Code: reasonNegativeQuantity, Description: "Request or limit is negative."},
+	{Code: reasonEphemeralContainersForbidden, Description: "Ephemeral (debug) containers are forbidden in the namespace."},
+	{Code: reasonTooManyResourceClaims, Description: "Pod declares more resource claims than maxResourceClaims."},
+	{Code: reasonDeviceClassNotAllowed, Description: "Resource claim requests a device class, which is not in allowedDeviceClasses."},
+	{Code: reasonQuotaExceeded, Description: "Workload uses more than the allowed percentage of namespace ResourceQuota."},
+	{Code: reasonReplicasExceeded, Description: "Deployment, StatefulSet or ReplicationController has more replicas than maxReplicas."},
+	{Code: reasonTopologySpreadRequired, Description: "Workload with at least topologySpreadReplicas replicas doesn't declare topologySpreadConstraints."},
+	{Code: reasonUnhandledKind, Description: "Kind of the object isn't handled by the controller and defaultAction is deny."},
+	{Code: reasonClusterCapacityExceeded, Description: "CPU or memory limits of all workload replicas exceed the allowed percentage of cluster allocatable."},
+	{Code: reasonPVCSizeMissing, Description: "PersistentVolumeClaim doesn't request storage."},
+	{Code: reasonPVCSizeExceeded, Description: "PersistentVolumeClaim size exceeds maxPVCSize."},
+	{Code: reasonPVCSizeBelowMinimum, Description: "PersistentVolumeClaim size is below minPVCSize."},
+	{Code: reasonNamespacePVCSizeExceeded, Description: "Total size of namespace PersistentVolumeClaims would exceed maxNamespacePVCSize."},
+	{Code: reasonNamespaceGPUsExceeded, Description: "Total GPU requests of namespace workloads would exceed maxNamespaceGPUs."},
+	{Code: reasonLatencyBudgetExceeded, Description: "Admission didn't finish within latency budget."},
+}
+
+var denialsCounter = promauto.NewCounterVec(prometheus.CounterOpts{Name: "admission_denials_total"}, []string{"reason"})
+
+func init() {
+	for _, reason := range denialReasons {
+		denialsCounter.WithLabelValues(string(reason.Code))
+	}
+}
+
+// reasonAnnotations returns audit annotations of response denied for the reason
+func reasonAnnotations(reason denialReason) map[string]string {
+	return map[string]string{denialReasonAnnotation: string(reason)}
+}
+
+// LoadDenialReasons overrides descriptions of denial reasons from yaml file, which maps codes to descriptions
+func LoadDenialReasons(filePath string) error {
+	file, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return errors.Wrap(err, "unable to read file")
+	}
+
+	var descriptions map[denialReason]string
+	if err := yaml.UnmarshalStrict(file, &descriptions); err != nil {
+		return errors.Wrap(err, "unable to unmarshal yaml file")
+	}
+
+	known := make(map[denialReason]bool, len(denialReasons))
+	for _, reason := range denialReasons {
+		known[reason.Code] = true
+	}
+	for code := range descriptions {
+		if !known[code] {
+			return errors.Errorf("unknown denial reason: %s", code)
+		}
+	}
+
+	reasons := make([]DenialReason, 0, len(denialReasons))
+	for _, reason := range denialReasons {
+		if description, ok := descriptions[reason.Code]; ok {
+			reason.Description = description
+		}
+		reasons = append(reasons, reason)
+	}
+
+	denialReasons = reasons
+	return nil
+}
+
+// ServeDenialReasons serves all denial reasons as JSON
+func ServeDenialReasons(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(denialReasons); err != nil {
+		log.WithError(err).Error("unable to write denial reasons response")
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// declaredReasons returns values of all denialReason constants declared in reasons.go
+func declaredReasons(t *testing.T) []string {
+	file, err := parser.ParseFile(token.NewFileSet(), "reasons.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var reasons []string
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+
+		for _, spec := range gen.Specs {
+			value := spec.(*ast.ValueSpec)
+			if ident, ok := value.Type.(*ast.Ident); !ok || ident.Name != "denialReason" {
+				continue
+			}
+
+			code, err := strconv.Unquote(value.Values[0].(*ast.BasicLit).Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reasons = append(reasons, code)
+		}
+	}
+
+	return reasons
+}
+
+func TestServeDenialReasons(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(ServeDenialReasons))
+	defer server.Close()
+
+	r, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Body.Close()
+
+	var reasons []DenialReason
+	if err := json.NewDecoder(r.Body).Decode(&reasons); err != nil {
+		t.Fatal(err)
+	}
+
+	served := make(map[string]string, len(reasons))
+	for _, reason := range reasons {
+		served[string(reason.Code)] = reason.Description
+	}
+
+	declared := declaredReasons(t)
+	assert.NotEmpty(t, declared)
+	assert.Len(t, reasons, len(declared))
+	for _, code := range declared {
+		assert.NotEmpty(t, served[code], code)
+	}
+}
+
+func TestLoadDenialReasons(t *testing.T) {
+	defaults := denialReasons
+	defer func() { denialReasons = defaults }()
+
+	if err := LoadDenialReasons("./testdata/reasons.yaml"); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, denialReasons, len(defaults))
+	for _, reason := range denialReasons {
+		if reason.Code == reasonLimitExceeded {
+			assert.Equal(t, "Your container asks for more CPU or memory than the namespace allows, lower its limits.", reason.Description)
+		}
+	}
+
+	f, err := ioutil.TempFile("", "reasons")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString("cpu_too_high: unknown"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	assert.EqualError(t, LoadDenialReasons(f.Name()), "unknown denial reason: cpu_too_high")
+}
+
+func TestHandleAdmissionDenialReason(t *testing.T) {
+	cpu := resource.MustParse("1")
+	rra := &ResourceRequestsAdmission{conf: &MockConfiger{cpu: &cpu}}
+
+	before := testutil.ToFloat64(denialsCounter.WithLabelValues(string(reasonLimitExceeded)))
+	beforeKind := testutil.ToFloat64(decisionsCounter.WithLabelValues(podKind, decisionDenied, string(reasonLimitExceeded)))
+
+	resp, err := rra.HandleAdmission(context.Background(), podReview(`{"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"cpu": "2"}}}]}`).Request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.False(t, resp.Allowed)
+	assert.Equal(t, map[string]string{denialReasonAnnotation: string(reasonLimitExceeded)}, resp.AuditAnnotations)
+	assert.Equal(t, before+1, testutil.ToFloat64(denialsCounter.WithLabelValues(string(reasonLimitExceeded))))
+	assert.Equal(t, beforeKind+1, testutil.ToFloat64(decisionsCounter.WithLabelValues(podKind, decisionDenied, string(reasonLimitExceeded))))
+}
+
+func TestHandleAdmissionDecisions(t *testing.T) {
+	cpu := resource.MustParse("1")
+	pvcSize := resource.MustParse("1Gi")
+	rra := New(&MockConfiger{cpu: &cpu, pvcSize: &pvcSize}, Options{})
+
+	// label combinations of built-in kinds are pre-initialized
+	assert.GreaterOrEqual(t, testutil.CollectAndCount(decisionsCounter), 8*(len(denialReasons)+1))
+
+	tests := []struct {
+		name     string
+		kind     string
+		object   string
+		decision string
+		reason   string
+	}{
+		{
+			name:     "allowed pod",
+			kind:     podKind,
+			object:   `{"metadata": {"name": "web"}, "spec": {"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}}}]}}`,
+			decision: decisionAllowed,
+			reason:   reasonNone,
+		},
+		{
+			name: "deployment over cpu limit",
+			kind: deploymentKind,
+			object: `{"metadata": {"name": "web"}, "spec": {"template": {"spec": {"containers": [
+				{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"cpu": 2}}}]}}}}`,
+			decision: decisionDenied,
+			reason:   string(reasonLimitExceeded),
+		},
+		{
+			name:     "job without requests",
+			kind:     jobKind,
+			object:   `{"metadata": {"name": "backup"}, "spec": {"template": {"spec": {"containers": [{"name": "app"}]}}}}`,
+			decision: decisionDenied,
+			reason:   string(reasonMissingRequest),
+		},
+		{
+			name:     "pvc over size",
+			kind:     pvcKind,
+			object:   `{"metadata": {"name": "data"}, "spec": {"resources": {"requests": {"storage": "2Gi"}}}}`,
+			decision: decisionDenied,
+			reason:   string(reasonPVCSizeExceeded),
+		},
+	}
+
+	for _, tt := range tests {
+		before := testutil.ToFloat64(decisionsCounter.WithLabelValues(tt.kind, tt.decision, tt.reason))
+
+		resp, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+			UID:       "e911857d-c318-11e8-bbad-025000000001",
+			Kind:      metav1.GroupVersionKind{Kind: tt.kind},
+			Namespace: "default",
+			Operation: v1beta1.Create,
+			Object:    runtime.RawExtension{Raw: []byte(tt.object)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.decision == decisionAllowed, resp.Allowed, tt.name)
+		assert.Equal(t, before+1, testutil.ToFloat64(decisionsCounter.WithLabelValues(tt.kind, tt.decision, tt.reason)), tt.name)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const redacted = "REDACTED"
+
+// redactPodSpec returns copy of podSpec with env values and secret references redacted
+func redactPodSpec(podSpec corev1.PodSpec) corev1.PodSpec {
+	spec := *podSpec.DeepCopy()
+
+	for i := range spec.InitContainers {
+		redactEnv(spec.InitContainers[i].Env, spec.InitContainers[i].EnvFrom)
+	}
+	for i := range spec.Containers {
+		redactEnv(spec.Containers[i].Env, spec.Containers[i].EnvFrom)
+	}
+	for i := range spec.EphemeralContainers {
+		redactEnv(spec.EphemeralContainers[i].Env, spec.EphemeralContainers[i].EnvFrom)
+	}
+
+	for i := range spec.Volumes {
+		if spec.Volumes[i].Secret != nil {
+			spec.Volumes[i].Secret.SecretName = redacted
+		}
+	}
+	for i := range spec.ImagePullSecrets {
+		spec.ImagePullSecrets[i].Name = redacted
+	}
+
+	return spec
+}
+
+func redactEnv(env []corev1.EnvVar, envFrom []corev1.EnvFromSource) {
+	for i := range env {
+		if env[i].Value != "" {
+			env[i].Value = redacted
+		}
+		if env[i].ValueFrom != nil && env[i].ValueFrom.SecretKeyRef != nil {
+			env[i].ValueFrom.SecretKeyRef.Name = redacted
+			env[i].ValueFrom.SecretKeyRef.Key = redacted
+		}
+	}
+
+	for i := range envFrom {
+		if envFrom[i].SecretRef != nil {
+			envFrom[i].SecretRef.Name = redacted
+		}
+	}
+}
+
+// logDenied logs redacted pod spec of denied workload at debug level
+func logDenied(w *workload, namespace string) {
+	if !log.IsLevelEnabled(log.DebugLevel) {
+		return
+	}
+
+	spec, err := json.Marshal(redactPodSpec(w.podSpec))
+	if err != nil {
+		log.WithError(err).Error("unable to marshal denied pod spec")
+		return
+	}
+
+	log.WithField("spec", string(spec)).Debugf("denied %s name: %s, namespace: %s", w.kind, w.name, namespace)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestRedactPodSpec(t *testing.T) {
+	spec := corev1.PodSpec{
+		Containers: []corev1.Container{{
+			Name: "app",
+			Env: []corev1.EnvVar{
+				{Name: "PASSWORD", Value: "hunter2"},
+				{Name: "TOKEN", ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "api"},
+						Key:                  "token",
+					},
+				}},
+			},
+			EnvFrom: []corev1.EnvFromSource{{
+				SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "db"}},
+			}},
+		}},
+		Volumes: []corev1.Volume{{
+			Name:         "tls",
+			VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "tls"}},
+		}},
+	}
+
+	r := redactPodSpec(spec)
+
+	assert.Equal(t, "PASSWORD", r.Containers[0].Env[0].Name)
+	assert.Equal(t, redacted, r.Containers[0].Env[0].Value)
+	assert.Equal(t, redacted, r.Containers[0].Env[1].ValueFrom.SecretKeyRef.Name)
+	assert.Equal(t, redacted, r.Containers[0].Env[1].ValueFrom.SecretKeyRef.Key)
+	assert.Equal(t, redacted, r.Containers[0].EnvFrom[0].SecretRef.Name)
+	assert.Equal(t, redacted, r.Volumes[0].Secret.SecretName)
+
+	// original is not modified
+	assert.Equal(t, "hunter2", spec.Containers[0].Env[0].Value)
+	assert.Equal(t, "tls", spec.Volumes[0].Secret.SecretName)
+}
+
+func TestHandleAdmissionLogDenied(t *testing.T) {
+	hook := test.NewGlobal()
+	defer hook.Reset()
+	defer log.SetLevel(log.GetLevel())
+
+	cpu := resource.MustParse("1")
+	rra := &ResourceRequestsAdmission{
+		conf: &MockConfiger{cpu: &cpu},
+		opts: Options{LogDenied: true},
+	}
+	req := &v1beta1.AdmissionRequest{
+		UID:       "e911857d-c318-11e8-bbad-025000000001",
+		Kind:      metav1.GroupVersionKind{Kind: "Pod"},
+		Operation: v1beta1.Create,
+		Object: runtime.RawExtension{
+			Raw: []byte(`{"metadata": {"name": "test"}, "spec": {"containers": [{"name": "app",
+				"env": [{"name": "PASSWORD", "value": "hunter2"}],
+				"resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"cpu": 2}}}]}}`),
+		},
+	}
+
+	deniedSpecs := func() []string {
+		var specs []string
+		for _, entry := range hook.AllEntries() {
+			if spec, ok := entry.Data["spec"]; ok {
+				specs = append(specs, spec.(string))
+			}
+		}
+		return specs
+	}
+
+	log.SetLevel(log.InfoLevel)
+	if _, err := rra.HandleAdmission(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, deniedSpecs())
+
+	log.SetLevel(log.DebugLevel)
+	if _, err := rra.HandleAdmission(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+
+	specs := deniedSpecs()
+	if assert.Len(t, specs, 1) {
+		assert.True(t, strings.Contains(specs[0], redacted))
+		assert.False(t, strings.Contains(specs[0], "hunter2"))
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// replicatedKinds have replicas, which are limited by MaxReplicas
+var replicatedKinds = map[string]bool{
+	deploymentKind:            true,
+	statefulsetKind:           true,
+	replicationControllerKind: true,
+}
+
+// replicasViolation returns message if the workload has more replicas than MaxReplicas
+func replicasViolation(w *workload, limit LimitResource) (string, bool) {
+	if limit.MaxReplicas == nil || !replicatedKinds[w.kind] {
+		return "", false
+	}
+
+	if w.replicas <= int64(*limit.MaxReplicas) {
+		return "", false
+	}
+
+	return fmt.Sprintf("error %s %s replicas: %d > %d", strings.ToLower(w.kind), w.name, w.replicas, *limit.MaxReplicas), true
+}
+
+// validateReplicas denies workload, which has more replicas than MaxReplicas
+func validateReplicas(req *v1beta1.AdmissionRequest, w *workload, limit LimitResource) *v1beta1.AdmissionResponse {
+	message, ok := replicasViolation(w, limit)
+	if !ok {
+		return nil
+	}
+
+	return &v1beta1.AdmissionResponse{
+		UID:     req.UID,
+		Allowed: false,
+		Result: &metav1.Status{
+			Message: message,
+		},
+		AuditAnnotations: reasonAnnotations(reasonReplicasExceeded),
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestReplicasViolation(t *testing.T) {
+	maxReplicas := 3
+
+	_, ok := replicasViolation(&workload{kind: deploymentKind, name: "web", replicas: 3}, LimitResource{MaxReplicas: &maxReplicas})
+	assert.False(t, ok)
+
+	message, ok := replicasViolation(&workload{kind: statefulsetKind, name: "db", replicas: 4}, LimitResource{MaxReplicas: &maxReplicas})
+	assert.True(t, ok)
+	assert.Equal(t, "error statefulset db replicas: 4 > 3", message)
+
+	// job parallelism isn't limited by maxReplicas
+	_, ok = replicasViolation(&workload{kind: jobKind, name: "batch", replicas: 4}, LimitResource{MaxReplicas: &maxReplicas})
+	assert.False(t, ok)
+
+	_, ok = replicasViolation(&workload{kind: deploymentKind, name: "web", replicas: 5000}, LimitResource{})
+	assert.False(t, ok)
+}
+
+func TestHandleAdmissionMaxReplicas(t *testing.T) {
+	maxReplicas := 10
+
+	tests := []struct {
+		name     string
+		replicas string
+		mode     string
+		allowed  bool
+		message  string
+	}{
+		{name: "within bounds", replicas: `"replicas": 10,`, allowed: true},
+		{name: "missing replicas default to 1", allowed: true},
+		{name: "too many replicas", replicas: `"replicas": 5000,`, message: "error deployment web replicas: 5000 > 10"},
+		{name: "too many replicas in warn mode", replicas: `"replicas": 5000,`, mode: modeWarn, allowed: true},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{conf: &MockConfiger{maxReplicas: &maxReplicas, mode: tt.mode}}
+
+		resp, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+			UID:       "e911857d-c318-11e8-bbad-025000000001",
+			Kind:      v1.GroupVersionKind{Group: "apps", Version: "v1", Kind: deploymentKind},
+			Namespace: "default",
+			Operation: v1beta1.Create,
+			Object: runtime.RawExtension{Raw: []byte(`{"metadata": {"name": "web"}, "spec": {` + tt.replicas + ` "template": {
+				"spec": {"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}}}]}}}}`)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.allowed, resp.Allowed, tt.name)
+		if tt.message != "" {
+			assert.Equal(t, tt.message, resp.Result.Message, tt.name)
+			assert.Equal(t, string(reasonReplicasExceeded), resp.AuditAnnotations[denialReasonAnnotation], tt.name)
+		}
+		if tt.mode == modeWarn {
+			assert.Equal(t, []string{"error deployment web replicas: 5000 > 10"}, resp.Warnings, tt.name)
+		}
+	}
+}
+
+func TestHandleAdmissionScaleMaxReplicas(t *testing.T) {
+	maxReplicas := 10
+	rra := &ResourceRequestsAdmission{
+		conf: &MockConfiger{maxReplicas: &maxReplicas},
+		opts: Options{Scales: mockScales{"web": &corev1.PodTemplateSpec{}}},
+	}
+
+	for replicas, message := range map[string]string{
+		"10":   "",
+		"5000": "error deployment web replicas: 5000 > 10",
+	} {
+		resp, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+			UID:         "e911857d-c318-11e8-bbad-025000000001",
+			Kind:        v1.GroupVersionKind{Group: "autoscaling", Version: "v1", Kind: "Scale"},
+			Resource:    v1.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"},
+			SubResource: scaleSubResource,
+			Name:        "web",
+			Namespace:   "default",
+			Operation:   v1beta1.Update,
+			Object:      runtime.RawExtension{Raw: []byte(`{"apiVersion": "autoscaling/v1", "kind": "Scale", "metadata": {"name": "web"}, "spec": {"replicas": ` + replicas + `}}`)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, message == "", resp.Allowed, replicas)
+		if message != "" {
+			assert.Equal(t, message, resp.Result.Message, replicas)
+		}
+	}
+}
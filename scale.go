@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/api/admission/v1beta1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// scaleSubResource changes replicas without carrying the pod template
+const scaleSubResource = "scale"
+
+// scaledKinds maps resources of scale subresource to their kinds
+var scaledKinds = map[string]string{
+	"deployments":  deploymentKind,
+	"statefulsets": statefulsetKind,
+}
+
+// PodTemplateGetter returns pod template of a scaled workload
+type PodTemplateGetter interface {
+	GetPodTemplate(kind, namespace, name string) (*corev1.PodTemplateSpec, error)
+}
+
+// ScaleLookup fetches Deployments and StatefulSets scaled through scale subresource
+type ScaleLookup struct {
+	client      kubernetes.Interface
+	integration *Integration
+}
+
+// NewScaleLookup creates new ScaleLookup
+func NewScaleLookup(client kubernetes.Interface, integration *Integration) *ScaleLookup {
+	return &ScaleLookup{
+		client:      client,
+		integration: integration,
+	}
+}
+
+// GetPodTemplate returns pod template of the Deployment or StatefulSet
+func (sl *ScaleLookup) GetPodTemplate(kind, namespace, name string) (*corev1.PodTemplateSpec, error) {
+	var template corev1.PodTemplateSpec
+	err := sl.integration.Do(func(ctx context.Context) error {
+		switch kind {
+		case deploymentKind:
+			deployment, err := sl.client.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			template = deployment.Spec.Template
+		case statefulsetKind:
+			sts, err := sl.client.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			template = sts.Spec.Template
+		default:
+			return errors.Errorf("unsupported kind %s", kind)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get %s %s/%s", strings.ToLower(kind), namespace, name)
+	}
+
+	return &template, nil
+}
+
+// handleScale enforces MaxReplicas, TopologySpreadReplicas, MaxQuotaPercent and MaxClusterPercent on scale ups of Deployments and StatefulSets through scale subresource.
+// Scale downs are allowed, as well as requests whose workload can't be fetched.
+func (rra *ResourceRequestsAdmission) handleScale(req *v1beta1.AdmissionRequest) (*v1beta1.AdmissionResponse, error) {
+	resp := &v1beta1.AdmissionResponse{
+		UID:     req.UID,
+		Allowed: true,
+	}
+
+	kind, ok := scaledKinds[req.Resource.Resource]
+	// maxReplicas is known only after the pod template is fetched, so scale ups are looked up even without percentages
+	quota := rra.opts.MaxQuotaPercent > 0 && rra.opts.Quotas != nil
+	cluster := rra.opts.MaxClusterPercent > 0 && rra.opts.Capacity != nil
+	if !ok || rra.opts.Scales == nil {
+		return resp, nil
+	}
+
+	var scale autoscalingv1.Scale
+	if err := json.Unmarshal(req.Object.Raw, &scale); err != nil {
+		return nil, errors.Wrapf(err, "unable to unmarshal json: %s", string(req.Object.Raw))
+	}
+
+	if req.Operation == v1beta1.Update && len(req.OldObject.Raw) > 0 {
+		var old autoscalingv1.Scale
+		if err := json.Unmarshal(req.OldObject.Raw, &old); err != nil {
+			return nil, errors.Wrapf(err, "unable to unmarshal json: %s", string(req.OldObject.Raw))
+		}
+
+		if scale.Spec.Replicas <= old.Spec.Replicas {
+			return resp, nil
+		}
+	}
+
+	template, err := rra.opts.Scales.GetPodTemplate(kind, req.Namespace, req.Name)
+	if err != nil {
+		log.WithError(err).Errorf("unable to get pod template of scaled %s %s", strings.ToLower(kind), req.Name)
+		return resp, nil
+	}
+
+	w := &workload{
+		kind:        kind,
+		name:        req.Name,
+		podSpec:     template.Spec,
+		annotations: template.Annotations,
+		labels:      template.Labels,
+		// labels of the scaled workload aren't looked up, its pod template labels usually contain them
+		objectLabels: template.Labels,
+		replicas:     int64(scale.Spec.Replicas),
+	}
+
+	limit := rra.conf.GetPodLimitByLabels(NameNamespace{
+		Name:      w.name,
+		Namespace: req.Namespace,
+	}, w.objectLabels, req.UserInfo.Groups...).ForLabels(w.labels).ForWorkloadClass(w.class()).ForRestartPolicy(w.podSpec.RestartPolicy).ForUserNamespace(w.podSpec.HostUsers)
+	if limit.Unlimited {
+		return resp, nil
+	}
+
+	if _, ok := limit.exemptUser(req.UserInfo); ok {
+		return resp, nil
+	}
+
+	if _, ok := limit.exemptToleration(w.podSpec); ok {
+		return resp, nil
+	}
+
+	limit = rra.approvedLimit(w, req.Namespace, limit)
+	if denyResp := validateReplicas(req, w, limit); denyResp != nil {
+		if limit.Mode == modeWarn {
+			return warnOnly(denyResp), nil
+		}
+
+		rra.logDenial(req, "denying scale of %s name: %s, namespace: %s to %d replicas, userInfo: %v", strings.ToLower(kind), w.name, req.Namespace, w.replicas, req.UserInfo)
+		return denyResp, nil
+	}
+
+	if denyResp := validateTopologySpread(req, w, limit); denyResp != nil {
+		switch {
+		case limit.Mode == modeWarn:
+			return warnOnly(denyResp), nil
+		case limit.TopologySpreadPolicy == topologySpreadWarn:
+			resp.Warnings = append(resp.Warnings, denyResp.Result.Message)
+		default:
+			rra.logDenial(req, "denying scale of %s name: %s, namespace: %s to %d replicas, userInfo: %v", strings.ToLower(kind), w.name, req.Namespace, w.replicas, req.UserInfo)
+			return denyResp, nil
+		}
+	}
+
+	if limit.Uncapped {
+		return resp, nil
+	}
+
+	var denyResp *v1beta1.AdmissionResponse
+	if quota {
+		denyResp = rra.validateQuota(req, w)
+	}
+	if denyResp == nil && cluster {
+		denyResp = rra.validateClusterCapacity(req, w)
+	}
+	if denyResp != nil {
+		rra.logDenial(req, "denying scale of %s name: %s, namespace: %s to %d replicas, userInfo: %v", strings.ToLower(kind), w.name, req.Namespace, w.replicas, req.UserInfo)
+		denyResp.Warnings = resp.Warnings
+		return denyResp, nil
+	}
+
+	return resp, nil
+}
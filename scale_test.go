@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/admission/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestScaleLookup(t *testing.T) {
+	client := fake.NewSimpleClientset(&appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+		Spec: appsv1.StatefulSetSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "postgres"}}},
+			},
+		},
+	})
+	sl := NewScaleLookup(client, NewIntegration("test_scales", DefaultIntegrationConfig()))
+
+	template, err := sl.GetPodTemplate(statefulsetKind, "default", "db")
+	assert.NoError(t, err)
+	assert.Equal(t, "postgres", template.Spec.Containers[0].Name)
+
+	_, err = sl.GetPodTemplate(deploymentKind, "default", "db")
+	assert.Error(t, err)
+}
+
+type mockScales map[string]*corev1.PodTemplateSpec
+
+func (ms mockScales) GetPodTemplate(kind, namespace, name string) (*corev1.PodTemplateSpec, error) {
+	template, ok := ms[name]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+
+	return template, nil
+}
+
+func TestHandleAdmissionScale(t *testing.T) {
+	scales := mockScales{
+		"web": &corev1.PodTemplateSpec{
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Name: "app",
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+				},
+			}}},
+		},
+	}
+	scale := func(replicas string) runtime.RawExtension {
+		return runtime.RawExtension{Raw: []byte(`{"apiVersion": "autoscaling/v1", "kind": "Scale", "metadata": {"name": "web"}, "spec": {"replicas": ` + replicas + `}}`)}
+	}
+
+	tests := []struct {
+		name      string
+		resource  string
+		workload  string
+		object    runtime.RawExtension
+		oldObject runtime.RawExtension
+		message   string
+	}{
+		{
+			name:      "scale up within quota percentage",
+			resource:  "deployments",
+			workload:  "web",
+			object:    scale("2"),
+			oldObject: scale("1"),
+		},
+		{
+			name:      "scale up over quota percentage",
+			resource:  "deployments",
+			workload:  "web",
+			object:    scale("3"),
+			oldObject: scale("1"),
+			message:   "error resourceQuota compute requests.cpu: 3 > 20% of 10",
+		},
+		{
+			name:      "scale down",
+			resource:  "deployments",
+			workload:  "web",
+			object:    scale("3"),
+			oldObject: scale("5"),
+		},
+		{
+			name:      "lookup fails",
+			resource:  "statefulsets",
+			workload:  "db",
+			object:    scale("10"),
+			oldObject: scale("1"),
+		},
+		{
+			name:      "replicasets are not handled",
+			resource:  "replicasets",
+			workload:  "web",
+			object:    scale("10"),
+			oldObject: scale("1"),
+		},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{
+			conf: &MockConfiger{},
+			opts: Options{
+				MaxQuotaPercent: 20,
+				Quotas:          mockQuotas{computeQuota},
+				Scales:          scales,
+			},
+		}
+
+		resp, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+			UID:         "e911857d-c318-11e8-bbad-025000000001",
+			Kind:        metav1.GroupVersionKind{Group: "autoscaling", Version: "v1", Kind: "Scale"},
+			Resource:    metav1.GroupVersionResource{Group: "apps", Version: "v1", Resource: tt.resource},
+			SubResource: "scale",
+			Name:        tt.workload,
+			Namespace:   "default",
+			Operation:   v1beta1.Update,
+			Object:      tt.object,
+			OldObject:   tt.oldObject,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.message == "", resp.Allowed, tt.name)
+		if tt.message != "" {
+			assert.Equal(t, tt.message, resp.Result.Message, tt.name)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const schemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// quantityFields are yaml keys of quantities, which may be written as yaml numbers (e.g. maxCPULimit: 2),
+// string maps (e.g. maxExtendedResources) hold quantities as values.
+var quantityFields = map[string]bool{
+	"maxCPULimit":               true,
+	"maxMemLimit":               true,
+	"maxCPURequest":             true,
+	"maxMemRequest":             true,
+	"maxExtendedResources":      true,
+	"maxEphemeralStorageLimit":  true,
+	"maxPodMemLimit":            true,
+	"maxGuaranteedCPU":          true,
+	"maxGuaranteedMem":          true,
+	"minCPURequest":             true,
+	"minMemRequest":             true,
+	"maxPVCSize":                true,
+	"minPVCSize":                true,
+	"maxPVCSizePerStorageClass": true,
+	"maxCPUBurst":               true,
+	"maxMemBurst":               true,
+	"maxNamespacePVCSize":       true,
+	"maxNamespaceGPUs":          true,
+	"cpuRequest":                true,
+	"memRequest":                true,
+	"cpuLimit":                  true,
+	"memLimit":                  true,
+}
+
+// ConfigSchema returns JSON Schema of the config file format, generated from Config yaml struct tags
+func ConfigSchema() map[string]interface{} {
+	definitions := make(map[string]interface{})
+	schema := structSchema(reflect.TypeOf(Config{}), definitions)
+	schema["$schema"] = schemaDraft
+	schema["title"] = "resource-requests-admission-controller config"
+	schema["definitions"] = definitions
+
+	return schema
+}
+
+// typeSchema returns schema of t, nested structs are added to definitions and referenced,
+// so that recursive types (e.g. Limit.Sidecar) terminate.
+func typeSchema(t reflect.Type, definitions map[string]interface{}) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem(), definitions)
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{
+			"type":  "array",
+			"items": typeSchema(t.Elem(), definitions),
+		}
+	case reflect.Map:
+		// struct keys (e.g. NameNamespace) are yaml flow mappings, which JSON Schema can't describe,
+		// so only values are validated.
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": typeSchema(t.Elem(), definitions),
+		}
+	case reflect.Struct:
+		if _, ok := definitions[t.Name()]; !ok {
+			// placeholder breaks recursion
+			definitions[t.Name()] = nil
+			definitions[t.Name()] = structSchema(t, definitions)
+		}
+
+		return map[string]interface{}{"$ref": "#/definitions/" + t.Name()}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func structSchema(t reflect.Type, definitions map[string]interface{}) map[string]interface{} {
+	properties := make(map[string]interface{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = f.Name
+		}
+
+		properties[name] = fieldSchema(name, f.Type, definitions)
+	}
+
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+// fieldSchema returns schema of the struct field with yaml key name
+func fieldSchema(name string, t reflect.Type, definitions map[string]interface{}) map[string]interface{} {
+	if !quantityFields[name] {
+		return typeSchema(t, definitions)
+	}
+
+	quantity := map[string]interface{}{"type": []string{"string", "number"}}
+	if t.Kind() == reflect.Map {
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": quantity,
+		}
+	}
+
+	return quantity
+}
+
+// ServeSchema serves config JSON Schema
+func ServeSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/schema+json")
+	if err := json.NewEncoder(w).Encode(ConfigSchema()); err != nil {
+		log.WithError(err).Error("unable to write schema response")
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestConfigSchema(t *testing.T) {
+	schema := ConfigSchema()
+
+	properties, ok := schema["properties"].(map[string]interface{})
+	if !ok {
+		t.Fatal("schema has no properties")
+	}
+
+	for _, key := range []string{"maxCPULimit", "maxMemLimit", "maxCPURequest", "maxMemRequest", "maxPVCSize", "customNamespaces", "customNames"} {
+		assert.Contains(t, properties, key)
+	}
+
+	names := properties["customNames"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"$ref": "#/definitions/Limit"}, names["additionalProperties"])
+
+	limit := schema["definitions"].(map[string]interface{})["Limit"].(map[string]interface{})
+	assert.Contains(t, limit["properties"], "unlimited")
+	assert.Contains(t, limit["properties"], "sidecar")
+}
+
+func TestConfigSchemaQuantities(t *testing.T) {
+	schema := ConfigSchema()
+	properties := schema["properties"].(map[string]interface{})
+
+	quantity := map[string]interface{}{"type": []string{"string", "number"}}
+	assert.Equal(t, quantity, properties["maxCPULimit"])
+	assert.Equal(t, quantity, properties["maxNamespaceGPUs"])
+	assert.Equal(t, map[string]interface{}{"type": "object", "additionalProperties": quantity}, properties["maxExtendedResources"])
+	assert.Equal(t, map[string]interface{}{"type": "string"}, properties["mode"])
+
+	profile := schema["definitions"].(map[string]interface{})["ResourceProfile"].(map[string]interface{})
+	assert.Equal(t, quantity, profile["properties"].(map[string]interface{})["cpuRequest"])
+}
+
+func TestConfigSchemaValidatesTestConfig(t *testing.T) {
+	schema := schemaJSON(t)
+
+	for _, file := range []string{"./testdata/test.yaml", "./testdata/policies.yaml", "./testdata/precedence.yaml"} {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Empty(t, validateSchema(schema, schema, configJSON(t, data), ""), file)
+	}
+
+	invalid := configJSON(t, []byte("maxCPULimit: [2]\ncustomNamespaces: {ml: {maxNamespaceGPUs: true, unknown: 1}}"))
+	assert.ElementsMatch(t, []string{
+		"/maxCPULimit: expected [string number]",
+		"/customNamespaces/ml/maxNamespaceGPUs: expected [string number]",
+		"/customNamespaces/ml/unknown: additional property",
+	}, validateSchema(schema, schema, invalid, ""))
+}
+
+// schemaJSON returns config schema as it's served
+func schemaJSON(t *testing.T) map[string]interface{} {
+	data, err := json.Marshal(ConfigSchema())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var schema map[string]interface{}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	return schema
+}
+
+// configJSON returns config file as JSON value, customNames keys are yaml mappings,
+// so they are decoded separately and formatted.
+func configJSON(t *testing.T, data []byte) map[string]interface{} {
+	var config struct {
+		Names  map[NameNamespace]interface{} `yaml:"customNames"`
+		Fields map[string]interface{}        `yaml:",inline"`
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		t.Fatal(err)
+	}
+
+	object := jsonValue(config.Fields).(map[string]interface{})
+	if config.Names != nil {
+		names := make(map[string]interface{}, len(config.Names))
+		for key, value := range config.Names {
+			names[fmt.Sprint(key)] = jsonValue(value)
+		}
+		object["customNames"] = names
+	}
+
+	return object
+}
+
+// jsonValue converts yaml mappings to JSON objects
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			object[key] = jsonValue(value)
+		}
+		return object
+	case map[interface{}]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			object[fmt.Sprint(key)] = jsonValue(value)
+		}
+		return object
+	case []interface{}:
+		array := make([]interface{}, len(v))
+		for i, value := range v {
+			array[i] = jsonValue(value)
+		}
+		return array
+	default:
+		return v
+	}
+}
+
+// validateSchema validates v against the subset of JSON Schema, which ConfigSchema generates,
+// and returns the violations.
+func validateSchema(root, schema map[string]interface{}, v interface{}, path string) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/definitions/")
+		return validateSchema(root, root["definitions"].(map[string]interface{})[name].(map[string]interface{}), v, path)
+	}
+
+	if typ, ok := schema["type"]; ok && !schemaTypeMatches(typ, v) {
+		return []string{fmt.Sprintf("%s: expected %v", path, typ)}
+	}
+
+	var violations []string
+	switch v := v.(type) {
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		for key, value := range v {
+			if property, ok := properties[key].(map[string]interface{}); ok {
+				violations = append(violations, validateSchema(root, property, value, path+"/"+key)...)
+				continue
+			}
+
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					violations = append(violations, fmt.Sprintf("%s/%s: additional property", path, key))
+				}
+			case map[string]interface{}:
+				violations = append(violations, validateSchema(root, additional, value, path+"/"+key)...)
+			}
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, value := range v {
+				violations = append(violations, validateSchema(root, items, value, fmt.Sprintf("%s/%d", path, i))...)
+			}
+		}
+	}
+
+	return violations
+}
+
+func schemaTypeMatches(typ interface{}, v interface{}) bool {
+	if types, ok := typ.([]interface{}); ok {
+		for _, t := range types {
+			if schemaTypeMatches(t, v) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch v.(type) {
+	case nil:
+		return typ == "null"
+	case string:
+		return typ == "string"
+	case bool:
+		return typ == "boolean"
+	case int:
+		return typ == "integer" || typ == "number"
+	case float64:
+		return typ == "number"
+	case map[string]interface{}:
+		return typ == "object"
+	case []interface{}:
+		return typ == "array"
+	default:
+		return false
+	}
+}
+
+func TestServeSchema(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(ServeSchema))
+	defer server.Close()
+
+	r, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Body.Close()
+
+	var schema map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&schema); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, r.StatusCode)
+	assert.Equal(t, schemaDraft, schema["$schema"])
+	assert.Contains(t, schema["properties"], "maxCPULimit")
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateSelfSignedCert(t *testing.T) {
+	cert, err := generateSelfSignedCert([]string{"localhost", "127.0.0.1"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewUnstartedServer(&AdmissionControllerServer{
+		AdmissionController: &ResourceRequestsAdmission{conf: &MockConfiger{}},
+		Decoder:             codecs.UniversalDeserializer(),
+	})
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	server.StartTLS()
+	defer server.Close()
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	review := podReview(`{"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}}}]}`)
+	resp, err := client.Post(server.URL, "application/json", strings.NewReader(string(encodeRequest(t, review))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	response := decodeResponse(t, resp.Body)
+	assert.True(t, response.Response.Allowed)
+}
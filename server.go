@@ -1,52 +1,155 @@
 package main
 
 import (
+	"context"
 	"io/ioutil"
-
 	"net/http"
+	"time"
 
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	log "github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
 	"k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/json"
 )
 
 // AdmissionController makes admission decisions
 type AdmissionController interface {
-	HandleAdmission(review *v1beta1.AdmissionRequest) (*v1beta1.AdmissionResponse, error)
+	HandleAdmission(ctx context.Context, review *v1beta1.AdmissionRequest) (*v1beta1.AdmissionResponse, error)
 }
 
 // AdmissionControllerServer is an HTTP server which unmarshals json and passes to AdmissionController
 type AdmissionControllerServer struct {
 	AdmissionController AdmissionController
 	Decoder             runtime.Decoder
+	// ForbidNonReview responds with generic 403 Status instead of decode error, if request is not an AdmissionReview
+	ForbidNonReview bool
+	// LatencyBudget is deadline of AdmissionController context, so that it responds early, if admission doesn't finish in time, 0 disables it.
+	// Budget is shortened to API server's webhook timeout (timeout query parameter) minus latencyBudgetMargin.
+	LatencyBudget time.Duration
+	// MaxRequestBytes limits size of request body, larger requests get 413 without being decoded, 0 disables it
+	MaxRequestBytes int64
+}
+
+// latencyBudgetMargin is reserved for writing response before API server's webhook timeout
+const latencyBudgetMargin = 100 * time.Millisecond
+
+// malformedReviewsCounter counts requests, which can't be decoded or have no AdmissionReview request
+var malformedReviewsCounter = promauto.NewCounter(prometheus.CounterOpts{Name: "malformed_admission_reviews_total"})
+
+var nonReviewStatus = metav1.Status{
+	TypeMeta: metav1.TypeMeta{
+		Kind:       "Status",
+		APIVersion: "v1",
+	},
+	Status:  metav1.StatusFailure,
+	Message: "request is not an AdmissionReview",
+	Reason:  metav1.StatusReasonForbidden,
+	Code:    http.StatusForbidden,
 }
 
 // ServeHTTP serves HTTP request
 func (acs *AdmissionControllerServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	var body []byte
-	if data, err := ioutil.ReadAll(r.Body); err == nil {
-		body = data
+	reqBody := r.Body
+	if acs.MaxRequestBytes > 0 {
+		reqBody = http.MaxBytesReader(w, r.Body, acs.MaxRequestBytes)
+	}
+
+	body, readErr := ioutil.ReadAll(reqBody)
+	var tooLarge *http.MaxBytesError
+	if errors.As(readErr, &tooLarge) {
+		malformedReviewsCounter.Inc()
+		log.Errorf("request body exceeds %d bytes", tooLarge.Limit)
+		http.Error(w, readErr.Error(), http.StatusRequestEntityTooLarge)
+		return
 	}
 	log.WithField("req", string(body)).Debug("handling request")
 
-	review := &v1beta1.AdmissionReview{}
+	// admission.k8s.io/v1 reviews are converted to v1beta1, reviews without apiVersion are handled as v1beta1
+	var typeMeta metav1.TypeMeta
+	_ = json.Unmarshal(body, &typeMeta)
 
-	_, _, err := acs.Decoder.Decode(body, nil, review)
-	if err != nil {
-		log.WithError(err).Error("unable to decode request")
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	var (
+		request   *v1beta1.AdmissionRequest
+		respond   func(resp *v1beta1.AdmissionResponse) interface{}
+		decodeErr error
+	)
+	switch typeMeta.APIVersion {
+	case admissionv1.SchemeGroupVersion.String():
+		review := &admissionv1.AdmissionReview{}
+		_, _, decodeErr = acs.Decoder.Decode(body, nil, review)
+		if review.Request != nil {
+			request = v1beta1Request(review.Request)
+		}
+		respond = func(resp *v1beta1.AdmissionResponse) interface{} {
+			review.TypeMeta = reviewTypeMeta(admissionv1.SchemeGroupVersion.String())
+			review.Response = v1Response(resp)
+			return review
+		}
+	default:
+		review := &v1beta1.AdmissionReview{}
+		_, _, decodeErr = acs.Decoder.Decode(body, nil, review)
+		request = review.Request
+		respond = func(resp *v1beta1.AdmissionResponse) interface{} {
+			review.TypeMeta = reviewTypeMeta(v1beta1.SchemeGroupVersion.String())
+			review.Response = resp
+			return review
+		}
+	}
+
+	// API server expects AdmissionReview, errors would be logged by it as opaque webhook failure
+	if readErr != nil {
+		malformedReviewsCounter.Inc()
+		log.WithError(readErr).Error("unable to read request")
+		writeReview(w, respond(badRequestResponse(partialUID(body), "unable to read AdmissionReview: "+readErr.Error())))
+		return
+	}
+
+	if decodeErr != nil {
+		malformedReviewsCounter.Inc()
+		log.WithError(decodeErr).Error("unable to decode request")
+		if acs.ForbidNonReview {
+			writeStatus(w, nonReviewStatus)
+			return
+		}
+
+		writeReview(w, respond(badRequestResponse(partialUID(body), "unable to decode AdmissionReview: "+decodeErr.Error())))
 		return
 	}
 
-	resp, err := acs.AdmissionController.HandleAdmission(review.Request)
+	if request == nil {
+		malformedReviewsCounter.Inc()
+		log.Error("AdmissionReview request is empty")
+		if acs.ForbidNonReview {
+			writeStatus(w, nonReviewStatus)
+			return
+		}
+
+		writeReview(w, respond(badRequestResponse(partialUID(body), "AdmissionReview request is empty")))
+		return
+	}
+
+	resp, err := acs.handleAdmission(r, request)
 	if err != nil {
 		log.WithError(err).Error("unable to handle admission request")
 		http.Error(w, err.Error(), http.StatusServiceUnavailable)
 		return
 	}
 
-	review.Response = resp
+	if verdicts, ok := resp.AuditAnnotations[containerVerdictsAnnotation]; ok {
+		w.Header().Set(containerVerdictsHeader, verdicts)
+	}
+
+	writeReview(w, respond(resp))
+}
+
+// writeReview writes AdmissionReview response
+func writeReview(w http.ResponseWriter, review interface{}) {
 	responseInBytes, err := json.Marshal(review)
 	if err != nil {
 		log.WithError(err).Error("unable to marshal response")
@@ -61,3 +164,110 @@ func (acs *AdmissionControllerServer) ServeHTTP(w http.ResponseWriter, r *http.R
 		return
 	}
 }
+
+// badRequestResponse returns failed response to review, which can't be read or decoded
+func badRequestResponse(uid types.UID, message string) *v1beta1.AdmissionResponse {
+	return &v1beta1.AdmissionResponse{
+		UID:     uid,
+		Allowed: false,
+		Result: &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Message: message,
+			Reason:  metav1.StatusReasonBadRequest,
+			Code:    http.StatusBadRequest,
+		},
+	}
+}
+
+// partialUID returns request UID of review, which can't be decoded, e.g. due to a field of wrong type,
+// so that API server can match the response. It's empty if body isn't a JSON object.
+func partialUID(body []byte) types.UID {
+	var review struct {
+		Request struct {
+			UID types.UID `json:"uid"`
+		} `json:"request"`
+	}
+	// type errors of other fields are ignored, since unmarshal continues after them
+	_ = json.Unmarshal(body, &review)
+
+	return review.Request.UID
+}
+
+// handleAdmission handles request within latency budget, if it's enabled
+func (acs *AdmissionControllerServer) handleAdmission(r *http.Request, req *v1beta1.AdmissionRequest) (*v1beta1.AdmissionResponse, error) {
+	if acs.LatencyBudget <= 0 {
+		return acs.AdmissionController.HandleAdmission(r.Context(), req)
+	}
+
+	budget := acs.LatencyBudget
+	if timeout, err := time.ParseDuration(r.URL.Query().Get("timeout")); err == nil && timeout-latencyBudgetMargin < budget {
+		budget = timeout - latencyBudgetMargin
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), budget)
+	defer cancel()
+
+	return acs.AdmissionController.HandleAdmission(ctx, req)
+}
+
+// reviewTypeMeta returns TypeMeta of AdmissionReview response, v1 API servers reject responses without it
+func reviewTypeMeta(apiVersion string) metav1.TypeMeta {
+	return metav1.TypeMeta{
+		APIVersion: apiVersion,
+		Kind:       "AdmissionReview",
+	}
+}
+
+// v1beta1Request converts admission.k8s.io/v1 request to v1beta1, which AdmissionController handles
+func v1beta1Request(req *admissionv1.AdmissionRequest) *v1beta1.AdmissionRequest {
+	return &v1beta1.AdmissionRequest{
+		UID:                req.UID,
+		Kind:               req.Kind,
+		Resource:           req.Resource,
+		SubResource:        req.SubResource,
+		RequestKind:        req.RequestKind,
+		RequestResource:    req.RequestResource,
+		RequestSubResource: req.RequestSubResource,
+		Name:               req.Name,
+		Namespace:          req.Namespace,
+		Operation:          v1beta1.Operation(req.Operation),
+		UserInfo:           req.UserInfo,
+		Object:             req.Object,
+		OldObject:          req.OldObject,
+		DryRun:             req.DryRun,
+		Options:            req.Options,
+	}
+}
+
+// v1Response converts v1beta1 response to admission.k8s.io/v1
+func v1Response(resp *v1beta1.AdmissionResponse) *admissionv1.AdmissionResponse {
+	out := &admissionv1.AdmissionResponse{
+		UID:              resp.UID,
+		Allowed:          resp.Allowed,
+		Result:           resp.Result,
+		Patch:            resp.Patch,
+		AuditAnnotations: resp.AuditAnnotations,
+		Warnings:         resp.Warnings,
+	}
+	if resp.PatchType != nil {
+		patchType := admissionv1.PatchType(*resp.PatchType)
+		out.PatchType = &patchType
+	}
+
+	return out
+}
+
+func writeStatus(w http.ResponseWriter, status metav1.Status) {
+	body, err := json.Marshal(status)
+	if err != nil {
+		log.WithError(err).Error("unable to marshal status")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(int(status.Code))
+	if _, err := w.Write(body); err != nil {
+		log.WithError(err).Error("unable to write status")
+	}
+}
@@ -1,20 +1,35 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
 
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"k8s.io/api/admission/v1beta1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 var (
@@ -78,6 +93,54 @@ var (
 	}
 )
 
+var updateGolden = flag.Bool("update", false, "update golden files in testdata/reviews")
+
+func pvcReview(size string) *v1beta1.AdmissionReview {
+	return &v1beta1.AdmissionReview{
+		TypeMeta: v1.TypeMeta{
+			Kind: "AdmissionReview",
+		},
+		Request: &v1beta1.AdmissionRequest{
+			UID: "e911857d-c318-11e8-bbad-025000000001",
+			Kind: v1.GroupVersionKind{
+				Kind: "PersistentVolumeClaim",
+			},
+			Operation: "CREATE",
+			Object: runtime.RawExtension{
+				Raw: []byte(`{"metadata": {"name": "test"}, "spec": {"resources": {"requests": {"storage": "` + size + `"}}}}`),
+			},
+		},
+	}
+}
+
+func podReview(spec string) *v1beta1.AdmissionReview {
+	return &v1beta1.AdmissionReview{
+		TypeMeta: v1.TypeMeta{
+			Kind: "AdmissionReview",
+		},
+		Request: &v1beta1.AdmissionRequest{
+			UID: "e911857d-c318-11e8-bbad-025000000001",
+			Kind: v1.GroupVersionKind{
+				Kind: "Pod",
+			},
+			Operation: "CREATE",
+			Object: runtime.RawExtension{
+				Raw: []byte(`{"metadata": {"name": "test"}, "spec": ` + spec + `}`),
+			},
+		},
+	}
+}
+
+func postReview(t *testing.T, url string, review *v1beta1.AdmissionReview) *v1beta1.AdmissionReview {
+	r, err := http.Post(url, "application/json", strings.NewReader(string(encodeRequest(t, review))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Body.Close()
+
+	return decodeResponse(t, r.Body)
+}
+
 func decodeResponse(t *testing.T, body io.ReadCloser) *v1beta1.AdmissionReview {
 	response, err := ioutil.ReadAll(body)
 	if err != nil {
@@ -101,7 +164,7 @@ func encodeRequest(t *testing.T, review *v1beta1.AdmissionReview) []byte {
 
 func TestServeReturnsCorrectJson(t *testing.T) {
 	conf := &MockConfiger{}
-	rra := &ResourceRequestsAdmission{conf}
+	rra := &ResourceRequestsAdmission{conf: conf}
 	server := httptest.NewServer(&AdmissionControllerServer{
 		AdmissionController: rra,
 		Decoder:             codecs.UniversalDeserializer(),
@@ -120,6 +183,79 @@ func TestServeReturnsCorrectJson(t *testing.T) {
 	assert.Equal(t, review.Response.Allowed, true)
 }
 
+func TestServeAdmissionReviewVersions(t *testing.T) {
+	cpu := resource.MustParse("1")
+	conf := &MockConfiger{cpu: &cpu}
+	server := httptest.NewServer(&AdmissionControllerServer{
+		AdmissionController: &ResourceRequestsAdmission{conf: conf},
+		Decoder:             codecs.UniversalDeserializer(),
+	})
+	defer server.Close()
+
+	review := func(apiVersion, cpu string) string {
+		return `{"apiVersion": "` + apiVersion + `", "kind": "AdmissionReview", "request": {"uid": "e911857d-c318-11e8-bbad-025000000001",
+			"kind": {"version": "v1", "kind": "Pod"}, "resource": {"version": "v1", "resource": "pods"}, "namespace": "default", "operation": "CREATE",
+			"object": {"metadata": {"name": "test"}, "spec": {"containers": [{"name": "app",
+				"resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"cpu": "` + cpu + `"}}}]}}}}`
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		apiVersion string
+		allowed    bool
+	}{
+		{
+			name:       "v1 allowed",
+			body:       review("admission.k8s.io/v1", "1"),
+			apiVersion: "admission.k8s.io/v1",
+			allowed:    true,
+		},
+		{
+			name:       "v1 denied",
+			body:       review("admission.k8s.io/v1", "2"),
+			apiVersion: "admission.k8s.io/v1",
+		},
+		{
+			name:       "v1beta1 allowed",
+			body:       review("admission.k8s.io/v1beta1", "1"),
+			apiVersion: "admission.k8s.io/v1beta1",
+			allowed:    true,
+		},
+		{
+			name:       "v1beta1 denied",
+			body:       review("admission.k8s.io/v1beta1", "2"),
+			apiVersion: "admission.k8s.io/v1beta1",
+		},
+	}
+
+	for _, tt := range tests {
+		r, err := http.Post(server.URL, "application/json", strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var resp struct {
+			APIVersion string `json:"apiVersion"`
+			Kind       string `json:"kind"`
+			Response   struct {
+				UID     string `json:"uid"`
+				Allowed bool   `json:"allowed"`
+			} `json:"response"`
+		}
+		err = json.NewDecoder(r.Body).Decode(&resp)
+		r.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.apiVersion, resp.APIVersion, tt.name)
+		assert.Equal(t, "AdmissionReview", resp.Kind, tt.name)
+		assert.Equal(t, "e911857d-c318-11e8-bbad-025000000001", resp.Response.UID, tt.name)
+		assert.Equal(t, tt.allowed, resp.Response.Allowed, tt.name)
+	}
+}
+
 func TestServePodUnderRestrictionsReturnsCorrectJson(t *testing.T) {
 	cpu := resource.MustParse("3")
 	mem := resource.MustParse("3Gi")
@@ -129,7 +265,7 @@ func TestServePodUnderRestrictionsReturnsCorrectJson(t *testing.T) {
 		cpuRequest: &cpu,
 		memRequest: &mem,
 	}
-	rra := &ResourceRequestsAdmission{conf}
+	rra := &ResourceRequestsAdmission{conf: conf}
 	server := httptest.NewServer(&AdmissionControllerServer{
 		AdmissionController: rra,
 		Decoder:             codecs.UniversalDeserializer(),
@@ -155,7 +291,7 @@ func TestServePodOverRequestReturnsCorrectJson(t *testing.T) {
 		cpuRequest: &cpu,
 		memRequest: &mem,
 	}
-	rra := &ResourceRequestsAdmission{conf}
+	rra := &ResourceRequestsAdmission{conf: conf}
 	server := httptest.NewServer(&AdmissionControllerServer{
 		AdmissionController: rra,
 		Decoder:             codecs.UniversalDeserializer(),
@@ -181,7 +317,7 @@ func TestServePodOverLimitReturnsCorrectJson(t *testing.T) {
 		cpu: &cpu,
 		mem: &mem,
 	}
-	rra := &ResourceRequestsAdmission{conf}
+	rra := &ResourceRequestsAdmission{conf: conf}
 	server := httptest.NewServer(&AdmissionControllerServer{
 		AdmissionController: rra,
 		Decoder:             codecs.UniversalDeserializer(),
@@ -204,7 +340,7 @@ func TestServePodUnlimitedReturnsCorrectJson(t *testing.T) {
 	conf := &MockConfiger{
 		unlimited: true,
 	}
-	rra := &ResourceRequestsAdmission{conf}
+	rra := &ResourceRequestsAdmission{conf: conf}
 	server := httptest.NewServer(&AdmissionControllerServer{
 		AdmissionController: rra,
 		Decoder:             codecs.UniversalDeserializer(),
@@ -224,20 +360,147 @@ func TestServePodUnlimitedReturnsCorrectJson(t *testing.T) {
 }
 
 type MockConfiger struct {
-	cpu        *resource.Quantity
-	mem        *resource.Quantity
-	cpuRequest *resource.Quantity
-	memRequest *resource.Quantity
-	pvcSize    *resource.Quantity
-	unlimited  bool
+	cpu          *resource.Quantity
+	mem          *resource.Quantity
+	cpuRequest   *resource.Quantity
+	memRequest   *resource.Quantity
+	storage      *resource.Quantity
+	pvcSize      *resource.Quantity
+	pvcClasses   map[string]*resource.Quantity
+	pvcMinSize   *resource.Quantity
+	pvcNsSize    *resource.Quantity
+	nsGPUs       *resource.Quantity
+	extended     map[corev1.ResourceName]*resource.Quantity
+	unlimited    bool
+	unlimitedPVC bool
+	uncapped     bool
+	sidecar      *LimitResource
+	severity     map[corev1.ResourceName]string
+	paired       []corev1.ResourceName
+	noEphemeral  bool
+	cpuBurst     *resource.Quantity
+	memBurst     *resource.Quantity
+	maxClaims    *int
+	maxRes       *int
+	maxReplicas  *int
+	spread       *int
+	spreadPolicy string
+	action       string
+	classes      []string
+	cpuPolicy    string
+	swapPolicy   string
+	restarts     map[corev1.RestartPolicy]LimitResource
+	workloads    map[string]LimitResource
+	probes       []string
+	probeExempt  []string
+	exemptTaints []string
+	exemptUsers  []*regexp.Regexp
+	exemptGroups []string
+	containers   map[string]LimitResource
+	userns       *LimitResource
+	imageMem     []imageMemLimit
+	minCPU       *resource.Quantity
+	minMem       *resource.Quantity
+	ratio        *float64
+	zeroRatio    string
+	mode         string
+	fraction     *float64
+	tolerance    float64
+	mutate       bool
+	podMem       *resource.Quantity
+	emptyDirs    bool
+	reqLimits    bool
+	labels       []labelLimit
+	gCPU         *resource.Quantity
+	gMem         *resource.Quantity
+	profiles     map[string]resourceProfile
+
+	lookups      []NameNamespace
+	groups       []string
+	objectLabels map[string]string
+}
+
+func (mc *MockConfiger) GetPodLimitByLabels(nn NameNamespace, labels map[string]string, groups ...string) LimitResource {
+	mc.objectLabels = labels
+	return mc.GetPodLimit(nn, groups...)
+}
+
+func (mc *MockConfiger) GetPodLimit(nn NameNamespace, groups ...string) LimitResource {
+	mc.lookups = append(mc.lookups, nn)
+	mc.groups = groups
+	return LimitResource{
+		CPULimit:                 mc.cpu,
+		MemLimit:                 mc.mem,
+		CPURequest:               mc.cpuRequest,
+		MemRequest:               mc.memRequest,
+		EphemeralStorageLimit:    mc.storage,
+		ExtendedResources:        mc.extended,
+		MinCPURequest:            mc.minCPU,
+		MinMemRequest:            mc.minMem,
+		MaxLimitRequestRatio:     mc.ratio,
+		ZeroRequestRatio:         mc.zeroRatio,
+		Mode:                     mc.mode,
+		Mutate:                   mc.mutate,
+		PodMemLimit:              mc.podMem,
+		GuaranteedCPU:            mc.gCPU,
+		GuaranteedMem:            mc.gMem,
+		CountMemoryEmptyDirs:     mc.emptyDirs,
+		RequireLimits:            mc.reqLimits,
+		RequestFraction:          mc.fraction,
+		RequestFractionTolerance: mc.tolerance,
+		CPUBurst:                 mc.cpuBurst,
+		MemBurst:                 mc.memBurst,
+		MaxResourceClaims:        mc.maxClaims,
+		MaxContainerResources:    mc.maxRes,
+		MaxReplicas:              mc.maxReplicas,
+		TopologySpreadReplicas:   mc.spread,
+		TopologySpreadPolicy:     mc.spreadPolicy,
+		DefaultAction:            mc.action,
+		AllowedDeviceClasses:     mc.classes,
+		CPULimitPolicy:           mc.cpuPolicy,
+		SwapPolicy:               mc.swapPolicy,
+		Unlimited:                mc.unlimited,
+		Uncapped:                 mc.uncapped,
+		Sidecar:                  mc.sidecar,
+		Severity:                 mc.severity,
+		PairedResources:          mc.paired,
+		RestartPolicies:          mc.restarts,
+		WorkloadClasses:          mc.workloads,
+		LabelLimits:              mc.labels,
+		RequiredProbes:           mc.probes,
+		ProbeExemptContainers:    mc.probeExempt,
+		ExemptTolerations:        mc.exemptTaints,
+		ExemptUsers:              mc.exemptUsers,
+		ExemptGroups:             mc.exemptGroups,
+		Containers:               mc.containers,
+		UserNamespace:            mc.userns,
+		ImageMemLimits:           mc.imageMem,
+		Profiles:                 mc.profiles,
+
+		ForbidEphemeralContainers: mc.noEphemeral,
+	}
+}
+
+func (mc *MockConfiger) GetMaxStorageClassPVCSize(nn NameNamespace, storageClass *string) (pvc *resource.Quantity, unlimited bool) {
+	if storageClass != nil {
+		if size, ok := mc.pvcClasses[*storageClass]; ok {
+			return size, mc.unlimitedPVC
+		}
+	}
+
+	return mc.pvcSize, mc.unlimitedPVC
+}
+
+func (mc *MockConfiger) GetMaxNamespaceGPUs(namespace string) (gpus *resource.Quantity, unlimited bool) {
+	return mc.nsGPUs, mc.unlimited
 }
 
-func (mc *MockConfiger) GetPodLimit(nn NameNamespace) (cpu, mem, cpuRequest, memRequest *resource.Quantity, unlimited bool) {
-	return mc.cpu, mc.mem, mc.cpuRequest, mc.memRequest, mc.unlimited
+func (mc *MockConfiger) GetMaxNamespacePVCSize(namespace string) (pvc *resource.Quantity, unlimited bool) {
+	return mc.pvcNsSize, mc.unlimitedPVC
 }
 
-func (mc *MockConfiger) GetMaxPVCSize(nn NameNamespace) (pvc *resource.Quantity, unlimited bool) {
-	return mc.pvcSize, mc.unlimited
+func (mc *MockConfiger) GetMinPVCSize(nn NameNamespace) (pvc *resource.Quantity, unlimited bool) {
+	return mc.pvcMinSize, mc.unlimitedPVC
 }
 
 func TestCompareMemoryQuantity(t *testing.T) {
@@ -261,3 +524,2714 @@ func TestCompareCPUQuantity(t *testing.T) {
 	fmt.Printf("%v, %v", q1, q2)
 	assert.True(t, q1.Cmp(q2) < 0)
 }
+
+func TestServePVCMinSize(t *testing.T) {
+	min := resource.MustParse("1Gi")
+	max := resource.MustParse("10Gi")
+	conf := &MockConfiger{
+		pvcSize:    &max,
+		pvcMinSize: &min,
+	}
+	rra := &ResourceRequestsAdmission{conf: conf}
+	server := httptest.NewServer(&AdmissionControllerServer{
+		AdmissionController: rra,
+		Decoder:             codecs.UniversalDeserializer(),
+	})
+
+	tests := []struct {
+		size    string
+		allowed bool
+	}{
+		{size: "100Mi", allowed: false},
+		{size: "1Gi", allowed: true},
+		{size: "5Gi", allowed: true},
+		{size: "20Gi", allowed: false},
+	}
+
+	for _, tt := range tests {
+		r, err := http.Post(server.URL, "application/json", strings.NewReader(string(encodeRequest(t, pvcReview(tt.size)))))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		review := decodeResponse(t, r.Body)
+		assert.Equal(t, tt.allowed, review.Response.Allowed, tt.size)
+	}
+}
+
+func TestServeSidecarLimit(t *testing.T) {
+	cpu := resource.MustParse("4")
+	sidecarCPU := resource.MustParse("500m")
+	conf := &MockConfiger{
+		cpu: &cpu,
+		sidecar: &LimitResource{
+			CPULimit: &sidecarCPU,
+		},
+	}
+	rra := &ResourceRequestsAdmission{conf: conf}
+	server := httptest.NewServer(&AdmissionControllerServer{
+		AdmissionController: rra,
+		Decoder:             codecs.UniversalDeserializer(),
+	})
+
+	tests := []struct {
+		name    string
+		spec    string
+		allowed bool
+	}{
+		{
+			name:    "regular init container uses container limit",
+			spec:    `{"initContainers": [{"name": "init", "resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"cpu": 2}}}]}`,
+			allowed: true,
+		},
+		{
+			name:    "native sidecar within sidecar limit",
+			spec:    `{"initContainers": [{"name": "proxy", "restartPolicy": "Always", "resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"cpu": "500m"}}}]}`,
+			allowed: true,
+		},
+		{
+			name:    "native sidecar over sidecar limit",
+			spec:    `{"initContainers": [{"name": "proxy", "restartPolicy": "Always", "resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"cpu": 2}}}]}`,
+			allowed: false,
+		},
+		{
+			name:    "regular container uses container limit",
+			spec:    `{"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"cpu": 2}}}]}`,
+			allowed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		review := postReview(t, server.URL, podReview(tt.spec))
+		assert.Equal(t, tt.allowed, review.Response.Allowed, tt.name)
+	}
+}
+
+func TestServeSeverity(t *testing.T) {
+	cpu := resource.MustParse("1")
+	mem := resource.MustParse("1Gi")
+	server := func(severity map[corev1.ResourceName]string) *httptest.Server {
+		conf := &MockConfiger{
+			cpu:      &cpu,
+			mem:      &mem,
+			severity: severity,
+		}
+		return httptest.NewServer(&AdmissionControllerServer{
+			AdmissionController: &ResourceRequestsAdmission{conf: conf},
+			Decoder:             codecs.UniversalDeserializer(),
+		})
+	}
+
+	cpuOver := `{"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"cpu": 2, "memory": "512Mi"}}}]}`
+	cpuAndMemOver := `{"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"cpu": 2, "memory": "2Gi"}}}]}`
+
+	warnCPU := server(map[corev1.ResourceName]string{corev1.ResourceCPU: severityWarn, corev1.ResourceMemory: severityDeny})
+
+	review := postReview(t, warnCPU.URL, podReview(cpuOver))
+	assert.True(t, review.Response.Allowed)
+	assert.Equal(t, []string{"error container app limits.CPU: 2 > 1"}, review.Response.Warnings)
+
+	review = postReview(t, warnCPU.URL, podReview(cpuAndMemOver))
+	assert.False(t, review.Response.Allowed)
+	assert.Equal(t, "error container app limits.Memory: 2Gi > 1Gi", review.Response.Result.Message)
+	assert.Equal(t, []string{"error container app limits.CPU: 2 > 1"}, review.Response.Warnings)
+
+	review = postReview(t, server(nil).URL, podReview(cpuOver))
+	assert.False(t, review.Response.Allowed)
+	assert.Equal(t, "error container app limits.CPU: 2 > 1", review.Response.Result.Message)
+}
+
+func TestServeNonReview(t *testing.T) {
+	tests := []struct {
+		forbidNonReview bool
+		body            string
+		code            int
+		message         string
+	}{
+		// JSON object is decoded as AdmissionReview without request
+		{forbidNonReview: false, body: `{"hello": "world"}`, code: http.StatusOK, message: "AdmissionReview request is empty"},
+		{forbidNonReview: false, body: `hello world`, code: http.StatusOK, message: "unable to decode AdmissionReview: "},
+		{forbidNonReview: true, body: `{"hello": "world"}`, code: http.StatusForbidden},
+		{forbidNonReview: true, body: `hello world`, code: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		testServeNonReview(t, tt.forbidNonReview, tt.code, tt.body, tt.message)
+	}
+}
+
+func testServeNonReview(t *testing.T, forbidNonReview bool, code int, reqBody, message string) {
+	server := httptest.NewServer(&AdmissionControllerServer{
+		AdmissionController: &ResourceRequestsAdmission{conf: &MockConfiger{}},
+		Decoder:             codecs.UniversalDeserializer(),
+		ForbidNonReview:     forbidNonReview,
+	})
+	defer server.Close()
+
+	r, err := http.Post(server.URL, "application/json", strings.NewReader(reqBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Body.Close()
+
+	assert.Equal(t, code, r.StatusCode, reqBody)
+	if forbidNonReview {
+		var status v1.Status
+		if err := json.Unmarshal(body, &status); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, "Status", status.Kind, reqBody)
+		assert.Equal(t, "v1", status.APIVersion, reqBody)
+		assert.Equal(t, v1.StatusFailure, status.Status, reqBody)
+		assert.Equal(t, int32(http.StatusForbidden), status.Code, reqBody)
+		assert.Equal(t, v1.StatusReasonForbidden, status.Reason, reqBody)
+		assert.Equal(t, "request is not an AdmissionReview", status.Message, reqBody)
+		return
+	}
+
+	var review v1beta1.AdmissionReview
+	if err := json.Unmarshal(body, &review); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.False(t, review.Response.Allowed, reqBody)
+	assert.Equal(t, v1.StatusFailure, review.Response.Result.Status, reqBody)
+	assert.Contains(t, review.Response.Result.Message, message, reqBody)
+}
+
+func TestServeDecodeError(t *testing.T) {
+	server := httptest.NewServer(&AdmissionControllerServer{
+		AdmissionController: &ResourceRequestsAdmission{conf: &MockConfiger{}},
+		Decoder:             codecs.UniversalDeserializer(),
+	})
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		body       string
+		apiVersion string
+		uid        types.UID
+	}{
+		{
+			name:       "garbage",
+			body:       `not an admission review`,
+			apiVersion: "admission.k8s.io/v1beta1",
+		},
+		{
+			name:       "v1 field of wrong type",
+			body:       `{"kind": "AdmissionReview", "apiVersion": "admission.k8s.io/v1", "request": {"uid": "e911857d-c318-11e8-bbad-025000000001", "operation": 5}}`,
+			apiVersion: "admission.k8s.io/v1",
+			uid:        "e911857d-c318-11e8-bbad-025000000001",
+		},
+		{
+			name:       "v1beta1 field of wrong type",
+			body:       `{"kind": "AdmissionReview", "apiVersion": "admission.k8s.io/v1beta1", "request": {"uid": "e911857d-c318-11e8-bbad-025000000001", "object": 5, "dryRun": "yes"}}`,
+			apiVersion: "admission.k8s.io/v1beta1",
+			uid:        "e911857d-c318-11e8-bbad-025000000001",
+		},
+	}
+
+	for _, tt := range tests {
+		malformed := testutil.ToFloat64(malformedReviewsCounter)
+
+		r, err := http.Post(server.URL, "application/json", strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var review v1beta1.AdmissionReview
+		if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+			t.Fatal(err)
+		}
+		r.Body.Close()
+
+		assert.Equal(t, http.StatusOK, r.StatusCode, tt.name)
+		assert.Equal(t, tt.apiVersion, review.APIVersion, tt.name)
+		assert.Equal(t, "AdmissionReview", review.Kind, tt.name)
+		assert.Equal(t, tt.uid, review.Response.UID, tt.name)
+		assert.False(t, review.Response.Allowed, tt.name)
+		assert.Equal(t, v1.StatusFailure, review.Response.Result.Status, tt.name)
+		assert.Equal(t, v1.StatusReasonBadRequest, review.Response.Result.Reason, tt.name)
+		assert.Contains(t, review.Response.Result.Message, "unable to decode AdmissionReview: ", tt.name)
+		assert.Equal(t, malformed+1, testutil.ToFloat64(malformedReviewsCounter), tt.name)
+	}
+}
+
+func TestServeEmptyRequest(t *testing.T) {
+	server := httptest.NewServer(&AdmissionControllerServer{
+		AdmissionController: &ResourceRequestsAdmission{conf: &MockConfiger{}},
+		Decoder:             codecs.UniversalDeserializer(),
+	})
+	defer server.Close()
+
+	for _, apiVersion := range []string{"admission.k8s.io/v1beta1", "admission.k8s.io/v1"} {
+		malformed := testutil.ToFloat64(malformedReviewsCounter)
+
+		reqBody := `{"kind": "AdmissionReview", "apiVersion": "` + apiVersion + `"}`
+		r, err := http.Post(server.URL, "application/json", strings.NewReader(reqBody))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var review v1beta1.AdmissionReview
+		if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+			t.Fatal(err)
+		}
+		r.Body.Close()
+
+		// API server expects AdmissionReview, so the failure is in the response
+		assert.Equal(t, http.StatusOK, r.StatusCode, apiVersion)
+		assert.Equal(t, apiVersion, review.APIVersion, apiVersion)
+		assert.Equal(t, "AdmissionReview", review.Kind, apiVersion)
+		assert.False(t, review.Response.Allowed, apiVersion)
+		assert.Equal(t, v1.StatusFailure, review.Response.Result.Status, apiVersion)
+		assert.Equal(t, v1.StatusReasonBadRequest, review.Response.Result.Reason, apiVersion)
+		assert.Equal(t, int32(http.StatusBadRequest), review.Response.Result.Code, apiVersion)
+		assert.Equal(t, "AdmissionReview request is empty", review.Response.Result.Message, apiVersion)
+		assert.Equal(t, malformed+1, testutil.ToFloat64(malformedReviewsCounter), apiVersion)
+	}
+
+	_, err := (&ResourceRequestsAdmission{conf: &MockConfiger{}}).HandleAdmission(context.Background(), nil)
+	assert.EqualError(t, err, "admission request is empty")
+}
+
+func TestServeReadError(t *testing.T) {
+	acs := &AdmissionControllerServer{
+		AdmissionController: &ResourceRequestsAdmission{conf: &MockConfiger{}},
+		Decoder:             codecs.UniversalDeserializer(),
+	}
+
+	malformed := testutil.ToFloat64(malformedReviewsCounter)
+
+	// connection is reset in the middle of the review
+	body := io.MultiReader(strings.NewReader(`{"kind": "AdmissionReview", "request": {"uid": "e911857d`), iotest.ErrReader(errors.New("connection reset by peer")))
+	w := httptest.NewRecorder()
+	acs.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", body))
+
+	var review v1beta1.AdmissionReview
+	if err := json.NewDecoder(w.Body).Decode(&review); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "AdmissionReview", review.Kind)
+	assert.False(t, review.Response.Allowed)
+	assert.Equal(t, v1.StatusReasonBadRequest, review.Response.Result.Reason)
+	assert.Equal(t, "unable to read AdmissionReview: connection reset by peer", review.Response.Result.Message)
+	assert.Equal(t, malformed+1, testutil.ToFloat64(malformedReviewsCounter))
+}
+
+func TestServeMaxRequestBytes(t *testing.T) {
+	server := httptest.NewServer(&AdmissionControllerServer{
+		AdmissionController: &ResourceRequestsAdmission{conf: &MockConfiger{}},
+		Decoder:             codecs.UniversalDeserializer(),
+		MaxRequestBytes:     1024,
+	})
+	defer server.Close()
+
+	review, err := json.Marshal(podReview(`{"containers": [{"name": "app", "resources": {"requests": {"cpu": "500m", "memory": "1Gi"}}}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{name: "review", body: string(review), code: http.StatusOK},
+		// annotations are cut in the middle, so the body isn't valid JSON
+		{name: "oversized review", body: `{"kind": "AdmissionReview", "request": {"object": {"metadata": {"annotations": {"a": "` + strings.Repeat("a", 2048) + `"}}}}}`, code: http.StatusRequestEntityTooLarge},
+		{name: "oversized garbage", body: strings.Repeat("{", 4096), code: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		malformed := testutil.ToFloat64(malformedReviewsCounter)
+
+		r, err := http.Post(server.URL, "application/json", strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Body.Close()
+
+		assert.Equal(t, tt.code, r.StatusCode, tt.name)
+		if tt.code == http.StatusRequestEntityTooLarge {
+			assert.Equal(t, malformed+1, testutil.ToFloat64(malformedReviewsCounter), tt.name)
+		}
+	}
+}
+
+func TestServePairedResources(t *testing.T) {
+	conf := &MockConfiger{
+		paired: []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory},
+	}
+	server := httptest.NewServer(&AdmissionControllerServer{
+		AdmissionController: &ResourceRequestsAdmission{conf: conf},
+		Decoder:             codecs.UniversalDeserializer(),
+	})
+	defer server.Close()
+
+	tests := []struct {
+		resources string
+		allowed   bool
+		message   string
+	}{
+		{
+			resources: `{"requests": {"cpu": 1, "memory": "1Gi"}, "limits": {"cpu": 1, "memory": "1Gi"}}`,
+			allowed:   true,
+		},
+		{
+			resources: `{"requests": {"cpu": 1, "memory": "1Gi"}, "limits": {"cpu": 1}}`,
+			message:   "error container app requests.memory and limits.memory must be both set or both empty",
+		},
+		{
+			resources: `{"requests": {"cpu": 1, "memory": "1Gi"}, "limits": {"memory": "1Gi"}}`,
+			message:   "error container app requests.cpu and limits.cpu must be both set or both empty",
+		},
+		{
+			resources: `{"requests": {"cpu": 1}, "limits": {"cpu": 1, "memory": "1Gi"}}`,
+			message:   "error container app requests.Memory is empty, must be 0",
+		},
+	}
+
+	for _, tt := range tests {
+		review := postReview(t, server.URL, podReview(`{"containers": [{"name": "app", "resources": `+tt.resources+`}]}`))
+		assert.Equal(t, tt.allowed, review.Response.Allowed, tt.resources)
+		if !tt.allowed {
+			assert.Equal(t, tt.message, review.Response.Result.Message)
+		}
+	}
+}
+
+func TestServeCronJobVersions(t *testing.T) {
+	cronJob := `{"metadata": {"name": "backup"}, "spec": {"schedule": "* * * * *", "jobTemplate": {"spec": {"template": {"spec":
+		{"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"cpu": 2}}}]}}}}}}`
+	job := func(ownerAPIVersion string) string {
+		return `{"metadata": {"name": "backup-27000000", "ownerReferences": [{"apiVersion": "` + ownerAPIVersion + `", "kind": "CronJob", "name": "backup"}]},
+			"spec": {"template": {"spec": {"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"cpu": 2}}}]}}}}`
+	}
+
+	tests := []struct {
+		kind    v1.GroupVersionKind
+		object  string
+		opts    Options
+		lookups []NameNamespace
+	}{
+		{
+			kind:    v1.GroupVersionKind{Group: "batch", Version: "v1beta1", Kind: "CronJob"},
+			object:  cronJob,
+			lookups: []NameNamespace{{Name: "backup", Namespace: "default"}},
+		},
+		{
+			kind:    v1.GroupVersionKind{Group: "batch", Version: "v1", Kind: "CronJob"},
+			object:  cronJob,
+			lookups: []NameNamespace{{Name: "backup", Namespace: "default"}},
+		},
+		{
+			kind:   v1.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"},
+			object: job("batch/v1"),
+		},
+		{
+			kind:    v1.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"},
+			object:  job("batch/v1"),
+			opts:    Options{ValidateCronJobJobs: true},
+			lookups: []NameNamespace{{Name: "backup", Namespace: "default"}},
+		},
+		{
+			kind:    v1.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"},
+			object:  job("batch/v1beta1"),
+			opts:    Options{ValidateCronJobJobs: true},
+			lookups: []NameNamespace{{Name: "backup", Namespace: "default"}},
+		},
+	}
+
+	for _, tt := range tests {
+		cpu := resource.MustParse("1")
+		conf := &MockConfiger{cpu: &cpu}
+		rra := &ResourceRequestsAdmission{conf: conf, opts: tt.opts}
+
+		resp, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+			UID:       "e911857d-c318-11e8-bbad-025000000001",
+			Kind:      tt.kind,
+			Namespace: "default",
+			Operation: v1beta1.Create,
+			Object:    runtime.RawExtension{Raw: []byte(tt.object)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.lookups, conf.lookups, tt.kind.String())
+		assert.Equal(t, len(tt.lookups) == 0, resp.Allowed, tt.kind.String())
+	}
+}
+
+func TestHandleAdmissionBatchV1CronJob(t *testing.T) {
+	// timeZone exists only in batch/v1
+	cronJob := func(cpu string) []byte {
+		return []byte(`{"apiVersion": "batch/v1", "kind": "CronJob", "metadata": {"name": "backup"}, "spec": {"schedule": "0 3 * * *", "timeZone": "Etc/UTC",
+			"jobTemplate": {"spec": {"parallelism": 2, "template": {"spec": {"restartPolicy": "OnFailure",
+			"containers": [{"name": "app", "resources": {"requests": {"cpu": "500m", "memory": "1Gi"}, "limits": {"cpu": "` + cpu + `"}}}]}}}}}}`)
+	}
+
+	tests := []struct {
+		name    string
+		cpu     string
+		allowed bool
+		message string
+	}{
+		{name: "within limit", cpu: "1", allowed: true},
+		{name: "over limit", cpu: "2", message: "error container app limits.CPU: 2 > 1"},
+	}
+
+	for _, tt := range tests {
+		cpu := resource.MustParse("1")
+		rra := &ResourceRequestsAdmission{conf: &MockConfiger{cpu: &cpu}}
+
+		resp, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+			UID:       "e911857d-c318-11e8-bbad-025000000001",
+			Kind:      v1.GroupVersionKind{Group: "batch", Version: "v1", Kind: "CronJob"},
+			Namespace: "default",
+			Operation: v1beta1.Create,
+			Object:    runtime.RawExtension{Raw: cronJob(tt.cpu)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.allowed, resp.Allowed, tt.name)
+		if !tt.allowed {
+			assert.Equal(t, tt.message, resp.Result.Message, tt.name)
+		}
+	}
+}
+
+func TestHandleAdmissionReplicationController(t *testing.T) {
+	tests := []struct {
+		name    string
+		object  string
+		allowed bool
+	}{
+		{
+			name:    "nil template",
+			object:  `{"metadata": {"name": "web"}, "spec": {"replicas": 1}}`,
+			allowed: true,
+		},
+		{
+			name: "over limit",
+			object: `{"metadata": {"name": "web"}, "spec": {"template": {"spec":
+				{"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"cpu": 2}}}]}}}}`,
+			allowed: false,
+		},
+	}
+
+	for _, tt := range tests {
+		cpu := resource.MustParse("1")
+		rra := &ResourceRequestsAdmission{conf: &MockConfiger{cpu: &cpu}}
+
+		resp, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+			UID:       "e911857d-c318-11e8-bbad-025000000001",
+			Kind:      v1.GroupVersionKind{Version: "v1", Kind: "ReplicationController"},
+			Namespace: "default",
+			Operation: v1beta1.Create,
+			Object:    runtime.RawExtension{Raw: []byte(tt.object)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.allowed, resp.Allowed, tt.name)
+	}
+}
+
+func TestHandleAdmissionUnlimitedIndependent(t *testing.T) {
+	pvcSize := resource.MustParse("1Gi")
+	cpu := resource.MustParse("1")
+
+	tests := []struct {
+		name       string
+		conf       *MockConfiger
+		podAllowed bool
+		pvcAllowed bool
+	}{
+		{
+			name:       "pods unlimited",
+			conf:       &MockConfiger{cpu: &cpu, pvcSize: &pvcSize, unlimited: true},
+			podAllowed: true,
+			pvcAllowed: false,
+		},
+		{
+			name:       "pvc unlimited",
+			conf:       &MockConfiger{cpu: &cpu, pvcSize: &pvcSize, unlimitedPVC: true},
+			podAllowed: false,
+			pvcAllowed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{conf: tt.conf}
+
+		resp, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+			UID:       "e911857d-c318-11e8-bbad-025000000001",
+			Kind:      v1.GroupVersionKind{Kind: "Pod"},
+			Operation: v1beta1.Create,
+			Object: runtime.RawExtension{
+				Raw: []byte(`{"metadata": {"name": "test"}, "spec": {"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"cpu": 2}}}]}}`),
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, tt.podAllowed, resp.Allowed, tt.name)
+
+		resp, err = rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+			UID:       "e911857d-c318-11e8-bbad-025000000001",
+			Kind:      v1.GroupVersionKind{Kind: "PersistentVolumeClaim"},
+			Operation: v1beta1.Create,
+			Object: runtime.RawExtension{
+				Raw: []byte(`{"metadata": {"name": "test"}, "spec": {"resources": {"requests": {"storage": "2Gi"}}}}`),
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, tt.pvcAllowed, resp.Allowed, tt.name)
+	}
+}
+
+func TestHandleAdmissionPVCStorageClass(t *testing.T) {
+	pvcSize := resource.MustParse("50Gi")
+	gp3 := resource.MustParse("100Gi")
+	io2 := resource.MustParse("20Gi")
+	rra := &ResourceRequestsAdmission{
+		conf: &MockConfiger{pvcSize: &pvcSize, pvcClasses: map[string]*resource.Quantity{"gp3": &gp3, "io2": &io2}},
+	}
+
+	tests := []struct {
+		name         string
+		storageClass string
+		storage      string
+		allowed      bool
+		message      string
+	}{
+		{name: "io2 over its limit", storageClass: `"io2"`, storage: "30Gi", message: "error persistentVolumeClaim data size is 30Gi > 20Gi"},
+		{name: "gp3 same size", storageClass: `"gp3"`, storage: "30Gi", allowed: true},
+		{name: "gp3 over maxPVCSize", storageClass: `"gp3"`, storage: "80Gi", allowed: true},
+		{name: "default class", storageClass: `null`, storage: "80Gi", message: "error persistentVolumeClaim data size is 80Gi > 50Gi"},
+		{name: "other class", storageClass: `"standard"`, storage: "30Gi", allowed: true},
+	}
+
+	for _, tt := range tests {
+		resp, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+			UID:       "e911857d-c318-11e8-bbad-025000000001",
+			Kind:      v1.GroupVersionKind{Kind: pvcKind},
+			Namespace: "default",
+			Operation: v1beta1.Create,
+			Object: runtime.RawExtension{
+				Raw: []byte(`{"metadata": {"name": "data"}, "spec": {"storageClassName": ` + tt.storageClass + `, "resources": {"requests": {"storage": "` + tt.storage + `"}}}}`),
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.allowed, resp.Allowed, tt.name)
+		if !tt.allowed {
+			assert.Equal(t, tt.message, resp.Result.Message, tt.name)
+		}
+	}
+}
+
+func TestHandleAdmissionStatefulSetVolumeClaimTemplates(t *testing.T) {
+	pvcSize := resource.MustParse("50Gi")
+	cpu := resource.MustParse("1")
+	statefulSet := func(storage string) []byte {
+		return []byte(`{"metadata": {"name": "db"}, "spec": {"template": {"spec": {"containers": [{"name": "app", "resources": {"requests": {"cpu": "500m", "memory": "1Gi"}, "limits": {"cpu": 1}}}]}},
+			"volumeClaimTemplates": [{"metadata": {"name": "wal"}, "spec": {"resources": {"requests": {"storage": "1Gi"}}}}, {"metadata": {"name": "data"}, "spec": {"resources": {"requests": {"storage": "` + storage + `"}}}}]}}`)
+	}
+
+	tests := []struct {
+		name    string
+		conf    *MockConfiger
+		storage string
+		allowed bool
+		message string
+	}{
+		{
+			name:    "template exceeds max pvc size",
+			conf:    &MockConfiger{cpu: &cpu, pvcSize: &pvcSize},
+			storage: "10Ti",
+			message: "error statefulset db volumeClaimTemplate data size is 10Ti > 50Gi",
+		},
+		{
+			name:    "template within max pvc size",
+			conf:    &MockConfiger{cpu: &cpu, pvcSize: &pvcSize},
+			storage: "50Gi",
+			allowed: true,
+		},
+		{
+			name:    "pods unlimited",
+			conf:    &MockConfiger{cpu: &cpu, pvcSize: &pvcSize, unlimited: true},
+			storage: "10Ti",
+			message: "error statefulset db volumeClaimTemplate data size is 10Ti > 50Gi",
+		},
+		{
+			name:    "pvc unlimited",
+			conf:    &MockConfiger{cpu: &cpu, pvcSize: &pvcSize, unlimitedPVC: true},
+			storage: "10Ti",
+			allowed: true,
+		},
+		{
+			name:    "pvc size not limited",
+			conf:    &MockConfiger{cpu: &cpu},
+			storage: "10Ti",
+			allowed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{conf: tt.conf}
+
+		resp, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+			UID:       "e911857d-c318-11e8-bbad-025000000001",
+			Kind:      v1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "StatefulSet"},
+			Namespace: "default",
+			Operation: v1beta1.Create,
+			Object:    runtime.RawExtension{Raw: statefulSet(tt.storage)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.allowed, resp.Allowed, tt.name)
+		if !tt.allowed {
+			assert.Equal(t, tt.message, resp.Result.Message, tt.name)
+			assert.Equal(t, string(reasonPVCSizeExceeded), resp.AuditAnnotations[denialReasonAnnotation], tt.name)
+		}
+	}
+}
+
+func TestHandleAdmissionUserGroups(t *testing.T) {
+	conf := &MockConfiger{}
+	rra := &ResourceRequestsAdmission{conf: conf}
+
+	_, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+		UID:       "e911857d-c318-11e8-bbad-025000000001",
+		Kind:      v1.GroupVersionKind{Kind: "Pod"},
+		Operation: v1beta1.Create,
+		UserInfo: authenticationv1.UserInfo{
+			Username: "admin",
+			Groups:   []string{"platform-admins", "system:authenticated"},
+		},
+		Object: runtime.RawExtension{Raw: []byte(`{"metadata": {"name": "test"}}`)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{"platform-admins", "system:authenticated"}, conf.groups)
+}
+
+func TestHandleAdmissionForbidEphemeralContainers(t *testing.T) {
+	pod := func(ephemeral string) []byte {
+		return []byte(`{"metadata": {"name": "test"}, "spec": {"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}}}], "ephemeralContainers": [` + ephemeral + `]}}`)
+	}
+	debugger := `{"name": "debugger", "image": "busybox"}`
+
+	tests := []struct {
+		name      string
+		forbid    bool
+		oldObject []byte
+		object    []byte
+		allowed   bool
+	}{
+		{
+			name:      "added",
+			forbid:    true,
+			oldObject: pod(""),
+			object:    pod(debugger),
+			allowed:   false,
+		},
+		{
+			name:      "already present",
+			forbid:    true,
+			oldObject: pod(debugger),
+			object:    pod(debugger),
+			allowed:   true,
+		},
+		{
+			name:      "allowed",
+			oldObject: pod(""),
+			object:    pod(debugger),
+			allowed:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{conf: &MockConfiger{noEphemeral: tt.forbid}}
+
+		resp, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+			UID:         "e911857d-c318-11e8-bbad-025000000001",
+			Kind:        v1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+			SubResource: "ephemeralcontainers",
+			Namespace:   "production",
+			Operation:   v1beta1.Update,
+			OldObject:   runtime.RawExtension{Raw: tt.oldObject},
+			Object:      runtime.RawExtension{Raw: tt.object},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.allowed, resp.Allowed, tt.name)
+		if !tt.allowed {
+			assert.Equal(t, "error ephemeral containers are forbidden in namespace production: debugger", resp.Result.Message)
+		}
+	}
+}
+
+func TestHandleAdmissionSubResources(t *testing.T) {
+	tests := []struct {
+		subResource string
+		operation   v1beta1.Operation
+		object      string
+	}{
+		{
+			subResource: "binding",
+			operation:   v1beta1.Create,
+			object:      `{"apiVersion": "v1", "kind": "Binding", "metadata": {"name": "test"}, "target": {"apiVersion": "v1", "kind": "Node", "name": "node-1"}}`,
+		},
+		{
+			subResource: "eviction",
+			operation:   v1beta1.Create,
+			object:      `{"apiVersion": "policy/v1", "kind": "Eviction", "metadata": {"name": "test"}}`,
+		},
+		{
+			subResource: "status",
+			operation:   v1beta1.Update,
+			object:      `{"metadata": {"name": "test"}, "spec": {"containers": [{"name": "app"}]}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		conf := &MockConfiger{}
+		rra := &ResourceRequestsAdmission{conf: conf}
+
+		resp, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+			UID:         "e911857d-c318-11e8-bbad-025000000001",
+			Kind:        v1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+			SubResource: tt.subResource,
+			Operation:   tt.operation,
+			Object:      runtime.RawExtension{Raw: []byte(tt.object)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, true, resp.Allowed, tt.subResource)
+		assert.Empty(t, conf.lookups, tt.subResource)
+	}
+}
+
+func TestServeBurst(t *testing.T) {
+	cpuBurst := resource.MustParse("500m")
+	memBurst := resource.MustParse("1Gi")
+	server := httptest.NewServer(&AdmissionControllerServer{
+		AdmissionController: &ResourceRequestsAdmission{conf: &MockConfiger{cpuBurst: &cpuBurst, memBurst: &memBurst}},
+		Decoder:             codecs.UniversalDeserializer(),
+	})
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		spec    string
+		message string
+	}{
+		{
+			name: "within burst",
+			spec: `{"containers": [{"name": "app", "resources": {"requests": {"cpu": "500m", "memory": "1Gi"}, "limits": {"cpu": 1, "memory": "2Gi"}}}]}`,
+		},
+		{
+			name: "without limits",
+			spec: `{"containers": [{"name": "app", "resources": {"requests": {"cpu": "500m", "memory": "1Gi"}}}]}`,
+		},
+		{
+			name:    "cpu beyond burst",
+			spec:    `{"containers": [{"name": "app", "resources": {"requests": {"cpu": "500m", "memory": "1Gi"}, "limits": {"cpu": 2, "memory": "1Gi"}}}]}`,
+			message: "error container app limits.CPU - requests.CPU: 1500m > 500m",
+		},
+		{
+			name:    "memory beyond burst",
+			spec:    `{"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"memory": "2Gi"}}}]}`,
+			message: "error container app limits.Memory - requests.Memory: 2Gi > 1Gi",
+		},
+	}
+
+	for _, tt := range tests {
+		review := postReview(t, server.URL, podReview(tt.spec))
+
+		assert.Equal(t, tt.message == "", review.Response.Allowed, tt.name)
+		if tt.message != "" {
+			assert.Equal(t, tt.message, review.Response.Result.Message, tt.name)
+		}
+	}
+}
+
+func TestHandleAdmissionDeploymentVersions(t *testing.T) {
+	deployment := `{"metadata": {"name": "web"}, "spec": {"replicas": 2, "template": {"spec":
+		{"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"cpu": 2}}}]}}}}`
+
+	for _, version := range []string{"v1", "v1beta1", "v1beta2"} {
+		cpu := resource.MustParse("1")
+		conf := &MockConfiger{cpu: &cpu}
+		rra := &ResourceRequestsAdmission{conf: conf}
+
+		resp, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+			UID:       "e911857d-c318-11e8-bbad-025000000001",
+			Kind:      v1.GroupVersionKind{Group: "apps", Version: version, Kind: "Deployment"},
+			Namespace: "default",
+			Operation: v1beta1.Create,
+			Object:    runtime.RawExtension{Raw: []byte(deployment)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, []NameNamespace{{Name: "web", Namespace: "default"}}, conf.lookups, version)
+		assert.Equal(t, false, resp.Allowed, version)
+		assert.Equal(t, "error container app limits.CPU: 2 > 1", resp.Result.Message, version)
+	}
+}
+
+func TestHandleAdmissionRequireMemoryLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    *MockConfiger
+		opts    Options
+		spec    string
+		message string
+	}{
+		{
+			name: "memory limit set",
+			conf: &MockConfiger{},
+			opts: Options{RequireMemoryLimit: true},
+			spec: `{"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"memory": "1Gi"}}}]}`,
+		},
+		{
+			name:    "memory limit missing",
+			conf:    &MockConfiger{},
+			opts:    Options{RequireMemoryLimit: true},
+			spec:    `{"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"cpu": 1}}}]}`,
+			message: "error container app limits.Memory is empty, memory limit is required",
+		},
+		{
+			name:    "init container memory limit missing",
+			conf:    &MockConfiger{},
+			opts:    Options{RequireMemoryLimit: true},
+			spec:    `{"initContainers": [{"name": "init", "resources": {"requests": {"cpu": 0, "memory": 0}}}], "containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"memory": "1Gi"}}}]}`,
+			message: "error init container init limits.Memory is empty, memory limit is required",
+		},
+		{
+			name: "unlimited namespace",
+			conf: &MockConfiger{unlimited: true},
+			opts: Options{RequireMemoryLimit: true},
+			spec: `{"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}}}]}`,
+		},
+		{
+			name: "disabled",
+			conf: &MockConfiger{},
+			spec: `{"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}}}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{conf: tt.conf, opts: tt.opts}
+
+		resp, err := rra.HandleAdmission(context.Background(), podReview(tt.spec).Request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.message == "", resp.Allowed, tt.name)
+		if tt.message != "" {
+			assert.Equal(t, tt.message, resp.Result.Message, tt.name)
+		}
+	}
+}
+
+func TestHandleAdmissionRequireEphemeralStorageLimit(t *testing.T) {
+	opts := Options{RequireEphemeralStorageLimit: true}
+	tests := []struct {
+		name    string
+		opts    Options
+		spec    string
+		message string
+	}{
+		{
+			name: "emptyDir with ephemeral-storage limit",
+			opts: opts,
+			spec: `{"volumes": [{"name": "tmp", "emptyDir": {}}],
+				"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"ephemeral-storage": "1Gi"}}}]}`,
+		},
+		{
+			name: "emptyDir without ephemeral-storage limit",
+			opts: opts,
+			spec: `{"volumes": [{"name": "tmp", "emptyDir": {}}],
+				"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}}}]}`,
+			message: "error container app limits.ephemeral-storage is empty, ephemeral-storage limit is required with emptyDir volumes",
+		},
+		{
+			name: "init container without ephemeral-storage limit",
+			opts: opts,
+			spec: `{"volumes": [{"name": "tmp", "emptyDir": {}}], "initContainers": [{"name": "init", "resources": {"requests": {"cpu": 0, "memory": 0}}}],
+				"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"ephemeral-storage": "1Gi"}}}]}`,
+			message: "error init container init limits.ephemeral-storage is empty, ephemeral-storage limit is required with emptyDir volumes",
+		},
+		{
+			name: "memory backed emptyDir",
+			opts: opts,
+			spec: `{"volumes": [{"name": "tmp", "emptyDir": {"medium": "Memory"}}],
+				"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}}}]}`,
+		},
+		{
+			name: "no emptyDir",
+			opts: opts,
+			spec: `{"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}}}]}`,
+		},
+		{
+			name: "disabled",
+			spec: `{"volumes": [{"name": "tmp", "emptyDir": {}}],
+				"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}}}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{conf: &MockConfiger{}, opts: tt.opts}
+
+		resp, err := rra.HandleAdmission(context.Background(), podReview(tt.spec).Request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.message == "", resp.Allowed, tt.name)
+		if tt.message != "" {
+			assert.Equal(t, tt.message, resp.Result.Message, tt.name)
+		}
+	}
+}
+
+func TestHandleAdmissionRestartPolicy(t *testing.T) {
+	pod := func(restartPolicy string) string {
+		return `{"restartPolicy": "` + restartPolicy + `", "containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"cpu": 3}}}]}`
+	}
+
+	cpu := resource.MustParse("2")
+	neverCPU := resource.MustParse("4")
+	conf := &MockConfiger{
+		cpu: &cpu,
+		restarts: map[corev1.RestartPolicy]LimitResource{
+			corev1.RestartPolicyNever: {CPULimit: &neverCPU},
+		},
+	}
+
+	tests := []struct {
+		restartPolicy string
+		message       string
+	}{
+		{restartPolicy: "Never"},
+		{restartPolicy: "Always", message: "error container app limits.CPU: 3 > 2"},
+		{restartPolicy: "OnFailure", message: "error container app limits.CPU: 3 > 2"},
+		{restartPolicy: "", message: "error container app limits.CPU: 3 > 2"},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{conf: conf}
+
+		resp, err := rra.HandleAdmission(context.Background(), podReview(pod(tt.restartPolicy)).Request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.message == "", resp.Allowed, tt.restartPolicy)
+		if tt.message != "" {
+			assert.Equal(t, tt.message, resp.Result.Message, tt.restartPolicy)
+		}
+	}
+}
+
+func TestHandleAdmissionMaxContainerResources(t *testing.T) {
+	maxRes := 3
+	tests := []struct {
+		name    string
+		spec    string
+		message string
+	}{
+		{
+			name: "within resource count",
+			spec: `{"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"cpu": 1, "ephemeral-storage": "1Gi"}}}]}`,
+		},
+		{
+			name: "resource count exceeded",
+			spec: `{"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0, "example.com/foo": 1},
+				"limits": {"example.com/foo": 1, "example.com/bar": 1}}}]}`,
+			message: "error container app distinct resources in requests and limits: 4 > 3",
+		},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{conf: &MockConfiger{maxRes: &maxRes}}
+
+		resp, err := rra.HandleAdmission(context.Background(), podReview(tt.spec).Request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.message == "", resp.Allowed, tt.name)
+		if tt.message != "" {
+			assert.Equal(t, tt.message, resp.Result.Message, tt.name)
+		}
+	}
+}
+
+func TestHandleAdmissionNamespaceDenialMetric(t *testing.T) {
+	denied := `{"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"cpu": 2}}}]}`
+	allowed := `{"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"cpu": 1}}}]}`
+
+	cpu := resource.MustParse("1")
+	denials := func() float64 {
+		return testutil.ToFloat64(namespaceDenialsCounter.WithLabelValues("denial-metric"))
+	}
+	handle := func(opts Options, spec string) {
+		review := podReview(spec)
+		review.Request.Namespace = "denial-metric"
+
+		rra := &ResourceRequestsAdmission{conf: &MockConfiger{cpu: &cpu}, opts: opts}
+		if _, err := rra.HandleAdmission(context.Background(), review.Request); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	before := denials()
+
+	handle(Options{NamespaceDenialMetric: true}, denied)
+	assert.Equal(t, before+1, denials())
+
+	handle(Options{NamespaceDenialMetric: true}, allowed)
+	assert.Equal(t, before+1, denials())
+
+	handle(Options{}, denied)
+	assert.Equal(t, before+1, denials())
+}
+
+func TestHandleAdmissionUncapped(t *testing.T) {
+	overCeiling := `{"containers": [{"name": "app", "resources": {"requests": {"cpu": 3, "memory": 0}, "limits": {"cpu": 3}}}]}`
+	missingRequests := `{"containers": [{"name": "app", "resources": {"limits": {"cpu": 1}}}]}`
+
+	cpu := resource.MustParse("2")
+	tests := []struct {
+		name    string
+		conf    *MockConfiger
+		spec    string
+		message string
+	}{
+		{
+			name:    "capped over ceiling",
+			conf:    &MockConfiger{cpu: &cpu, cpuRequest: &cpu},
+			spec:    overCeiling,
+			message: "error container app requests.CPU: 3 > 2",
+		},
+		{
+			name: "uncapped over ceiling",
+			conf: &MockConfiger{cpu: &cpu, cpuRequest: &cpu, uncapped: true},
+			spec: overCeiling,
+		},
+		{
+			name:    "uncapped missing requests",
+			conf:    &MockConfiger{cpu: &cpu, uncapped: true},
+			spec:    missingRequests,
+			message: "error container app requests.CPU is empty, must be 0",
+		},
+		{
+			name:    "uncapped cpu limit policy",
+			conf:    &MockConfiger{cpu: &cpu, uncapped: true, cpuPolicy: "forbidden"},
+			spec:    overCeiling,
+			message: "error container app limits.CPU is set, cpu limit is forbidden",
+		},
+		{
+			name: "unlimited missing requests",
+			conf: &MockConfiger{cpu: &cpu, unlimited: true},
+			spec: missingRequests,
+		},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{conf: tt.conf}
+
+		resp, err := rra.HandleAdmission(context.Background(), podReview(tt.spec).Request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.message == "", resp.Allowed, tt.name)
+		if tt.message != "" {
+			assert.Equal(t, tt.message, resp.Result.Message, tt.name)
+		}
+	}
+}
+
+func TestHandleAdmissionTemplateResourceChanges(t *testing.T) {
+	deployment := func(cpu string) []byte {
+		return []byte(`{"metadata": {"name": "web"}, "spec": {"template": {"spec": {"containers": [
+			{"name": "app", "resources": {"requests": {"cpu": "` + cpu + `", "memory": 0}}},
+			{"name": "proxy", "resources": {"requests": {"cpu": 0, "memory": 0}}}]}}}}`)
+	}
+
+	tests := []struct {
+		name      string
+		opts      Options
+		operation v1beta1.Operation
+		old       []byte
+		warnings  []string
+	}{
+		{
+			name:      "resources changed",
+			opts:      Options{WarnTemplateResourceChanges: true},
+			operation: v1beta1.Update,
+			old:       deployment("500m"),
+			warnings:  []string{"container app resources changed, pods of deployment web will be restarted"},
+		},
+		{
+			name:      "resources unchanged",
+			opts:      Options{WarnTemplateResourceChanges: true},
+			operation: v1beta1.Update,
+			old:       deployment("1"),
+		},
+		{
+			name:      "create",
+			opts:      Options{WarnTemplateResourceChanges: true},
+			operation: v1beta1.Create,
+		},
+		{
+			name:      "disabled",
+			operation: v1beta1.Update,
+			old:       deployment("500m"),
+		},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{conf: &MockConfiger{}, opts: tt.opts}
+
+		resp, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+			UID:       "e911857d-c318-11e8-bbad-025000000001",
+			Kind:      v1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+			Namespace: "default",
+			Operation: tt.operation,
+			Object:    runtime.RawExtension{Raw: deployment("1")},
+			OldObject: runtime.RawExtension{Raw: tt.old},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.True(t, resp.Allowed, tt.name)
+		assert.Equal(t, tt.warnings, resp.Warnings, tt.name)
+	}
+}
+
+func TestHandleAdmissionWorkloadClass(t *testing.T) {
+	template := func(labels string) string {
+		return `{"metadata": {"labels": ` + labels + `}, "spec": {"containers": [{"name": "app",
+			"resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"cpu": 3, "memory": "4Gi"}}}]}}`
+	}
+
+	cpu := resource.MustParse("4")
+	mem := resource.MustParse("1Gi")
+	jobCPU := resource.MustParse("1")
+	jobMem := resource.MustParse("8Gi")
+	conf := &MockConfiger{
+		cpu: &cpu,
+		mem: &mem,
+		workloads: map[string]LimitResource{
+			workloadClassJob: {CPULimit: &jobCPU, MemLimit: &jobMem},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		kind    v1.GroupVersionKind
+		object  string
+		message string
+	}{
+		{
+			name:    "job class",
+			kind:    v1.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"},
+			object:  `{"metadata": {"name": "migrate"}, "spec": {"template": ` + template(`{}`) + `}}`,
+			message: "error container app limits.CPU: 3 > 1",
+		},
+		{
+			name:    "service class",
+			kind:    v1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+			object:  `{"metadata": {"name": "web"}, "spec": {"template": ` + template(`{}`) + `}}`,
+			message: "error container app limits.Memory: 4Gi > 1Gi",
+		},
+		{
+			name:    "class label",
+			kind:    v1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+			object:  `{"metadata": {"name": "web"}, "spec": {"template": ` + template(`{"`+workloadClassLabel+`": "job"}`) + `}}`,
+			message: "error container app limits.CPU: 3 > 1",
+		},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{conf: conf}
+
+		resp, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+			UID:       "e911857d-c318-11e8-bbad-025000000001",
+			Kind:      tt.kind,
+			Namespace: "default",
+			Operation: v1beta1.Create,
+			Object:    runtime.RawExtension{Raw: []byte(tt.object)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.message == "", resp.Allowed, tt.name)
+		if tt.message != "" {
+			assert.Equal(t, tt.message, resp.Result.Message, tt.name)
+		}
+	}
+}
+
+// slowConfiger blocks pod limit lookup until released
+type slowConfiger struct {
+	*MockConfiger
+	release chan struct{}
+}
+
+func (sc slowConfiger) GetPodLimitByLabels(nn NameNamespace, labels map[string]string, groups ...string) LimitResource {
+	<-sc.release
+	return sc.MockConfiger.GetPodLimitByLabels(nn, labels, groups...)
+}
+
+func TestServeLatencyBudget(t *testing.T) {
+	tests := []struct {
+		name    string
+		budget  time.Duration
+		allow   bool
+		query   string
+		allowed bool
+	}{
+		{name: "deny", budget: 50 * time.Millisecond},
+		{name: "allow", budget: 50 * time.Millisecond, allow: true, allowed: true},
+		{name: "webhook timeout shortens budget", budget: time.Hour, query: "?timeout=150ms"},
+	}
+
+	for _, tt := range tests {
+		release := make(chan struct{})
+		rra := New(slowConfiger{MockConfiger: &MockConfiger{}, release: release}, Options{LatencyBudgetAllow: tt.allow})
+		server := httptest.NewServer(&AdmissionControllerServer{
+			AdmissionController: rra,
+			Decoder:             codecs.UniversalDeserializer(),
+			LatencyBudget:       tt.budget,
+		})
+
+		exceeded := testutil.ToFloat64(latencyBudgetExceededCounter)
+		denied := testutil.ToFloat64(decisionsCounter.WithLabelValues(podKind, decisionDenied, string(reasonLatencyBudgetExceeded)))
+		allowed := testutil.ToFloat64(decisionsCounter.WithLabelValues(podKind, decisionAllowed, reasonNone))
+
+		start := time.Now()
+		review := postReview(t, server.URL+tt.query, podReview(`{"containers": []}`))
+
+		assert.True(t, time.Since(start) < 2*time.Second, tt.name)
+		assert.Equal(t, tt.allowed, review.Response.Allowed, tt.name)
+		assert.True(t, strings.HasPrefix(review.Response.Result.Message, "admission didn't finish within latency budget"), tt.name)
+
+		// abandoned admission finishes, but only the response, which was sent, is counted
+		close(release)
+		time.Sleep(50 * time.Millisecond)
+
+		assert.Equal(t, exceeded+1, testutil.ToFloat64(latencyBudgetExceededCounter), tt.name)
+		if tt.allowed {
+			assert.Equal(t, allowed+1, testutil.ToFloat64(decisionsCounter.WithLabelValues(podKind, decisionAllowed, reasonNone)), tt.name)
+			assert.Equal(t, denied, testutil.ToFloat64(decisionsCounter.WithLabelValues(podKind, decisionDenied, string(reasonLatencyBudgetExceeded))), tt.name)
+		} else {
+			assert.Equal(t, allowed, testutil.ToFloat64(decisionsCounter.WithLabelValues(podKind, decisionAllowed, reasonNone)), tt.name)
+			assert.Equal(t, denied+1, testutil.ToFloat64(decisionsCounter.WithLabelValues(podKind, decisionDenied, string(reasonLatencyBudgetExceeded))), tt.name)
+		}
+
+		server.Close()
+	}
+}
+
+func TestHandleAdmissionLatencyBudgetReservation(t *testing.T) {
+	release := make(chan struct{})
+	gpus := resource.MustParse("4")
+	rra := New(slowConfiger{MockConfiger: &MockConfiger{nsGPUs: &gpus}, release: release}, Options{})
+
+	req := podReview(`{"containers": [{"name": "train", "resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"nvidia.com/gpu": 2}}}]}`).Request
+	req.Namespace = "ml"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	resp, err := rra.HandleAdmission(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, resp.Allowed)
+	assert.Equal(t, string(reasonLatencyBudgetExceeded), resp.AuditAnnotations[denialReasonAnnotation])
+
+	// abandoned admission finishes, but doesn't reserve GPUs of the denied pod
+	close(release)
+	time.Sleep(50 * time.Millisecond)
+
+	rra.gpuUsage.m.Lock()
+	assert.Empty(t, rra.gpuUsage.usage["ml"])
+	rra.gpuUsage.m.Unlock()
+}
+
+func TestLatencyBudgetReserve(t *testing.T) {
+	tracker := newUsageTracker()
+	max := resource.MustParse("4")
+
+	// reservation before abandon is kept, abandon reports it, so that the admission's response is sent
+	lb := &latencyBudget{}
+	ctx := context.WithValue(context.Background(), latencyBudgetKey{}, lb)
+	_, ok := reserve(ctx, tracker, "ml", "pod/a", resource.MustParse("1"), max, false)
+	assert.True(t, ok)
+	assert.True(t, lb.abandon())
+
+	// reservation after abandon is only checked
+	lb = &latencyBudget{}
+	ctx = context.WithValue(context.Background(), latencyBudgetKey{}, lb)
+	assert.False(t, lb.abandon())
+	total, ok := reserve(ctx, tracker, "ml", "pod/b", resource.MustParse("2"), max, false)
+	assert.True(t, ok)
+	assert.Equal(t, "3", total.String())
+
+	assert.Equal(t, map[string]resource.Quantity{"pod/a": resource.MustParse("1")}, tracker.usage["ml"])
+}
+
+func TestHandleAdmissionRequiredProbes(t *testing.T) {
+	conf := &MockConfiger{probes: []string{"readiness", "liveness"}, probeExempt: []string{"istio-proxy"}}
+	tests := []struct {
+		name    string
+		spec    string
+		message string
+	}{
+		{
+			name: "probes defined",
+			spec: `{"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}},
+				"readinessProbe": {"httpGet": {"path": "/ready", "port": 8080}}, "livenessProbe": {"httpGet": {"path": "/health", "port": 8080}}}]}`,
+		},
+		{
+			name:    "probes missing",
+			spec:    `{"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}}}]}`,
+			message: "error container app readinessProbe is required",
+		},
+		{
+			name: "liveness probe missing",
+			spec: `{"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}},
+				"readinessProbe": {"httpGet": {"path": "/ready", "port": 8080}}}]}`,
+			message: "error container app livenessProbe is required",
+		},
+		{
+			name: "exempt sidecar",
+			spec: `{"containers": [{"name": "istio-proxy", "resources": {"requests": {"cpu": 0, "memory": 0}}}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{conf: conf}
+
+		resp, err := rra.HandleAdmission(context.Background(), podReview(tt.spec).Request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.message == "", resp.Allowed, tt.name)
+		if tt.message != "" {
+			assert.Equal(t, tt.message, resp.Result.Message, tt.name)
+		}
+	}
+}
+
+func TestHandleAdmissionRejectNegativeQuantities(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		reject  bool
+		message string
+	}{
+		{
+			name:   "non-negative quantities",
+			spec:   `{"containers": [{"name": "app", "resources": {"requests": {"cpu": "100m", "memory": 0}, "limits": {"memory": "1Gi"}}}]}`,
+			reject: true,
+		},
+		{
+			name:    "negative request",
+			spec:    `{"containers": [{"name": "app", "resources": {"requests": {"cpu": "-100m", "memory": 0}}}]}`,
+			reject:  true,
+			message: "error container app requests.cpu: -100m must not be negative",
+		},
+		{
+			name:    "negative init container limit",
+			spec:    `{"initContainers": [{"name": "init", "resources": {"limits": {"memory": "-1Gi"}}}], "containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}}}]}`,
+			reject:  true,
+			message: "error init container init limits.memory: -1Gi must not be negative",
+		},
+		{
+			name: "disabled",
+			spec: `{"containers": [{"name": "app", "resources": {"requests": {"cpu": "-100m", "memory": 0}}}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{
+			conf: &MockConfiger{},
+			opts: Options{RejectNegativeQuantities: tt.reject},
+		}
+
+		resp, err := rra.HandleAdmission(context.Background(), podReview(tt.spec).Request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.message == "", resp.Allowed, tt.name)
+		if tt.message != "" {
+			assert.Equal(t, tt.message, resp.Result.Message, tt.name)
+		}
+	}
+}
+
+func TestHandleAdmissionImageMemLimits(t *testing.T) {
+	cpu := resource.MustParse("1")
+	mem := resource.MustParse("1Gi")
+	jvmMem := resource.MustParse("8Gi")
+	conf := &MockConfiger{
+		cpu: &cpu,
+		mem: &mem,
+		imageMem: []imageMemLimit{
+			{imagePrefix: "eclipse-temurin:", memLimit: &jvmMem},
+			{imagePrefix: "pytorch/"},
+		},
+	}
+	container := func(image, cpu, mem string) string {
+		return `{"containers": [{"name": "app", "image": "` + image + `", "resources": {"requests": {"cpu": 0, "memory": 0},
+			"limits": {"cpu": "` + cpu + `", "memory": "` + mem + `"}}}]}`
+	}
+
+	tests := []struct {
+		name    string
+		spec    string
+		message string
+	}{
+		{
+			name: "allowlisted image within raised ceiling",
+			spec: container("eclipse-temurin:21", "1", "8Gi"),
+		},
+		{
+			name:    "allowlisted image over raised ceiling",
+			spec:    container("eclipse-temurin:21", "1", "16Gi"),
+			message: "error container app limits.Memory: 16Gi > 8Gi",
+		},
+		{
+			name:    "allowlisted image keeps cpu ceiling",
+			spec:    container("eclipse-temurin:21", "2", "2Gi"),
+			message: "error container app limits.CPU: 2 > 1",
+		},
+		{
+			name: "allowlisted image without memory ceiling",
+			spec: container("pytorch/pytorch:2.3", "1", "64Gi"),
+		},
+		{
+			name:    "other image",
+			spec:    container("nginx:1.27", "1", "2Gi"),
+			message: "error container app limits.Memory: 2Gi > 1Gi",
+		},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{conf: conf}
+
+		resp, err := rra.HandleAdmission(context.Background(), podReview(tt.spec).Request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.message == "", resp.Allowed, tt.name)
+		if tt.message != "" {
+			assert.Equal(t, tt.message, resp.Result.Message, tt.name)
+		}
+	}
+}
+
+func TestHandleAdmissionMinRequests(t *testing.T) {
+	minCPU := resource.MustParse("100m")
+	minMem := resource.MustParse("128Mi")
+	container := func(requests string) string {
+		return `{"containers": [{"name": "app", "resources": {"requests": {` + requests + `}}}]}`
+	}
+
+	tests := []struct {
+		name    string
+		conf    *MockConfiger
+		spec    string
+		message string
+	}{
+		{
+			name: "meets minimum",
+			conf: &MockConfiger{minCPU: &minCPU, minMem: &minMem},
+			spec: container(`"cpu": "100m", "memory": "256Mi"`),
+		},
+		{
+			name:    "cpu below minimum",
+			conf:    &MockConfiger{minCPU: &minCPU, minMem: &minMem},
+			spec:    container(`"cpu": "50m", "memory": "256Mi"`),
+			message: "error container app requests.CPU: 50m < 100m",
+		},
+		{
+			name:    "memory below minimum",
+			conf:    &MockConfiger{minCPU: &minCPU, minMem: &minMem},
+			spec:    container(`"cpu": "1", "memory": "64Mi"`),
+			message: "error container app requests.Memory: 64Mi < 128Mi",
+		},
+		{
+			name:    "empty request with minimum",
+			conf:    &MockConfiger{minCPU: &minCPU},
+			spec:    container(`"memory": 0`),
+			message: "error container app requests.CPU is empty, must be at least 100m",
+		},
+		{
+			name: "zero request without minimum",
+			conf: &MockConfiger{},
+			spec: container(`"cpu": 0, "memory": 0`),
+		},
+		{
+			name:    "empty request without minimum",
+			conf:    &MockConfiger{},
+			spec:    container(`"cpu": 0`),
+			message: "error container app requests.Memory is empty, must be 0",
+		},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{conf: tt.conf}
+
+		resp, err := rra.HandleAdmission(context.Background(), podReview(tt.spec).Request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.message == "", resp.Allowed, tt.name)
+		if tt.message != "" {
+			assert.Equal(t, tt.message, resp.Result.Message, tt.name)
+		}
+	}
+}
+
+func TestHandleAdmissionEphemeralStorageLimit(t *testing.T) {
+	storage := resource.MustParse("1Gi")
+	container := func(limits string) string {
+		return `{"containers": [{"name": "app", "resources": {"requests": {"cpu": "100m", "memory": "128Mi"}, "limits": {` + limits + `}}}]}`
+	}
+
+	tests := []struct {
+		name    string
+		conf    *MockConfiger
+		spec    string
+		message string
+	}{
+		{
+			name: "within limit",
+			conf: &MockConfiger{storage: &storage},
+			spec: container(`"ephemeral-storage": "1Gi"`),
+		},
+		{
+			name:    "over limit",
+			conf:    &MockConfiger{storage: &storage},
+			spec:    container(`"ephemeral-storage": "5Gi"`),
+			message: "error container app limits.EphemeralStorage: 5Gi > 1Gi",
+		},
+		{
+			name: "no limit set",
+			conf: &MockConfiger{storage: &storage},
+			spec: container(`"memory": "128Mi"`),
+		},
+		{
+			name: "not limited",
+			conf: &MockConfiger{},
+			spec: container(`"ephemeral-storage": "5Gi"`),
+		},
+		{
+			name: "uncapped",
+			conf: &MockConfiger{storage: &storage, uncapped: true},
+			spec: container(`"ephemeral-storage": "5Gi"`),
+		},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{conf: tt.conf}
+
+		resp, err := rra.HandleAdmission(context.Background(), podReview(tt.spec).Request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.message == "", resp.Allowed, tt.name)
+		if tt.message != "" {
+			assert.Equal(t, tt.message, resp.Result.Message, tt.name)
+		}
+	}
+}
+
+func TestHandleAdmissionInitAndEphemeralContainers(t *testing.T) {
+	cpu := resource.MustParse("1")
+	mem := resource.MustParse("1Gi")
+	app := `"containers": [{"name": "app", "resources": {"requests": {"cpu": "500m", "memory": "512Mi"}}}]`
+
+	tests := []struct {
+		name    string
+		spec    string
+		message string
+	}{
+		{
+			name: "init container within limits",
+			spec: `{"initContainers": [{"name": "migrate", "resources": {"requests": {"cpu": "500m", "memory": "512Mi"}, "limits": {"cpu": 1}}}], ` + app + `}`,
+		},
+		{
+			name:    "init container over cpu limit",
+			spec:    `{"initContainers": [{"name": "migrate", "resources": {"requests": {"cpu": "500m", "memory": "512Mi"}, "limits": {"cpu": 4}}}], ` + app + `}`,
+			message: "error init container migrate limits.CPU: 4 > 1",
+		},
+		{
+			name:    "init container without requests",
+			spec:    `{"initContainers": [{"name": "migrate"}], ` + app + `}`,
+			message: "error init container migrate requests.CPU is empty, must be 0",
+		},
+		{
+			name:    "native sidecar without sidecar limit uses container limit",
+			spec:    `{"initContainers": [{"name": "proxy", "restartPolicy": "Always", "resources": {"requests": {"cpu": "100m", "memory": "128Mi"}, "limits": {"memory": "2Gi"}}}], ` + app + `}`,
+			message: "error sidecar container proxy limits.Memory: 2Gi > 1Gi",
+		},
+		{
+			name:    "ephemeral container over memory limit",
+			spec:    `{"ephemeralContainers": [{"name": "debugger", "resources": {"limits": {"memory": "4Gi"}}}], ` + app + `}`,
+			message: "error ephemeral container debugger limits.Memory: 4Gi > 1Gi",
+		},
+		{
+			name: "ephemeral container without resources",
+			spec: `{"ephemeralContainers": [{"name": "debugger"}], ` + app + `}`,
+		},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{conf: &MockConfiger{cpu: &cpu, mem: &mem}}
+
+		resp, err := rra.HandleAdmission(context.Background(), podReview(tt.spec).Request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.message == "", resp.Allowed, tt.name)
+		if tt.message != "" {
+			assert.Equal(t, tt.message, resp.Result.Message, tt.name)
+		}
+	}
+}
+
+func TestHandleAdmissionExtendedResources(t *testing.T) {
+	gpus := resource.MustParse("2")
+	hugepages := resource.MustParse("1Gi")
+	conf := &MockConfiger{extended: map[corev1.ResourceName]*resource.Quantity{
+		"nvidia.com/gpu": &gpus,
+		"hugepages-2Mi":  &hugepages,
+	}}
+	container := func(limits string) string {
+		return `{"containers": [{"name": "train", "resources": {"requests": {"cpu": "1", "memory": "1Gi"}, "limits": {` + limits + `}}}]}`
+	}
+
+	tests := []struct {
+		name    string
+		spec    string
+		message string
+	}{
+		{
+			name: "within caps",
+			spec: container(`"nvidia.com/gpu": 2, "hugepages-2Mi": "512Mi"`),
+		},
+		{
+			name:    "gpus over cap",
+			spec:    container(`"nvidia.com/gpu": 4`),
+			message: "error container train limits.nvidia.com/gpu: 4 > 2",
+		},
+		{
+			name:    "hugepages over cap",
+			spec:    container(`"hugepages-2Mi": "2Gi"`),
+			message: "error container train limits.hugepages-2Mi: 2Gi > 1Gi",
+		},
+		{
+			name: "not capped resource",
+			spec: container(`"example.com/fpga": 8`),
+		},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{conf: conf}
+
+		resp, err := rra.HandleAdmission(context.Background(), podReview(tt.spec).Request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.message == "", resp.Allowed, tt.name)
+		if tt.message != "" {
+			assert.Equal(t, tt.message, resp.Result.Message, tt.name)
+		}
+	}
+}
+
+func TestHandleAdmissionWarnMode(t *testing.T) {
+	cpu := resource.MustParse("1")
+	maxClaims := 0
+
+	tests := []struct {
+		name     string
+		mode     string
+		spec     string
+		allowed  bool
+		warnings []string
+	}{
+		{
+			name:     "warn mode over limit",
+			mode:     modeWarn,
+			spec:     `{"containers": [{"name": "app", "resources": {"requests": {"cpu": "500m", "memory": "1Gi"}, "limits": {"cpu": 2}}}]}`,
+			allowed:  true,
+			warnings: []string{"error container app limits.CPU: 2 > 1"},
+		},
+		{
+			name:    "warn mode all violations are warnings",
+			mode:    modeWarn,
+			spec:    `{"containers": [{"name": "app", "resources": {"requests": {"memory": "1Gi"}, "limits": {"cpu": 2}}}]}`,
+			allowed: true,
+			warnings: []string{
+				"error container app requests.CPU is empty, must be 0",
+				"error container app limits.CPU: 2 > 1",
+			},
+		},
+		{
+			name:     "warn mode resource claims",
+			mode:     modeWarn,
+			spec:     `{"resourceClaims": [{"name": "gpu"}], "containers": [{"name": "app", "resources": {"requests": {"cpu": "500m", "memory": "1Gi"}}}]}`,
+			allowed:  true,
+			warnings: []string{"error pod resourceClaims: 1 > 0"},
+		},
+		{
+			name:    "warn mode within limit",
+			mode:    modeWarn,
+			spec:    `{"containers": [{"name": "app", "resources": {"requests": {"cpu": "500m", "memory": "1Gi"}, "limits": {"cpu": 1}}}]}`,
+			allowed: true,
+		},
+		{
+			name: "enforce mode over limit",
+			mode: modeEnforce,
+			spec: `{"containers": [{"name": "app", "resources": {"requests": {"cpu": "500m", "memory": "1Gi"}, "limits": {"cpu": 2}}}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		rra := New(&MockConfiger{cpu: &cpu, maxClaims: &maxClaims, mode: tt.mode}, Options{})
+		allowed := testutil.ToFloat64(admissionCounter.WithLabelValues(strconv.FormatBool(tt.allowed), tt.mode, "false"))
+
+		resp, err := rra.HandleAdmission(context.Background(), podReview(tt.spec).Request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.allowed, resp.Allowed, tt.name)
+		assert.Equal(t, tt.warnings, resp.Warnings, tt.name)
+		assert.Equal(t, allowed+1, testutil.ToFloat64(admissionCounter.WithLabelValues(strconv.FormatBool(tt.allowed), tt.mode, "false")), tt.name)
+		if tt.mode == modeWarn {
+			assert.Equal(t, modeWarn, resp.AuditAnnotations[modeAnnotation], tt.name)
+		}
+	}
+}
+
+func TestHandleAdmissionDryRun(t *testing.T) {
+	cpu := resource.MustParse("1")
+	rra := New(&MockConfiger{cpu: &cpu}, Options{})
+	overLimit := `{"containers": [{"name": "app", "resources": {"requests": {"cpu": "500m", "memory": "1Gi"}, "limits": {"cpu": 2}}}]}`
+
+	for _, dryRun := range []bool{true, false} {
+		req := podReview(overLimit).Request
+		req.DryRun = &dryRun
+
+		label := strconv.FormatBool(dryRun)
+		denied := testutil.ToFloat64(admissionCounter.WithLabelValues("false", modeEnforce, label))
+		other := testutil.ToFloat64(admissionCounter.WithLabelValues("false", modeEnforce, strconv.FormatBool(!dryRun)))
+
+		resp, err := rra.HandleAdmission(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// decision doesn't depend on dry run
+		assert.False(t, resp.Allowed, label)
+		assert.Equal(t, "error container app limits.CPU: 2 > 1", resp.Result.Message, label)
+		assert.Equal(t, denied+1, testutil.ToFloat64(admissionCounter.WithLabelValues("false", modeEnforce, label)), label)
+		assert.Equal(t, other, testutil.ToFloat64(admissionCounter.WithLabelValues("false", modeEnforce, strconv.FormatBool(!dryRun))), label)
+	}
+}
+
+func TestHandleAdmissionRequireLimits(t *testing.T) {
+	cpu := resource.MustParse("1")
+	mem := resource.MustParse("1Gi")
+
+	tests := []struct {
+		name        string
+		conf        *MockConfiger
+		spec        string
+		message     string
+		noViolation bool
+	}{
+		{
+			name:        "container without limits passes without requireLimits",
+			conf:        &MockConfiger{cpu: &cpu, mem: &mem},
+			spec:        `{"containers": [{"name": "app", "resources": {"requests": {"cpu": "500m", "memory": "512Mi"}}}]}`,
+			noViolation: true,
+		},
+		{
+			name:    "container without limits",
+			conf:    &MockConfiger{cpu: &cpu, mem: &mem, reqLimits: true},
+			spec:    `{"containers": [{"name": "app", "resources": {"requests": {"cpu": "500m", "memory": "512Mi"}}}]}`,
+			message: "error container app limits.CPU is empty, limit is required, must be at most 1",
+		},
+		{
+			name:    "container without memory limit",
+			conf:    &MockConfiger{cpu: &cpu, mem: &mem, reqLimits: true},
+			spec:    `{"containers": [{"name": "app", "resources": {"requests": {"cpu": "500m", "memory": "512Mi"}, "limits": {"cpu": "1"}}}]}`,
+			message: "error container app limits.Memory is empty, limit is required, must be at most 1Gi",
+		},
+		{
+			name:    "init container without limits",
+			conf:    &MockConfiger{cpu: &cpu, mem: &mem, reqLimits: true},
+			spec:    `{"initContainers": [{"name": "migrate", "resources": {"requests": {"cpu": "500m", "memory": "512Mi"}}}], "containers": [{"name": "app", "resources": {"requests": {"cpu": "500m", "memory": "512Mi"}, "limits": {"cpu": "1", "memory": "1Gi"}}}]}`,
+			message: "error init container migrate limits.CPU is empty, limit is required, must be at most 1",
+		},
+		{
+			name:        "only limits with configured maximum are required",
+			conf:        &MockConfiger{cpu: &cpu, reqLimits: true},
+			spec:        `{"containers": [{"name": "app", "resources": {"requests": {"cpu": "500m", "memory": "512Mi"}, "limits": {"cpu": "1"}}}]}`,
+			noViolation: true,
+		},
+		{
+			name:        "pod level limits satisfy it",
+			conf:        &MockConfiger{cpu: &cpu, mem: &mem, reqLimits: true},
+			spec:        `{"resources": {"limits": {"cpu": "1", "memory": "1Gi"}}, "containers": [{"name": "app", "resources": {"requests": {"cpu": "500m", "memory": "512Mi"}}}]}`,
+			noViolation: true,
+		},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{conf: tt.conf}
+		resp, err := rra.HandleAdmission(context.Background(), podReview(tt.spec).Request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if tt.noViolation {
+			assert.True(t, resp.Allowed, tt.name)
+			continue
+		}
+
+		assert.False(t, resp.Allowed, tt.name)
+		assert.Equal(t, tt.message, resp.Result.Message, tt.name)
+		assert.Equal(t, string(reasonMissingLimit), resp.AuditAnnotations[denialReasonAnnotation], tt.name)
+	}
+}
+
+func TestHandleAdmissionRequestFraction(t *testing.T) {
+	fraction := 0.5
+	conf := &MockConfiger{fraction: &fraction, tolerance: 0.05}
+	container := func(requests, limits string) string {
+		return `{"containers": [{"name": "app", "resources": {"requests": {` + requests + `}, "limits": {` + limits + `}}}]}`
+	}
+
+	tests := []struct {
+		name    string
+		spec    string
+		message string
+	}{
+		{
+			name: "requests are half of limits",
+			spec: container(`"cpu": "500m", "memory": "1Gi"`, `"cpu": "1", "memory": "2Gi"`),
+		},
+		{
+			name: "within tolerance",
+			spec: container(`"cpu": "540m", "memory": "950Mi"`, `"cpu": "1", "memory": "2Gi"`),
+		},
+		{
+			name:    "cpu request too low",
+			spec:    container(`"cpu": "250m", "memory": "1Gi"`, `"cpu": "1", "memory": "2Gi"`),
+			message: "error container app requests.CPU / limits.CPU: 0.25, must be 0.50 ± 0.05",
+		},
+		{
+			name:    "memory request equals limit",
+			spec:    container(`"cpu": "500m", "memory": "2Gi"`, `"cpu": "1", "memory": "2Gi"`),
+			message: "error container app requests.Memory / limits.Memory: 1.00, must be 0.50 ± 0.05",
+		},
+		{
+			name: "resources without limit are skipped",
+			spec: container(`"cpu": "100m", "memory": "1Gi"`, `"memory": "2Gi"`),
+		},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{conf: conf}
+
+		resp, err := rra.HandleAdmission(context.Background(), podReview(tt.spec).Request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.message == "", resp.Allowed, tt.name)
+		if tt.message != "" {
+			assert.Equal(t, tt.message, resp.Result.Message, tt.name)
+			assert.Equal(t, string(reasonRequestFractionMismatch), resp.AuditAnnotations[denialReasonAnnotation], tt.name)
+		}
+	}
+}
+
+func TestHandleAdmissionLimitRequestRatio(t *testing.T) {
+	ratio := 4.0
+	container := func(requests, limits string) string {
+		return `{"containers": [{"name": "app", "resources": {"requests": {` + requests + `}, "limits": {` + limits + `}}}]}`
+	}
+
+	tests := []struct {
+		name    string
+		conf    *MockConfiger
+		spec    string
+		message string
+	}{
+		{
+			name: "cpu within ratio",
+			conf: &MockConfiger{ratio: &ratio},
+			spec: container(`"cpu": "500m", "memory": "1Gi"`, `"cpu": "2"`),
+		},
+		{
+			name:    "cpu over ratio",
+			conf:    &MockConfiger{ratio: &ratio},
+			spec:    container(`"cpu": "100m", "memory": "1Gi"`, `"cpu": "16"`),
+			message: "error container app limits.CPU / requests.CPU: 160.00 > 4.00",
+		},
+		{
+			name: "memory within ratio",
+			conf: &MockConfiger{ratio: &ratio},
+			spec: container(`"cpu": "1", "memory": "1Gi"`, `"memory": "4Gi"`),
+		},
+		{
+			name:    "memory over ratio",
+			conf:    &MockConfiger{ratio: &ratio},
+			spec:    container(`"cpu": "1", "memory": "512Mi"`, `"memory": "4Gi"`),
+			message: "error container app limits.Memory / requests.Memory: 8.00 > 4.00",
+		},
+		{
+			name: "zero request skipped",
+			conf: &MockConfiger{ratio: &ratio},
+			spec: container(`"cpu": 0, "memory": "1Gi"`, `"cpu": "16"`),
+		},
+		{
+			name:    "zero request denied",
+			conf:    &MockConfiger{ratio: &ratio, zeroRatio: zeroRequestDeny},
+			spec:    container(`"cpu": 0, "memory": "1Gi"`, `"cpu": "16"`),
+			message: "error container app limits.CPU / requests.CPU is infinite, requests.CPU is 0",
+		},
+		{
+			name: "disabled",
+			conf: &MockConfiger{},
+			spec: container(`"cpu": "100m", "memory": "1Gi"`, `"cpu": "16"`),
+		},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{conf: tt.conf}
+
+		resp, err := rra.HandleAdmission(context.Background(), podReview(tt.spec).Request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.message == "", resp.Allowed, tt.name)
+		if tt.message != "" {
+			assert.Equal(t, tt.message, resp.Result.Message, tt.name)
+		}
+	}
+}
+
+func TestHandleAdmissionPodLevelResources(t *testing.T) {
+	cpu := resource.MustParse("2")
+	mem := resource.MustParse("2Gi")
+	conf := &MockConfiger{cpu: &cpu, mem: &mem, cpuRequest: &cpu, memRequest: &mem}
+
+	tests := []struct {
+		name               string
+		spec               string
+		requireMemoryLimit bool
+		message            string
+	}{
+		{
+			name: "only pod level resources",
+			spec: `{"resources": {"requests": {"cpu": "1", "memory": "1Gi"}, "limits": {"cpu": "2", "memory": "2Gi"}},
+				"containers": [{"name": "app"}, {"name": "sidecar"}]}`,
+			requireMemoryLimit: true,
+		},
+		{
+			name: "pod level resources over ceiling",
+			spec: `{"resources": {"requests": {"cpu": "1", "memory": "1Gi"}, "limits": {"cpu": "4", "memory": "2Gi"}},
+				"containers": [{"name": "app"}]}`,
+			message: "error pod limits.CPU: 4 > 2",
+		},
+		{
+			name:    "pod level cpu only",
+			spec:    `{"resources": {"requests": {"cpu": "1"}}, "containers": [{"name": "app"}]}`,
+			message: "error container app requests.Memory is empty, must be 0",
+		},
+		{
+			name:               "memory limit required without pod level limit",
+			spec:               `{"resources": {"requests": {"cpu": "1", "memory": "1Gi"}}, "containers": [{"name": "app"}]}`,
+			requireMemoryLimit: true,
+			message:            "error container app limits.Memory is empty, memory limit is required",
+		},
+		{
+			name:    "container resources still validated",
+			spec:    `{"resources": {"requests": {"cpu": "1", "memory": "1Gi"}}, "containers": [{"name": "app", "resources": {"limits": {"memory": "4Gi"}}}]}`,
+			message: "error container app limits.Memory: 4Gi > 2Gi",
+		},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{
+			conf: conf,
+			opts: Options{RequireMemoryLimit: tt.requireMemoryLimit},
+		}
+
+		resp, err := rra.HandleAdmission(context.Background(), podReview(tt.spec).Request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.message == "", resp.Allowed, tt.name)
+		if tt.message != "" {
+			assert.Equal(t, tt.message, resp.Result.Message, tt.name)
+		}
+	}
+}
+
+func TestHandleAdmissionCPULimitPolicy(t *testing.T) {
+	withLimit := `{"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"cpu": 1}}}]}`
+	withoutLimit := `{"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}}}]}`
+
+	tests := []struct {
+		policy  string
+		spec    string
+		message string
+	}{
+		{policy: "required", spec: withLimit},
+		{policy: "required", spec: withoutLimit, message: "error container app limits.CPU is empty, cpu limit is required"},
+		{policy: "forbidden", spec: withLimit, message: "error container app limits.CPU is set, cpu limit is forbidden"},
+		{policy: "forbidden", spec: withoutLimit},
+		{policy: "optional", spec: withLimit},
+		{policy: "optional", spec: withoutLimit},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{conf: &MockConfiger{cpuPolicy: tt.policy}}
+
+		resp, err := rra.HandleAdmission(context.Background(), podReview(tt.spec).Request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.message == "", resp.Allowed, tt.policy+" "+tt.spec)
+		if tt.message != "" {
+			assert.Equal(t, tt.message, resp.Result.Message, tt.policy)
+		}
+	}
+}
+
+func TestHandleAdmissionSwapPolicy(t *testing.T) {
+	swapEnabled := `{"containers": [{"name": "app", "resources": {"requests": {"cpu": "100m", "memory": "1Gi"}, "limits": {"memory": "2Gi"}}}]}`
+	noSwap := `{"containers": [{"name": "app", "resources": {"requests": {"cpu": "100m", "memory": "1Gi"}, "limits": {"memory": "1Gi"}}}]}`
+
+	tests := []struct {
+		policy  string
+		spec    string
+		message string
+	}{
+		{policy: "forbid", spec: swapEnabled, message: "error container app can use swap, swap is forbidden, limits.Memory must equal requests.Memory"},
+		{policy: "forbid", spec: noSwap},
+		{policy: "allow", spec: swapEnabled},
+		{policy: "", spec: swapEnabled},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{conf: &MockConfiger{swapPolicy: tt.policy}}
+
+		resp, err := rra.HandleAdmission(context.Background(), podReview(tt.spec).Request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.message == "", resp.Allowed, tt.policy+" "+tt.spec)
+		if tt.message != "" {
+			assert.Equal(t, tt.message, resp.Result.Message, tt.policy)
+			assert.Equal(t, string(reasonSwapForbidden), resp.AuditAnnotations[denialReasonAnnotation])
+		}
+	}
+}
+
+func TestHandleAdmissionWarmup(t *testing.T) {
+	cpu := resource.MustParse("1")
+	started := time.Unix(0, 0)
+	now := started
+	rra := &ResourceRequestsAdmission{
+		conf:    &MockConfiger{cpu: &cpu},
+		opts:    Options{Warmup: time.Minute},
+		started: started,
+		now:     func() time.Time { return now },
+	}
+
+	req := podReview(`{"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"cpu": 2}}}]}`).Request
+
+	now = started.Add(30 * time.Second)
+	resp, err := rra.HandleAdmission(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, resp.Allowed)
+
+	now = started.Add(time.Minute)
+	resp, err = rra.HandleAdmission(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, resp.Allowed)
+}
+
+func TestHandleAdmissionNamespacePVCSize(t *testing.T) {
+	budget := resource.MustParse("10Gi")
+	rra := &ResourceRequestsAdmission{
+		conf:     &MockConfiger{pvcNsSize: &budget},
+		pvcUsage: newUsageTracker(),
+	}
+
+	dryRun := true
+	tests := []struct {
+		name    string
+		size    string
+		dryRun  *bool
+		allowed bool
+	}{
+		{name: "a", size: "4Gi", allowed: true},
+		{name: "b", size: "4Gi", allowed: true},
+		{name: "c", size: "4Gi", allowed: false},
+		// update replaces previous size
+		{name: "a", size: "2Gi", allowed: true},
+		{name: "c", size: "2Gi", dryRun: &dryRun, allowed: true},
+		{name: "c", size: "2Gi", allowed: true},
+		{name: "d", size: "3Gi", allowed: false},
+	}
+
+	for _, tt := range tests {
+		resp, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+			UID:       "e911857d-c318-11e8-bbad-025000000001",
+			Kind:      v1.GroupVersionKind{Kind: "PersistentVolumeClaim"},
+			Namespace: "default",
+			Operation: v1beta1.Create,
+			DryRun:    tt.dryRun,
+			Object: runtime.RawExtension{
+				Raw: []byte(`{"metadata": {"name": "` + tt.name + `"}, "spec": {"resources": {"requests": {"storage": "` + tt.size + `"}}}}`),
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.allowed, resp.Allowed, tt.name+" "+tt.size)
+	}
+}
+
+// serveReview feeds raw AdmissionReview through ServeHTTP and returns response status and body
+func serveReview(t *testing.T, handler http.Handler, body []byte) (int, []byte) {
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	return rec.Code, rec.Body.Bytes()
+}
+
+// assertGolden compares JSON body with golden file, or rewrites the file if -update flag is set
+func assertGolden(t *testing.T, path string, body []byte) {
+	if *updateGolden {
+		var out bytes.Buffer
+		if err := json.Indent(&out, body, "", "  "); err != nil {
+			t.Fatal(err)
+		}
+		out.WriteString("\n")
+
+		if err := ioutil.WriteFile(path, out.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	golden, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.JSONEq(t, string(golden), string(body), path)
+}
+
+// TestServeReviewFixtures round-trips fixtures of every AdmissionReview version,
+// run `go test -run TestServeReviewFixtures -update` to regenerate golden files.
+func TestServeReviewFixtures(t *testing.T) {
+	cpu := resource.MustParse("1")
+	pvcSize := resource.MustParse("10Gi")
+	server := &AdmissionControllerServer{
+		AdmissionController: &ResourceRequestsAdmission{conf: &MockConfiger{cpu: &cpu, pvcSize: &pvcSize}},
+		Decoder:             codecs.UniversalDeserializer(),
+	}
+
+	fixtures, err := filepath.Glob("testdata/reviews/*/*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tested int
+	for _, fixture := range fixtures {
+		if strings.HasSuffix(fixture, ".golden.json") {
+			continue
+		}
+		tested++
+
+		body, err := ioutil.ReadFile(fixture)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		code, resp := serveReview(t, server, body)
+		if !assert.Equal(t, http.StatusOK, code, fixture) {
+			continue
+		}
+
+		var request, response struct {
+			v1.TypeMeta `json:",inline"`
+			Request     *v1beta1.AdmissionRequest  `json:"request"`
+			Response    *v1beta1.AdmissionResponse `json:"response"`
+		}
+		if err := json.Unmarshal(body, &request); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(resp, &response); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, request.APIVersion, response.APIVersion, fixture)
+		assert.Equal(t, request.Kind, response.Kind, fixture)
+		if assert.NotNil(t, response.Response, fixture) {
+			assert.Equal(t, request.Request.UID, response.Response.UID, fixture)
+		}
+
+		assertGolden(t, strings.TrimSuffix(fixture, ".json")+".golden.json", resp)
+	}
+
+	assert.Equal(t, 6, tested)
+}
+
+func TestHandleAdmissionPodMemory(t *testing.T) {
+	podMem := resource.MustParse("2Gi")
+
+	tests := []struct {
+		name      string
+		emptyDirs bool
+		spec      string
+		message   string
+	}{
+		{
+			name: "containers within pod memory",
+			spec: `{"containers": [
+				{"name": "app", "resources": {"requests": {"cpu": 0, "memory": "1Gi"}, "limits": {"memory": "1Gi"}}},
+				{"name": "proxy", "resources": {"requests": {"cpu": 0, "memory": "512Mi"}, "limits": {"memory": "512Mi"}}}]}`,
+		},
+		{
+			name: "containers over pod memory",
+			spec: `{"containers": [
+				{"name": "app", "resources": {"requests": {"cpu": 0, "memory": "1Gi"}, "limits": {"memory": "1536Mi"}}},
+				{"name": "proxy", "resources": {"requests": {"cpu": 0, "memory": "512Mi"}, "limits": {"memory": "1Gi"}}}]}`,
+			message: "error pod memory limits.Memory: 2560Mi > 2Gi",
+		},
+		{
+			name: "sidecar counts, init container doesn't add up",
+			spec: `{"initContainers": [
+				{"name": "migrate", "resources": {"requests": {"cpu": 0, "memory": "1Gi"}, "limits": {"memory": "2Gi"}}},
+				{"name": "proxy", "restartPolicy": "Always", "resources": {"requests": {"cpu": 0, "memory": "1Gi"}, "limits": {"memory": "1Gi"}}}],
+				"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": "1Gi"}, "limits": {"memory": "1536Mi"}}}]}`,
+			message: "error pod memory limits.Memory: 2560Mi > 2Gi",
+		},
+		{
+			name: "pod level limit wins over containers",
+			spec: `{"resources": {"limits": {"memory": "2Gi"}}, "containers": [
+				{"name": "app", "resources": {"requests": {"cpu": 0, "memory": "1Gi"}, "limits": {"memory": "2Gi"}}},
+				{"name": "proxy", "resources": {"requests": {"cpu": 0, "memory": "512Mi"}, "limits": {"memory": "2Gi"}}}]}`,
+		},
+		{
+			name: "memory emptyDir not counted",
+			spec: `{"volumes": [{"name": "cache", "emptyDir": {"medium": "Memory", "sizeLimit": "1Gi"}}],
+				"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": "1Gi"}, "limits": {"memory": "1536Mi"}}}]}`,
+		},
+		{
+			name:      "memory emptyDir over pod memory",
+			emptyDirs: true,
+			spec: `{"volumes": [
+				{"name": "cache", "emptyDir": {"medium": "Memory", "sizeLimit": "512Mi"}},
+				{"name": "shm", "emptyDir": {"medium": "Memory", "sizeLimit": "256Mi"}},
+				{"name": "tmp", "emptyDir": {"sizeLimit": "10Gi"}},
+				{"name": "unsized", "emptyDir": {"medium": "Memory"}}],
+				"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": "1Gi"}, "limits": {"memory": "1536Mi"}}}]}`,
+			message: "error pod memory limits.Memory + emptyDir cache, shm: 2304Mi > 2Gi",
+		},
+		{
+			name:      "memory emptyDir within pod memory",
+			emptyDirs: true,
+			spec: `{"volumes": [{"name": "cache", "emptyDir": {"medium": "Memory", "sizeLimit": "512Mi"}}],
+				"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": "1Gi"}, "limits": {"memory": "1536Mi"}}}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{conf: &MockConfiger{podMem: &podMem, emptyDirs: tt.emptyDirs}}
+
+		resp, err := rra.HandleAdmission(context.Background(), podReview(tt.spec).Request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.message == "", resp.Allowed, tt.name)
+		if tt.message != "" {
+			assert.Equal(t, tt.message, resp.Result.Message, tt.name)
+			assert.Equal(t, string(reasonPodMemoryExceeded), resp.AuditAnnotations[denialReasonAnnotation], tt.name)
+		}
+	}
+}
+
+func TestHandleAdmissionLabelLimits(t *testing.T) {
+	cpu := resource.MustParse("2")
+	canaryCPU := resource.MustParse("500m")
+	conf := &MockConfiger{
+		cpu: &cpu,
+		labels: []labelLimit{{
+			matchLabels: map[string]string{"track": "canary"},
+			limit:       LimitResource{CPULimit: &canaryCPU},
+		}},
+	}
+
+	tests := []struct {
+		name    string
+		track   string
+		cpu     string
+		message string
+	}{
+		{name: "stable within limit", track: "stable", cpu: "2"},
+		{name: "canary within limit", track: "canary", cpu: "500m"},
+		{name: "canary over limit", track: "canary", cpu: "1", message: "error container app limits.CPU: 1 > 500m"},
+		{name: "stable over limit", track: "stable", cpu: "3", message: "error container app limits.CPU: 3 > 2"},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{conf: conf}
+
+		resp, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+			UID:       "e911857d-c318-11e8-bbad-025000000001",
+			Kind:      v1.GroupVersionKind{Kind: deploymentKind},
+			Namespace: "default",
+			Operation: v1beta1.Create,
+			Object: runtime.RawExtension{Raw: []byte(`{"metadata": {"name": "web-` + tt.track + `"}, "spec": {"template": {
+				"metadata": {"labels": {"app": "web", "track": "` + tt.track + `"}},
+				"spec": {"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"cpu": "` + tt.cpu + `"}}}]}}}}`)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.message == "", resp.Allowed, tt.name)
+		if tt.message != "" {
+			assert.Equal(t, tt.message, resp.Result.Message, tt.name)
+		}
+	}
+}
+
+func TestHandleAdmissionGrandfathered(t *testing.T) {
+	cpu := resource.MustParse("1")
+	rra := &ResourceRequestsAdmission{
+		conf: &MockConfiger{cpu: &cpu},
+		opts: Options{GrandfatherCutoff: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	tests := []struct {
+		name      string
+		operation v1beta1.Operation
+		created   string
+		allowed   bool
+	}{
+		{name: "grandfathered update", operation: v1beta1.Update, created: "2023-06-01T00:00:00Z", allowed: true},
+		{name: "update of new workload", operation: v1beta1.Update, created: "2024-06-01T00:00:00Z"},
+		{name: "create", operation: v1beta1.Create},
+		// creationTimestamp isn't set by API server before admission, but clients may send it
+		{name: "create with old timestamp", operation: v1beta1.Create, created: "2023-06-01T00:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		metadata := `{"name": "web"}`
+		if tt.created != "" {
+			metadata = `{"name": "web", "creationTimestamp": "` + tt.created + `"}`
+		}
+
+		resp, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+			UID:       "e911857d-c318-11e8-bbad-025000000001",
+			Kind:      v1.GroupVersionKind{Kind: deploymentKind},
+			Namespace: "default",
+			Operation: tt.operation,
+			Object: runtime.RawExtension{Raw: []byte(`{"metadata": ` + metadata + `, "spec": {"template": {
+				"spec": {"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"cpu": "2"}}}]}}}}`)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.allowed, resp.Allowed, tt.name)
+		if tt.allowed {
+			assert.Equal(t, "true", resp.AuditAnnotations[grandfatheredAnnotation], tt.name)
+		}
+	}
+}
+
+func TestHandleAdmissionExemptTolerations(t *testing.T) {
+	cpu := resource.MustParse("1")
+	rra := &ResourceRequestsAdmission{
+		conf: &MockConfiger{cpu: &cpu, exemptTaints: []string{"dedicated", "node-role.kubernetes.io/control-plane"}},
+	}
+
+	tests := []struct {
+		name        string
+		tolerations string
+		allowed     bool
+		key         string
+	}{
+		{name: "tolerates exempt taint", tolerations: `[{"key": "dedicated", "operator": "Equal", "value": "ml", "effect": "NoSchedule"}]`, allowed: true, key: "dedicated"},
+		{name: "tolerates exempt taint with exists", tolerations: `[{"key": "node-role.kubernetes.io/control-plane", "operator": "Exists"}]`, allowed: true, key: "node-role.kubernetes.io/control-plane"},
+		{name: "tolerates other taint", tolerations: `[{"key": "node.kubernetes.io/not-ready", "operator": "Exists"}]`},
+		{name: "tolerates every taint", tolerations: `[{"operator": "Exists"}]`},
+		{name: "no tolerations", tolerations: `[]`},
+	}
+
+	for _, tt := range tests {
+		resp, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+			UID:       "e911857d-c318-11e8-bbad-025000000001",
+			Kind:      v1.GroupVersionKind{Kind: deploymentKind},
+			Namespace: "default",
+			Operation: v1beta1.Create,
+			Object: runtime.RawExtension{Raw: []byte(`{"metadata": {"name": "trainer"}, "spec": {"template": {"spec": {"tolerations": ` + tt.tolerations + `,
+				"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"cpu": "2"}}}]}}}}`)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.allowed, resp.Allowed, tt.name)
+		assert.Equal(t, tt.key, resp.AuditAnnotations[exemptTolerationAnnotation], tt.name)
+	}
+}
+
+func TestHandleAdmissionExemptUsers(t *testing.T) {
+	cpu := resource.MustParse("1")
+	rra := &ResourceRequestsAdmission{
+		conf: &MockConfiger{
+			cpu:          &cpu,
+			exemptUsers:  []*regexp.Regexp{regexp.MustCompile(`^(?:system:serviceaccount:kube-system:.*)$`)},
+			exemptGroups: []string{"platform:admins"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		username string
+		groups   []string
+		allowed  bool
+		user     string
+		group    string
+	}{
+		{name: "exempt service account", username: "system:serviceaccount:kube-system:replicaset-controller", allowed: true, user: "system:serviceaccount:kube-system:replicaset-controller"},
+		{name: "exempt group", username: "jane", groups: []string{"system:authenticated", "platform:admins"}, allowed: true, group: "platform:admins"},
+		{name: "other service account", username: "system:serviceaccount:team-a:deployer", groups: []string{"system:serviceaccounts"}},
+		{name: "anonymous"},
+	}
+
+	for _, tt := range tests {
+		req := podReview(`{"containers": [{"name": "app", "resources": {"requests": {"cpu": "500m", "memory": "1Gi"}, "limits": {"cpu": 2}}}]}`).Request
+		req.UserInfo.Username = tt.username
+		req.UserInfo.Groups = tt.groups
+
+		resp, err := rra.HandleAdmission(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.allowed, resp.Allowed, tt.name)
+		assert.Equal(t, tt.user, resp.AuditAnnotations[exemptUserAnnotation], tt.name)
+		assert.Equal(t, tt.group, resp.AuditAnnotations[exemptGroupAnnotation], tt.name)
+	}
+}
+
+func TestHandleAdmissionContainers(t *testing.T) {
+	cpu := resource.MustParse("2")
+	proxyCPU := resource.MustParse("500m")
+	rra := &ResourceRequestsAdmission{
+		conf: &MockConfiger{cpu: &cpu, containers: map[string]LimitResource{
+			"istio-proxy": {CPULimit: &proxyCPU},
+		}},
+	}
+
+	tests := []struct {
+		name     string
+		appCPU   string
+		proxyCPU string
+		allowed  bool
+		message  string
+	}{
+		{name: "both within caps", appCPU: "2", proxyCPU: "500m", allowed: true},
+		{name: "proxy over its cap", appCPU: "1", proxyCPU: "1", message: "error container istio-proxy limits.CPU: 1 > 500m"},
+		{name: "app over workload cap", appCPU: "3", proxyCPU: "100m", message: "error container app limits.CPU: 3 > 2"},
+	}
+
+	for _, tt := range tests {
+		resp, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+			UID:       "e911857d-c318-11e8-bbad-025000000001",
+			Kind:      v1.GroupVersionKind{Kind: deploymentKind},
+			Namespace: "default",
+			Operation: v1beta1.Create,
+			Object: runtime.RawExtension{Raw: []byte(`{"metadata": {"name": "web"}, "spec": {"template": {"spec": {"containers": [
+				{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"cpu": "` + tt.appCPU + `"}}},
+				{"name": "istio-proxy", "resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"cpu": "` + tt.proxyCPU + `"}}}]}}}}`)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.allowed, resp.Allowed, tt.name)
+		if !tt.allowed {
+			assert.Equal(t, tt.message, resp.Result.Message, tt.name)
+		}
+	}
+}
+
+func TestHandleAdmissionUserNamespace(t *testing.T) {
+	cpu := resource.MustParse("1")
+	usernsCPU := resource.MustParse("2")
+	rra := &ResourceRequestsAdmission{
+		conf: &MockConfiger{cpu: &cpu, userns: &LimitResource{CPULimit: &usernsCPU, CPULimitPolicy: cpuLimitRequired}},
+	}
+
+	tests := []struct {
+		name      string
+		hostUsers string
+		cpu       string
+		message   string
+	}{
+		{name: "user namespace within its limit", hostUsers: `"hostUsers": false,`, cpu: `"limits": {"cpu": "2"}`},
+		{name: "user namespace requires cpu limit", hostUsers: `"hostUsers": false,`, message: "error container app limits.CPU is empty, cpu limit is required"},
+		{name: "host users over limit", hostUsers: `"hostUsers": true,`, cpu: `"limits": {"cpu": "2"}`, message: "error container app limits.CPU: 2 > 1"},
+		{name: "host users by default over limit", cpu: `"limits": {"cpu": "2"}`, message: "error container app limits.CPU: 2 > 1"},
+		{name: "host users without cpu limit"},
+	}
+
+	for _, tt := range tests {
+		resources := `"requests": {"cpu": 0, "memory": 0}`
+		if tt.cpu != "" {
+			resources += ", " + tt.cpu
+		}
+
+		resp, err := rra.HandleAdmission(context.Background(), podReview(`{`+tt.hostUsers+` "containers": [{"name": "app", "resources": {`+resources+`}}]}`).Request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.message == "", resp.Allowed, tt.name)
+		if tt.message != "" {
+			assert.Equal(t, tt.message, resp.Result.Message, tt.name)
+		}
+	}
+}
+
+func TestHandleAdmissionObjectLabels(t *testing.T) {
+	conf := &MockConfiger{}
+	rra := &ResourceRequestsAdmission{conf: conf}
+
+	_, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+		UID:       "e911857d-c318-11e8-bbad-025000000001",
+		Kind:      v1.GroupVersionKind{Kind: deploymentKind},
+		Namespace: "default",
+		Operation: v1beta1.Create,
+		Object: runtime.RawExtension{Raw: []byte(`{"metadata": {"name": "etl", "labels": {"team": "data"}}, "spec": {"template": {
+			"metadata": {"labels": {"app": "etl"}},
+			"spec": {"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}}}]}}}}`)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// customLabels are matched against metadata labels, not pod template labels
+	assert.Equal(t, map[string]string{"team": "data"}, conf.objectLabels)
+}
+
+func sampleCount(t *testing.T, kind string) uint64 {
+	var m dto.Metric
+	if err := durationHistogram.WithLabelValues(kind).(prometheus.Histogram).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestHandleAdmissionDuration(t *testing.T) {
+	rra := &ResourceRequestsAdmission{conf: &MockConfiger{}}
+
+	pods := sampleCount(t, podKind)
+	resp, err := rra.HandleAdmission(context.Background(), podReview(`{"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}}}]}`).Request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, resp.Allowed)
+	assert.Equal(t, pods+1, sampleCount(t, podKind))
+
+	// requests which fail are observed too
+	deployments := sampleCount(t, deploymentKind)
+	_, err = rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+		UID:       "e911857d-c318-11e8-bbad-025000000001",
+		Kind:      v1.GroupVersionKind{Kind: deploymentKind},
+		Operation: v1beta1.Create,
+		Object:    runtime.RawExtension{Raw: []byte(`{"spec": `)},
+	})
+	assert.Error(t, err)
+	assert.Equal(t, deployments+1, sampleCount(t, deploymentKind))
+}
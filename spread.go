@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// topologySpreadViolation returns message if the workload has at least TopologySpreadReplicas replicas,
+// but its pod template doesn't declare topologySpreadConstraints
+func topologySpreadViolation(w *workload, limit LimitResource) (string, bool) {
+	if limit.TopologySpreadReplicas == nil || !replicatedKinds[w.kind] {
+		return "", false
+	}
+
+	if w.replicas < int64(*limit.TopologySpreadReplicas) || len(w.podSpec.TopologySpreadConstraints) > 0 {
+		return "", false
+	}
+
+	return fmt.Sprintf("error %s %s replicas: %d >= %d, topologySpreadConstraints are required", strings.ToLower(w.kind), w.name, w.replicas, *limit.TopologySpreadReplicas), true
+}
+
+// validateTopologySpread denies workload, which has at least TopologySpreadReplicas replicas without topologySpreadConstraints
+func validateTopologySpread(req *v1beta1.AdmissionRequest, w *workload, limit LimitResource) *v1beta1.AdmissionResponse {
+	message, ok := topologySpreadViolation(w, limit)
+	if !ok {
+		return nil
+	}
+
+	return &v1beta1.AdmissionResponse{
+		UID:     req.UID,
+		Allowed: false,
+		Result: &metav1.Status{
+			Message: message,
+		},
+		AuditAnnotations: reasonAnnotations(reasonTopologySpreadRequired),
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestTopologySpreadViolation(t *testing.T) {
+	spread := 3
+	limit := LimitResource{TopologySpreadReplicas: &spread}
+	constraints := corev1.PodSpec{TopologySpreadConstraints: []corev1.TopologySpreadConstraint{{TopologyKey: "topology.kubernetes.io/zone"}}}
+
+	_, ok := topologySpreadViolation(&workload{kind: deploymentKind, name: "web", replicas: 2}, limit)
+	assert.False(t, ok)
+
+	message, ok := topologySpreadViolation(&workload{kind: statefulsetKind, name: "db", replicas: 3}, limit)
+	assert.True(t, ok)
+	assert.Equal(t, "error statefulset db replicas: 3 >= 3, topologySpreadConstraints are required", message)
+
+	_, ok = topologySpreadViolation(&workload{kind: deploymentKind, name: "web", replicas: 10, podSpec: constraints}, limit)
+	assert.False(t, ok)
+
+	// job parallelism doesn't require spread constraints
+	_, ok = topologySpreadViolation(&workload{kind: jobKind, name: "batch", replicas: 10}, limit)
+	assert.False(t, ok)
+
+	_, ok = topologySpreadViolation(&workload{kind: deploymentKind, name: "web", replicas: 10}, LimitResource{})
+	assert.False(t, ok)
+}
+
+func TestHandleAdmissionTopologySpread(t *testing.T) {
+	spread := 3
+	message := "error deployment web replicas: 5 >= 3, topologySpreadConstraints are required"
+	constraints := `"topologySpreadConstraints": [{"maxSkew": 1, "topologyKey": "topology.kubernetes.io/zone", "whenUnsatisfiable": "DoNotSchedule"}],`
+
+	tests := []struct {
+		name        string
+		replicas    int
+		constraints string
+		policy      string
+		mode        string
+		allowed     bool
+		warnings    []string
+	}{
+		{name: "small workload", replicas: 2, allowed: true},
+		{name: "large workload missing spread constraints", replicas: 5},
+		{name: "large workload with spread constraints", replicas: 5, constraints: constraints, allowed: true},
+		{name: "large workload missing spread constraints with warn policy", replicas: 5, policy: topologySpreadWarn, allowed: true, warnings: []string{message}},
+		{name: "large workload missing spread constraints in warn mode", replicas: 5, mode: modeWarn, allowed: true, warnings: []string{message}},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{conf: &MockConfiger{spread: &spread, spreadPolicy: tt.policy, mode: tt.mode}}
+
+		resp, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+			UID:       "e911857d-c318-11e8-bbad-025000000001",
+			Kind:      v1.GroupVersionKind{Group: "apps", Version: "v1", Kind: deploymentKind},
+			Namespace: "default",
+			Operation: v1beta1.Create,
+			Object: runtime.RawExtension{Raw: []byte(`{"metadata": {"name": "web"}, "spec": {"replicas": ` + strconv.Itoa(tt.replicas) + `, "template": {
+				"spec": {` + tt.constraints + `"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}}}]}}}}`)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.allowed, resp.Allowed, tt.name)
+		assert.Equal(t, tt.warnings, resp.Warnings, tt.name)
+		if !tt.allowed {
+			assert.Equal(t, message, resp.Result.Message, tt.name)
+			assert.Equal(t, string(reasonTopologySpreadRequired), resp.AuditAnnotations[denialReasonAnnotation], tt.name)
+		}
+	}
+}
+
+func TestHandleAdmissionScaleTopologySpread(t *testing.T) {
+	spread := 3
+	for _, policy := range []string{"", topologySpreadWarn} {
+		rra := &ResourceRequestsAdmission{
+			conf: &MockConfiger{spread: &spread, spreadPolicy: policy},
+			opts: Options{Scales: mockScales{"web": &corev1.PodTemplateSpec{}}},
+		}
+
+		resp, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+			UID:         "e911857d-c318-11e8-bbad-025000000001",
+			Kind:        v1.GroupVersionKind{Group: "autoscaling", Version: "v1", Kind: "Scale"},
+			Resource:    v1.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"},
+			SubResource: scaleSubResource,
+			Name:        "web",
+			Namespace:   "default",
+			Operation:   v1beta1.Update,
+			Object:      runtime.RawExtension{Raw: []byte(`{"apiVersion": "autoscaling/v1", "kind": "Scale", "metadata": {"name": "web"}, "spec": {"replicas": 3}}`)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if policy == topologySpreadWarn {
+			assert.True(t, resp.Allowed)
+			assert.Equal(t, []string{"error deployment web replicas: 3 >= 3, topologySpreadConstraints are required"}, resp.Warnings)
+			continue
+		}
+
+		assert.False(t, resp.Allowed)
+		assert.Equal(t, "error deployment web replicas: 3 >= 3, topologySpreadConstraints are required", resp.Result.Message)
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// runStartupStep runs step, which loads e.g. config or certificates, and returns an error if it doesn't finish
+// before ctx is done, so that a hanging network backed mount fails startup instead of blocking it forever.
+// The step keeps running in background after the deadline, callers are expected to exit.
+func runStartupStep(ctx context.Context, name string, step func() error) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- step()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return errors.Wrapf(ctx.Err(), "%s didn't finish within startup timeout", name)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunStartupStep(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var configer *Configurer
+	err := runStartupStep(ctx, "config load", func() error {
+		var err error
+		configer, err = NewConfigurer("./testdata/test.yaml", 1*time.Hour, false)
+		return err
+	})
+	assert.NoError(t, err)
+	if assert.NotNil(t, configer) {
+		configer.Close()
+	}
+
+	err = runStartupStep(ctx, "config load", func() error {
+		_, err := NewConfigurer("./testdata/missing.yaml", 1*time.Hour, false)
+		return err
+	})
+	assert.True(t, os.IsNotExist(errors.Cause(err)), "error of the step is returned: %v", err)
+}
+
+func TestRunStartupStepSlow(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	release := make(chan struct{})
+	defer close(release)
+
+	start := time.Now()
+	// simulates certificate load from a hanging mount
+	err := runStartupStep(ctx, "certificate load", func() error {
+		<-release
+		return nil
+	})
+	assert.EqualError(t, err, "certificate load didn't finish within startup timeout: context deadline exceeded")
+	assert.Equal(t, context.DeadlineExceeded, errors.Cause(err))
+	assert.True(t, time.Since(start) < time.Second)
+}
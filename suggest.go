@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// resourceFix is a value of container resource, which satisfies the limit
+type resourceFix struct {
+	container string
+	// field is requests or limits
+	field    string
+	resource corev1.ResourceName
+	value    resource.Quantity
+}
+
+type patchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// podSpecPaths are JSON pointers of pod spec in objects of the kind
+var podSpecPaths = map[string]string{
+	podKind:                   "/spec",
+	deploymentKind:            "/spec/template/spec",
+	statefulsetKind:           "/spec/template/spec",
+	daemonsetKind:             "/spec/template/spec",
+	replicationControllerKind: "/spec/template/spec",
+	jobKind:                   "/spec/template/spec",
+	cronJobKind:               "/spec/jobTemplate/spec/template/spec",
+}
+
+// escapePointer escapes JSON pointer token, e.g. resource name nvidia.com/gpu
+func escapePointer(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+// suggestPatch returns warning with JSONPatch, which replaces resources of violations with values satisfying the limit.
+// It returns false if kind has no known pod spec path or no violation can be fixed, ephemeral containers can't be patched.
+func suggestPatch(kind string, podSpec corev1.PodSpec, violations []violation) (string, bool) {
+	specPath, ok := podSpecPaths[kind]
+	if !ok {
+		return "", false
+	}
+
+	paths := make(map[string]string, len(podSpec.InitContainers)+len(podSpec.Containers))
+	for i, container := range podSpec.InitContainers {
+		paths[container.Name] = fmt.Sprintf("%s/initContainers/%d", specPath, i)
+	}
+	for i, container := range podSpec.Containers {
+		paths[container.Name] = fmt.Sprintf("%s/containers/%d", specPath, i)
+	}
+
+	var patch []patchOperation
+	for _, v := range violations {
+		if v.fix == nil {
+			continue
+		}
+
+		containerPath, ok := paths[v.fix.container]
+		if !ok {
+			continue
+		}
+
+		patch = append(patch, patchOperation{
+			Op:    "replace",
+			Path:  fmt.Sprintf("%s/resources/%s/%s", containerPath, v.fix.field, escapePointer(string(v.fix.resource))),
+			Value: v.fix.value.String(),
+		})
+	}
+
+	if len(patch) == 0 {
+		return "", false
+	}
+
+	b, err := json.Marshal(patch)
+	if err != nil {
+		return "", false
+	}
+
+	return fmt.Sprintf("suggested patch: %s", b), true
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestHandleAdmissionSuggestPatches(t *testing.T) {
+	cpu := resource.MustParse("1")
+	mem := resource.MustParse("1Gi")
+	gpus := resource.MustParse("1")
+
+	tests := []struct {
+		name     string
+		kind     string
+		object   string
+		severity map[corev1.ResourceName]string
+		allowed  bool
+		warnings []string
+	}{
+		{
+			name: "pod cpu limit over the max",
+			kind: "Pod",
+			object: `{"metadata": {"name": "web"}, "spec": {"containers": [{"name": "app",
+				"resources": {"requests": {"cpu": "500m", "memory": "512Mi"}, "limits": {"cpu": 2}}}]}}`,
+			warnings: []string{`suggested patch: [{"op":"replace","path":"/spec/containers/0/resources/limits/cpu","value":"1"}]`},
+		},
+		{
+			name: "deployment cpu request and limit over the max",
+			kind: "Deployment",
+			object: `{"metadata": {"name": "web"}, "spec": {"template": {"spec": {"containers": [
+				{"name": "proxy", "resources": {"requests": {"cpu": "100m", "memory": "64Mi"}}},
+				{"name": "app", "resources": {"requests": {"cpu": 2, "memory": "512Mi"}, "limits": {"cpu": 4, "nvidia.com/gpu": 2}}}]}}}}`,
+			warnings: []string{`suggested patch: [{"op":"replace","path":"/spec/template/spec/containers/1/resources/requests/cpu","value":"1"},` +
+				`{"op":"replace","path":"/spec/template/spec/containers/1/resources/limits/cpu","value":"1"},` +
+				`{"op":"replace","path":"/spec/template/spec/containers/1/resources/limits/nvidia.com~1gpu","value":"1"}]`},
+		},
+		{
+			name: "cronjob init container cpu limit over the max",
+			kind: "CronJob",
+			object: `{"metadata": {"name": "backup"}, "spec": {"jobTemplate": {"spec": {"template": {"spec": {
+				"initContainers": [{"name": "init", "resources": {"requests": {"cpu": "100m", "memory": "64Mi"}, "limits": {"cpu": "1500m"}}}],
+				"containers": [{"name": "app", "resources": {"requests": {"cpu": "100m", "memory": "64Mi"}}}]}}}}}}`,
+			warnings: []string{`suggested patch: [{"op":"replace","path":"/spec/jobTemplate/spec/template/spec/initContainers/0/resources/limits/cpu","value":"1"}]`},
+		},
+		{
+			name: "warned cpu limit",
+			kind: "Pod",
+			object: `{"metadata": {"name": "web"}, "spec": {"containers": [{"name": "app",
+				"resources": {"requests": {"cpu": "500m", "memory": "512Mi"}, "limits": {"cpu": 2}}}]}}`,
+			severity: map[corev1.ResourceName]string{corev1.ResourceCPU: severityWarn},
+			allowed:  true,
+			warnings: []string{
+				"error container app limits.CPU: 2 > 1",
+				`suggested patch: [{"op":"replace","path":"/spec/containers/0/resources/limits/cpu","value":"1"}]`,
+			},
+		},
+		{
+			name: "missing request can't be fixed by replacing",
+			kind: "Pod",
+			object: `{"metadata": {"name": "web"}, "spec": {"containers": [{"name": "app",
+				"resources": {"requests": {"memory": "512Mi"}}}]}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		rra := &ResourceRequestsAdmission{
+			conf: &MockConfiger{
+				cpu:        &cpu,
+				mem:        &mem,
+				cpuRequest: &cpu,
+				memRequest: &mem,
+				severity:   tt.severity,
+				extended:   map[corev1.ResourceName]*resource.Quantity{"nvidia.com/gpu": &gpus},
+			},
+			opts: Options{SuggestPatches: true},
+		}
+
+		resp, err := rra.HandleAdmission(context.Background(), &v1beta1.AdmissionRequest{
+			UID:       "e911857d-c318-11e8-bbad-025000000001",
+			Kind:      metav1.GroupVersionKind{Kind: tt.kind},
+			Namespace: "default",
+			Operation: v1beta1.Create,
+			Object:    runtime.RawExtension{Raw: []byte(tt.object)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.allowed, resp.Allowed, tt.name)
+		assert.Equal(t, tt.warnings, resp.Warnings, tt.name)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// criticalPriorityClasses are high priority classes, whose pods can't use swap
+var criticalPriorityClasses = []string{"system-node-critical", "system-cluster-critical"}
+
+// canSwap returns true if kubelet with LimitedSwap lets the container use swap: containers of Burstable pods,
+// which aren't critical, get swap proportional to their memory request, unless the request equals memory limit
+func canSwap(podSpec corev1.PodSpec, container corev1.Container) bool {
+	if contains(criticalPriorityClasses, podSpec.PriorityClassName) || podQOSClass(podSpec) != corev1.PodQOSBurstable {
+		return false
+	}
+
+	request, ok := container.Resources.Requests[corev1.ResourceMemory]
+	if !ok || request.IsZero() {
+		return false
+	}
+
+	limit, ok := container.Resources.Limits[corev1.ResourceMemory]
+	return !ok || limit.Cmp(request) != 0
+}
+
+// validateSwap returns violation if the container can use swap, which is forbidden by the policy,
+// since memory limit doesn't cap memory the container uses with swap
+func validateSwap(podSpec corev1.PodSpec, container corev1.Container, policy string) []violation {
+	if policy != swapForbid || !canSwap(podSpec, container) {
+		return nil
+	}
+
+	return []violation{{
+		resource: corev1.ResourceMemory,
+		reason:   reasonSwapForbidden,
+		message:  fmt.Sprintf("error container %s can use swap, swap is forbidden, limits.Memory must equal requests.Memory", container.Name),
+	}}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestCanSwap(t *testing.T) {
+	resources := func(request, limit string) corev1.ResourceRequirements {
+		r := corev1.ResourceRequirements{Requests: corev1.ResourceList{}, Limits: corev1.ResourceList{}}
+		if request != "" {
+			r.Requests[corev1.ResourceMemory] = resource.MustParse(request)
+		}
+		if limit != "" {
+			r.Limits[corev1.ResourceMemory] = resource.MustParse(limit)
+		}
+		return r
+	}
+
+	tests := []struct {
+		name     string
+		priority string
+		request  string
+		limit    string
+		swap     bool
+	}{
+		{name: "burstable with lower request", request: "1Gi", limit: "2Gi", swap: true},
+		{name: "burstable without limit", request: "1Gi", swap: true},
+		{name: "burstable without request", limit: "1Gi"},
+		{name: "request equals limit", request: "1Gi", limit: "1Gi"},
+		{name: "best effort"},
+		{name: "critical", priority: "system-node-critical", request: "1Gi", limit: "2Gi"},
+	}
+
+	for _, tt := range tests {
+		container := corev1.Container{Name: "app", Resources: resources(tt.request, tt.limit)}
+		podSpec := corev1.PodSpec{PriorityClassName: tt.priority, Containers: []corev1.Container{container}}
+		assert.Equal(t, tt.swap, canSwap(podSpec, container), tt.name)
+	}
+
+	// Guaranteed pod, both CPU and memory requests equal limits
+	container := corev1.Container{Name: "app", Resources: corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1"), corev1.ResourceMemory: resource.MustParse("1Gi")},
+		Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1"), corev1.ResourceMemory: resource.MustParse("1Gi")},
+	}}
+	assert.False(t, canSwap(corev1.PodSpec{Containers: []corev1.Container{container}}, container))
+}
+
+func TestValidateSwap(t *testing.T) {
+	container := corev1.Container{Name: "app", Resources: corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+	}}
+	podSpec := corev1.PodSpec{Containers: []corev1.Container{container}}
+
+	assert.Empty(t, validateSwap(podSpec, container, swapAllow))
+	assert.Empty(t, validateSwap(podSpec, container, ""))
+	if violations := validateSwap(podSpec, container, swapForbid); assert.Len(t, violations, 1) {
+		assert.Equal(t, reasonSwapForbidden, violations[0].reason)
+		assert.Equal(t, corev1.ResourceMemory, violations[0].resource)
+	}
+}
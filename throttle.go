@@ -0,0 +1,96 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/api/admission/v1beta1"
+)
+
+// suppressedDenialLogsCounter counts denial logs dropped by denialThrottle, denials themselves are counted as usual
+var suppressedDenialLogsCounter = promauto.NewCounter(prometheus.CounterOpts{Name: "denial_logs_suppressed_total"})
+
+// denialThrottle is a token bucket per namespace, which limits denial logs, so that a controller retrying
+// a denied workload doesn't flood the logs. Logs suppressed since the last allowed one are reported with it.
+type denialThrottle struct {
+	burst   float64
+	rate    float64
+	buckets map[string]*denialBucket
+	m       sync.Mutex
+}
+
+type denialBucket struct {
+	tokens     float64
+	last       time.Time
+	suppressed int
+}
+
+// newDenialThrottle returns throttle allowing burst logs per namespace, refilled by rate logs per second
+func newDenialThrottle(burst int, rate float64) *denialThrottle {
+	return &denialThrottle{
+		burst:   float64(burst),
+		rate:    rate,
+		buckets: make(map[string]*denialBucket),
+	}
+}
+
+// allow takes a token of the namespace bucket at now, it returns false if the bucket is empty
+// and number of logs suppressed since the last allowed one otherwise
+func (dt *denialThrottle) allow(namespace string, now time.Time) (suppressed int, ok bool) {
+	dt.m.Lock()
+	defer dt.m.Unlock()
+
+	b, found := dt.buckets[namespace]
+	if !found {
+		b = &denialBucket{tokens: dt.burst, last: now}
+		dt.buckets[namespace] = b
+	}
+
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * dt.rate
+		if b.tokens > dt.burst {
+			b.tokens = dt.burst
+		}
+		b.last = now
+	}
+
+	if b.tokens < 1 {
+		b.suppressed++
+		return 0, false
+	}
+
+	b.tokens--
+	suppressed = b.suppressed
+	b.suppressed = 0
+	return suppressed, true
+}
+
+// logDenial logs denial of the request at info level, unless it's throttled in request's namespace, and returns true if it was logged.
+// Healthchecks are denied on purpose, so they are not logged.
+func (rra *ResourceRequestsAdmission) logDenial(req *v1beta1.AdmissionRequest, format string, args ...interface{}) bool {
+	if req.UID == healthcheckUID {
+		return false
+	}
+
+	if rra.denials == nil {
+		log.Infof(format, args...)
+		return true
+	}
+
+	suppressed, ok := rra.denials.allow(req.Namespace, rra.now())
+	if !ok {
+		suppressedDenialLogsCounter.Inc()
+		return false
+	}
+
+	if suppressed > 0 {
+		log.WithField("suppressed", suppressed).Infof(format, args...)
+		return true
+	}
+
+	log.Infof(format, args...)
+	return true
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestDenialThrottle(t *testing.T) {
+	dt := newDenialThrottle(2, 0.5)
+	now := time.Unix(0, 0)
+
+	for i := 0; i < 2; i++ {
+		suppressed, ok := dt.allow("team-a", now)
+		assert.True(t, ok)
+		assert.Equal(t, 0, suppressed)
+	}
+
+	for i := 0; i < 3; i++ {
+		_, ok := dt.allow("team-a", now)
+		assert.False(t, ok)
+	}
+
+	// buckets are per namespace
+	_, ok := dt.allow("team-b", now)
+	assert.True(t, ok)
+
+	// a token is refilled every 2s, logs suppressed meanwhile are reported with the next allowed one
+	now = now.Add(time.Second)
+	_, ok = dt.allow("team-a", now)
+	assert.False(t, ok)
+
+	now = now.Add(time.Second)
+	suppressed, ok := dt.allow("team-a", now)
+	assert.True(t, ok)
+	assert.Equal(t, 4, suppressed)
+
+	// refill doesn't exceed burst
+	now = now.Add(time.Hour)
+	for i := 0; i < 2; i++ {
+		_, ok = dt.allow("team-a", now)
+		assert.True(t, ok)
+	}
+	_, ok = dt.allow("team-a", now)
+	assert.False(t, ok)
+}
+
+func TestHandleAdmissionDenialLogThrottle(t *testing.T) {
+	hook := test.NewGlobal()
+	defer hook.Reset()
+	defer log.SetLevel(log.GetLevel())
+	log.SetLevel(log.InfoLevel)
+
+	cpu := resource.MustParse("1")
+	now := time.Unix(0, 0)
+	rra := &ResourceRequestsAdmission{
+		conf:    &MockConfiger{cpu: &cpu},
+		opts:    Options{NamespaceDenialMetric: true},
+		now:     func() time.Time { return now },
+		denials: newDenialThrottle(1, 1),
+	}
+
+	denialLogs := func() []*log.Entry {
+		var entries []*log.Entry
+		for _, entry := range hook.AllEntries() {
+			if strings.HasPrefix(entry.Message, "denying request") {
+				entries = append(entries, entry)
+			}
+		}
+		return entries
+	}
+
+	review := podReview(`{"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"cpu": 2}}}]}`)
+	review.Request.Namespace = "denial-throttle"
+
+	denials := testutil.ToFloat64(namespaceDenialsCounter.WithLabelValues("denial-throttle"))
+	suppressed := testutil.ToFloat64(suppressedDenialLogsCounter)
+
+	for i := 0; i < 3; i++ {
+		resp, err := rra.HandleAdmission(context.Background(), review.Request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.False(t, resp.Allowed)
+	}
+
+	// every denial is counted, but only the first one is logged
+	assert.Equal(t, denials+3, testutil.ToFloat64(namespaceDenialsCounter.WithLabelValues("denial-throttle")))
+	assert.Equal(t, suppressed+2, testutil.ToFloat64(suppressedDenialLogsCounter))
+	assert.Len(t, denialLogs(), 1)
+
+	now = now.Add(time.Second)
+	if _, err := rra.HandleAdmission(context.Background(), review.Request); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := denialLogs()
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, 2, entries[1].Data["suppressed"])
+	}
+}
+
+func TestHandleAdmissionHealthcheckDenialNotLogged(t *testing.T) {
+	hook := test.NewGlobal()
+	defer hook.Reset()
+	defer log.SetLevel(log.GetLevel())
+	log.SetLevel(log.InfoLevel)
+
+	cpu := resource.MustParse("1")
+	rra := New(&MockConfiger{cpu: &cpu}, Options{})
+
+	review := healthcheckReview("default")
+	resp, err := rra.HandleAdmission(context.Background(), review.Request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, resp.Allowed)
+
+	for _, entry := range hook.AllEntries() {
+		assert.False(t, strings.HasPrefix(entry.Message, "denying request"), entry.Message)
+	}
+}
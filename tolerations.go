@@ -0,0 +1,24 @@
+package main
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// exemptTolerationAnnotation is the audit annotation of pods allowed, since they tolerate a taint of ExemptTolerations
+const exemptTolerationAnnotation = "exempt-toleration"
+
+// exemptToleration returns the first taint key of ExemptTolerations, which the pod tolerates.
+// Tolerations without key, which tolerate every taint, don't exempt the pod.
+func (l LimitResource) exemptToleration(podSpec corev1.PodSpec) (string, bool) {
+	for _, toleration := range podSpec.Tolerations {
+		if toleration.Key == "" {
+			continue
+		}
+
+		if contains(l.ExemptTolerations, toleration.Key) {
+			return toleration.Key, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestExemptToleration(t *testing.T) {
+	limit := LimitResource{ExemptTolerations: []string{"dedicated", "gpu"}}
+
+	tests := []struct {
+		name        string
+		tolerations []corev1.Toleration
+		key         string
+		exempt      bool
+	}{
+		{name: "no tolerations"},
+		{name: "other key", tolerations: []corev1.Toleration{{Key: "spot", Operator: corev1.TolerationOpExists}}},
+		{name: "empty key tolerates everything", tolerations: []corev1.Toleration{{Operator: corev1.TolerationOpExists}}},
+		{
+			name: "first exempt key",
+			tolerations: []corev1.Toleration{
+				{Key: "spot", Operator: corev1.TolerationOpExists},
+				{Key: "gpu", Operator: corev1.TolerationOpEqual, Value: "a100", Effect: corev1.TaintEffectNoSchedule},
+				{Key: "dedicated", Operator: corev1.TolerationOpExists},
+			},
+			key:    "gpu",
+			exempt: true,
+		},
+	}
+
+	for _, tt := range tests {
+		key, exempt := limit.exemptToleration(corev1.PodSpec{Tolerations: tt.tolerations})
+		assert.Equal(t, tt.exempt, exempt, tt.name)
+		assert.Equal(t, tt.key, key, tt.name)
+	}
+
+	_, exempt := LimitResource{}.exemptToleration(corev1.PodSpec{Tolerations: []corev1.Toleration{{Key: "dedicated"}}})
+	assert.False(t, exempt)
+}
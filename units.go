@@ -0,0 +1,127 @@
+package main
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// unitsAuto displays quantities as they are written, e.g. in the config file
+	unitsAuto = "auto"
+
+	cpuUnitsCores      = "cores"
+	cpuUnitsMillicores = "millicores"
+
+	memoryUnitsBinary  = "binary"
+	memoryUnitsDecimal = "decimal"
+)
+
+var (
+	// cpuUnits and memoryUnits are display units of quantities in messages, set once at startup by SetQuantityUnits
+	cpuUnits    = unitsAuto
+	memoryUnits = unitsAuto
+
+	binaryUnits  = []unit{{"Pi", 1 << 50}, {"Ti", 1 << 40}, {"Gi", 1 << 30}, {"Mi", 1 << 20}, {"Ki", 1 << 10}}
+	decimalUnits = []unit{{"P", 1e15}, {"T", 1e12}, {"G", 1e9}, {"M", 1e6}, {"k", 1e3}}
+)
+
+type unit struct {
+	suffix string
+	size   int64
+}
+
+// SetQuantityUnits sets display units of CPU (auto, cores or millicores) and of memory and storage (auto, binary or decimal)
+func SetQuantityUnits(cpu, memory string) error {
+	switch cpu {
+	case unitsAuto, cpuUnitsCores, cpuUnitsMillicores:
+	default:
+		return errors.Errorf("cpu units must be %s, %s or %s, got: %s", unitsAuto, cpuUnitsCores, cpuUnitsMillicores, cpu)
+	}
+
+	switch memory {
+	case unitsAuto, memoryUnitsBinary, memoryUnitsDecimal:
+	default:
+		return errors.Errorf("memory units must be %s, %s or %s, got: %s", unitsAuto, memoryUnitsBinary, memoryUnitsDecimal, memory)
+	}
+
+	cpuUnits = cpu
+	memoryUnits = memory
+	return nil
+}
+
+// formatQuantity formats quantity of the resource in configured display units, other resources
+// (e.g. GPUs) and quantities in auto units are formatted as written
+func formatQuantity(name corev1.ResourceName, q resource.Quantity) string {
+	switch name {
+	case corev1.ResourceCPU, corev1.ResourceRequestsCPU, corev1.ResourceLimitsCPU:
+		return formatCPU(q)
+	case corev1.ResourceMemory, corev1.ResourceRequestsMemory, corev1.ResourceLimitsMemory,
+		corev1.ResourceEphemeralStorage, corev1.ResourceRequestsEphemeralStorage, corev1.ResourceLimitsEphemeralStorage,
+		corev1.ResourceStorage, corev1.ResourceRequestsStorage:
+		return formatBytes(q)
+	default:
+		return q.String()
+	}
+}
+
+// formatComparison formats q op bound, e.g. 3Gi > 2Gi, in configured display units. If rounding to display units
+// would hide their difference (e.g. 1Gi > 1Gi), both are formatted exactly as written.
+func formatComparison(name corev1.ResourceName, q resource.Quantity, op string, bound resource.Quantity) string {
+	value, boundValue := formatQuantity(name, q), formatQuantity(name, bound)
+	if value == boundValue && q.Cmp(bound) != 0 {
+		value, boundValue = q.String(), bound.String()
+	}
+
+	return value + " " + op + " " + boundValue
+}
+
+// formatCPU formats CPU in cores, e.g. 1.5, or millicores, e.g. 1500m
+func formatCPU(q resource.Quantity) string {
+	switch cpuUnits {
+	case cpuUnitsCores:
+		return strconv.FormatFloat(float64(q.MilliValue())/1000, 'f', -1, 64)
+	case cpuUnitsMillicores:
+		return strconv.FormatInt(q.MilliValue(), 10) + "m"
+	default:
+		return q.String()
+	}
+}
+
+// formatBytes formats bytes in the largest binary (Ki, Mi, Gi...) or decimal (k, M, G...) unit, which isn't bigger than
+// the value. Values, which are not a multiple of the unit, are rounded to 3 decimal places.
+func formatBytes(q resource.Quantity) string {
+	var units []unit
+	switch memoryUnits {
+	case memoryUnitsBinary:
+		units = binaryUnits
+	case memoryUnitsDecimal:
+		units = decimalUnits
+	default:
+		return q.String()
+	}
+
+	value := q.Value()
+	sign := ""
+	if value < 0 {
+		sign = "-"
+		value = -value
+	}
+
+	for _, u := range units {
+		if value < u.size {
+			continue
+		}
+
+		if value%u.size == 0 {
+			return sign + strconv.FormatInt(value/u.size, 10) + u.suffix
+		}
+
+		return sign + strconv.FormatFloat(math.Round(float64(value)/float64(u.size)*1000)/1000, 'f', -1, 64) + u.suffix
+	}
+
+	return sign + strconv.FormatInt(value, 10)
+}
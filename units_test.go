@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestFormatQuantity(t *testing.T) {
+	defer SetQuantityUnits(unitsAuto, unitsAuto)
+
+	tests := []struct {
+		cpuUnits    string
+		memoryUnits string
+		name        corev1.ResourceName
+		quantity    string
+		expected    string
+	}{
+		{cpuUnits: unitsAuto, memoryUnits: unitsAuto, name: corev1.ResourceCPU, quantity: "1500m", expected: "1500m"},
+		{cpuUnits: unitsAuto, memoryUnits: unitsAuto, name: corev1.ResourceMemory, quantity: "2G", expected: "2G"},
+		{cpuUnits: cpuUnitsCores, memoryUnits: unitsAuto, name: corev1.ResourceCPU, quantity: "1500m", expected: "1.5"},
+		{cpuUnits: cpuUnitsCores, memoryUnits: unitsAuto, name: corev1.ResourceLimitsCPU, quantity: "2", expected: "2"},
+		{cpuUnits: cpuUnitsMillicores, memoryUnits: unitsAuto, name: corev1.ResourceCPU, quantity: "1.5", expected: "1500m"},
+		{cpuUnits: unitsAuto, memoryUnits: memoryUnitsBinary, name: corev1.ResourceMemory, quantity: "1536Mi", expected: "1.5Gi"},
+		{cpuUnits: unitsAuto, memoryUnits: memoryUnitsBinary, name: corev1.ResourceMemory, quantity: "2Gi", expected: "2Gi"},
+		{cpuUnits: unitsAuto, memoryUnits: memoryUnitsBinary, name: corev1.ResourceMemory, quantity: "2G", expected: "1.863Gi"},
+		{cpuUnits: unitsAuto, memoryUnits: memoryUnitsBinary, name: corev1.ResourceStorage, quantity: "-1Ki", expected: "-1Ki"},
+		{cpuUnits: unitsAuto, memoryUnits: memoryUnitsBinary, name: corev1.ResourceMemory, quantity: "100", expected: "100"},
+		{cpuUnits: unitsAuto, memoryUnits: memoryUnitsDecimal, name: corev1.ResourceMemory, quantity: "512M", expected: "512M"},
+		{cpuUnits: unitsAuto, memoryUnits: memoryUnitsDecimal, name: corev1.ResourceEphemeralStorage, quantity: "1Gi", expected: "1.074G"},
+		{cpuUnits: unitsAuto, memoryUnits: memoryUnitsDecimal, name: corev1.ResourceRequestsMemory, quantity: "2000k", expected: "2M"},
+		// other resources are formatted as written
+		{cpuUnits: cpuUnitsMillicores, memoryUnits: memoryUnitsDecimal, name: gpuResource, quantity: "2", expected: "2"},
+	}
+
+	for _, tt := range tests {
+		if err := SetQuantityUnits(tt.cpuUnits, tt.memoryUnits); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.expected, formatQuantity(tt.name, resource.MustParse(tt.quantity)), tt.quantity+" "+tt.cpuUnits+" "+tt.memoryUnits)
+	}
+}
+
+func TestFormatComparison(t *testing.T) {
+	defer SetQuantityUnits(unitsAuto, unitsAuto)
+
+	tests := []struct {
+		cpuUnits    string
+		memoryUnits string
+		name        corev1.ResourceName
+		quantity    string
+		bound       string
+		expected    string
+	}{
+		{cpuUnits: unitsAuto, memoryUnits: memoryUnitsBinary, name: corev1.ResourceMemory, quantity: "3Gi", bound: "2Gi", expected: "3Gi > 2Gi"},
+		{cpuUnits: unitsAuto, memoryUnits: memoryUnitsBinary, name: corev1.ResourceMemory, quantity: "2G", bound: "1Gi", expected: "1.863Gi > 1Gi"},
+		// rounding would print 1Gi > 1Gi
+		{cpuUnits: unitsAuto, memoryUnits: memoryUnitsBinary, name: corev1.ResourceMemory, quantity: "1073741825", bound: "1Gi", expected: "1073741825 > 1Gi"},
+		{cpuUnits: unitsAuto, memoryUnits: memoryUnitsDecimal, name: corev1.ResourceStorage, quantity: "1000001k", bound: "1G", expected: "1000001k > 1G"},
+		{cpuUnits: cpuUnitsCores, memoryUnits: unitsAuto, name: corev1.ResourceCPU, quantity: "1500m", bound: "2", expected: "1.5 > 2"},
+	}
+
+	for _, tt := range tests {
+		if err := SetQuantityUnits(tt.cpuUnits, tt.memoryUnits); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.expected, formatComparison(tt.name, resource.MustParse(tt.quantity), ">", resource.MustParse(tt.bound)), tt.quantity+" "+tt.bound)
+	}
+}
+
+func TestSetQuantityUnitsInvalid(t *testing.T) {
+	assert.Error(t, SetQuantityUnits("vcpu", unitsAuto))
+	assert.Error(t, SetQuantityUnits(unitsAuto, "si"))
+	assert.Equal(t, unitsAuto, cpuUnits)
+	assert.Equal(t, unitsAuto, memoryUnits)
+}
+
+func TestHandleAdmissionQuantityUnits(t *testing.T) {
+	defer SetQuantityUnits(unitsAuto, unitsAuto)
+	if err := SetQuantityUnits(cpuUnitsMillicores, memoryUnitsBinary); err != nil {
+		t.Fatal(err)
+	}
+
+	cpu := resource.MustParse("1")
+	mem := resource.MustParse("1G")
+	spec := `{"containers": [{"name": "app", "resources": {"requests": {"cpu": "500m", "memory": "512Mi"}, "limits": {"cpu": "1.5", "memory": "2Gi"}}}]}`
+
+	// denial message and warnings of warn mode use the same units
+	rra := &ResourceRequestsAdmission{conf: &MockConfiger{cpu: &cpu, mem: &mem}}
+	resp, err := rra.HandleAdmission(context.Background(), podReview(spec).Request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, resp.Allowed)
+	assert.Equal(t, "error container app limits.CPU: 1500m > 1000m", resp.Result.Message)
+
+	rra = &ResourceRequestsAdmission{conf: &MockConfiger{cpu: &cpu, mem: &mem, mode: modeWarn}}
+	resp, err = rra.HandleAdmission(context.Background(), podReview(spec).Request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, resp.Allowed)
+	assert.Equal(t, []string{
+		"error container app limits.CPU: 1500m > 1000m",
+		"error container app limits.Memory: 2Gi > 953.674Mi",
+	}, resp.Warnings)
+}
+
+func TestHandleAdmissionQuantityUnitsRounding(t *testing.T) {
+	defer SetQuantityUnits(unitsAuto, unitsAuto)
+	if err := SetQuantityUnits(unitsAuto, memoryUnitsBinary); err != nil {
+		t.Fatal(err)
+	}
+
+	cpu := resource.MustParse("1")
+	mem := resource.MustParse("1Gi")
+	spec := `{"containers": [{"name": "app", "resources": {"requests": {"cpu": "500m", "memory": "512Mi"}, "limits": {"cpu": "1", "memory": "1073742k"}}}]}`
+
+	rra := &ResourceRequestsAdmission{conf: &MockConfiger{cpu: &cpu, mem: &mem}}
+	resp, err := rra.HandleAdmission(context.Background(), podReview(spec).Request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, resp.Allowed)
+	assert.Equal(t, "error container app limits.Memory: 1073742k > 1Gi", resp.Result.Message)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// usageTracker is a best-effort in-memory tally of resources admitted per namespace.
+// Webhook doesn't see deletions, so tally only grows and is reset on restart.
+type usageTracker struct {
+	usage map[string]map[string]resource.Quantity
+	m     sync.Mutex
+}
+
+func newUsageTracker() *usageTracker {
+	return &usageTracker{
+		usage: make(map[string]map[string]resource.Quantity),
+	}
+}
+
+// reserve adds q of object name to namespace tally, if new total doesn't exceed max.
+// Objects which are already tracked (e.g. on update) are replaced, not added twice.
+// If dryRun is true, tally is not modified.
+func (ut *usageTracker) reserve(namespace, name string, q resource.Quantity, max resource.Quantity, dryRun bool) (total resource.Quantity, ok bool) {
+	ut.m.Lock()
+	defer ut.m.Unlock()
+
+	objects := ut.usage[namespace]
+	for objName, objQ := range objects {
+		if objName != name {
+			total.Add(objQ)
+		}
+	}
+	total.Add(q)
+
+	if total.Cmp(max) > 0 {
+		return total, false
+	}
+
+	if dryRun {
+		return total, true
+	}
+
+	if objects == nil {
+		objects = make(map[string]resource.Quantity)
+		ut.usage[namespace] = objects
+	}
+	objects[name] = q.DeepCopy()
+
+	return total, true
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	authenticationv1 "k8s.io/api/authentication/v1"
+)
+
+const (
+	// exemptUserAnnotation is the audit annotation of requests allowed, since their user matches ExemptUsers
+	exemptUserAnnotation = "exempt-user"
+	// exemptGroupAnnotation is the audit annotation of requests allowed, since their user is in a group of ExemptGroups
+	exemptGroupAnnotation = "exempt-group"
+)
+
+// exemptUser returns audit annotation of the requesting user, if the username matches ExemptUsers
+// or the user is in any of ExemptGroups
+func (l LimitResource) exemptUser(userInfo authenticationv1.UserInfo) (map[string]string, bool) {
+	for _, re := range l.ExemptUsers {
+		if re.MatchString(userInfo.Username) {
+			return map[string]string{exemptUserAnnotation: userInfo.Username}, true
+		}
+	}
+
+	for _, group := range userInfo.Groups {
+		if contains(l.ExemptGroups, group) {
+			return map[string]string{exemptGroupAnnotation: group}, true
+		}
+	}
+
+	return nil, false
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	authenticationv1 "k8s.io/api/authentication/v1"
+)
+
+func TestExemptUser(t *testing.T) {
+	limit := LimitResource{
+		ExemptUsers:  []*regexp.Regexp{regexp.MustCompile(`^(?:system:serviceaccount:kube-system:.*)$`), regexp.MustCompile(`^(?:admin)$`)},
+		ExemptGroups: []string{"platform:admins"},
+	}
+
+	tests := []struct {
+		name        string
+		userInfo    authenticationv1.UserInfo
+		annotations map[string]string
+		exempt      bool
+	}{
+		{
+			name:        "glob matches service account",
+			userInfo:    authenticationv1.UserInfo{Username: "system:serviceaccount:kube-system:replicaset-controller"},
+			annotations: map[string]string{exemptUserAnnotation: "system:serviceaccount:kube-system:replicaset-controller"},
+			exempt:      true,
+		},
+		{
+			name:     "glob doesn't match other namespace",
+			userInfo: authenticationv1.UserInfo{Username: "system:serviceaccount:team-a:kube-system:deployer"},
+		},
+		{
+			name:        "exact username",
+			userInfo:    authenticationv1.UserInfo{Username: "admin"},
+			annotations: map[string]string{exemptUserAnnotation: "admin"},
+			exempt:      true,
+		},
+		{
+			name:     "username prefix",
+			userInfo: authenticationv1.UserInfo{Username: "administrator"},
+		},
+		{
+			name:        "group",
+			userInfo:    authenticationv1.UserInfo{Username: "jane", Groups: []string{"system:authenticated", "platform:admins"}},
+			annotations: map[string]string{exemptGroupAnnotation: "platform:admins"},
+			exempt:      true,
+		},
+		{
+			name:     "other groups",
+			userInfo: authenticationv1.UserInfo{Username: "jane", Groups: []string{"system:authenticated"}},
+		},
+	}
+
+	for _, tt := range tests {
+		annotations, ok := limit.exemptUser(tt.userInfo)
+		assert.Equal(t, tt.exempt, ok, tt.name)
+		assert.Equal(t, tt.annotations, annotations, tt.name)
+	}
+
+	_, ok := LimitResource{}.exemptUser(authenticationv1.UserInfo{Username: "admin", Groups: []string{"platform:admins"}})
+	assert.False(t, ok)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Validate checks the whole config before it's converted, so that a malformed config is rejected as a whole:
+// quantities must parse, unlimited limits must not declare pod ceilings and customNames must name an object
+// in a namespace, so that they don't shadow customNamespaces.
+func (config *Config) Validate() error {
+	if err := config.defaultLimit().validate(); err != nil {
+		return errors.Wrap(err, "top level")
+	}
+
+	for ns, limit := range config.Namespaces {
+		if err := limit.validate(); err != nil {
+			return errors.Wrapf(err, "customNamespaces %s", ns)
+		}
+	}
+
+	for nn, limit := range config.Names {
+		if err := limit.validate(); err != nil {
+			return errors.Wrapf(err, "customNames %s", nn)
+		}
+
+		// entry without name would apply to namespace level lookups, e.g. maxNamespaceGPUs, instead of customNamespaces
+		if nn.Name == "" && nn.NameRegex == "" {
+			return errors.Errorf("customNames %s: name or nameRegex must be set", nn)
+		}
+
+		if nn.Namespace == "" {
+			return errors.Errorf("customNames %s: namespace must not be empty", nn)
+		}
+	}
+
+	for i, selector := range config.NamespaceSelectors {
+		if err := selector.Limit.validate(); err != nil {
+			return errors.Wrapf(err, "namespaceSelectors[%d]", i)
+		}
+	}
+
+	for i, group := range config.Groups {
+		if err := group.Limit.validate(); err != nil {
+			return errors.Wrapf(err, "customGroups[%d]", i)
+		}
+	}
+
+	for i, label := range config.Labels {
+		if err := label.Limit.validate(); err != nil {
+			return errors.Wrapf(err, "customLabels[%d]", i)
+		}
+	}
+
+	for i, policy := range config.Policies {
+		if err := policy.Limit.validate(); err != nil {
+			return errors.Wrapf(err, "policies[%d]", i)
+		}
+	}
+
+	return nil
+}
+
+// validate checks quantities of the limit and its nested limits and that unlimited doesn't declare pod ceilings
+func (l Limit) validate() error {
+	type quantity struct {
+		key   string
+		value string
+		// pvc limits are independent from unlimited
+		pvc bool
+	}
+	quantities := []quantity{
+		{key: "maxCPULimit", value: l.CPULimit},
+		{key: "maxMemLimit", value: l.MemLimit},
+		{key: "maxCPURequest", value: l.CPURequest},
+		{key: "maxMemRequest", value: l.MemRequest},
+		{key: "maxEphemeralStorageLimit", value: l.EphemeralStorageLimit},
+		{key: "maxPodMemLimit", value: l.PodMemLimit},
+		{key: "maxGuaranteedCPU", value: l.GuaranteedCPU},
+		{key: "maxGuaranteedMem", value: l.GuaranteedMem},
+		{key: "minCPURequest", value: l.MinCPURequest},
+		{key: "minMemRequest", value: l.MinMemRequest},
+		{key: "maxCPUBurst", value: l.CPUBurst},
+		{key: "maxMemBurst", value: l.MemBurst},
+		{key: "maxPVCSize", value: l.PVCSize, pvc: true},
+		{key: "minPVCSize", value: l.MinPVCSize, pvc: true},
+		{key: "maxNamespacePVCSize", value: l.NamespacePVCSize, pvc: true},
+		{key: "maxNamespaceGPUs", value: l.NamespaceGPUs},
+	}
+	for name, value := range l.ExtendedResources {
+		quantities = append(quantities, quantity{key: "maxExtendedResources " + name, value: value})
+	}
+
+	for _, q := range quantities {
+		if q.value == "" {
+			continue
+		}
+
+		if _, err := resource.ParseQuantity(q.value); err != nil {
+			return errors.Wrapf(err, "%s: %s", q.key, q.value)
+		}
+
+		if l.Unlimited && !q.pvc {
+			return errors.Errorf("unlimited must not be combined with %s", q.key)
+		}
+	}
+
+	if l.Sidecar != nil {
+		if err := l.Sidecar.validate(); err != nil {
+			return errors.Wrap(err, "sidecar")
+		}
+	}
+
+	for policy, limit := range l.RestartPolicies {
+		if err := limit.validate(); err != nil {
+			return errors.Wrapf(err, "restartPolicies %s", policy)
+		}
+	}
+
+	for class, limit := range l.WorkloadClasses {
+		if err := limit.validate(); err != nil {
+			return errors.Wrapf(err, "workloadClasses %s", class)
+		}
+	}
+
+	for i, ll := range l.LabelLimits {
+		if err := ll.Limit.validate(); err != nil {
+			return errors.Wrapf(err, "labelLimits[%d]", i)
+		}
+	}
+
+	if l.UserNamespace != nil {
+		if err := l.UserNamespace.validate(); err != nil {
+			return errors.Wrap(err, "userNamespace")
+		}
+	}
+
+	for name, limit := range l.Containers {
+		if err := limit.validate(); err != nil {
+			return errors.Wrapf(err, "containers %s", name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		config string
+		err    string
+	}{
+		{config: "maxCPULimit: 2\ncustomNamespaces: {web: {maxCPULimit: 1, maxPVCSize: 10Gi}}"},
+		{config: "customNamespaces: {sandbox: {unlimited: true, maxPVCSize: 10Gi}}"},
+		{config: "customNames: {{nameRegex: web-.*, namespace: web}: {maxCPULimit: 1}}"},
+		{
+			config: "maxCPULimit: notaquantity",
+			err:    "top level: maxCPULimit: notaquantity: ",
+		},
+		{
+			config: "customNamespaces: {web: {sidecar: {maxMemLimit: lots}}}",
+			err:    "customNamespaces web: sidecar: maxMemLimit: lots: ",
+		},
+		{
+			config: "customNamespaces: {sandbox: {unlimited: true, maxCPULimit: 1}}",
+			err:    "customNamespaces sandbox: unlimited must not be combined with maxCPULimit",
+		},
+		{
+			config: "customNamespaces: {web: {workloadClasses: {job: {unlimited: true, maxExtendedResources: {nvidia.com/gpu: 1}}}}}",
+			err:    "customNamespaces web: workloadClasses job: unlimited must not be combined with maxExtendedResources nvidia.com/gpu",
+		},
+		{
+			config: "customNames: {{name: web}: {maxCPULimit: 1}}",
+			err:    "customNames Name: web, : namespace must not be empty",
+		},
+		{
+			config: "customNames: {{namespace: web}: {maxCPULimit: 1}}",
+			err:    "customNames Name: , web: name or nameRegex must be set",
+		},
+		{
+			config: "customGroups: [{group: admins, limit: {unlimited: true, maxMemLimit: 1Gi}}]",
+			err:    "customGroups[0]: unlimited must not be combined with maxMemLimit",
+		},
+	}
+
+	for _, tt := range tests {
+		var config Config
+		if err := yaml.Unmarshal([]byte(tt.config), &config); err != nil {
+			t.Fatal(err)
+		}
+
+		err := config.Validate()
+		if tt.err == "" {
+			assert.NoError(t, err, tt.config)
+			continue
+		}
+		if assert.Error(t, err, tt.config) {
+			assert.True(t, strings.HasPrefix(err.Error(), tt.err), "%s: %v", tt.config, err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strings"
+
+	"k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// containerVerdictsAnnotation is the audit annotation with verdicts of pod containers, e.g. "app=fail:limit_exceeded, proxy=pass"
+const containerVerdictsAnnotation = "container-verdicts"
+
+// containerVerdictsHeader serves containerVerdictsAnnotation, so that e2e tests can assert it without parsing messages
+const containerVerdictsHeader = "X-Container-Verdicts"
+
+const (
+	verdictPass = "pass"
+	verdictWarn = "warn"
+	verdictFail = "fail"
+)
+
+// inContainer attributes violations to the container
+func inContainer(name string, violations []violation) []violation {
+	for i := range violations {
+		violations[i].container = name
+	}
+
+	return violations
+}
+
+// containerVerdicts returns verdict of every init, regular and ephemeral container in spec order.
+// Container fails if any of its violations denies, the reason is of the first such violation.
+func containerVerdicts(podSpec corev1.PodSpec, violations []violation, warned func(violation) bool) string {
+	var names []string
+	for _, container := range podSpec.InitContainers {
+		names = append(names, container.Name)
+	}
+	for _, container := range podSpec.Containers {
+		names = append(names, container.Name)
+	}
+	for _, container := range podSpec.EphemeralContainers {
+		names = append(names, container.Name)
+	}
+
+	verdicts := make([]string, 0, len(names))
+	for _, name := range names {
+		verdict, reason := verdictPass, denialReason("")
+		for _, v := range violations {
+			if v.container != name {
+				continue
+			}
+
+			switch {
+			case !warned(v) && verdict != verdictFail:
+				verdict, reason = verdictFail, v.reason
+			case warned(v) && verdict == verdictPass:
+				verdict, reason = verdictWarn, v.reason
+			}
+		}
+
+		if verdict == verdictPass {
+			verdicts = append(verdicts, name+"="+verdict)
+			continue
+		}
+		verdicts = append(verdicts, name+"="+verdict+":"+string(reason))
+	}
+
+	return strings.Join(verdicts, ", ")
+}
+
+// withVerdicts adds container verdicts to audit annotations of the response, if they are enabled
+func withVerdicts(resp *v1beta1.AdmissionResponse, verdicts string) *v1beta1.AdmissionResponse {
+	if verdicts == "" {
+		return resp
+	}
+
+	if resp.AuditAnnotations == nil {
+		resp.AuditAnnotations = make(map[string]string)
+	}
+	resp.AuditAnnotations[containerVerdictsAnnotation] = verdicts
+
+	return resp
+}
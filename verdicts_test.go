@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestContainerVerdicts(t *testing.T) {
+	podSpec := corev1.PodSpec{
+		InitContainers:      []corev1.Container{{Name: "migrate"}},
+		Containers:          []corev1.Container{{Name: "app"}, {Name: "proxy"}},
+		EphemeralContainers: []corev1.EphemeralContainer{{EphemeralContainerCommon: corev1.EphemeralContainerCommon{Name: "debug"}}},
+	}
+	violations := []violation{
+		{container: "app", resource: corev1.ResourceMemory, reason: reasonRequestExceeded},
+		{container: "app", resource: corev1.ResourceCPU, reason: reasonLimitExceeded},
+		{container: "proxy", resource: corev1.ResourceCPU, reason: reasonBurstExceeded},
+		// pod level violations don't change container verdicts
+		{resource: corev1.ResourceMemory, reason: reasonPodMemoryExceeded},
+	}
+	warned := func(v violation) bool {
+		return v.resource == corev1.ResourceMemory
+	}
+
+	assert.Equal(t, "migrate=pass, app=fail:limit_exceeded, proxy=fail:burst_exceeded, debug=pass", containerVerdicts(podSpec, violations, warned))
+
+	violations[1].resource = corev1.ResourceMemory
+	violations[2].resource = corev1.ResourceMemory
+	assert.Equal(t, "migrate=pass, app=warn:request_exceeded, proxy=warn:burst_exceeded, debug=pass", containerVerdicts(podSpec, violations, warned))
+}
+
+func TestServeContainerVerdicts(t *testing.T) {
+	cpu := resource.MustParse("1")
+	mem := resource.MustParse("1Gi")
+	conf := &MockConfiger{
+		cpu:      &cpu,
+		mem:      &mem,
+		severity: map[corev1.ResourceName]string{corev1.ResourceMemory: severityWarn},
+	}
+
+	spec := `{
+		"initContainers": [{"name": "migrate", "resources": {"requests": {"cpu": 0, "memory": 0}}}],
+		"containers": [
+			{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"cpu": "2"}}},
+			{"name": "cache", "resources": {"requests": {"cpu": 0, "memory": 0}, "limits": {"memory": "2Gi"}}}
+		]
+	}`
+
+	tests := []struct {
+		name     string
+		enabled  bool
+		spec     string
+		verdicts string
+	}{
+		{
+			name:     "denied",
+			enabled:  true,
+			spec:     spec,
+			verdicts: "migrate=pass, app=fail:limit_exceeded, cache=warn:limit_exceeded",
+		},
+		{
+			name:     "allowed",
+			enabled:  true,
+			spec:     `{"containers": [{"name": "app", "resources": {"requests": {"cpu": 0, "memory": 0}}}]}`,
+			verdicts: "app=pass",
+		},
+		{
+			name: "disabled",
+			spec: spec,
+		},
+	}
+
+	for _, tt := range tests {
+		server := httptest.NewServer(&AdmissionControllerServer{
+			AdmissionController: &ResourceRequestsAdmission{conf: conf, opts: Options{ContainerVerdicts: tt.enabled}},
+			Decoder:             codecs.UniversalDeserializer(),
+		})
+
+		r, err := http.Post(server.URL, "application/json", strings.NewReader(string(encodeRequest(t, podReview(tt.spec)))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		review := decodeResponse(t, r.Body)
+		server.Close()
+
+		assert.Equal(t, tt.verdicts, r.Header.Get(containerVerdictsHeader), tt.name)
+		assert.Equal(t, tt.verdicts, review.Response.AuditAnnotations[containerVerdictsAnnotation], tt.name)
+	}
+}
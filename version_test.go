@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/common/version"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServeVersion(t *testing.T) {
+	defer func(v, r, b, u, d string) {
+		version.Version, version.Revision, version.Branch, version.BuildUser, version.BuildDate = v, r, b, u, d
+	}(version.Version, version.Revision, version.Branch, version.BuildUser, version.BuildDate)
+
+	version.Version = "1.2.3"
+	version.Revision = "3bd4d3d"
+	version.Branch = "master"
+	version.BuildUser = "ci"
+	version.BuildDate = "20201017-10:00:00"
+
+	server := httptest.NewServer(http.HandlerFunc(ServeVersion))
+	defer server.Close()
+
+	r, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Body.Close()
+
+	assert.Equal(t, http.StatusOK, r.StatusCode)
+	assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+	var info map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&info); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, map[string]string{
+		"version":   "1.2.3",
+		"revision":  "3bd4d3d",
+		"branch":    "master",
+		"buildUser": "ci",
+		"buildDate": "20201017-10:00:00",
+		"goVersion": version.GoVersion,
+	}, info)
+}